@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"micro-savings-app/database"
+	"micro-savings-app/ledger"
+	"micro-savings-app/migrations"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// runCommand runs a one-off maintenance command instead of starting the server
+func runCommand(name string, args []string) {
+	db := database.MongoClient.Database(os.Getenv("DB_NAME"))
+
+	switch name {
+	case "migrate-money":
+		// Optional currency code for the legacy amounts, defaults to NGN
+		currency := models.DefaultCurrency
+		if len(args) > 0 {
+			currency = strings.ToUpper(args[0])
+		}
+		if !models.IsSupportedCurrency(currency) {
+			log.Fatalf("Unsupported currency %q", currency)
+		}
+		if err := migrations.ConvertMoneyFields(context.Background(), db, currency); err != nil {
+			log.Fatalf("Money migration failed: %v", err)
+		}
+		fmt.Println("Money migration completed")
+	case "migrate-ledger":
+		// Journal pre-ledger balances so every balance is backed by history
+		recorded, err := ledger.RecordOpeningBalances(context.Background(), repository.NewMongoStore(db))
+		if err != nil {
+			log.Fatalf("Ledger migration failed: %v", err)
+		}
+		fmt.Printf("Recorded %d opening balance entries\n", recorded)
+	case "migrate":
+		runMigrate(db, args)
+	case "rotate-jwt-key":
+		// Optional algorithm, EdDSA or RS256, defaults to EdDSA
+		algorithm := models.AlgEdDSA
+		if len(args) > 0 {
+			algorithm = args[0]
+		}
+		key, err := services.RotateSigningKey(context.Background(), repository.NewMongoStore(db), algorithm, time.Now())
+		if err != nil {
+			log.Fatalf("Key rotation failed: %v", err)
+		}
+		fmt.Printf("New %s signing key %s starts signing tokens at %s; old keys verify until their tokens expire\n",
+			key.Algorithm, key.ID, key.ActiveFrom.Format(time.RFC3339))
+	default:
+		log.Fatalf("Unknown command %q", name)
+	}
+}
+
+// runMigrate handles `migrate [up | down [steps] | status]`
+func runMigrate(db *mongo.Database, args []string) {
+	ctx := context.Background()
+	action := "up"
+	if len(args) > 0 {
+		action = args[0]
+	}
+
+	switch action {
+	case "up":
+		applied, err := migrations.Up(ctx, db)
+		if err != nil {
+			log.Fatalf("Migration failed after %d step(s): %v", applied, err)
+		}
+		fmt.Printf("Applied %d migration(s)\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				log.Fatalf("Invalid number of steps %q", args[1])
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(ctx, db, steps)
+		if err != nil {
+			log.Fatalf("Revert failed after %d step(s): %v", reverted, err)
+		}
+		fmt.Printf("Reverted %d migration(s)\n", reverted)
+	case "status":
+		statuses, err := migrations.Status(ctx, db)
+		if err != nil {
+			log.Fatalf("Failed to read migration status: %v", err)
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatalf("Unknown migrate action %q, expected up, down or status", action)
+	}
+}
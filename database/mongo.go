@@ -51,6 +51,11 @@ func DisconnectMongoDB() error {
 
 // GetCollection returns a reference to a specific collection
 func GetCollection(collectionName string) *mongo.Collection {
+	return GetDatabase().Collection(collectionName)
+}
+
+// GetDatabase returns a reference to the application database
+func GetDatabase() *mongo.Database {
 	// Load environment variables
 	err := godotenv.Load()
 	if err != nil {
@@ -61,5 +66,5 @@ func GetCollection(collectionName string) *mongo.Collection {
     if dbName == "" {
         panic("DB_NAME is not set in the environment variables")
     }
-    return MongoClient.Database(dbName).Collection(collectionName)
+    return MongoClient.Database(dbName)
 }
@@ -0,0 +1,83 @@
+// Package fraud screens money leaving the platform. Each rule looks at a
+// withdrawal, transfer or pool contribution together with what we know about
+// the user making it and allows it, holds it for a reviewer or blocks it; the engine acts on the
+// most severe answer. Rules are plain values, so the set can be changed
+// without touching the code that moves money.
+package fraud
+
+import (
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// HistoryWindow is how much of the user's transaction history rules are given
+const HistoryWindow = 90 * 24 * time.Hour
+
+// Movement is a withdrawal, transfer or pool contribution about to happen and what rules need to
+// know about the user making it
+type Movement struct {
+	Kind        string // models.HeldWithdrawal, models.HeldTransfer or models.HeldPoolContribution
+	User        *models.User
+	Amount      models.Money
+	RecipientID primitive.ObjectID // set on transfers
+	Session     *models.Session    // the device making the request; nil if not known
+	Sessions    []models.Session   // every session of the user, newest first
+	History     []models.Transaction
+	Now         time.Time
+}
+
+// Rule checks one pattern. It returns models.FraudAllow when the movement
+// doesn't match, and otherwise the action to take and a reason for the
+// reviewer.
+type Rule interface {
+	Name() string
+	Check(movement *Movement) (action string, reason string)
+}
+
+// Engine runs a set of rules
+type Engine struct {
+	rules []Rule
+}
+
+// NewEngine returns an engine running the rules
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Screen runs every rule and returns the most severe action along with the
+// rules that did not allow the movement
+func (e *Engine) Screen(movement *Movement) (string, []models.FraudFlag) {
+	action := models.FraudAllow
+	flags := []models.FraudFlag{}
+	for _, rule := range e.rules {
+		ruleAction, reason := rule.Check(movement)
+		ruleAction = actions[severity(ruleAction)]
+		if ruleAction == models.FraudAllow {
+			continue
+		}
+		flags = append(flags, models.FraudFlag{Rule: rule.Name(), Action: ruleAction, Reason: reason})
+		if severity(ruleAction) > severity(action) {
+			action = ruleAction
+		}
+	}
+	return action, flags
+}
+
+// actions are indexed by severity
+var actions = []string{models.FraudAllow, models.FraudHold, models.FraudBlock}
+
+// severity orders actions; anything unknown is treated as a hold so a
+// misconfigured rule fails safe
+func severity(action string) int {
+	switch action {
+	case models.FraudAllow, "":
+		return 0
+	case models.FraudBlock:
+		return 2
+	default:
+		return 1
+	}
+}
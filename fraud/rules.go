@@ -0,0 +1,149 @@
+package fraud
+
+import (
+	"fmt"
+	"time"
+
+	"micro-savings-app/models"
+)
+
+// DefaultRules is the rule set the app screens with
+func DefaultRules() []Rule {
+	return []Rule{
+		RecentPasswordChange{BlockWithin: time.Hour, HoldWithin: 24 * time.Hour},
+		NewDevice{Within: 72 * time.Hour},
+		UnusualAmount{Multiple: 5, MinHistory: 3},
+		DepositsThenDrain{Within: 48 * time.Hour, MinDeposits: 5, DrainPercent: 90},
+	}
+}
+
+// RecentPasswordChange catches money leaving soon after the password was
+// reset or changed, the usual sign of a taken-over account
+type RecentPasswordChange struct {
+	BlockWithin time.Duration
+	HoldWithin  time.Duration
+}
+
+func (RecentPasswordChange) Name() string { return "recent_password_change" }
+
+func (r RecentPasswordChange) Check(m *Movement) (string, string) {
+	changedAt := m.User.PasswordChangedAt
+	if changedAt == nil {
+		return models.FraudAllow, ""
+	}
+	since := m.Now.Sub(*changedAt)
+	switch {
+	case since < r.BlockWithin:
+		return models.FraudBlock, fmt.Sprintf("password changed %s ago", since.Round(time.Minute))
+	case since < r.HoldWithin:
+		return models.FraudHold, fmt.Sprintf("password changed %s ago", since.Round(time.Minute))
+	}
+	return models.FraudAllow, ""
+}
+
+// NewDevice catches money leaving from a device that first signed in
+// recently, when the user has signed in from other devices before it
+type NewDevice struct {
+	Within time.Duration
+}
+
+func (NewDevice) Name() string { return "new_device" }
+
+func (r NewDevice) Check(m *Movement) (string, string) {
+	if m.Session == nil || m.Session.UserAgent == "" || m.Now.Sub(m.Session.CreatedAt) >= r.Within {
+		return models.FraudAllow, ""
+	}
+
+	olderDevices := 0
+	for _, session := range m.Sessions {
+		if !session.CreatedAt.Before(m.Session.CreatedAt) {
+			continue
+		}
+		// Addresses change as phones move between networks, so only the
+		// user agent identifies the device
+		if session.UserAgent == m.Session.UserAgent {
+			return models.FraudAllow, ""
+		}
+		olderDevices++
+	}
+	if olderDevices == 0 {
+		// The user's first device has nothing to compare against
+		return models.FraudAllow, ""
+	}
+	return models.FraudHold, fmt.Sprintf("device first signed in %s ago (%s from %s)",
+		m.Now.Sub(m.Session.CreatedAt).Round(time.Minute), m.Session.UserAgent, m.Session.IP)
+}
+
+// UnusualAmount catches an amount far above what the user usually moves out
+type UnusualAmount struct {
+	Multiple   int64 // how many times the average counts as unusual
+	MinHistory int   // withdrawals and transfers needed before an average means anything
+}
+
+func (UnusualAmount) Name() string { return "unusual_amount" }
+
+func (r UnusualAmount) Check(m *Movement) (string, string) {
+	var total int64
+	count := 0
+	for _, transaction := range m.History {
+		if isOutgoing(transaction) && transaction.Amount.SameCurrency(m.Amount) {
+			total += transaction.Amount.Amount
+			count++
+		}
+	}
+	if count < r.MinHistory {
+		return models.FraudAllow, ""
+	}
+
+	average := models.NewMoney(total/int64(count), m.Amount.CurrencyCode())
+	if m.Amount.Amount > average.Amount*r.Multiple {
+		return models.FraudHold, fmt.Sprintf("%s %s is more than %d times the average of %s over %d payments",
+			m.Amount.CurrencyCode(), m.Amount, r.Multiple, average, count)
+	}
+	return models.FraudAllow, ""
+}
+
+// DepositsThenDrain catches a burst of deposits followed by taking out
+// nearly everything, a common way of moving stolen money through an account
+type DepositsThenDrain struct {
+	Within       time.Duration
+	MinDeposits  int
+	DrainPercent int64 // share of the savings balance that counts as nearly everything
+}
+
+func (DepositsThenDrain) Name() string { return "deposits_then_drain" }
+
+func (r DepositsThenDrain) Check(m *Movement) (string, string) {
+	balance := m.User.SavingsBalance
+	if !balance.SameCurrency(m.Amount) || !balance.IsPositive() {
+		return models.FraudAllow, ""
+	}
+	if m.Amount.Amount*100 < balance.Amount*r.DrainPercent {
+		return models.FraudAllow, ""
+	}
+
+	deposits := 0
+	since := m.Now.Add(-r.Within)
+	for _, transaction := range m.History {
+		if models.TransactionType(transaction.Type) == models.Deposit && transaction.CreatedAt.After(since) {
+			deposits++
+		}
+	}
+	if deposits < r.MinDeposits {
+		return models.FraudAllow, ""
+	}
+	return models.FraudHold, fmt.Sprintf("%d deposits in the last %s, then %d%% or more of the balance taken out",
+		deposits, r.Within, r.DrainPercent)
+}
+
+// isOutgoing reports whether a transaction took money out of the platform
+// or to another user
+func isOutgoing(transaction models.Transaction) bool {
+	switch models.TransactionType(transaction.Type) {
+	case models.Withdrawal:
+		return true
+	case models.Transfer:
+		return transaction.Direction == models.Debit
+	}
+	return false
+}
@@ -2,140 +2,142 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"time"
 
-	"micro-savings-app/database"
+	"micro-savings-app/ledger"
 	"micro-savings-app/models"
+	"micro-savings-app/repository"
 	"micro-savings-app/services"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
-//var usersCollection = database.GetCollection("users")
+func RegisterAdmin(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name     string `json:"name" binding:"required"`
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+			SecretKey   string `json:"secret_key" binding:"required"` // Admin secret Key
+		}
 
-func RegisterAdmin(c *gin.Context) {
-	var request struct {
-		Name     string `json:"name" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-		SecretKey   string `json:"secret_key" binding:"required"` // Admin secret Key
-	}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+		// Check if email is already registered
+		_, err := store.Users.GetByEmail(context.Background(), request.Email)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered. Kindly contact admin."})
+			return
+		}
 
-	// Check if email is already registered
-	usersCollection := database.GetCollection("users")
-	var existingUser models.User
-	err := usersCollection.FindOne(context.Background(), bson.M{"email": request.Email}).Decode(&existingUser)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered. Kindly contact admin."})
-		return
-	}
+		// verify admin secret key
+		if request.SecretKey != os.Getenv("ADMIN_SECRET") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized!"})
+			return
+		}
 
-	// verify admin secret key
-	if request.SecretKey != os.Getenv("ADMIN_SECRET") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized!"})
-		return
-	}
+		// Hash the password
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-		return
-	}
+		// Create the admin user document
+		admin := models.User{
+			Name:              request.Name,
+			Email:             request.Email,
+			PasswordHash:      string(hashedPassword),
+			SavingsBalance:    models.NewMoney(0, models.DefaultCurrency),
+			InvestmentBalance: models.NewMoney(0, models.DefaultCurrency),
+			GoalsBalance:      models.NewMoney(0, models.DefaultCurrency),
+			Roles:             []string{models.RoleSuperAdmin},
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
 
-	// Create the admin user document
-	admin := models.User{
-		Name:              request.Name,
-		Email:             request.Email,
-		PasswordHash:      string(hashedPassword),
-		SavingsBalance:    0,
-		InvestmentBalance: 0,
-		IsAdmin: 		   true,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
-	}
+		err = store.Users.Create(context.Background(), &admin)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register admin user"})
+			return
+		}
 
-	_, err = usersCollection.InsertOne(context.Background(), admin)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register admin user"})
-		return
+		c.JSON(http.StatusCreated, gin.H{"message": "Admin user registered successfully"})
 	}
-
-	c.JSON(http.StatusCreated, gin.H{"message": "Admin user registered successfully"})
 }
 
-// This is used to make an existing user an admin
-func MakeAdmin(c *gin.Context) {
-	var request struct {
-		UserId   string `json:"user_id" binding:"required"`
-		SecretKey   string `json:"secret_key" binding:"required"`
-	}
+// This is used to make an existing user an admin with full access. Use
+// AdminSetUserRoles to grant narrower roles.
+func MakeAdmin(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			UserId   string `json:"user_id" binding:"required"`
+			SecretKey   string `json:"secret_key" binding:"required"`
+		}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	// Fetch the user from the database
-	user, err := services.GetUserByID(request.UserId)
-	if err != nil || user == nil {
-		c.JSON(http.StatusForbidden, gin.H{"error": "User not found"})
-		c.Abort()
-		return
-	}
+		// Fetch the user from the database
+		user, err := services.GetUserByID(store, request.UserId)
+		if err != nil || user == nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
 
-	// Check if the user is an admin
-	if user.IsAdmin {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Forbidden! user is already an admin."})
-		c.Abort()
-		return
-	}
+		// Check if the user is an admin
+		if user.IsStaff() {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Forbidden! user is already an admin."})
+			c.Abort()
+			return
+		}
 
-	// verify admin secret key
-	if request.SecretKey != os.Getenv("ADMIN_SECRET") {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized!"})
-	}
+		// verify admin secret key
+		if request.SecretKey != os.Getenv("ADMIN_SECRET") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized!"})
+			return
+		}
 
-	// update user to admin
-    var usersCollection = database.GetCollection("users")
-
-	_, err = usersCollection.UpdateOne(context.Background(),
-				bson.M{"_id": user.ID},
-				bson.M{"$set": bson.M{
-					"is_admin": true,
-					"updated_at": time.Now(),
-				}})
-				
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create an admin user"})
-		return
-	}
+		// update user to admin
+		actorID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		_, err = services.SetUserRoles(c.Request.Context(), store, actorID, user.ID, []string{models.RoleSuperAdmin}, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create an admin user"})
+			return
+		}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "Admin user created successfully"})
+		c.JSON(http.StatusCreated, gin.H{"message": "Admin user created successfully"})
+	}
 }
 
 // This is used to remove admin rights from a user
-func RemoveAdmin(c *gin.Context) {
-	var request struct {
-		UserId   string `json:"user_id" binding:"required"`
-	}
+func RemoveAdmin(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			UserId   string `json:"user_id" binding:"required"`
+		}
 
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		
+
 		// Fetch the user from the database
-		user, err := services.GetUserByID(request.UserId)
+		user, err := services.GetUserByID(store, request.UserId)
 		if err != nil || user == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "User not found"})
 			c.Abort()
@@ -143,60 +145,63 @@ func RemoveAdmin(c *gin.Context) {
 		}
 
 		// Check if the user is not an admin
-		if !user.IsAdmin {
+		if !user.IsStaff() {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "user is not an admin."})
 			c.Abort()
-			return		
+			return
 		}
 
-		// update. remove user from being an admin
-        usersCollection := database.GetCollection("users")
+		// update. remove every staff role from the user
+		actorID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		_, err = services.SetUserRoles(c.Request.Context(), store, actorID, user.ID, nil, time.Now())
+		if errors.Is(err, services.ErrOwnRoles) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove a user from being an admin"})
+			return
+		}
 
-	_, err = usersCollection.UpdateOne(context.Background(),
-				bson.M{"_id": user.ID},
-				bson.M{"$set": bson.M{"is_admin": false}})
-				
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove a user from being an admin"})
-		return
+		c.JSON(http.StatusCreated, gin.H{"message": "User removed from an admin successfully"})
 	}
-
-	c.JSON(http.StatusCreated, gin.H{"message": "User removed from an admin successfully"})
 }
 
 // This function handles admin dashboard statistics
-func AdminDashboard(c *gin.Context) {
-	// fetch stats from the database
-	usersCollection := database.GetCollection("users")
-	totalUsers, err := usersCollection.CountDocuments(context.Background(), bson.M{"is_admin": false})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total users"})
-		return
-	}
+func AdminDashboard(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// fetch stats from the database
+		totalUsers, err := store.Users.CountNonAdmins(context.Background())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total users"})
+			return
+		}
 
-	transactionsCollection := database.GetCollection("transactions")
-	totalDeposits, err := transactionsCollection.CountDocuments(context.Background(), bson.M{"type": "deposit"})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total deposits"})
-		return
-	}
+		totalDeposits, err := store.Transactions.CountByType(context.Background(), models.Deposit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total deposits"})
+			return
+		}
 
-	totalWithdrawals, err := transactionsCollection.CountDocuments(context.Background(), bson.M{"type": "withdrawal"})
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total withdrawals"})
-		return
-	}
+		totalWithdrawals, err := store.Transactions.CountByType(context.Background(), models.Withdrawal)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch total withdrawals"})
+			return
+		}
 
-	// return the stats
-	c.JSON(http.StatusOK, gin.H{
-		"total_users":       totalUsers,
-		"total_deposits":    totalDeposits,
-		"total_withdrawals": totalWithdrawals,
-	})
+		// return the stats
+		c.JSON(http.StatusOK, gin.H{
+			"total_users":       totalUsers,
+			"total_deposits":    totalDeposits,
+			"total_withdrawals": totalWithdrawals,
+		})
+	}
 }
 
 // This is admin get user by ID
-func AdminGetUserByID() gin.HandlerFunc {
+func AdminGetUserByID(store *repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.Query("user_id") // fetch the user id from the url (query param)
 		if userId == "" {
@@ -205,7 +210,7 @@ func AdminGetUserByID() gin.HandlerFunc {
 			return
 		}
 
-		user, err := services.GetUserByID(userId)
+		user, err := services.GetUserByID(store, userId)
 		if err != nil || user == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden! User not found"})
 			c.Abort()
@@ -213,6 +218,102 @@ func AdminGetUserByID() gin.HandlerFunc {
 		}
 		
 		// Return the user details
-		c.JSON(http.StatusOK, user)
+		c.JSON(http.StatusOK, user.Details())
+	}
+}
+
+// This checks a user's cached balances against the ledger history
+func AdminReconcileUser(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := services.GetUserByID(store, c.Param("user_id"))
+		if err != nil || user == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		discrepancies, err := ledger.Reconcile(c.Request.Context(), store, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reconcile balances"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user_id":       user.ID.Hex(),
+			"balanced":      len(discrepancies) == 0,
+			"discrepancies": discrepancies,
+		})
+	}
+}
+
+// AdminGetRoles lists the staff roles and the permissions each grants
+func AdminGetRoles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"roles": models.RolePermissions})
+	}
+}
+
+// AdminSetUserRoles replaces a user's staff roles. An empty list makes them a
+// customer again. They are logged out everywhere so their next login carries
+// the new roles.
+func AdminSetUserRoles(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Roles []string `json:"roles" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		actorID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		roles, err := services.SetUserRoles(c.Request.Context(), store, actorID, userObjectID, request.Roles, time.Now())
+		switch {
+		case errors.Is(err, services.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrOwnRoles):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update roles"})
+		default:
+			c.JSON(http.StatusOK, gin.H{
+				"user_id":     userObjectID.Hex(),
+				"roles":       roles,
+				"permissions": models.PermissionsFor(roles),
+			})
+		}
+	}
+}
+
+// AdminUnlockUser clears a user's failed logins so they can sign in again
+// straight away
+func AdminUnlockUser(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		err = services.UnlockAccount(c.Request.Context(), store, userObjectID)
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock user"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "User unlocked", "user_id": userObjectID.Hex()})
+		}
 	}
 }
\ No newline at end of file
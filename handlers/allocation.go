@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetAllocationPolicy returns the allocation policy that applies to the
+// authenticated user and whether it is their own or the global one
+func GetAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		user, err := services.GetUserByID(store, userObjectID.Hex())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		policy, source, err := services.EffectiveAllocationPolicy(c.Request.Context(), store, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allocation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"policy": policy, "source": source})
+	}
+}
+
+// UpdateAllocationPolicy sets the authenticated user's own allocation policy,
+// e.g. to keep a liquid minimum or opt out of sweeps altogether
+func UpdateAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		setUserAllocationPolicy(c, store, userObjectID)
+	}
+}
+
+// ResetAllocationPolicy puts the authenticated user back on the global policy
+func ResetAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		resetUserAllocationPolicy(c, store, userObjectID)
+	}
+}
+
+// AdminGetAllocationPolicy returns the global allocation policy
+func AdminGetAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy, err := services.GlobalAllocationPolicy(c.Request.Context(), store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch allocation policy"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"policy": policy})
+	}
+}
+
+// AdminUpdateAllocationPolicy replaces the global allocation policy
+func AdminUpdateAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var policy models.AllocationPolicy
+		if err := c.ShouldBindJSON(&policy); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		saved, err := services.SetGlobalAllocationPolicy(c.Request.Context(), store, policy)
+		if errors.Is(err, models.ErrInvalidPolicy) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allocation policy"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Allocation policy updated", "policy": saved})
+	}
+}
+
+// AdminUpdateUserAllocationPolicy sets the allocation policy of a given user
+func AdminUpdateUserAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		setUserAllocationPolicy(c, store, userObjectID)
+	}
+}
+
+// AdminResetUserAllocationPolicy puts a given user back on the global policy
+func AdminResetUserAllocationPolicy(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		resetUserAllocationPolicy(c, store, userObjectID)
+	}
+}
+
+func setUserAllocationPolicy(c *gin.Context, store *repository.Store, userID primitive.ObjectID) {
+	var policy models.AllocationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := services.SetUserAllocationPolicy(c.Request.Context(), store, userID, &policy)
+	switch {
+	case errors.Is(err, models.ErrInvalidPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update allocation policy"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Allocation policy updated"})
+	}
+}
+
+func resetUserAllocationPolicy(c *gin.Context, store *repository.Store, userID primitive.ObjectID) {
+	err := services.SetUserAllocationPolicy(c.Request.Context(), store, userID, nil)
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset allocation policy"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"message": "Allocation policy reset to the global policy"})
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminListReviews returns withdrawals and transfers stopped by fraud
+// screening by status, pending by default, oldest first
+func AdminListReviews(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		reviews, err := services.ListFraudReviews(c.Request.Context(), store, c.DefaultQuery("status", models.ReviewPending))
+		if err != nil {
+			respondFraudReviewError(c, err, "Failed to list reviews")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+	}
+}
+
+// AdminApproveReview sends a held withdrawal or transfer on its way
+func AdminApproveReview(store *repository.Store) gin.HandlerFunc {
+	return decideReview(store, services.ApproveFraudReview, "Payment approved")
+}
+
+// AdminRejectReview cancels a held withdrawal or transfer and returns the
+// money to the user's savings. A note for other reviewers is required.
+func AdminRejectReview(store *repository.Store) gin.HandlerFunc {
+	return decideReview(store, services.RejectFraudReview, "Payment rejected")
+}
+
+type fraudDecision func(ctx context.Context, store *repository.Store, reviewerID, reviewID primitive.ObjectID, note string, now time.Time) (*models.FraudReview, error)
+
+func decideReview(store *repository.Store, decide fraudDecision, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Note string `json:"note" binding:"max=500"`
+		}
+
+		reviewID, err := primitive.ObjectIDFromHex(c.Param("review_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+			return
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		reviewerID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		review, err := decide(c.Request.Context(), store, reviewerID, reviewID, request.Note, time.Now())
+		if err != nil {
+			respondFraudReviewError(c, err, "Failed to review payment")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "review": review})
+	}
+}
+
+// respondFraudReviewError maps errors from the fraud review services to HTTP
+// responses
+func respondFraudReviewError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrFraudReviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Review not found"})
+	case errors.Is(err, services.ErrInvalidFraudReview):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFraudOwnReview):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFraudReviewed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAccountClosed),
+		errors.Is(err, services.ErrKYCLimitExceeded):
+		// The recipient can no longer take the transfer; reject it instead
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
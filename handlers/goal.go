@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// goalRequest is the body for creating or editing a goal. Dates take RFC 3339
+// or YYYY-MM-DD.
+type goalRequest struct {
+	Name         *string       `json:"name" binding:"omitempty,max=80"`
+	TargetAmount *models.Money `json:"target_amount"`
+	Deadline     string        `json:"deadline"`
+	LockedUntil  string        `json:"locked_until"`
+}
+
+func (r goalRequest) changes() (services.GoalChanges, error) {
+	changes := services.GoalChanges{Name: r.Name, TargetAmount: r.TargetAmount}
+	var err error
+	if changes.Deadline, err = optionalDate("deadline", r.Deadline); err != nil {
+		return changes, err
+	}
+	if changes.LockedUntil, err = optionalDate("locked_until", r.LockedUntil); err != nil {
+		return changes, err
+	}
+	return changes, nil
+}
+
+func optionalDate(field, value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := parseDateParam(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: use RFC 3339 or YYYY-MM-DD", field)
+	}
+	return &parsed, nil
+}
+
+// CreateGoal starts a savings goal for the authenticated user
+func CreateGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request goalRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		changes, err := request.changes()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		goal, err := services.CreateGoal(c.Request.Context(), store, userObjectID, changes, now)
+		if err != nil {
+			respondGoalError(c, err, "Failed to create goal")
+			return
+		}
+
+		c.JSON(http.StatusCreated, services.NewGoalProgress(*goal, now))
+	}
+}
+
+// GetGoals lists the authenticated user's goals with their progress
+func GetGoals(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		goals, err := store.Goals.ListByUser(c.Request.Context(), userObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goals"})
+			return
+		}
+
+		now := time.Now()
+		progress := make([]services.GoalProgress, 0, len(goals))
+		for _, goal := range goals {
+			progress = append(progress, services.NewGoalProgress(goal, now))
+		}
+		c.JSON(http.StatusOK, gin.H{"goals": progress})
+	}
+}
+
+// GetGoal returns one of the authenticated user's goals with its progress
+func GetGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, goalID, ok := goalParams(c)
+		if !ok {
+			return
+		}
+
+		goal, err := store.Goals.GetByID(c.Request.Context(), userObjectID, goalID)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch goal"})
+			return
+		}
+
+		c.JSON(http.StatusOK, services.NewGoalProgress(*goal, time.Now()))
+	}
+}
+
+// UpdateGoal edits the name, target, deadline or lock of a goal
+func UpdateGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request goalRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		changes, err := request.changes()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, goalID, ok := goalParams(c)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		goal, err := services.UpdateGoal(c.Request.Context(), store, userObjectID, goalID, changes, now)
+		if err != nil {
+			respondGoalError(c, err, "Failed to update goal")
+			return
+		}
+
+		c.JSON(http.StatusOK, services.NewGoalProgress(*goal, now))
+	}
+}
+
+// DeleteGoal removes an empty goal
+func DeleteGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, goalID, ok := goalParams(c)
+		if !ok {
+			return
+		}
+
+		if err := services.DeleteGoal(c.Request.Context(), store, userObjectID, goalID, time.Now()); err != nil {
+			respondGoalError(c, err, "Failed to delete goal")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Goal deleted"})
+	}
+}
+
+// DepositToGoal pays money into a goal
+func DepositToGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount models.Money `json:"amount" binding:"required,gt=0"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, goalID, ok := goalParams(c)
+		if !ok {
+			return
+		}
+
+		goal, err := services.DepositToGoal(c.Request.Context(), store, userObjectID, goalID, request.Amount)
+		if err != nil {
+			respondGoalError(c, err, "Failed to process goal deposit")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Deposit to goal successful",
+			"amount":  request.Amount,
+			"goal":    services.NewGoalProgress(*goal, time.Now()),
+		})
+	}
+}
+
+// WithdrawFromGoal pays money out of a goal once its lock has passed
+func WithdrawFromGoal(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount models.Money `json:"amount" binding:"required,gt=0"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, goalID, ok := goalParams(c)
+		if !ok {
+			return
+		}
+		if !requireStepUp(c, store, userObjectID, request.Amount, "withdraw", "Failed to process goal withdrawal") {
+			return
+		}
+
+		withdrawal, err := services.WithdrawFromGoal(c.Request.Context(), store, userObjectID, goalID, requestSessionID(c), request.Amount)
+		if err != nil {
+			respondGoalError(c, err, "Failed to process goal withdrawal")
+			return
+		}
+
+		// Fraud screening set the money aside until a reviewer decides
+		if withdrawal.Review != nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":   "Withdrawal from goal is being reviewed",
+				"review_id": withdrawal.Review.ID.Hex(),
+				"amount":    request.Amount,
+				"goal":      services.NewGoalProgress(*withdrawal.Goal, time.Now()),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Withdrawal from goal successful",
+			"amount":  request.Amount,
+			"goal":    services.NewGoalProgress(*withdrawal.Goal, time.Now()),
+		})
+	}
+}
+
+// goalParams reads the authenticated user and the :goal_id route parameter
+func goalParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userObjectID, ok := authenticatedUserID(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	goalID, err := primitive.ObjectIDFromHex(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return userObjectID, goalID, true
+}
+
+// respondGoalError maps errors from the goal services to HTTP responses
+func respondGoalError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidGoal):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrGoalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+	case errors.Is(err, services.ErrGoalLocked):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Goal is locked"})
+	case errors.Is(err, services.ErrGoalHasFunds):
+		c.JSON(http.StatusConflict, gin.H{"error": "Withdraw the goal's savings before deleting it"})
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency does not match the goal"})
+	default:
+		respondBalanceError(c, err, fallback)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AdminGetInterestTiers returns the interest tiers investment balances earn at
+func AdminGetInterestTiers(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		byName, err := services.InterestTiers(c.Request.Context(), store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch interest tiers"})
+			return
+		}
+
+		tiers := make([]models.InterestTier, 0, len(byName))
+		for _, tier := range byName {
+			tiers = append(tiers, tier)
+		}
+		sort.Slice(tiers, func(i, j int) bool { return tiers[i].Name < tiers[j].Name })
+
+		c.JSON(http.StatusOK, gin.H{"tiers": tiers})
+	}
+}
+
+// AdminUpdateInterestTiers replaces the full set of interest tiers
+func AdminUpdateInterestTiers(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Tiers []models.InterestTier `json:"tiers" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := services.SetInterestTiers(c.Request.Context(), store, request.Tiers)
+		if errors.Is(err, models.ErrInvalidTier) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update interest tiers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Interest tiers updated", "tiers": request.Tiers})
+	}
+}
+
+// AdminSetProductTier moves a user onto another interest tier
+func AdminSetProductTier(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var request struct {
+			Tier string `json:"tier" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = services.SetProductTier(c.Request.Context(), store, userObjectID, request.Tier)
+		switch {
+		case errors.Is(err, models.ErrInvalidTier):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown interest tier"})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update product tier"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Product tier updated"})
+		}
+	}
+}
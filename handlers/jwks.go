@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKS publishes the public keys access tokens are signed with, so other
+// services can verify them without sharing a secret
+func JWKS() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := services.JWKS()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+			return
+		}
+		// Verifiers may cache the keys, but not past a rotation's overlap
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetKYC returns the authenticated user's verification tier, the limits it
+// comes with and what they have submitted
+func GetKYC(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		status, err := services.GetKYC(c.Request.Context(), store, userObjectID)
+		if err != nil {
+			respondKYCError(c, err, "Failed to get verification status")
+			return
+		}
+		c.JSON(http.StatusOK, status)
+	}
+}
+
+// SubmitKYC asks for the authenticated user to be verified to their next
+// tier. Tier 1 takes a BVN or NIN; tiers 2 and 3 take documents.
+func SubmitKYC(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			IdentifierType string               `json:"identifier_type"` // bvn or nin
+			Identifier     string               `json:"identifier"`
+			Documents      []models.KYCDocument `json:"documents"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		submission, err := services.SubmitKYC(c.Request.Context(), store, userObjectID, services.KYCRequest{
+			IdentifierType: request.IdentifierType,
+			Identifier:     request.Identifier,
+			Documents:      request.Documents,
+		}, time.Now())
+		if err != nil {
+			respondKYCError(c, err, "Failed to submit verification")
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Submitted for review", "submission": submission})
+	}
+}
+
+// AdminListKYC returns KYC submissions by status, pending by default, oldest
+// first
+func AdminListKYC(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		submissions, err := services.ListKYCSubmissions(c.Request.Context(), store, c.DefaultQuery("status", models.KYCPending))
+		if err != nil {
+			respondKYCError(c, err, "Failed to list verification submissions")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"submissions": submissions})
+	}
+}
+
+// AdminApproveKYC approves a pending submission and moves the user up to
+// its tier
+func AdminApproveKYC(store *repository.Store) gin.HandlerFunc {
+	return reviewKYC(store, services.ApproveKYC, "Submission approved")
+}
+
+// AdminRejectKYC rejects a pending submission. A note for the user is
+// required.
+func AdminRejectKYC(store *repository.Store) gin.HandlerFunc {
+	return reviewKYC(store, services.RejectKYC, "Submission rejected")
+}
+
+type kycReview func(ctx context.Context, store *repository.Store, reviewerID, submissionID primitive.ObjectID, note string, now time.Time) (*models.KYCSubmission, error)
+
+func reviewKYC(store *repository.Store, review kycReview, message string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Note string `json:"note" binding:"max=500"`
+		}
+
+		submissionID, err := primitive.ObjectIDFromHex(c.Param("submission_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid submission ID"})
+			return
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		reviewerID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		submission, err := review(c.Request.Context(), store, reviewerID, submissionID, request.Note, time.Now())
+		if err != nil {
+			respondKYCError(c, err, "Failed to review submission")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": message, "submission": submission})
+	}
+}
+
+// respondKYCError maps errors from the KYC services to HTTP responses
+func respondKYCError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrKYCNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Submission not found"})
+	case errors.Is(err, services.ErrInvalidKYC):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrKYCOwnSubmission):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrKYCPending),
+		errors.Is(err, services.ErrKYCMaxTier),
+		errors.Is(err, services.ErrKYCReviewed),
+		errors.Is(err, services.ErrAccountClosed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mfaCodeRequest is the body of every request that takes a two-factor code:
+// six digits from the authenticator app, or a recovery code
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// LoginMFA completes a login for a user with two-factor on, swapping the
+// mfa_token from Login and a code for a session
+func LoginMFA(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			MFAToken string `json:"mfa_token" binding:"required"`
+			Code     string `json:"code" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tokens, err := services.CompleteMFALogin(c.Request.Context(), store, request.MFAToken, request.Code,
+			c.Request.UserAgent(), c.ClientIP(), time.Now())
+		var blocked *services.LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			respondMFABlocked(c, blocked)
+		case errors.Is(err, services.ErrInvalidToken):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired; please log in again"})
+		case errors.Is(err, services.ErrInvalidMFACode), errors.Is(err, services.ErrMFANotEnabled):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code; please log in again"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		default:
+			c.JSON(http.StatusOK, gin.H{
+				"status":        "success",
+				"message":       "Login successful",
+				"token":         tokens.AccessToken,
+				"refresh_token": tokens.RefreshToken,
+				"expires_in":    tokens.ExpiresIn,
+			})
+		}
+	}
+}
+
+// SetupMFA starts two-factor enrolment and returns the secret to add to an
+// authenticator app
+func SetupMFA(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		setup, err := services.SetupMFA(c.Request.Context(), store, userObjectID)
+		if err != nil {
+			respondMFAError(c, err, "Failed to set up two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, setup)
+	}
+}
+
+// EnableMFA turns two-factor on with a code from the app and returns the
+// recovery codes
+func EnableMFA(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request mfaCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		codes, err := services.EnableMFA(c.Request.Context(), store, userObjectID, request.Code, time.Now())
+		if err != nil {
+			respondMFAError(c, err, "Failed to turn on two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication is on. Keep these recovery codes somewhere safe; they won't be shown again.",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+// DisableMFA turns two-factor off
+func DisableMFA(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request mfaCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		if err := services.DisableMFA(c.Request.Context(), store, userObjectID, request.Code, time.Now()); err != nil {
+			respondMFAError(c, err, "Failed to turn off two-factor authentication")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication is off"})
+	}
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes
+func RegenerateRecoveryCodes(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request mfaCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		codes, err := services.RegenerateRecoveryCodes(c.Request.Context(), store, userObjectID, request.Code, time.Now())
+		if err != nil {
+			respondMFAError(c, err, "Failed to create recovery codes")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// VerifyMFA takes a fresh code for the current session, which is needed
+// for large withdrawals and admin requests
+func VerifyMFA(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request mfaCodeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		sessionID, err := primitive.ObjectIDFromHex(c.GetString("session_id"))
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized! Session has ended"})
+			return
+		}
+
+		if err := services.StepUp(c.Request.Context(), store, userObjectID, sessionID, request.Code, time.Now()); err != nil {
+			respondMFAError(c, err, "Failed to verify code")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":    "Code accepted",
+			"expires_in": int64(models.StepUpTTL.Seconds()),
+		})
+	}
+}
+
+// AdminGetStepUpThreshold returns the withdrawal amount above which users
+// with two-factor on must enter a fresh code
+func AdminGetStepUpThreshold(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		threshold, err := services.StepUpThreshold(c.Request.Context(), store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch step-up threshold"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"threshold": threshold})
+	}
+}
+
+// AdminUpdateStepUpThreshold changes the withdrawal step-up threshold
+func AdminUpdateStepUpThreshold(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Threshold models.Money `json:"threshold" binding:"required"`
+		}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := services.SetStepUpThreshold(c.Request.Context(), store, request.Threshold)
+		if errors.Is(err, services.ErrInvalidStepUp) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update step-up threshold"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Step-up threshold updated", "threshold": request.Threshold})
+	}
+}
+
+// respondMFAError maps errors from the two-factor services to HTTP responses
+func respondMFAError(c *gin.Context, err error, fallback string) {
+	var blocked *services.LoginBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		respondMFABlocked(c, blocked)
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	case errors.Is(err, services.ErrMFAAlreadyEnabled),
+		errors.Is(err, services.ErrMFANotEnabled),
+		errors.Is(err, services.ErrMFANotSetUp):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// respondMFABlocked is a 429 with Retry-After while too many wrong codes
+// have been entered
+func respondMFABlocked(c *gin.Context, blocked *services.LoginBlockedError) {
+	retryAfter := int64(math.Ceil(time.Until(blocked.RetryAt).Seconds()))
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Too many wrong two-factor codes; try again later",
+		"retry_after": retryAfter,
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreatePool starts a savings pool with the authenticated user as its
+// creator and first member
+func CreatePool(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name               string        `json:"name" binding:"required"`
+			ContributionAmount *models.Money `json:"contribution_amount" binding:"required"`
+			Cycle              string        `json:"cycle" binding:"required"`
+			MaxMembers         int           `json:"max_members" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		pool, err := services.CreatePool(c.Request.Context(), store, userObjectID, services.PoolSettings{
+			Name:               request.Name,
+			ContributionAmount: *request.ContributionAmount,
+			Cycle:              request.Cycle,
+			MaxMembers:         request.MaxMembers,
+		}, time.Now())
+		if err != nil {
+			respondPoolError(c, err, "Failed to create pool")
+			return
+		}
+
+		c.JSON(http.StatusCreated, pool)
+	}
+}
+
+// GetPools lists the pools the authenticated user belongs to
+func GetPools(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		pools, err := store.Pools.ListByMember(c.Request.Context(), userObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pools"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pools": pools})
+	}
+}
+
+// GetPool returns one of the authenticated user's pools with its members
+func GetPool(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, poolID, ok := poolParams(c)
+		if !ok {
+			return
+		}
+
+		pool, err := services.GetPool(c.Request.Context(), store, userObjectID, poolID)
+		if err != nil {
+			respondPoolError(c, err, "Failed to fetch pool")
+			return
+		}
+
+		c.JSON(http.StatusOK, pool)
+	}
+}
+
+// JoinPool adds the authenticated user to a pool that is still taking members
+func JoinPool(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, poolID, ok := poolParams(c)
+		if !ok {
+			return
+		}
+
+		pool, err := services.JoinPool(c.Request.Context(), store, userObjectID, poolID, time.Now())
+		if err != nil {
+			respondPoolError(c, err, "Failed to join pool")
+			return
+		}
+
+		c.JSON(http.StatusOK, pool)
+	}
+}
+
+// LeavePool takes the authenticated user out of a pool that has not started
+func LeavePool(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, poolID, ok := poolParams(c)
+		if !ok {
+			return
+		}
+
+		if _, err := services.LeavePool(c.Request.Context(), store, userObjectID, poolID, time.Now()); err != nil {
+			respondPoolError(c, err, "Failed to leave pool")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Left pool"})
+	}
+}
+
+// StartPool closes the pool to new members and fixes the payout rotation.
+// The body is optional: "order" lists member IDs in payout order, and
+// "start_at" (RFC 3339 or YYYY-MM-DD) sets the first cycle, otherwise now.
+func StartPool(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Order   []string `json:"order"`
+			StartAt string   `json:"start_at"`
+		}
+
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&request); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+
+		startAt, err := optionalDate("start_at", request.StartAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		order := make([]primitive.ObjectID, 0, len(request.Order))
+		for _, hex := range request.Order {
+			memberID, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid member ID in order"})
+				return
+			}
+			order = append(order, memberID)
+		}
+
+		userObjectID, poolID, ok := poolParams(c)
+		if !ok {
+			return
+		}
+
+		pool, err := services.StartPool(c.Request.Context(), store, userObjectID, poolID, order, startAt, time.Now())
+		if err != nil {
+			respondPoolError(c, err, "Failed to start pool")
+			return
+		}
+
+		c.JSON(http.StatusOK, pool)
+	}
+}
+
+// poolParams reads the authenticated user and the :pool_id route parameter
+func poolParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userObjectID, ok := authenticatedUserID(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	poolID, err := primitive.ObjectIDFromHex(c.Param("pool_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pool ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return userObjectID, poolID, true
+}
+
+// respondPoolError maps errors from the pool services to HTTP responses
+func respondPoolError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPool):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, models.ErrInvalidAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Contribution amount must be greater than zero"})
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Your savings are not held in the pool's currency"})
+	case errors.Is(err, services.ErrPoolNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pool not found"})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrNotPoolCreator), errors.Is(err, services.ErrKYCLimitExceeded):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPoolFull), errors.Is(err, services.ErrPoolStarted), errors.Is(err, services.ErrAlreadyMember):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProfile changes the authenticated user's name, phone, date of birth
+// or address. Fields left out are not changed.
+func UpdateProfile(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name        *string         `json:"name"`
+			Phone       *string         `json:"phone"`
+			DateOfBirth *string         `json:"date_of_birth"` // YYYY-MM-DD
+			Address     *models.Address `json:"address"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		user, err := services.UpdateProfile(c.Request.Context(), store, userObjectID, services.ProfileChanges{
+			Name:        request.Name,
+			Phone:       request.Phone,
+			DateOfBirth: request.DateOfBirth,
+			Address:     request.Address,
+		}, time.Now())
+		if err != nil {
+			respondProfileError(c, err, "Failed to update profile")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Profile updated", "user": user.Details()})
+	}
+}
+
+// ChangePassword sets a new password for the authenticated user. Every
+// device is signed out and the caller gets new tokens.
+func ChangePassword(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			CurrentPassword string `json:"current_password" binding:"required"`
+			NewPassword     string `json:"new_password" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		tokens, err := services.ChangePassword(c.Request.Context(), store, userObjectID, request.CurrentPassword,
+			request.NewPassword, c.Request.UserAgent(), c.ClientIP(), time.Now())
+		if err != nil {
+			respondProfileError(c, err, "Failed to change password")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "Password changed",
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+		})
+	}
+}
+
+// ChangeEmail emails a confirmation link to the new address
+func ChangeEmail(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		err := services.RequestEmailChange(c.Request.Context(), store, userObjectID, request.Password, request.Email, time.Now())
+		if err != nil {
+			respondProfileError(c, err, "Failed to change email address")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Check your new email address to confirm the change"})
+	}
+}
+
+// ConfirmEmailChange switches a user to their new address using the token
+// emailed to it
+func ConfirmEmailChange(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Token string `json:"token" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := services.ConfirmEmailChange(c.Request.Context(), store, request.Token, time.Now())
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		} else if err != nil {
+			respondProfileError(c, err, "Failed to change email address")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Email address changed"})
+	}
+}
+
+// CloseAccount closes the authenticated user's account. It is refused while
+// they still have money saved.
+func CloseAccount(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Password string `json:"password" binding:"required"`
+			Reason   string `json:"reason" binding:"max=500"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		err := services.CloseAccount(c.Request.Context(), store, userObjectID, request.Password, request.Reason, time.Now())
+		if err != nil {
+			respondProfileError(c, err, "Failed to close account")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Your account has been closed"})
+	}
+}
+
+// respondProfileError maps errors from the profile services to HTTP responses
+func respondProfileError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	case errors.Is(err, services.ErrInvalidProfile),
+		errors.Is(err, services.ErrInvalidEmail),
+		errors.Is(err, services.ErrWeakPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWrongPassword):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrEmailTaken),
+		errors.Is(err, services.ErrBalanceNotZero),
+		errors.Is(err, services.ErrAccountInUse),
+		errors.Is(err, services.ErrPaymentsHeld),
+		errors.Is(err, services.ErrInterestPending),
+		errors.Is(err, services.ErrAccountClosed),
+		errors.Is(err, services.ErrClosureNotNeeded):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RedeemInvestment moves money from the authenticated user's investment
+// balance back to savings. Send an amount for a partial redemption or
+// "full": true for everything; "early": true skips the notice period for
+// a penalty. Settled redemptions answer 200, scheduled ones 202.
+func RedeemInvestment(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount *models.Money `json:"amount"`
+			Full   bool          `json:"full"`
+			Early  bool          `json:"early"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.Full == (request.Amount != nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide either an amount or full: true"})
+			return
+		}
+		var amount models.Money
+		if request.Amount != nil {
+			if !request.Amount.IsPositive() {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than zero"})
+				return
+			}
+			amount = *request.Amount
+		}
+
+		// Get the user ID from JWT claims
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		redemption, err := services.RequestRedemption(c.Request.Context(), store, userObjectID, amount, request.Full, request.Early, time.Now())
+		switch {
+		case errors.Is(err, services.ErrNothingToRedeem):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No investment balance to redeem"})
+			return
+		case errors.Is(err, services.ErrRedemptionPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "Another redemption is already pending"})
+			return
+		case errors.Is(err, services.ErrInsufficientBalance):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient investment balance"})
+			return
+		case err != nil:
+			respondBalanceError(c, err, "Failed to process redemption")
+			return
+		}
+
+		if redemption.Status == models.RedemptionSettled {
+			c.JSON(http.StatusOK, gin.H{"message": "Redemption settled", "redemption": redemption})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"message": "Redemption scheduled", "redemption": redemption})
+	}
+}
+
+// GetRedemptions lists the authenticated user's redemptions, newest first
+func GetRedemptions(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		redemptions, err := store.Redemptions.ListByUser(c.Request.Context(), userObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch redemptions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"redemptions": redemptions})
+	}
+}
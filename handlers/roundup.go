@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CardPurchaseWebhook receives card purchases from the card processor and
+// holds their round-ups for the day's batch. Purchases by users without
+// round-ups, and repeats of an event already received, are acknowledged so
+// the processor stops retrying them.
+func CardPurchaseWebhook(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			EventID     string        `json:"event_id" binding:"required"`
+			UserID      string        `json:"user_id" binding:"required"`
+			Amount      *models.Money `json:"amount" binding:"required"`
+			Merchant    string        `json:"merchant"`
+			PurchasedAt *time.Time    `json:"purchased_at"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userObjectID, err := primitive.ObjectIDFromHex(request.UserID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		now := time.Now()
+		purchase := services.CardPurchase{
+			EventID:     request.EventID,
+			UserID:      userObjectID,
+			Amount:      *request.Amount,
+			Merchant:    request.Merchant,
+			PurchasedAt: now,
+		}
+		if request.PurchasedAt != nil {
+			purchase.PurchasedAt = *request.PurchasedAt
+		}
+
+		event, err := services.RecordPurchase(c.Request.Context(), store, purchase, now)
+		switch {
+		case errors.Is(err, services.ErrDuplicatePurchase):
+			c.JSON(http.StatusOK, gin.H{"status": "duplicate"})
+		case errors.Is(err, services.ErrRoundUpsOff):
+			c.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		case errors.Is(err, models.ErrInvalidAmount):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than zero"})
+		case err != nil:
+			respondBalanceError(c, err, "Failed to record purchase")
+		default:
+			c.JSON(http.StatusCreated, gin.H{"status": "recorded", "roundup": event})
+		}
+	}
+}
+
+// GetRoundUpRule returns the authenticated user's round-up rule, null when
+// round-ups are off
+func GetRoundUpRule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		user, err := services.GetUserByID(store, userObjectID.Hex())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"rule": user.RoundUpRule})
+	}
+}
+
+// UpdateRoundUpRule turns round-ups on for the authenticated user or changes
+// how they are worked out
+func UpdateRoundUpRule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var rule models.RoundUpRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		err := services.SetRoundUpRule(c.Request.Context(), store, userObjectID, &rule, time.Now())
+		switch {
+		case errors.Is(err, models.ErrInvalidRoundUpRule):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update round-up rule"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Round-up rule updated", "rule": rule})
+		}
+	}
+}
+
+// DeleteRoundUpRule turns round-ups off for the authenticated user. Round-ups
+// already received are still saved in the next batch.
+func DeleteRoundUpRule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		err := services.SetRoundUpRule(c.Request.Context(), store, userObjectID, nil, time.Now())
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to turn off round-ups"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Round-ups turned off"})
+		}
+	}
+}
+
+// GetPendingRoundUps lists the authenticated user's round-ups waiting for
+// their daily batch, newest first
+func GetPendingRoundUps(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		events, err := store.RoundUps.ListByUser(c.Request.Context(), userObjectID, models.RoundUpPending)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch round-ups"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"roundups": events})
+	}
+}
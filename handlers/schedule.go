@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// recentScheduleRuns is how many runs GetSchedule returns with a schedule
+const recentScheduleRuns = 20
+
+// CreateSchedule sets up a recurring deposit into the authenticated user's
+// savings, or into one of their goals when goal_id is given. Dates take
+// RFC 3339 or YYYY-MM-DD.
+func CreateSchedule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Frequency string        `json:"frequency" binding:"required"`
+			Amount    *models.Money `json:"amount" binding:"required"`
+			StartAt   string        `json:"start_at"`
+			EndAt     string        `json:"end_at"`
+			GoalID    string        `json:"goal_id"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes := services.ScheduleChanges{Frequency: &request.Frequency, Amount: request.Amount}
+		var err error
+		if changes.StartAt, err = optionalDate("start_at", request.StartAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if changes.EndAt, err = optionalDate("end_at", request.EndAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if request.GoalID != "" {
+			goalID, err := primitive.ObjectIDFromHex(request.GoalID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+				return
+			}
+			changes.GoalID = &goalID
+		}
+
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		schedule, err := services.CreateSchedule(c.Request.Context(), store, userObjectID, changes, time.Now())
+		if err != nil {
+			respondScheduleError(c, err, "Failed to create schedule")
+			return
+		}
+
+		c.JSON(http.StatusCreated, schedule)
+	}
+}
+
+// GetSchedules lists the authenticated user's scheduled deposits
+func GetSchedules(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		schedules, err := store.Schedules.ListByUser(c.Request.Context(), userObjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"schedules": schedules})
+	}
+}
+
+// GetSchedule returns one scheduled deposit with its most recent runs
+func GetSchedule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		schedule, err := store.Schedules.GetByID(c.Request.Context(), userObjectID, scheduleID)
+		if errors.Is(err, repository.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule"})
+			return
+		}
+
+		runs, err := store.Schedules.ListRuns(c.Request.Context(), scheduleID, recentScheduleRuns)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch schedule runs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"schedule": schedule, "runs": runs})
+	}
+}
+
+// UpdateSchedule changes a schedule's amount or end date, or pauses
+// ("status": "paused") or resumes ("status": "active") it
+func UpdateSchedule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount *models.Money `json:"amount"`
+			EndAt  string        `json:"end_at"`
+			Status *string       `json:"status"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		changes := services.ScheduleChanges{Amount: request.Amount, Status: request.Status}
+		var err error
+		if changes.EndAt, err = optionalDate("end_at", request.EndAt); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userObjectID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		schedule, err := services.UpdateSchedule(c.Request.Context(), store, userObjectID, scheduleID, changes, time.Now())
+		if err != nil {
+			respondScheduleError(c, err, "Failed to update schedule")
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+// CancelSchedule stops a scheduled deposit for good
+func CancelSchedule(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, scheduleID, ok := scheduleParams(c)
+		if !ok {
+			return
+		}
+
+		if err := services.CancelSchedule(c.Request.Context(), store, userObjectID, scheduleID, time.Now()); err != nil {
+			respondScheduleError(c, err, "Failed to cancel schedule")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Schedule cancelled"})
+	}
+}
+
+// scheduleParams reads the authenticated user and the :schedule_id route parameter
+func scheduleParams(c *gin.Context) (primitive.ObjectID, primitive.ObjectID, bool) {
+	userObjectID, ok := authenticatedUserID(c)
+	if !ok {
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	scheduleID, err := primitive.ObjectIDFromHex(c.Param("schedule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return primitive.NilObjectID, primitive.NilObjectID, false
+	}
+	return userObjectID, scheduleID, true
+}
+
+// respondScheduleError maps errors from the schedule services to HTTP responses
+func respondScheduleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSchedule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Schedule not found"})
+	case errors.Is(err, models.ErrInvalidAmount):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Amount must be greater than zero"})
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency does not match the schedule or its goal"})
+	default:
+		respondGoalError(c, err, fallback)
+	}
+}
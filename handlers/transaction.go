@@ -1,171 +1,339 @@
 package handlers
 
 import (
-	"context"
-	"micro-savings-app/database"
-	"micro-savings-app/models"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 // Deposit handles user deposits into savings
-func Deposit(c *gin.Context) {
-	var request struct {
-		Amount float64 `json:"amount" binding:"required,gt=0"`
-	}
+func Deposit(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount models.Money `json:"amount" binding:"required,gt=0"`
+		}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Get the user ID from JWT claims
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		// Credit the balance and log the transaction in one database transaction
+		change, err := services.Deposit(c.Request.Context(), store, userObjectID, request.Amount)
+		if err != nil {
+			respondBalanceError(c, err, "Failed to process deposit")
+			return
+		}
+
+		// Return a success response
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Deposit successful",
+			"new_balance":      change.NewBalance,
+			"previous_balance": change.PreviousBalance,
+		})
 	}
+}
 
-	// Get the user ID from JWT claims
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized! user not authenticated"})
-		return
+// Withdraw handles user withdrawals from savings
+func Withdraw(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Amount models.Money `json:"amount" binding:"required,gt=0"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Get the user ID from JWT claims
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		// Large withdrawals need a fresh two-factor code from users who have it on
+		if !requireStepUp(c, store, userObjectID, request.Amount, "withdraw", "Failed to process withdrawal") {
+			return
+		}
+
+		// Debit the balance only if it covers the amount, and log the transaction
+		// in the same database transaction
+		change, err := services.Withdraw(c.Request.Context(), store, userObjectID, requestSessionID(c), request.Amount)
+		if err != nil {
+			respondBalanceError(c, err, "Failed to process withdrawal")
+			return
+		}
+
+		// Fraud screening set the money aside until a reviewer decides
+		if change.Review != nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":           "Withdrawal is being reviewed",
+				"review_id":         change.Review.ID.Hex(),
+				"withdrawal_amount": request.Amount,
+				"previous_balance":  change.PreviousBalance,
+				"new_balance":       change.NewBalance,
+			})
+			return
+		}
+
+		// Return a success response
+		c.JSON(http.StatusOK, gin.H{
+			"message":           "Withdrawal successful",
+			"withdrawal_amount": request.Amount,
+			"previous_balance":  change.PreviousBalance,
+			"new_balance":       change.NewBalance,
+		})
 	}
+}
 
-	// Parse the user ID
-	userObjectID, err := primitive.ObjectIDFromHex(userID.(string))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+// Transfer moves savings balance from the authenticated user to another user
+func Transfer(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Recipient string       `json:"recipient" binding:"required"` // email or user ID
+			Amount    models.Money `json:"amount" binding:"required,gt=0"`
+			Note      string       `json:"note" binding:"max=140"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Get the user ID from JWT claims
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		sender, err := services.GetUserByID(store, userObjectID.Hex())
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		if !requireStepUp(c, store, userObjectID, request.Amount, "transfer", "Failed to process transfer") {
+			return
+		}
+
+		// The recipient can be given by user ID or by email
+		var recipient *models.User
+		if primitive.IsValidObjectID(request.Recipient) {
+			recipient, err = services.GetUserByID(store, request.Recipient)
+		} else {
+			recipient, err = services.GetUserByEmail(store, request.Recipient)
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+			return
+		}
+
+		result, err := services.Transfer(c.Request.Context(), store, sender, recipient, requestSessionID(c), request.Amount, request.Note)
+		if errors.Is(err, services.ErrSelfTransfer) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot transfer to yourself"})
+			return
+		} else if errors.Is(err, services.ErrAccountClosed) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Recipient not found"})
+			return
+		} else if err != nil {
+			respondBalanceError(c, err, "Failed to process transfer")
+			return
+		}
+
+		if result.Review != nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":          "Transfer is being reviewed",
+				"review_id":        result.Review.ID.Hex(),
+				"reference":        result.Reference,
+				"transfer_amount":  request.Amount,
+				"recipient_id":     recipient.ID.Hex(),
+				"previous_balance": result.PreviousBalance,
+				"new_balance":      result.NewBalance,
+			})
+			return
+		}
+
+		// Return a success response
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Transfer successful",
+			"reference":        result.Reference,
+			"transfer_amount":  request.Amount,
+			"recipient_id":     recipient.ID.Hex(),
+			"previous_balance": result.PreviousBalance,
+			"new_balance":      result.NewBalance,
+		})
 	}
+}
 
-	// Fetch the user's current balance
-	usersCollection := database.GetCollection("users")
-	var user models.User
+// GetTransactions returns the authenticated user's transaction history, newest first
+// by default. Supports ?type=, ?min_amount=, ?max_amount=, ?from=, ?to=, ?order=asc|desc,
+// ?limit= and ?cursor= (the next_cursor from the previous page).
+func GetTransactions(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
 
-	err = usersCollection.FindOne(context.Background(), bson.M{"_id": userObjectID}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		return
+		query, err := parseHistoryQuery(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		page, err := services.ListTransactions(c.Request.Context(), store, userObjectID, query)
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transactions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, page)
 	}
+}
 
-	// Update the user's savings balance
-	newBalance := user.SavingsBalance + request.Amount
+func parseHistoryQuery(c *gin.Context) (services.TransactionQuery, error) {
+	query := services.TransactionQuery{Cursor: c.Query("cursor")}
 
-	_, err = usersCollection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": userObjectID},
-		bson.M{"$set": bson.M{"savings_balance": newBalance}},
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update savings balance"})
-		return
+	if t := c.Query("type"); t != "" {
+		query.Type = models.TransactionType(t)
+		if !query.Type.IsValid() {
+			return query, fmt.Errorf("invalid transaction type %q", t)
+		}
 	}
 
-	// Log the transaction
-	transactionsCollection := database.GetCollection("transactions")
-	transaction := models.Transaction{
-		ID:        primitive.NewObjectID(),
-		UserID:    userObjectID,
-		Type:      string(models.Deposit),
-		Amount:    request.Amount,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	for param, target := range map[string]**models.Money{"min_amount": &query.MinAmount, "max_amount": &query.MaxAmount} {
+		if value := c.Query(param); value != "" {
+			amount, err := models.ParseMoney(value, c.Query("currency"))
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: %v", param, err)
+			}
+			*target = &amount
+		}
 	}
-	_, err = transactionsCollection.InsertOne(context.Background(), transaction)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log transaction"})
-		return
+
+	for param, target := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := c.Query(param); value != "" {
+			parsed, err := parseDateParam(value)
+			if err != nil {
+				return query, fmt.Errorf("invalid %s: use RFC 3339 or YYYY-MM-DD", param)
+			}
+			*target = parsed
+		}
+	}
+	// A bare end date includes that whole day
+	if to := c.Query("to"); len(to) == len(time.DateOnly) && !query.To.IsZero() {
+		query.To = query.To.AddDate(0, 0, 1)
 	}
 
-	// Return a success response
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "Deposit successful",
-		"new_balance":     newBalance,
-		"previous_balance": user.SavingsBalance,
-	})
+	switch c.DefaultQuery("order", "desc") {
+	case "asc":
+		query.Ascending = true
+	case "desc":
+	default:
+		return query, errors.New("order must be asc or desc")
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return query, errors.New("limit must be a positive number")
+		}
+		query.Limit = n
+	}
+	return query, nil
 }
 
-// Withdraw handles user withdrawals from savings
-func Withdraw(c *gin.Context) {
-	var request struct {
-		Amount float64 `json:"amount" binding:"required,gt=0"`
+func parseDateParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
 	}
+	return time.Parse(time.DateOnly, value)
+}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+// requireStepUp responds and returns false when moving amount out of the
+// user's account needs a fresh two-factor code first. verb says what the
+// user is doing, e.g. "withdraw".
+func requireStepUp(c *gin.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, verb, fallback string) bool {
+	err := services.CheckWithdrawalStepUp(c.Request.Context(), store, userID, amount, middlewares.MFAVerifiedAt(c), time.Now())
+	if errors.Is(err, services.ErrStepUpRequired) {
+		// The same request goes through once the user has entered a code
+		middlewares.NotFinal(c)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":            "Enter a two-factor code at /user/mfa/verify to " + verb + " this amount",
+			"step_up_required": true,
+		})
+		return false
+	} else if err != nil {
+		respondBalanceError(c, err, fallback)
+		return false
 	}
+	return true
+}
 
-	// Get the user ID from JWT claims
+// authenticatedUserID reads the user ID set by AuthMiddleware, writing an
+// error response and returning false if it is missing or malformed
+func authenticatedUserID(c *gin.Context) (primitive.ObjectID, bool) {
 	userID, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized! user not authenticated"})
-		return
+		return primitive.NilObjectID, false
 	}
 
-	// Parse the user ID
-	userObjectID, err := primitive.ObjectIDFromHex(userID.(string))
+	userIDString, _ := userID.(string)
+	userObjectID, err := primitive.ObjectIDFromHex(userIDString)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
-		return
+		return primitive.NilObjectID, false
 	}
+	return userObjectID, true
+}
 
-	// Fetch the user's current balance
-	usersCollection := database.GetCollection("users")
-	var user models.User
+// requestSessionID returns the session making the request, or a zero ID if
+// it is not known
+func requestSessionID(c *gin.Context) primitive.ObjectID {
+	sessionID, _ := primitive.ObjectIDFromHex(c.GetString("session_id"))
+	return sessionID
+}
 
-	err = usersCollection.FindOne(context.Background(), bson.M{"_id": userObjectID}).Decode(&user)
-	if err == mongo.ErrNoDocuments {
+// respondBalanceError maps errors from the balance services to HTTP responses
+func respondBalanceError(c *gin.Context, err error, fallback string) {
+	var overVelocity *services.VelocityLimitError
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-		return
-	} else if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
-		return
-	}
-
-	// Check if the user has sufficient balance
-	if user.SavingsBalance < request.Amount {
+	case errors.Is(err, services.ErrInsufficientBalance):
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Insufficient balance"})
-		return
+	case errors.Is(err, models.ErrCurrencyMismatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Currency does not match savings balance"})
+	case errors.Is(err, services.ErrEmailNotVerified):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email address before withdrawing or transferring money"})
+	case errors.Is(err, services.ErrKYCLimitExceeded):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPaymentBlocked):
+		// Don't tell whoever is behind it which rule they tripped
+		c.JSON(http.StatusForbidden, gin.H{"error": "This payment can't be made. Contact support if you think this is a mistake"})
+	case errors.As(err, &overVelocity):
+		respondVelocityLimit(c, overVelocity)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
 	}
-
-	// Update the user's savings balance
-	newBalance := user.SavingsBalance - request.Amount
-
-	_, err = usersCollection.UpdateOne(
-		context.Background(),
-		bson.M{"_id": userObjectID},
-		bson.M{"$set": bson.M{"savings_balance": newBalance}},
-	)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update savings balance"})
-		return
-	}
-
-	// Log the transaction
-	transactionsCollection := database.GetCollection("transactions")
-	transaction := models.Transaction{
-		ID:        primitive.NewObjectID(),
-		UserID:    userObjectID,
-		Type:      string(models.Withdrawal),
-		Amount:    request.Amount,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-	_, err = transactionsCollection.InsertOne(context.Background(), transaction)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log transaction"})
-		return
-	}
-
-	// Return a success response
-	c.JSON(http.StatusOK, gin.H{
-		"message":         "Withdrawal successful",
-		"withdrawal_amount": request.Amount,
-		"previous_balance": user.SavingsBalance,
-		"new_balance":     newBalance,
-	})
-}
\ No newline at end of file
+}
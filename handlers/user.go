@@ -2,112 +2,152 @@ package handlers
 
 import (
 	"context"
-	"time"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
-	"micro-savings-app/database"
 	"micro-savings-app/models"
+	"micro-savings-app/repository"
 	"micro-savings-app/services"
 
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"golang.org/x/crypto/bcrypt"
 )
 
 // RegisterUser handles user registration
-func RegisterUser(c *gin.Context) {
-	var request struct {
-		Name     string `json:"name" binding:"required"`
-		Email    string `json:"email" binding:"required,email"`
-		Password string `json:"password" binding:"required"`
-	}
+func RegisterUser(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Name     string `json:"name" binding:"required"`
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+		}
 
-	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	// Check if email is already registered
-	usersCollection := database.GetCollection("users")
-	var existingUser models.User
-	err := usersCollection.FindOne(context.Background(), bson.M{"email": request.Email}).Decode(&existingUser)
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
-		return
-	}
+		// Check if email is already registered
+		_, err := store.Users.GetByEmail(context.Background(), request.Email)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Email already registered"})
+			return
+		}
 
-	// Hash the password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
-		return
-	}
+		// Hash the password
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
 
-	// Create the user document
-	newUser := models.User{
-		Name:              request.Name,
-		Email:             request.Email,
-		PasswordHash:      string(hashedPassword),
-		SavingsBalance:    0,
-		InvestmentBalance: 0,
-		IsAdmin: 		   false,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
-	}
+		// Create the user document
+		newUser := models.User{
+			Name:              request.Name,
+			Email:             request.Email,
+			PasswordHash:      string(hashedPassword),
+			SavingsBalance:    models.NewMoney(0, models.DefaultCurrency),
+			InvestmentBalance: models.NewMoney(0, models.DefaultCurrency),
+			GoalsBalance:      models.NewMoney(0, models.DefaultCurrency),
+			CreatedAt:         time.Now(),
+			UpdatedAt:         time.Now(),
+		}
 
-	_, err = usersCollection.InsertOne(context.Background(), newUser)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
-		return
-	}
+		err = store.Users.Create(context.Background(), &newUser)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register user"})
+			return
+		}
+
+		// Ask the user to prove they own the address; they can request
+		// another email if this one goes astray
+		if err := services.SendEmailVerification(c.Request.Context(), store, newUser.ID, time.Now()); err != nil {
+			log.Printf("Failed to send verification email to user %s: %v", newUser.ID.Hex(), err)
+		}
 
-	c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully"})
+		c.JSON(http.StatusCreated, gin.H{"message": "User registered successfully. Check your email to verify your address."})
+	}
 }
 
 // Login user handles user login returns JWT token
-func Login(c *gin.Context) {
-    var request struct {
-        Email    string `json:"email" binding:"required,email"`
-        Password string `json:"password" binding:"required"`
-    }
-
-    if err := c.ShouldBindJSON(&request); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-        return
-    }
-
-    // Fetch the user document from the database
-    usersCollection := database.GetCollection("users")
-    var user models.User
-    err := usersCollection.FindOne(context.Background(), bson.M{"email": request.Email}).Decode(&user)
-    if err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-        return
-    }
-
-    // Compare the password with the hash (verify the password)
-    err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(request.Password))
-    if err != nil {
-        c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
-        return
-    }
-
-    // Generate JWT
-	token, err := services.GenerateJWT(user.ID.Hex())
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
-	}
+// and a refresh token for the new session
+func Login(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Email    string `json:"email" binding:"required,email"`
+			Password string `json:"password" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Check the password. Unknown emails and wrong passwords get the same
+		// answer, and repeated failures are slowed down and then locked out
+		user, err := services.Authenticate(c.Request.Context(), store, request.Email, request.Password, c.ClientIP(), time.Now())
+		var blocked *services.LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			retryAfter := int64(math.Ceil(time.Until(blocked.RetryAt).Seconds()))
+			c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Too many failed login attempts; try again later",
+				"retry_after": retryAfter,
+			})
+			return
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			return
+		case errors.Is(err, services.ErrAccountClosed):
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account has been closed"})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+			return
+		}
 
-	// Return the token
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
-	})
+		// With two-factor on, the password only earns a challenge; the code
+		// goes to /user/login/mfa
+		if user.MFAEnabled() {
+			challenge, err := services.StartMFAChallenge(c.Request.Context(), store, user, time.Now())
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{
+				"status":     "mfa_required",
+				"message":    "Enter the code from your authenticator app",
+				"mfa_token":  challenge,
+				"expires_in": int64(models.MFAChallengeTTL.Seconds()),
+			})
+			return
+		}
+
+		// Start a session for this device and issue its tokens
+		tokens, err := services.StartSession(c.Request.Context(), store, user.ID, c.Request.UserAgent(), c.ClientIP(), time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
+
+		// Return the tokens
+		c.JSON(http.StatusOK, gin.H{
+			"status":        "success",
+			"message":       "Login successful",
+			"token":         tokens.AccessToken,
+			"refresh_token": tokens.RefreshToken,
+			"expires_in":    tokens.ExpiresIn,
+		})
+	}
 }
 
 // This is for user. Update to fetch useId from the token
-func GetUserByID() gin.HandlerFunc {
+func GetUserByID(store *repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userId := c.Query("user_id") // fetch the user id from the url (query param)
 		if userId == "" {
@@ -116,7 +156,18 @@ func GetUserByID() gin.HandlerFunc {
 			return
 		}
 
-		user, err := services.GetUserByID(userId)
+		// Users can only look up their own account
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+		if userId != userObjectID.Hex() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden! You can only view your own account"})
+			c.Abort()
+			return
+		}
+
+		user, err := services.GetUserByID(store, userId)
 		if err != nil || user == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden! User not found"})
 			c.Abort()
@@ -124,6 +175,6 @@ func GetUserByID() gin.HandlerFunc {
 		}
 		
 		// Return the user details
-		c.JSON(http.StatusOK, user)
+		c.JSON(http.StatusOK, user.Details())
 	}
 }
\ No newline at end of file
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"reflect"
+
+	"micro-savings-app/models"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// Register custom binding rules so request structs can validate models.Money
+// with the usual tags, e.g. `binding:"required,gt=0"` compares the minor units
+func init() {
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterCustomTypeFunc(moneyMinorUnits, models.Money{})
+	}
+}
+
+func moneyMinorUnits(field reflect.Value) interface{} {
+	if money, ok := field.Interface().(models.Money); ok {
+		return money.Amount
+	}
+	return nil
+}
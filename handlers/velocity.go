@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetVelocityLimits returns the authenticated user's daily and monthly
+// limits, how much of each is left and when it resets
+func GetVelocityLimits(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		usages, source, err := services.GetVelocityUsage(c.Request.Context(), store, userObjectID, time.Now())
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch limits"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"source": source, "limits": usages})
+	}
+}
+
+// AdminGetVelocityLimits returns the global velocity limits
+func AdminGetVelocityLimits(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limits, err := services.GlobalVelocityLimits(c.Request.Context(), store)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch velocity limits"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"limits": limits})
+	}
+}
+
+// AdminUpdateVelocityLimits replaces the global velocity limits. A window
+// left out has no limit.
+func AdminUpdateVelocityLimits(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var limits models.VelocityLimits
+		if err := c.ShouldBindJSON(&limits); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		saved, err := services.SetGlobalVelocityLimits(c.Request.Context(), store, limits)
+		if errors.Is(err, models.ErrInvalidVelocityLimits) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update velocity limits"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Velocity limits updated", "limits": saved})
+	}
+}
+
+// AdminUpdateUserVelocityLimits gives a user their own velocity limits in
+// place of the global ones
+func AdminUpdateUserVelocityLimits(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var limits models.VelocityLimits
+
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if err := c.ShouldBindJSON(&limits); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = services.SetUserVelocityLimits(c.Request.Context(), store, userObjectID, &limits)
+		switch {
+		case errors.Is(err, models.ErrInvalidVelocityLimits):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update velocity limits"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Velocity limits updated"})
+		}
+	}
+}
+
+// AdminResetUserVelocityLimits puts a user back on the global velocity limits
+func AdminResetUserVelocityLimits(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, err := primitive.ObjectIDFromHex(c.Param("user_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		err = services.SetUserVelocityLimits(c.Request.Context(), store, userObjectID, nil)
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset velocity limits"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Velocity limits reset to the global limits"})
+		}
+	}
+}
+
+// respondVelocityLimit tells the client which limit was hit and how much of
+// it is left. It is a 429 with Retry-After when the same amount will fit
+// later, and a 403 when the amount is over the limit on its own.
+func respondVelocityLimit(c *gin.Context, exceeded *services.VelocityLimitError) {
+	body := gin.H{
+		"error":     exceeded.Error(),
+		"movement":  exceeded.Usage.Movement,
+		"window":    exceeded.Usage.Window,
+		"limit":     exceeded.Usage.Limit,
+		"used":      exceeded.Usage.Used,
+		"remaining": exceeded.Usage.Remaining,
+	}
+	if exceeded.Usage.ResetsAt != nil {
+		body["resets_at"] = exceeded.Usage.ResetsAt
+	}
+	if !exceeded.Retryable() {
+		c.JSON(http.StatusForbidden, body)
+		return
+	}
+
+	retryAfter := int64(math.Ceil(time.Until(exceeded.RetryAt).Seconds()))
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	body["retry_at"] = exceeded.RetryAt
+	body["retry_after"] = retryAfter
+	c.JSON(http.StatusTooManyRequests, body)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VerifyEmail spends an emailed verification token
+func VerifyEmail(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Token string `json:"token" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := services.VerifyEmail(c.Request.Context(), store, request.Token, time.Now())
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+	}
+}
+
+// ResendEmailVerification emails the authenticated user a new verification
+// link; earlier links stop working
+func ResendEmailVerification(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userObjectID, ok := authenticatedUserID(c)
+		if !ok {
+			return
+		}
+
+		err := services.SendEmailVerification(c.Request.Context(), store, userObjectID, time.Now())
+		switch {
+		case errors.Is(err, services.ErrEmailAlreadyVerified):
+			c.JSON(http.StatusConflict, gin.H{"error": "Email is already verified"})
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send verification email"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+		}
+	}
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the address has an account.
+func ForgotPassword(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Email string `json:"email" binding:"required,email"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := services.RequestPasswordReset(c.Request.Context(), store, request.Email, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to request password reset"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If that email has an account, a reset link is on its way"})
+	}
+}
+
+// ResetPassword sets a new password using an emailed reset token and logs
+// the user out everywhere
+func ResetPassword(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request struct {
+			Token    string `json:"token" binding:"required"`
+			Password string `json:"password" binding:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		err := services.ResetPassword(c.Request.Context(), store, request.Token, request.Password, time.Now())
+		switch {
+		case errors.Is(err, services.ErrWeakPassword):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidToken):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Password reset. Log in with your new password."})
+		}
+	}
+}
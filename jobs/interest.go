@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"micro-savings-app/clock"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+	"time"
+)
+
+// AccrueInterest accrues daily interest on every investment balance at its
+// product tier's rate, capitalising it when the tier compounds
+func AccrueInterest(store *repository.Store, clk clock.Clock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	now := clk.Now()
+	tiers, err := services.InterestTiers(ctx, store)
+	if err != nil {
+		fmt.Printf("Failed to load interest tiers: %v\n", err)
+		return
+	}
+
+	users, err := store.Users.FindInvested(ctx)
+	if err != nil {
+		fmt.Printf("Failed to find users with investments: %v\n", err)
+		return
+	}
+
+	for _, user := range users {
+		paid, err := services.AccrueInterest(ctx, store, user.ID, tiers, now)
+		if err != nil {
+			fmt.Printf("Failed to accrue interest for user %v: %v\n", user.ID.Hex(), err)
+			continue
+		}
+		if paid.IsPositive() {
+			fmt.Printf("Paid %v interest to user %v\n", paid, user.ID.Hex())
+		}
+	}
+}
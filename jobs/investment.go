@@ -3,81 +3,104 @@ package jobs
 import (
 	"context"
 	"fmt"
+	"micro-savings-app/ledger"
 	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
-func AllocateIdleBalances(db *mongo.Database) {
-	collection := db.Collection("users")
-	transactionCollection := db.Collection("transactions")
+// AllocateIdleBalances sweeps idle savings into investments. Each user's
+// allocation policy (their own, or the global one) decides when savings count
+// as idle and how much of them is moved. Money saved towards goals sits in a
+// separate balance and is never swept.
+func AllocateIdleBalances(store *repository.Store) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Define the idle period (e.g., 30 days)
-	// Idle period is Period when savings_balance is not used 
-	// for any transaction
-	idlePeriod := time.Hour * 24 * 30
 	now := time.Now()
-
-	// Find users with idle balances
-	filter := bson.M{
-		"savings_balance": bson.M{"$gt": 0}, // Positive balance
-		"last_transaction_at": bson.M{
-			"$lt": now.Add(-idlePeriod), // Last transaction older than 30 days
-		},
+	globalPolicy, err := services.GlobalAllocationPolicy(ctx, store)
+	if err != nil {
+		fmt.Printf("Failed to load allocation policy: %v\n", err)
+		return
 	}
 
-	cursor, err := collection.Find(ctx, filter)
+	// Find users with savings idle for at least the shortest threshold a
+	// policy can set; each user's own threshold is checked below
+	users, err := store.Users.FindIdle(ctx, now.AddDate(0, 0, -models.MinIdleDays))
 	if err != nil {
 		fmt.Printf("Failed to find users with idle savings_balance: %v\n", err)
 		return
 	}
-	defer cursor.Close(ctx)
 
 	// Process each idle user
-	for cursor.Next(ctx) {
-		var user models.User
-		if err := cursor.Decode(&user); err != nil {
-			fmt.Printf("Failed to decode user: %v\n", err)
-			continue
+	for _, user := range users {
+		policy := globalPolicy
+		if user.AllocationPolicy != nil {
+			policy = *user.AllocationPolicy
 		}
-
-		// Move funds to the investment balance
-		transferAmount := user.SavingsBalance
-		update := bson.M{
-			"$set": bson.M{
-				"savings_balance": 0,
-				"investment_balance": user.InvestmentBalance + transferAmount,
-				"updated_at": now,
-			},
+		if !policy.IsIdle(user.LastTransactionAt, now) {
+			continue
 		}
-
-		_, err := collection.UpdateByID(ctx, user.ID, update)
-		if err != nil {
-			fmt.Printf("Failed to update user: %v\n", err)
+		transferAmount := policy.SweepFrom(user.SavingsBalance)
+		if !transferAmount.IsPositive() {
 			continue
 		}
 
-		// Log the investment allocation as a transaction
-		transaction := models.Transaction{
-			ID:        primitive.NewObjectID(),
-			UserID:    user.ID,
-			Type:      string(models.Investment),
-			Amount:    transferAmount,
-			CreatedAt: now,
-			UpdatedAt: now,
-		}
+		// Move funds to the investment balance. The ledger only lets the savings
+		// account go down while it still covers the amount, so a withdrawal that
+		// lands in between makes this user fail and get picked up next run.
+		err := store.WithTransaction(ctx, func(ctx context.Context) error {
+			transaction := models.Transaction{
+				ID:             primitive.NewObjectID(),
+				UserID:         user.ID,
+				Type:           string(models.Investment),
+				Amount:         transferAmount,
+				JournalEntryID: primitive.NewObjectID(),
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}
+
+			balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+				ID:          transaction.JournalEntryID,
+				Reference:   transaction.ID.Hex(),
+				Description: string(models.Investment),
+				Lines: []models.JournalLine{
+					ledger.DebitLine(ledger.UserSavingsAccount(user.ID), transferAmount),
+					ledger.CreditLine(ledger.UserInvestmentAccount(user.ID), transferAmount),
+				},
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			// Interest on a newly funded investment balance runs from today
+			investmentBalance := balances[ledger.UserInvestmentAccount(user.ID)]
+			if investmentBalance == transferAmount && user.Interest.Accrued == 0 {
+				if err := services.StartInterestAccrual(ctx, store, user.ID, now); err != nil {
+					return err
+				}
+			}
+
+			// The sweep counts as activity, so a partial sweep waits for the
+			// savings left behind to go idle again
+			if err := store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID}, now); err != nil {
+				return err
+			}
 
-		_, err = transactionCollection.InsertOne(ctx, transaction)
+			// Log the investment allocation as a transaction
+			savingsBalance := balances[ledger.UserSavingsAccount(user.ID)]
+			transaction.BalanceAfter = &savingsBalance
+			return store.Transactions.Insert(ctx, transaction)
+		})
 		if err != nil {
-			fmt.Printf("Failed to log transaction: %v\n", err)
+			fmt.Printf("Failed to allocate idle balance for user %v: %v\n", user.ID.Hex(), err)
 			continue
 		}
 
 		fmt.Printf("Allocated %v to investments for user %v\n", transferAmount, user.ID.Hex())
 	}
-}
\ No newline at end of file
+}
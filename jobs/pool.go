@@ -0,0 +1,25 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"micro-savings-app/clock"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+	"time"
+)
+
+// RunPoolCycles collects contributions and pays out every savings pool cycle
+// that has fallen due
+func RunPoolCycles(store *repository.Store, clk clock.Clock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cycles, shortfalls, err := services.RunDuePools(ctx, store, clk.Now())
+	if err != nil {
+		fmt.Printf("Failed to run pool cycles: %v\n", err)
+	}
+	if cycles > 0 {
+		fmt.Printf("Ran %d pool cycle(s), %d member(s) short of funds\n", cycles, shortfalls)
+	}
+}
@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"micro-savings-app/clock"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+	"time"
+)
+
+// SettleRedemptions pays out redemptions whose notice period has ended
+func SettleRedemptions(store *repository.Store, clk clock.Clock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	settled, failed, err := services.SettleDueRedemptions(ctx, store, clk.Now())
+	if err != nil {
+		fmt.Printf("Failed to settle redemptions: %v\n", err)
+	}
+	if settled > 0 || failed > 0 {
+		fmt.Printf("Settled %d redemption(s), %d failed\n", settled, failed)
+	}
+}
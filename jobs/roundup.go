@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"micro-savings-app/clock"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+	"time"
+)
+
+// PostRoundUps saves the previous days' round-ups, one batch per user per day
+func PostRoundUps(store *repository.Store, clk clock.Clock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	posted, err := services.PostRoundUps(ctx, store, clk.Now())
+	if err != nil {
+		fmt.Printf("Failed to post round-ups: %v\n", err)
+	}
+	if posted > 0 {
+		fmt.Printf("Posted %d round-up batch(es)\n", posted)
+	}
+}
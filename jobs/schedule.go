@@ -0,0 +1,24 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"micro-savings-app/clock"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+	"time"
+)
+
+// RunScheduledDeposits makes the scheduled deposits that have fallen due
+func RunScheduledDeposits(store *repository.Store, clk clock.Clock) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	succeeded, failed, err := services.RunDueSchedules(ctx, store, clk.Now())
+	if err != nil {
+		fmt.Printf("Failed to run scheduled deposits: %v\n", err)
+	}
+	if succeeded > 0 || failed > 0 {
+		fmt.Printf("Made %d scheduled deposit(s), %d failed\n", succeeded, failed)
+	}
+}
@@ -0,0 +1,109 @@
+package ledger
+
+import (
+	"fmt"
+	"strings"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// System accounts shared by every user
+const (
+	ExternalCashAccount    = "system:external_cash"    // money held with our banking partners
+	InterestExpenseAccount = "system:interest_expense" // returns paid on investments
+	OpeningBalanceAccount  = "system:opening_balance"  // balances that predate the ledger
+	FeeIncomeAccount       = "system:fee_income"       // penalties and fees we charge
+	HeldFundsAccount       = "system:held_funds"       // withdrawals and transfers waiting for a fraud review
+)
+
+var systemAccountTypes = map[string]models.AccountType{
+	ExternalCashAccount:    models.Asset,
+	InterestExpenseAccount: models.Expense,
+	OpeningBalanceAccount:  models.Equity,
+	FeeIncomeAccount:       models.Income,
+	HeldFundsAccount:       models.Liability,
+}
+
+// Kinds of per-user accounts and the user document field caching their balance
+const (
+	savingsKind    = "savings"
+	investmentKind = "investment"
+	goalsKind      = "goals"
+)
+
+var userBalanceFields = map[string]repository.BalanceField{
+	savingsKind:    repository.SavingsBalance,
+	investmentKind: repository.InvestmentBalance,
+	goalsKind:      repository.GoalsBalance,
+}
+
+// UserSavingsAccount is the ledger account behind a user's savings balance
+func UserSavingsAccount(userID primitive.ObjectID) string {
+	return "user:" + userID.Hex() + ":" + savingsKind
+}
+
+// UserInvestmentAccount is the ledger account behind a user's investment balance
+func UserInvestmentAccount(userID primitive.ObjectID) string {
+	return "user:" + userID.Hex() + ":" + investmentKind
+}
+
+// UserGoalsAccount is the ledger account behind the money a user has saved
+// towards goals; each goal's share is tracked on the goal itself
+func UserGoalsAccount(userID primitive.ObjectID) string {
+	return "user:" + userID.Hex() + ":" + goalsKind
+}
+
+// PoolAccount holds the contributions a savings pool has collected and not
+// yet paid out
+func PoolAccount(poolID primitive.ObjectID) string {
+	return "pool:" + poolID.Hex()
+}
+
+func isPoolAccount(accountID string) bool {
+	hex, ok := strings.CutPrefix(accountID, "pool:")
+	return ok && primitive.IsValidObjectID(hex)
+}
+
+// userAccount splits a user account ID into the user and the cached balance field
+func userAccount(accountID string) (primitive.ObjectID, repository.BalanceField, bool) {
+	parts := strings.Split(accountID, ":")
+	if len(parts) != 3 || parts[0] != "user" {
+		return primitive.NilObjectID, "", false
+	}
+	field, ok := userBalanceFields[parts[2]]
+	if !ok {
+		return primitive.NilObjectID, "", false
+	}
+	userID, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return primitive.NilObjectID, "", false
+	}
+	return userID, field, true
+}
+
+// AccountType returns the type of a known account. Money users hold with us
+// is a liability: credits increase it and debits decrease it.
+func AccountType(accountID string) (models.AccountType, error) {
+	if t, ok := systemAccountTypes[accountID]; ok {
+		return t, nil
+	}
+	if _, _, ok := userAccount(accountID); ok {
+		return models.Liability, nil
+	}
+	// Pooled money still belongs to the pool's members
+	if isPoolAccount(accountID) {
+		return models.Liability, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownAccount, accountID)
+}
+
+// signedAmount is the change a journal line makes to its account's balance
+func signedAmount(accountType models.AccountType, line models.JournalLine) models.Money {
+	if accountType.DebitNormal() == (line.Direction == models.Debit) {
+		return line.Amount
+	}
+	return line.Amount.Neg()
+}
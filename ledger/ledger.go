@@ -0,0 +1,121 @@
+// Package ledger keeps a double-entry record of every movement of money.
+// Each movement is a balanced journal entry; user balances on the user
+// document are a cache of their ledger accounts, updated in the same
+// database transaction as the entry, and can be re-derived at any time.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUnbalanced        = errors.New("journal entry is not balanced")
+	ErrInvalidEntry      = errors.New("invalid journal entry")
+	ErrUnknownAccount    = errors.New("unknown ledger account")
+	ErrAccountNotFound   = repository.ErrNotFound
+	ErrInsufficientFunds = repository.ErrInsufficientFunds
+)
+
+// Line builders keep call sites readable
+func DebitLine(accountID string, amount models.Money) models.JournalLine {
+	return models.JournalLine{AccountID: accountID, Direction: models.Debit, Amount: amount}
+}
+
+func CreditLine(accountID string, amount models.Money) models.JournalLine {
+	return models.JournalLine{AccountID: accountID, Direction: models.Credit, Amount: amount}
+}
+
+// Validate checks that an entry is well formed and that debits equal credits
+func Validate(entry *models.JournalEntry) error {
+	if entry.Reference == "" {
+		return fmt.Errorf("%w: reference is required", ErrInvalidEntry)
+	}
+	if len(entry.Lines) < 2 {
+		return fmt.Errorf("%w: at least two lines are required", ErrInvalidEntry)
+	}
+
+	var debits, credits int64
+	currency := entry.Lines[0].Amount.Currency
+	for _, line := range entry.Lines {
+		if _, err := AccountType(line.AccountID); err != nil {
+			return err
+		}
+		if !line.Amount.IsPositive() {
+			return fmt.Errorf("%w: line amounts must be positive", ErrInvalidEntry)
+		}
+		if line.Amount.Currency != currency {
+			return fmt.Errorf("%w: lines must share a currency", ErrInvalidEntry)
+		}
+		switch line.Direction {
+		case models.Debit:
+			debits += line.Amount.Amount
+		case models.Credit:
+			credits += line.Amount.Amount
+		default:
+			return fmt.Errorf("%w: unknown direction %q", ErrInvalidEntry, line.Direction)
+		}
+	}
+	if debits != credits {
+		return ErrUnbalanced
+	}
+	return nil
+}
+
+// Post records a balanced journal entry and applies it to the cached balances,
+// returning the new balance of every account it touched. A line that would take
+// a user account below zero fails with ErrInsufficientFunds.
+//
+// Post does not start a transaction of its own: call it inside
+// store.WithTransaction so the entry, the balances and anything else the caller
+// writes (e.g. the models.Transaction row) commit together.
+func Post(ctx context.Context, store *repository.Store, entry *models.JournalEntry) (map[string]models.Money, error) {
+	if err := Validate(entry); err != nil {
+		return nil, err
+	}
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	balances := make(map[string]models.Money, len(entry.Lines))
+	for _, line := range entry.Lines {
+		balance, err := applyLine(ctx, store, line, entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		balances[line.AccountID] = balance
+	}
+
+	if err := store.Ledger.InsertEntry(ctx, entry); err != nil {
+		return nil, err
+	}
+	return balances, nil
+}
+
+// applyLine updates the cached balance of one account and returns its new value
+func applyLine(ctx context.Context, store *repository.Store, line models.JournalLine, now time.Time) (models.Money, error) {
+	accountType, err := AccountType(line.AccountID)
+	if err != nil {
+		return models.Money{}, err
+	}
+	delta := signedAmount(accountType, line)
+
+	if userID, field, ok := userAccount(line.AccountID); ok {
+		user, err := store.Users.AdjustBalance(ctx, userID, field, delta, now)
+		if err != nil {
+			return models.Money{}, err
+		}
+		return field.Balance(user), nil
+	}
+	return store.Ledger.AdjustAccount(ctx, line.AccountID, accountType, delta, now)
+}
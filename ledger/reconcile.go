@@ -0,0 +1,114 @@
+package ledger
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Discrepancy is an account whose cached balance differs from its journal history
+type Discrepancy struct {
+	AccountID string       `json:"account_id"`
+	Cached    models.Money `json:"cached"`
+	Derived   models.Money `json:"derived"`
+}
+
+// DerivedBalance recomputes an account's balance from the journal alone
+func DerivedBalance(ctx context.Context, store *repository.Store, accountID string, currency string) (models.Money, error) {
+	accountType, err := AccountType(accountID)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	debits, credits, err := store.Ledger.LineTotals(ctx, accountID, currency)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if accountType.DebitNormal() {
+		return models.NewMoney(debits-credits, currency), nil
+	}
+	return models.NewMoney(credits-debits, currency), nil
+}
+
+// Reconcile compares a user's cached balances with their journal history
+func Reconcile(ctx context.Context, store *repository.Store, user *models.User) ([]Discrepancy, error) {
+	discrepancies := []Discrepancy{}
+	for accountID, cached := range userAccounts(user) {
+		derived, err := DerivedBalance(ctx, store, accountID, cached.CurrencyCode())
+		if err != nil {
+			return nil, err
+		}
+		if derived.Amount != cached.Amount {
+			discrepancies = append(discrepancies, Discrepancy{AccountID: accountID, Cached: cached, Derived: derived})
+		}
+	}
+	return discrepancies, nil
+}
+
+// RecordOpeningBalances journals the balances that existed before the ledger did,
+// so every user's cached balance is explained by history. The cached balances are
+// not touched; only the opening balance account moves. Safe to re-run.
+func RecordOpeningBalances(ctx context.Context, store *repository.Store) (int, error) {
+	recorded := 0
+	err := store.Users.ForEach(ctx, func(user *models.User) error {
+		discrepancies, err := Reconcile(ctx, store, user)
+		if err != nil {
+			return err
+		}
+		for _, d := range discrepancies {
+			if err := recordOpeningEntry(ctx, store, d); err != nil {
+				return err
+			}
+			recorded++
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+func recordOpeningEntry(ctx context.Context, store *repository.Store, d Discrepancy) error {
+	now := time.Now()
+	gap := d.Cached.Sub(d.Derived)
+
+	// A positive gap means we owe the user more than history shows
+	lines := []models.JournalLine{DebitLine(OpeningBalanceAccount, gap), CreditLine(d.AccountID, gap)}
+	if gap.IsNegative() {
+		gap = gap.Neg()
+		lines = []models.JournalLine{DebitLine(d.AccountID, gap), CreditLine(OpeningBalanceAccount, gap)}
+	}
+
+	entry := &models.JournalEntry{
+		ID:          primitive.NewObjectID(),
+		Description: "Opening balance",
+		Lines:       lines,
+		CreatedAt:   now,
+	}
+	entry.Reference = "opening:" + d.AccountID + ":" + entry.ID.Hex()
+	if err := Validate(entry); err != nil {
+		return err
+	}
+
+	// Only the system side is applied; the user side is already in the cache
+	return store.WithTransaction(ctx, func(ctx context.Context) error {
+		for _, line := range entry.Lines {
+			if line.AccountID == OpeningBalanceAccount {
+				if _, err := applyLine(ctx, store, line, now); err != nil {
+					return err
+				}
+			}
+		}
+		return store.Ledger.InsertEntry(ctx, entry)
+	})
+}
+
+func userAccounts(user *models.User) map[string]models.Money {
+	return map[string]models.Money{
+		UserSavingsAccount(user.ID):    user.SavingsBalance,
+		UserInvestmentAccount(user.ID): user.InvestmentBalance,
+		UserGoalsAccount(user.ID):      user.GoalsBalance,
+	}
+}
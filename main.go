@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
+	"log"
+	"micro-savings-app/clock"
 	"micro-savings-app/database"
 	"micro-savings-app/handlers"
 	"micro-savings-app/jobs"
 	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
 	"os"
 
 	"github.com/gin-gonic/gin"
@@ -22,33 +28,163 @@ func main() {
 	// Connect to MongoDB
 	database.ConnectDB(os.Getenv("MONGO_URI"))
 
+	// Run a maintenance command (e.g. `migrate-money`) instead of the server
+	if len(os.Args) > 1 {
+		defer database.DisconnectMongoDB()
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Make sure the indexes the app depends on exist
+	if err := database.EnsureIndexes(context.Background()); err != nil {
+		log.Printf("Failed to create indexes: %v", err)
+	}
+
+	// All handlers, middlewares and jobs share one store
+	store := repository.NewMongoStore(database.GetDatabase())
+
+	// Load the keys access tokens are signed with
+	if err := services.LoadSigningKeys(context.Background(), store); err != nil {
+		log.Fatalf("Failed to load signing keys: %v", err)
+	}
+
 	// Create a new Gin router
 	router := gin.Default()
 
 	// Register the user routes
-	router.POST("/user/register", handlers.RegisterUser)
-	router.POST("/user/login", handlers.Login)
-	router.POST("/admin/register", handlers.RegisterAdmin)
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", handlers.JWKS())
+
+	router.POST("/user/register", handlers.RegisterUser(store))
+	router.POST("/user/login", handlers.Login(store))
+	router.POST("/user/login/mfa", handlers.LoginMFA(store))
+	router.POST("/user/refresh", handlers.RefreshToken(store))
+	router.POST("/user/verify-email", handlers.VerifyEmail(store))
+	router.POST("/user/forgot-password", handlers.ForgotPassword(store))
+	router.POST("/user/reset-password", handlers.ResetPassword(store))
+	router.POST("/user/change-email/confirm", handlers.ConfirmEmailChange(store))
+	router.POST("/admin/register", handlers.RegisterAdmin(store))
 
-	// Register the admin protected routes
+	// Card purchases from the card processor, signed with a shared secret
+	router.POST("/webhooks/card-purchases", middlewares.WebhookSignatureMiddleware("CARD_WEBHOOK_SECRET"), handlers.CardPurchaseWebhook(store))
+
+	// Register the admin protected routes. Staff only, with a recent
+	// two-factor code; each route also needs the permission named on it
 	protectedAdmin := router.Group("/admin")
-	protectedAdmin.Use(middlewares.AuthMiddleware(), middlewares.AdminAuthMiddleware())
-	protectedAdmin.POST("/create-user-admin", handlers.MakeAdmin)
-	protectedAdmin.POST("/remove-admin-user", handlers.RemoveAdmin)
-	protectedAdmin.GET("/dashboard", handlers.AdminDashboard)
-	protectedAdmin.GET("/get-user/:user_id", handlers.AdminGetUserByID())
+	protectedAdmin.Use(middlewares.AuthMiddleware(store), middlewares.AdminAuthMiddleware(store), middlewares.RequireStepUp())
+	protectedAdmin.POST("/create-user-admin", middlewares.RequirePermission(models.PermAdminsManage), handlers.MakeAdmin(store))
+	protectedAdmin.POST("/remove-admin-user", middlewares.RequirePermission(models.PermAdminsManage), handlers.RemoveAdmin(store))
+	protectedAdmin.GET("/roles", middlewares.RequirePermission(models.PermAdminsManage), handlers.AdminGetRoles())
+	protectedAdmin.PUT("/users/:user_id/roles", middlewares.RequirePermission(models.PermAdminsManage), handlers.AdminSetUserRoles(store))
+	protectedAdmin.GET("/dashboard", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminDashboard(store))
+	protectedAdmin.GET("/get-user/:user_id", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminGetUserByID(store))
+	protectedAdmin.POST("/users/:user_id/unlock", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminUnlockUser(store))
+	protectedAdmin.GET("/kyc", middlewares.RequirePermission(models.PermKYCReview), handlers.AdminListKYC(store))
+	protectedAdmin.POST("/kyc/:submission_id/approve", middlewares.RequirePermission(models.PermKYCReview), handlers.AdminApproveKYC(store))
+	protectedAdmin.POST("/kyc/:submission_id/reject", middlewares.RequirePermission(models.PermKYCReview), handlers.AdminRejectKYC(store))
+	protectedAdmin.GET("/reviews", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminListReviews(store))
+	protectedAdmin.POST("/reviews/:review_id/approve", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminApproveReview(store))
+	protectedAdmin.POST("/reviews/:review_id/reject", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminRejectReview(store))
+	protectedAdmin.GET("/reconcile/:user_id", middlewares.RequirePermission(models.PermLedgerRead), handlers.AdminReconcileUser(store))
+	protectedAdmin.GET("/allocation-policy", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminGetAllocationPolicy(store))
+	protectedAdmin.PUT("/allocation-policy", middlewares.RequirePermission(models.PermSettingsManage), handlers.AdminUpdateAllocationPolicy(store))
+	protectedAdmin.PUT("/allocation-policy/:user_id", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminUpdateUserAllocationPolicy(store))
+	protectedAdmin.DELETE("/allocation-policy/:user_id", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminResetUserAllocationPolicy(store))
+	protectedAdmin.GET("/interest-tiers", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminGetInterestTiers(store))
+	protectedAdmin.PUT("/interest-tiers", middlewares.RequirePermission(models.PermSettingsManage), handlers.AdminUpdateInterestTiers(store))
+	protectedAdmin.PUT("/product-tier/:user_id", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminSetProductTier(store))
+	protectedAdmin.GET("/velocity-limits", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminGetVelocityLimits(store))
+	protectedAdmin.PUT("/velocity-limits", middlewares.RequirePermission(models.PermSettingsManage), handlers.AdminUpdateVelocityLimits(store))
+	protectedAdmin.PUT("/velocity-limits/:user_id", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminUpdateUserVelocityLimits(store))
+	protectedAdmin.DELETE("/velocity-limits/:user_id", middlewares.RequirePermission(models.PermUsersManage), handlers.AdminResetUserVelocityLimits(store))
+	protectedAdmin.GET("/step-up-threshold", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminGetStepUpThreshold(store))
+	protectedAdmin.PUT("/step-up-threshold", middlewares.RequirePermission(models.PermSettingsManage), handlers.AdminUpdateStepUpThreshold(store))
     
 	// Register users protected routes
 	protected := router.Group("/user")
-	protected.Use(middlewares.AuthMiddleware())	
-	protected.POST("/deposit", handlers.Deposit)
-	protected.POST("/withdraw", handlers.Withdraw)
-	protected.GET("", handlers.GetUserByID())
+	protected.Use(middlewares.AuthMiddleware(store))	
+	protected.POST("/deposit", middlewares.IdempotencyMiddleware(store), handlers.Deposit(store))
+	protected.POST("/withdraw", middlewares.IdempotencyMiddleware(store), handlers.Withdraw(store))
+	protected.POST("/transfer", middlewares.IdempotencyMiddleware(store), handlers.Transfer(store))
+	protected.GET("/transactions", handlers.GetTransactions(store))
+	protected.POST("/investments/redeem", middlewares.IdempotencyMiddleware(store), handlers.RedeemInvestment(store))
+	protected.GET("/investments/redemptions", handlers.GetRedemptions(store))
+	protected.GET("/allocation-policy", handlers.GetAllocationPolicy(store))
+	protected.PUT("/allocation-policy", handlers.UpdateAllocationPolicy(store))
+	protected.DELETE("/allocation-policy", handlers.ResetAllocationPolicy(store))
+	protected.POST("/goals", handlers.CreateGoal(store))
+	protected.GET("/goals", handlers.GetGoals(store))
+	protected.GET("/goals/:goal_id", handlers.GetGoal(store))
+	protected.PATCH("/goals/:goal_id", handlers.UpdateGoal(store))
+	protected.DELETE("/goals/:goal_id", handlers.DeleteGoal(store))
+	protected.POST("/goals/:goal_id/deposit", middlewares.IdempotencyMiddleware(store), handlers.DepositToGoal(store))
+	protected.POST("/goals/:goal_id/withdraw", middlewares.IdempotencyMiddleware(store), handlers.WithdrawFromGoal(store))
+	protected.POST("/schedules", handlers.CreateSchedule(store))
+	protected.GET("/schedules", handlers.GetSchedules(store))
+	protected.GET("/schedules/:schedule_id", handlers.GetSchedule(store))
+	protected.PATCH("/schedules/:schedule_id", handlers.UpdateSchedule(store))
+	protected.DELETE("/schedules/:schedule_id", handlers.CancelSchedule(store))
+	protected.GET("/roundup-rule", handlers.GetRoundUpRule(store))
+	protected.PUT("/roundup-rule", handlers.UpdateRoundUpRule(store))
+	protected.DELETE("/roundup-rule", handlers.DeleteRoundUpRule(store))
+	protected.GET("/roundups", handlers.GetPendingRoundUps(store))
+	protected.POST("/pools", handlers.CreatePool(store))
+	protected.GET("/pools", handlers.GetPools(store))
+	protected.GET("/pools/:pool_id", handlers.GetPool(store))
+	protected.POST("/pools/:pool_id/join", handlers.JoinPool(store))
+	protected.POST("/pools/:pool_id/leave", handlers.LeavePool(store))
+	protected.POST("/pools/:pool_id/start", handlers.StartPool(store))
+	protected.POST("/verify-email/resend", handlers.ResendEmailVerification(store))
+	protected.POST("/mfa/setup", handlers.SetupMFA(store))
+	protected.POST("/mfa/enable", handlers.EnableMFA(store))
+	protected.POST("/mfa/disable", handlers.DisableMFA(store))
+	protected.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes(store))
+	protected.POST("/mfa/verify", handlers.VerifyMFA(store))
+	protected.PATCH("/profile", handlers.UpdateProfile(store))
+	protected.GET("/velocity-limits", handlers.GetVelocityLimits(store))
+	protected.GET("/kyc", handlers.GetKYC(store))
+	protected.POST("/kyc", handlers.SubmitKYC(store))
+	protected.POST("/change-password", handlers.ChangePassword(store))
+	protected.POST("/change-email", handlers.ChangeEmail(store))
+	protected.POST("/close", handlers.CloseAccount(store))
+	protected.POST("/logout", handlers.Logout(store))
+	protected.POST("/logout-all", handlers.LogoutAll(store))
+	protected.GET("", handlers.GetUserByID(store))
 	
 	// Set up the cron job
 	c := cron.New()
 	_, err = c.AddFunc("@daily", func() {
-		jobs.AllocateIdleBalances(database.MongoClient.Database(os.Getenv("DB_NAME")))
+		jobs.AllocateIdleBalances(store)
+	})
+	if err != nil {
+		panic("Failed to add cron job: " + err.Error())
+	}
+	_, err = c.AddFunc("@daily", func() {
+		jobs.AccrueInterest(store, clock.System{})
+	})
+	if err != nil {
+		panic("Failed to add cron job: " + err.Error())
+	}
+	_, err = c.AddFunc("@hourly", func() {
+		jobs.SettleRedemptions(store, clock.System{})
+	})
+	if err != nil {
+		panic("Failed to add cron job: " + err.Error())
+	}
+	_, err = c.AddFunc("@hourly", func() {
+		jobs.RunScheduledDeposits(store, clock.System{})
+	})
+	if err != nil {
+		panic("Failed to add cron job: " + err.Error())
+	}
+	_, err = c.AddFunc("@daily", func() {
+		jobs.PostRoundUps(store, clock.System{})
+	})
+	if err != nil {
+		panic("Failed to add cron job: " + err.Error())
+	}
+	_, err = c.AddFunc("@hourly", func() {
+		jobs.RunPoolCycles(store, clock.System{})
 	})
 	if err != nil {
 		panic("Failed to add cron job: " + err.Error())
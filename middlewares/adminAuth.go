@@ -1,13 +1,15 @@
 package middlewares
 
 import (
+	"micro-savings-app/repository"
 	"micro-savings-app/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
-func AdminAuthMiddleware() gin.HandlerFunc {
+// AdminAuthMiddleware only lets staff into the admin routes
+func AdminAuthMiddleware(store *repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Check if user ID is present in the context
 		userID, exists := c.Get("user_id")
@@ -18,15 +20,16 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Fetch the user from the database
-		user, err := services.GetUserByID(userID.(string))
+		user, err := services.GetUserByID(store, userID.(string))
 		if err != nil || user == nil {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden! User not found"})
 			c.Abort()
 			return
 		}
 
-		// Check if the user is an admin
-		if !user.IsAdmin {
+		// Check if the user holds any staff role; RequirePermission checks
+		// what each route needs
+		if !user.IsStaff() {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Forbidden! Admin access required"})
 			c.Abort()
 			return
@@ -34,4 +37,4 @@ func AdminAuthMiddleware() gin.HandlerFunc {
 		// Allow the request to proceed
 		c.Next()
 	}
-}
\ No newline at end of file
+}
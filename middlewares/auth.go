@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"micro-savings-app/repository"
 	"micro-savings-app/services"
 	"net/http"
 	"strings"
@@ -9,8 +10,9 @@ import (
 )
 
 // AuthMiddleware is a middleware that checks if the request is authenticated
-// Verifies the JWT token in the Authorization header
-func AuthMiddleware() gin.HandlerFunc {
+// Verifies the JWT token in the Authorization header and that its session
+// has not been logged out
+func AuthMiddleware(store *repository.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Extract the token from the Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -35,8 +37,34 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Pass the user ID to the next handler
+		// Reject tokens whose session has been logged out
+		userID, _ := claims["user_id"].(string)
+		sessionID, _ := claims["sid"].(string)
+		session, err := services.CheckSession(c.Request.Context(), store, userID, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized! Session has ended"})
+			c.Abort()
+			return
+		}
+
+		// Pass the user, session, roles and when a two-factor code was last
+		// entered to the next handler
 		c.Set("user_id", claims["user_id"])
+		c.Set("session_id", sessionID)
+		c.Set("roles", roleClaims(claims["roles"]))
+		c.Set("mfa_verified_at", session.MFAVerifiedAt)
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// roleClaims reads the roles claim, which decodes as a list of interfaces
+func roleClaims(claim interface{}) []string {
+	list, _ := claim.([]interface{})
+	roles := make([]string, 0, len(list))
+	for _, item := range list {
+		if role, ok := item.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
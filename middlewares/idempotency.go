@@ -0,0 +1,156 @@
+package middlewares
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+	notFinalKey          = "idempotency_not_final"
+)
+
+// NotFinal tells IdempotencyMiddleware that the response is something the
+// user can clear and then retry with the same key, such as entering a
+// two-factor code, so it isn't stored for replay
+func NotFinal(c *gin.Context) {
+	c.Set(notFinalKey, true)
+}
+
+// responseRecorder keeps a copy of everything the handler writes
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware makes money-moving endpoints safe to retry. When a request
+// carries an Idempotency-Key header, the first response for that key is stored and
+// replayed for every retry with the same body; reusing the key with a different
+// body is rejected with 422. Must run after AuthMiddleware, as keys are per user.
+func IdempotencyMiddleware(store *repository.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			c.Abort()
+			return
+		}
+
+		userID, _ := c.Get("user_id")
+		userIDString, _ := userID.(string)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := context.Background()
+		now := time.Now()
+		record := models.IdempotencyRecord{
+			UserID:      userIDString,
+			Key:         key,
+			RequestHash: hashRequest(c.Request, body),
+			Status:      models.IdempotencyProcessing,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		// Claim the key. The unique index on (user_id, key) guarantees only one
+		// request with a given key ever runs the handler.
+		err = store.Idempotency.Create(ctx, &record)
+		if errors.Is(err, repository.ErrDuplicateKey) {
+			replayStoredResponse(c, store, record)
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process Idempotency-Key"})
+			c.Abort()
+			return
+		}
+
+		// A panicking handler never finished, so release the key before passing
+		// the panic on, or every retry would be turned away until it expires
+		defer func() {
+			if r := recover(); r != nil {
+				store.Idempotency.Delete(ctx, record.UserID, record.Key)
+				panic(r)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Server errors, rate limits and responses the handler marked with
+		// NotFinal are not the request's outcome, so release the key and let
+		// the client retry
+		if recorder.Status() >= http.StatusInternalServerError || recorder.Status() == http.StatusTooManyRequests ||
+			c.GetBool(notFinalKey) {
+			store.Idempotency.Delete(ctx, record.UserID, record.Key)
+			return
+		}
+
+		store.Idempotency.Complete(ctx, record.UserID, record.Key,
+			recorder.Status(), recorder.body.Bytes(), recorder.Header().Get("Content-Type"))
+	}
+}
+
+// replayStoredResponse answers a request whose key has already been used
+func replayStoredResponse(c *gin.Context, store *repository.Store, attempt models.IdempotencyRecord) {
+	defer c.Abort()
+
+	existing, err := store.Idempotency.Get(context.Background(), attempt.UserID, attempt.Key)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is being processed, retry shortly"})
+		return
+	}
+
+	if existing.RequestHash != attempt.RequestHash {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+		return
+	}
+
+	if existing.Status != models.IdempotencyCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Request with this Idempotency-Key is being processed, retry shortly"})
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(existing.ResponseStatus, existing.ContentType, existing.ResponseBody)
+}
+
+// hashRequest fingerprints the parts of a request that must match on a retry
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"micro-savings-app/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission lets the request through only if one of the roles in the
+// access token grants the permission. Must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, _ := c.Get("roles")
+		granted, _ := roles.([]string)
+		if !models.HasPermission(granted, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden! Requires " + permission + " permission"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"micro-savings-app/services"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireStepUp lets the request through only if a two-factor code was
+// entered in this session within models.StepUpTTL, so a stolen access token
+// alone can't be used for it. Must run after AuthMiddleware.
+func RequireStepUp() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.SteppedUp(MFAVerifiedAt(c), time.Now()) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":            "Forbidden! Turn on two-factor authentication and enter a code at /user/mfa/verify",
+				"step_up_required": true,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// MFAVerifiedAt is when a two-factor code was last entered in the request's
+// session, nil if never
+func MFAVerifiedAt(c *gin.Context) *time.Time {
+	value, _ := c.Get("mfa_verified_at")
+	verifiedAt, _ := value.(*time.Time)
+	return verifiedAt
+}
@@ -0,0 +1,46 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Transactions were stored with a misspelled "cretaed_at" field, so every
+// query or index on "created_at" silently matched nothing
+var renameTransactionCreatedAt = Migration{
+	Version: 1,
+	Name:    "rename_transaction_cretaed_at",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		return renameTransactionField(ctx, db, "cretaed_at", "created_at")
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		return renameTransactionField(ctx, db, "created_at", "cretaed_at")
+	},
+}
+
+func renameTransactionField(ctx context.Context, db *mongo.Database, from, to string) error {
+	transactions := db.Collection("transactions")
+	_, err := transactions.UpdateMany(ctx,
+		bson.M{from: bson.M{"$exists": true}},
+		bson.M{"$rename": bson.M{from: to}})
+	if err != nil {
+		return err
+	}
+
+	// The history index was built on the old name; database.EnsureIndexes
+	// creates the one on the new name at the next startup
+	return dropIndexIfExists(ctx, transactions, "user_id_1_"+from+"_-1__id_-1")
+}
+
+func dropIndexIfExists(ctx context.Context, collection *mongo.Collection, name string) error {
+	_, err := collection.Indexes().DropOne(ctx, name)
+	var cmdErr mongo.CommandError
+	// 27 = IndexNotFound, 26 = NamespaceNotFound
+	if errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Goal savings are held in their own balance on the user. Balance updates
+// match on the balance's currency, so users created before goals existed need
+// an empty one in their savings currency.
+var addGoalsBalance = Migration{
+	Version: 2,
+	Name:    "add_goals_balance",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		_, err := db.Collection("users").UpdateMany(ctx,
+			bson.M{"goals_balance": bson.M{"$exists": false}},
+			mongo.Pipeline{{{Key: "$set", Value: bson.M{
+				"goals_balance": bson.M{"amount": int64(0), "currency": "$savings_balance.currency"},
+			}}}})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		// Only empty balances are removed; goals still holding money must be
+		// paid out before rolling back
+		_, err := db.Collection("users").UpdateMany(ctx,
+			bson.M{"goals_balance.amount": 0},
+			bson.M{"$unset": bson.M{"goals_balance": ""}})
+		return err
+	},
+}
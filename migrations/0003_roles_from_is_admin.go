@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Staff access moved from the is_admin flag to roles. Every existing admin
+// had full access, so each becomes a superadmin.
+var rolesFromIsAdmin = Migration{
+	Version: 3,
+	Name:    "roles_from_is_admin",
+	Up: func(ctx context.Context, db *mongo.Database) error {
+		users := db.Collection("users")
+		_, err := users.UpdateMany(ctx,
+			bson.M{"is_admin": true},
+			bson.M{"$set": bson.M{"roles": bson.A{"superadmin"}}})
+		if err != nil {
+			return err
+		}
+		_, err = users.UpdateMany(ctx,
+			bson.M{"is_admin": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"is_admin": ""}})
+		return err
+	},
+	Down: func(ctx context.Context, db *mongo.Database) error {
+		// Any staff role becomes a full admin again, since the flag can't
+		// express anything narrower
+		users := db.Collection("users")
+		_, err := users.UpdateMany(ctx,
+			bson.M{"roles.0": bson.M{"$exists": true}},
+			bson.M{"$set": bson.M{"is_admin": true}, "$unset": bson.M{"roles": ""}})
+		if err != nil {
+			return err
+		}
+		_, err = users.UpdateMany(ctx,
+			bson.M{"is_admin": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"is_admin": false}, "$unset": bson.M{"roles": ""}})
+		return err
+	},
+}
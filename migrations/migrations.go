@@ -0,0 +1,219 @@
+// Package migrations holds versioned, reversible changes to the shape of the
+// data in Mongo. Applied versions are recorded in the "migrations" collection
+// so each step runs exactly once per database.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned step. Down must undo exactly what Up did.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// AppliedMigration is the record kept for every applied step
+type AppliedMigration struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+// MigrationStatus pairs a registered migration with when it was applied, if ever
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+// registry lists every migration; append new steps with the next version number
+var registry = []Migration{
+	renameTransactionCreatedAt,
+	addGoalsBalance,
+	rolesFromIsAdmin,
+}
+
+const collectionName = "migrations"
+
+var ErrUnknownVersion = errors.New("unknown migration version")
+
+// History records which migrations have been applied to a database
+type History interface {
+	// Applied returns every applied migration keyed by version
+	Applied(ctx context.Context) (map[int]AppliedMigration, error)
+	Record(ctx context.Context, applied AppliedMigration) error
+	Remove(ctx context.Context, version int) error
+}
+
+// Runner applies and reverts a set of migrations against a database
+type Runner struct {
+	DB         *mongo.Database
+	History    History
+	Migrations []Migration
+}
+
+// NewRunner returns a runner for every registered migration, recording them
+// in the database's "migrations" collection
+func NewRunner(db *mongo.Database) *Runner {
+	return &Runner{DB: db, History: mongoHistory{db.Collection(collectionName)}, Migrations: registry}
+}
+
+// Registered returns every registered migration in version order
+func Registered() []Migration {
+	return (&Runner{Migrations: registry}).sorted()
+}
+
+// Status reports every registered migration and whether it has been applied
+func Status(ctx context.Context, db *mongo.Database) ([]MigrationStatus, error) {
+	return NewRunner(db).Status(ctx)
+}
+
+// Up applies every pending registered migration
+func Up(ctx context.Context, db *mongo.Database) (int, error) {
+	return NewRunner(db).Up(ctx)
+}
+
+// Down reverts the most recently applied registered migrations
+func Down(ctx context.Context, db *mongo.Database, steps int) (int, error) {
+	return NewRunner(db).Down(ctx, steps)
+}
+
+func (r *Runner) sorted() []Migration {
+	all := append([]Migration(nil), r.Migrations...)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Status reports every migration and whether it has been applied
+func (r *Runner) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := r.History.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, m := range r.sorted() {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order, stopping at the first failure
+func (r *Runner) Up(ctx context.Context) (int, error) {
+	applied, err := r.History.Applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range r.sorted() {
+		if _, done := applied[m.Version]; done {
+			continue
+		}
+
+		log.Printf("Applying migration %d %s\n", m.Version, m.Name)
+		if err := m.Up(ctx, r.DB); err != nil {
+			return count, fmt.Errorf("migration %d %s: %w", m.Version, m.Name, err)
+		}
+		if err := r.History.Record(ctx, AppliedMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Down reverts the most recently applied migrations, newest first
+func (r *Runner) Down(ctx context.Context, steps int) (int, error) {
+	applied, err := r.History.Applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// A newer build applied steps this one can't revert; refuse rather than skip them
+	for version := range applied {
+		if !r.isRegistered(version) {
+			return 0, fmt.Errorf("%w: %d is applied but not registered", ErrUnknownVersion, version)
+		}
+	}
+
+	all := r.sorted()
+	count := 0
+	for i := len(all) - 1; i >= 0 && count < steps; i-- {
+		m := all[i]
+		if _, done := applied[m.Version]; !done {
+			continue
+		}
+
+		log.Printf("Reverting migration %d %s\n", m.Version, m.Name)
+		if err := m.Down(ctx, r.DB); err != nil {
+			return count, fmt.Errorf("revert migration %d %s: %w", m.Version, m.Name, err)
+		}
+		if err := r.History.Remove(ctx, m.Version); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (r *Runner) isRegistered(version int) bool {
+	for _, m := range r.Migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// mongoHistory keeps the applied migrations in a collection
+type mongoHistory struct {
+	collection *mongo.Collection
+}
+
+func (h mongoHistory) Applied(ctx context.Context) (map[int]AppliedMigration, error) {
+	cursor, err := h.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	var records []AppliedMigration
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int]AppliedMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+func (h mongoHistory) Record(ctx context.Context, applied AppliedMigration) error {
+	_, err := h.collection.UpdateOne(ctx,
+		bson.M{"_id": applied.Version},
+		bson.M{"$set": bson.M{"name": applied.Name, "applied_at": applied.AppliedAt}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (h mongoHistory) Remove(ctx context.Context, version int) error {
+	_, err := h.collection.DeleteOne(ctx, bson.M{"_id": version})
+	return err
+}
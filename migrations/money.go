@@ -0,0 +1,74 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// moneyFields lists every field that used to hold a float64 amount, per collection
+var moneyFields = map[string][]string{
+	"users":        {"savings_balance", "investment_balance"},
+	"transactions": {"amount"},
+}
+
+// ConvertMoneyFields rewrites legacy numeric amounts ({savings_balance: 1500.5})
+// into models.Money documents ({savings_balance: {amount: 150050, currency: "NGN"}}).
+// Documents that are already converted are left alone, so it is safe to re-run.
+func ConvertMoneyFields(ctx context.Context, db *mongo.Database, currency string) error {
+	for collectionName, fields := range moneyFields {
+		collection := db.Collection(collectionName)
+		for _, field := range fields {
+			converted, err := convertField(ctx, collection, field, currency)
+			if err != nil {
+				return fmt.Errorf("converting %s.%s: %w", collectionName, field, err)
+			}
+			log.Printf("Converted %d %s.%s values to minor units\n", converted, collectionName, field)
+		}
+	}
+	return nil
+}
+
+func convertField(ctx context.Context, collection *mongo.Collection, field, currency string) (int, error) {
+	filter := bson.M{field: bson.M{"$type": bson.A{"double", "int", "long"}}}
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	converted := 0
+	for cursor.Next(ctx) {
+		var doc bson.M
+		if err := cursor.Decode(&doc); err != nil {
+			return converted, err
+		}
+
+		var minor int64
+		switch v := doc[field].(type) {
+		case float64:
+			minor = models.MinorUnitsFromFloat(v, currency)
+		case int32:
+			minor = models.MinorUnitsFromFloat(float64(v), currency)
+		case int64:
+			minor = models.MinorUnitsFromFloat(float64(v), currency)
+		default:
+			continue
+		}
+
+		// Match on the old value too, so a concurrent write is never overwritten
+		_, err := collection.UpdateOne(ctx,
+			bson.M{"_id": doc["_id"], field: doc[field]},
+			bson.M{"$set": bson.M{field: models.NewMoney(minor, currency)}})
+		if err != nil {
+			return converted, err
+		}
+		converted++
+	}
+	return converted, cursor.Err()
+}
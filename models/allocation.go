@@ -0,0 +1,105 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// How much of an idle savings balance the allocation job moves into investments
+const (
+	SweepPercentage = "percentage"
+	SweepFixed      = "fixed"
+)
+
+// Bounds on the idle threshold an allocation policy may use
+const (
+	MinIdleDays = 1
+	MaxIdleDays = 365
+)
+
+var ErrInvalidPolicy = errors.New("invalid allocation policy")
+
+// AllocationPolicy controls how idle savings are swept into investments. One
+// policy applies to everybody; a user may replace it with their own.
+type AllocationPolicy struct {
+	IdleDays         int       `bson:"idle_days" json:"idle_days"`                             // days without a transaction before savings count as idle
+	SweepMode        string    `bson:"sweep_mode" json:"sweep_mode"`                           // percentage or fixed
+	SweepPercent     int       `bson:"sweep_percent,omitempty" json:"sweep_percent,omitempty"` // share of the sweepable balance, 1-100
+	SweepAmount      *Money    `bson:"sweep_amount,omitempty" json:"sweep_amount,omitempty"`   // most to move per run in fixed mode
+	MinLiquidBalance Money     `bson:"min_liquid_balance" json:"min_liquid_balance"`           // always left in savings
+	OptOut           bool      `bson:"opt_out" json:"opt_out"`                                 // never sweep
+	UpdatedAt        time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DefaultAllocationPolicy sweeps the whole balance after 30 idle days, which is
+// how the allocation job behaved before policies existed
+func DefaultAllocationPolicy() AllocationPolicy {
+	return AllocationPolicy{
+		IdleDays:         30,
+		SweepMode:        SweepPercentage,
+		SweepPercent:     100,
+		MinLiquidBalance: NewMoney(0, DefaultCurrency),
+	}
+}
+
+// Validate checks that a policy is usable by the allocation job
+func (p AllocationPolicy) Validate() error {
+	if p.IdleDays < MinIdleDays || p.IdleDays > MaxIdleDays {
+		return fmt.Errorf("%w: idle_days must be between 1 and 365", ErrInvalidPolicy)
+	}
+	if p.MinLiquidBalance.IsNegative() {
+		return fmt.Errorf("%w: min_liquid_balance cannot be negative", ErrInvalidPolicy)
+	}
+
+	switch p.SweepMode {
+	case SweepPercentage:
+		if p.SweepPercent < 1 || p.SweepPercent > 100 {
+			return fmt.Errorf("%w: sweep_percent must be between 1 and 100", ErrInvalidPolicy)
+		}
+	case SweepFixed:
+		if p.SweepAmount == nil || !p.SweepAmount.IsPositive() {
+			return fmt.Errorf("%w: sweep_amount must be positive", ErrInvalidPolicy)
+		}
+		if !p.SweepAmount.SameCurrency(p.MinLiquidBalance) {
+			return fmt.Errorf("%w: sweep_amount and min_liquid_balance must share a currency", ErrInvalidPolicy)
+		}
+	default:
+		return fmt.Errorf("%w: sweep_mode must be percentage or fixed", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// IsIdle reports whether a balance last used at lastTransactionAt is idle at now
+func (p AllocationPolicy) IsIdle(lastTransactionAt, now time.Time) bool {
+	return lastTransactionAt.Before(now.AddDate(0, 0, -p.IdleDays))
+}
+
+// SweepFrom works out how much of a savings balance to move into investments.
+// The result is zero when the user opted out, the balance does not exceed the
+// liquid minimum, or the policy is held in another currency.
+func (p AllocationPolicy) SweepFrom(balance Money) Money {
+	none := NewMoney(0, balance.CurrencyCode())
+	if p.OptOut || !balance.SameCurrency(p.MinLiquidBalance) {
+		return none
+	}
+
+	available := balance.Sub(p.MinLiquidBalance)
+	if !available.IsPositive() {
+		return none
+	}
+
+	switch p.SweepMode {
+	case SweepFixed:
+		if p.SweepAmount == nil || !p.SweepAmount.SameCurrency(balance) {
+			return none
+		}
+		if p.SweepAmount.Cmp(available) < 0 {
+			return *p.SweepAmount
+		}
+		return available
+	default:
+		// Round down so we never sweep more than the policy allows
+		return NewMoney(available.Amount/100*int64(p.SweepPercent)+available.Amount%100*int64(p.SweepPercent)/100, balance.CurrencyCode())
+	}
+}
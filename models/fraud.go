@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What fraud screening decides about a withdrawal or transfer, least severe
+// first
+const (
+	FraudAllow = "allow"
+	FraudHold  = "hold"  // set the money aside until a reviewer decides
+	FraudBlock = "block" // refuse it outright
+)
+
+// Review status of a withdrawal or transfer stopped by fraud screening
+const (
+	ReviewPending  = "pending"
+	ReviewApproved = "approved" // the money was sent on
+	ReviewRejected = "rejected" // the money went back to the user's savings
+	ReviewBlocked  = "blocked"  // refused outright; kept on file, nothing to decide
+)
+
+// What a fraud review is holding
+const (
+	HeldWithdrawal       = "withdrawal"
+	HeldTransfer         = "transfer"
+	HeldPoolContribution = "pool_contribution"
+)
+
+// FraudFlag is a screening rule that did not allow a movement, and why
+type FraudFlag struct {
+	Rule   string `bson:"rule" json:"rule"`
+	Action string `bson:"action" json:"action"`
+	Reason string `bson:"reason" json:"reason"`
+}
+
+// FraudReview is a withdrawal, transfer or pool contribution held by fraud
+// screening. The amount sits in the held funds ledger account until a
+// reviewer approves it (it is sent on) or rejects it (it goes back to the
+// user's savings).
+type FraudReview struct {
+	ID          primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Kind        string              `bson:"kind" json:"kind"` // withdrawal, transfer or pool_contribution
+	Amount      Money               `bson:"amount" json:"amount"`
+	RecipientID primitive.ObjectID  `bson:"recipient_id,omitempty" json:"recipient_id,omitempty"` // set on transfers
+	GoalID      primitive.ObjectID  `bson:"goal_id,omitempty" json:"goal_id,omitempty"`           // set on withdrawals from a goal
+	PoolID      primitive.ObjectID  `bson:"pool_id,omitempty" json:"pool_id,omitempty"`           // set on pool contributions
+	Note        string              `bson:"note,omitempty" json:"note,omitempty"`                 // the transfer's note
+	Flags       []FraudFlag         `bson:"flags" json:"flags"`
+	Status      string              `bson:"status" json:"status"`
+	Reference   string              `bson:"reference" json:"reference"` // journal reference of the hold
+	ReviewerID  *primitive.ObjectID `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"`
+	ReviewNote  string              `bson:"review_note,omitempty" json:"review_note,omitempty"`
+	CreatedAt   time.Time           `bson:"created_at" json:"created_at"`
+	ReviewedAt  *time.Time          `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+}
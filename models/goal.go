@@ -0,0 +1,55 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Goal is money a user sets aside towards a target. Goal savings are held in
+// their own ledger account, apart from the savings balance.
+type Goal struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID       primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name         string             `bson:"name" json:"name"`
+	TargetAmount Money              `bson:"target_amount" json:"target_amount"`
+	SavedAmount  Money              `bson:"saved_amount" json:"saved_amount"`
+	Deadline     *time.Time         `bson:"deadline,omitempty" json:"deadline,omitempty"`
+	LockedUntil  *time.Time         `bson:"locked_until,omitempty" json:"locked_until,omitempty"` // no withdrawals before this
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt    time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// IsLocked reports whether the goal's savings can't be withdrawn yet
+func (g Goal) IsLocked(now time.Time) bool {
+	return g.LockedUntil != nil && now.Before(*g.LockedUntil)
+}
+
+// IsComplete reports whether the target has been reached
+func (g Goal) IsComplete() bool {
+	return g.SavedAmount.Cmp(g.TargetAmount) >= 0
+}
+
+// ProgressPercent is how much of the target has been saved, capped at 100
+func (g Goal) ProgressPercent() float64 {
+	if !g.TargetAmount.IsPositive() || g.IsComplete() {
+		return 100
+	}
+	return math.Floor(float64(g.SavedAmount.Amount)*10000/float64(g.TargetAmount.Amount)) / 100
+}
+
+// ProjectedCompletion estimates when the target will be reached if saving
+// continues at the average daily rate since the goal was created. It is nil
+// until something has been saved, and for completed goals.
+func (g Goal) ProjectedCompletion(now time.Time) *time.Time {
+	if !g.SavedAmount.IsPositive() || g.IsComplete() {
+		return nil
+	}
+
+	elapsedDays := math.Max(now.Sub(g.CreatedAt).Hours()/24, 1)
+	perDay := float64(g.SavedAmount.Amount) / elapsedDays
+	remaining := float64(g.TargetAmount.Sub(g.SavedAmount).Amount)
+	projected := now.AddDate(0, 0, int(math.Ceil(remaining/perDay)))
+	return &projected
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	IdempotencyProcessing = "processing"
+	IdempotencyCompleted  = "completed"
+)
+
+// IdempotencyRecord remembers the outcome of a request sent with an Idempotency-Key
+// so a retry of the same request gets the same response instead of running twice
+type IdempotencyRecord struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty"`
+	UserID         string             `bson:"user_id"`
+	Key            string             `bson:"key"`
+	RequestHash    string             `bson:"request_hash"`
+	Status         string             `bson:"status"` // processing or completed
+	ResponseStatus int                `bson:"response_status,omitempty"`
+	ResponseBody   []byte             `bson:"response_body,omitempty"`
+	ContentType    string             `bson:"content_type,omitempty"`
+	CreatedAt      time.Time          `bson:"created_at"` // expired by a TTL index
+	UpdatedAt      time.Time          `bson:"updated_at"`
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// How often accrued interest is added to the investment balance
+const (
+	CompoundDaily   = "daily"
+	CompoundMonthly = "monthly"
+)
+
+// DefaultProductTier applies to users who have not been put on another tier
+const DefaultProductTier = "standard"
+
+// Interest accrues in millionths of a minor unit so the fractions earned each
+// day are not lost to rounding; only whole minor units are ever paid out
+const InterestPrecision = 1_000_000
+
+// Interest uses the Actual/365 (Fixed) day count: every calendar day earns
+// 1/365th of the annual rate, leap years included
+const DaysPerYear = 365
+
+var ErrInvalidTier = errors.New("invalid interest tier")
+
+// InterestTier is an investment product with its own annual rate and the
+// terms for taking money back out of it
+type InterestTier struct {
+	Name            string `bson:"name" json:"name"`
+	AnnualRateBps   int64  `bson:"annual_rate_bps" json:"annual_rate_bps"`     // 1250 = 12.5% a year
+	Compounding     string `bson:"compounding" json:"compounding"`             // daily or monthly
+	NoticeDays      int    `bson:"notice_days" json:"notice_days"`             // wait before a redemption settles, 0 for instant
+	EarlyPenaltyBps int64  `bson:"early_penalty_bps" json:"early_penalty_bps"` // charged to skip the notice period
+}
+
+// DefaultInterestTiers apply until an admin configures tiers
+func DefaultInterestTiers() []InterestTier {
+	return []InterestTier{
+		{Name: DefaultProductTier, AnnualRateBps: 800, Compounding: CompoundMonthly},
+		{Name: "premium", AnnualRateBps: 1200, Compounding: CompoundMonthly, NoticeDays: 30, EarlyPenaltyBps: 200},
+	}
+}
+
+// ValidateInterestTiers checks a full set of tiers, which must include the default tier
+func ValidateInterestTiers(tiers []InterestTier) error {
+	seen := map[string]bool{}
+	for _, tier := range tiers {
+		if tier.Name == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidTier)
+		}
+		if seen[tier.Name] {
+			return fmt.Errorf("%w: duplicate tier %q", ErrInvalidTier, tier.Name)
+		}
+		seen[tier.Name] = true
+		if tier.AnnualRateBps < 0 || tier.AnnualRateBps > 10000 {
+			return fmt.Errorf("%w: annual_rate_bps must be between 0 and 10000", ErrInvalidTier)
+		}
+		if tier.Compounding != CompoundDaily && tier.Compounding != CompoundMonthly {
+			return fmt.Errorf("%w: compounding must be daily or monthly", ErrInvalidTier)
+		}
+		if tier.NoticeDays < 0 || tier.NoticeDays > 365 {
+			return fmt.Errorf("%w: notice_days must be between 0 and 365", ErrInvalidTier)
+		}
+		if tier.EarlyPenaltyBps < 0 || tier.EarlyPenaltyBps > 10000 {
+			return fmt.Errorf("%w: early_penalty_bps must be between 0 and 10000", ErrInvalidTier)
+		}
+	}
+	if !seen[DefaultProductTier] {
+		return fmt.Errorf("%w: the %q tier is required", ErrInvalidTier, DefaultProductTier)
+	}
+	return nil
+}
+
+// DailyInterest is what a balance earns in one day, in millionths of a minor unit
+func (t InterestTier) DailyInterest(balance Money) int64 {
+	if !balance.IsPositive() {
+		return 0
+	}
+	// balance * rate/10000 / 365 * InterestPrecision, in big ints so large
+	// balances can't overflow
+	n := new(big.Int).Mul(big.NewInt(balance.Amount), big.NewInt(t.AnnualRateBps))
+	n.Mul(n, big.NewInt(InterestPrecision))
+	n.Quo(n, big.NewInt(10000*DaysPerYear))
+	return n.Int64()
+}
+
+// EarlyPenalty is the charge for redeeming amount without serving the notice
+// period, rounded down to a minor unit
+func (t InterestTier) EarlyPenalty(amount Money) Money {
+	n := new(big.Int).Mul(big.NewInt(amount.Amount), big.NewInt(t.EarlyPenaltyBps))
+	n.Quo(n, big.NewInt(10000))
+	return NewMoney(n.Int64(), amount.CurrencyCode())
+}
+
+// CapitalisesAfter reports whether interest accrued up to and including day
+// is added to the balance at the end of that day
+func (t InterestTier) CapitalisesAfter(day time.Time) bool {
+	if t.Compounding == CompoundDaily {
+		return true
+	}
+	return day.AddDate(0, 0, 1).Day() == 1
+}
+
+// InterestAccrual tracks interest earned on a user's investment balance but
+// not yet paid into it
+type InterestAccrual struct {
+	Accrued        int64     `bson:"accrued"`         // millionths of a minor unit
+	AccruedThrough time.Time `bson:"accrued_through"` // last whole day (UTC) accrued
+}
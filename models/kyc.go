@@ -0,0 +1,94 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KYC tiers. Every user starts at tier 0 and moves up one tier at a time as
+// a reviewer approves what they submit.
+const (
+	KYCTier0   = 0 // email address only
+	KYCTier1   = 1 // BVN or NIN
+	KYCTier2   = 2 // plus a government-issued photo ID
+	KYCTier3   = 3 // plus proof of address
+	KYCTierMax = KYCTier3
+)
+
+// Review status of a KYC submission
+const (
+	KYCPending  = "pending"
+	KYCApproved = "approved"
+	KYCRejected = "rejected"
+)
+
+// Identifiers accepted for tier 1. Both are 11 digits.
+const (
+	IdentifierBVN = "bvn"
+	IdentifierNIN = "nin"
+)
+
+// Documents accepted for tiers 2 and 3
+const (
+	DocumentPassport       = "passport"
+	DocumentDriversLicence = "drivers_licence"
+	DocumentNationalID     = "national_id"
+	DocumentVotersCard     = "voters_card"
+	DocumentUtilityBill    = "utility_bill"
+	DocumentBankStatement  = "bank_statement"
+)
+
+// KYCDocumentsForTier lists the document types that satisfy each tier
+var KYCDocumentsForTier = map[int][]string{
+	KYCTier2: {DocumentPassport, DocumentDriversLicence, DocumentNationalID, DocumentVotersCard},
+	KYCTier3: {DocumentUtilityBill, DocumentBankStatement},
+}
+
+// KYCDocument describes an uploaded document. The file itself lives in
+// document storage; only its reference is kept here.
+type KYCDocument struct {
+	Type       string     `bson:"type" json:"type"`
+	Number     string     `bson:"number,omitempty" json:"number,omitempty"`
+	FileRef    string     `bson:"file_ref" json:"file_ref"`
+	IssuedAt   *time.Time `bson:"issued_at,omitempty" json:"issued_at,omitempty"`
+	ExpiresAt  *time.Time `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	UploadedAt time.Time  `bson:"uploaded_at" json:"uploaded_at"`
+}
+
+// KYCSubmission asks for a user to be moved up to the next tier
+type KYCSubmission struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID  `bson:"user_id" json:"user_id"`
+	Tier           int                 `bson:"tier" json:"tier"`                                            // the tier asked for
+	IdentifierType string              `bson:"identifier_type,omitempty" json:"identifier_type,omitempty"`  // bvn or nin, for tier 1
+	Identifier     string              `bson:"identifier,omitempty" json:"-"`                               // never sent back in full
+	IdentifierLast string              `bson:"identifier_last,omitempty" json:"identifier_last4,omitempty"` // last four digits, for display
+	Documents      []KYCDocument       `bson:"documents,omitempty" json:"documents,omitempty"`
+	Status         string              `bson:"status" json:"status"`
+	ReviewerID     *primitive.ObjectID `bson:"reviewer_id,omitempty" json:"reviewer_id,omitempty"`
+	ReviewNote     string              `bson:"review_note,omitempty" json:"review_note,omitempty"`
+	SubmittedAt    time.Time           `bson:"submitted_at" json:"submitted_at"`
+	ReviewedAt     *time.Time          `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+}
+
+// KYCTierLimits caps what a user at a tier can move. MaxBalance is the most
+// they can hold across savings, goals and investments; nil means no cap.
+type KYCTierLimits struct {
+	MaxDeposit    Money  `json:"max_deposit"`    // largest single deposit
+	MaxWithdrawal Money  `json:"max_withdrawal"` // largest single withdrawal or transfer out
+	MaxBalance    *Money `json:"max_balance,omitempty"`
+}
+
+// KYCLimits are the caps for each tier, in DefaultCurrency. Amounts in any
+// other currency need the top tier.
+var KYCLimits = map[int]KYCTierLimits{
+	KYCTier0: {MaxDeposit: NewMoney(1000000, DefaultCurrency), MaxWithdrawal: NewMoney(1000000, DefaultCurrency), MaxBalance: moneyPtr(NewMoney(5000000, DefaultCurrency))},
+	KYCTier1: {MaxDeposit: NewMoney(5000000, DefaultCurrency), MaxWithdrawal: NewMoney(5000000, DefaultCurrency), MaxBalance: moneyPtr(NewMoney(30000000, DefaultCurrency))},
+	KYCTier2: {MaxDeposit: NewMoney(20000000, DefaultCurrency), MaxWithdrawal: NewMoney(20000000, DefaultCurrency), MaxBalance: moneyPtr(NewMoney(50000000, DefaultCurrency))},
+	KYCTier3: {MaxDeposit: NewMoney(500000000, DefaultCurrency), MaxWithdrawal: NewMoney(500000000, DefaultCurrency)},
+}
+
+func moneyPtr(m Money) *Money {
+	return &m
+}
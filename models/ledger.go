@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AccountType decides which side of a journal line increases an account's balance
+type AccountType string
+
+const (
+	Asset     AccountType = "asset"     // debit-normal, e.g. the cash we hold
+	Liability AccountType = "liability" // credit-normal, e.g. what we owe a user
+	Equity    AccountType = "equity"    // credit-normal, e.g. opening balances
+	Expense   AccountType = "expense"   // debit-normal, e.g. interest we pay out
+	Income    AccountType = "income"    // credit-normal, e.g. fees we charge
+)
+
+// DebitNormal reports whether debits increase the balance of this account type
+func (t AccountType) DebitNormal() bool {
+	return t == Asset || t == Expense
+}
+
+// Direction is the side of a journal line
+type Direction string
+
+const (
+	Debit  Direction = "debit"
+	Credit Direction = "credit"
+)
+
+// LedgerAccount is a system-owned ledger account such as external cash.
+// User accounts are not stored here; their cached balances live on the user document.
+type LedgerAccount struct {
+	ID        string      `bson:"_id"`
+	Type      AccountType `bson:"type"`
+	Balance   Money       `bson:"balance"`
+	CreatedAt time.Time   `bson:"created_at"`
+	UpdatedAt time.Time   `bson:"updated_at"`
+}
+
+// JournalLine moves an amount into or out of one account
+type JournalLine struct {
+	AccountID string    `bson:"account_id"`
+	Direction Direction `bson:"direction"`
+	Amount    Money     `bson:"amount"`
+}
+
+// JournalEntry is a balanced set of journal lines: total debits equal total credits
+type JournalEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty"`
+	Reference   string             `bson:"reference"` // unique, e.g. the transaction ID
+	Description string             `bson:"description"`
+	Lines       []JournalLine      `bson:"lines"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// LoginFailureWindow is how long failed logins are remembered. A failure
+// after a quiet spell this long starts the count again.
+const LoginFailureWindow = 24 * time.Hour
+
+// LoginThrottlePolicy is how hard failed logins are slowed down: after
+// FreeAttempts failures each further attempt waits twice as long as the one
+// before, up to MaxBackoff, and LockoutAfter failures lock the key for
+// LockoutFor
+type LoginThrottlePolicy struct {
+	FreeAttempts int
+	MaxBackoff   time.Duration
+	LockoutAfter int
+	LockoutFor   time.Duration
+}
+
+// Failed logins are counted per account and per IP address. Many customers
+// can share an IP behind a carrier's NAT, so the IP policy is looser.
+var (
+	AccountLoginPolicy = LoginThrottlePolicy{FreeAttempts: 3, MaxBackoff: 5 * time.Minute, LockoutAfter: 10, LockoutFor: 30 * time.Minute}
+	IPLoginPolicy      = LoginThrottlePolicy{FreeAttempts: 20, MaxBackoff: 5 * time.Minute, LockoutAfter: 100, LockoutFor: time.Hour}
+)
+
+// MFACodePolicy throttles wrong two-factor codes per user. A six-digit code
+// is far easier to guess than a password, so it locks sooner.
+var MFACodePolicy = LoginThrottlePolicy{FreeAttempts: 3, MaxBackoff: 5 * time.Minute, LockoutAfter: 5, LockoutFor: 30 * time.Minute}
+
+// Backoff is how long to wait after the given number of failures
+func (p LoginThrottlePolicy) Backoff(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+	backoff := time.Second
+	for i := p.FreeAttempts; i < failures && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// LoginThrottle counts recent failed logins for an account or IP address
+type LoginThrottle struct {
+	Key           string     `bson:"_id"` // "account:<email>", "ip:<address>" or "mfa:<user id>"
+	Failures      int        `bson:"failures"`
+	LastFailureAt time.Time  `bson:"last_failure_at"` // removed by a TTL index once past the window
+	LockedUntil   *time.Time `bson:"locked_until,omitempty"`
+}
+
+// BlockedUntil is when the next login attempt may be made under the policy
+func (t LoginThrottle) BlockedUntil(policy LoginThrottlePolicy) time.Time {
+	until := t.LastFailureAt.Add(policy.Backoff(t.Failures))
+	if t.LockedUntil != nil && t.LockedUntil.After(until) {
+		until = *t.LockedUntil
+	}
+	return until
+}
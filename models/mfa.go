@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// How many recovery codes a user gets when they turn on two-factor
+// authentication or ask for new ones
+const RecoveryCodeCount = 10
+
+// StepUpTTL is how long after entering a code a session may make sensitive
+// requests, such as large withdrawals or anything under /admin
+const StepUpTTL = 10 * time.Minute
+
+// MFAChallengeTTL is how long a user has to enter their code after their
+// password is accepted
+const MFAChallengeTTL = 5 * time.Minute
+
+// DefaultStepUpWithdrawalThreshold applies until an admin sets one:
+// withdrawals above it need a fresh code from users with two-factor on
+var DefaultStepUpWithdrawalThreshold = NewMoney(5000000, DefaultCurrency)
+
+// MFASettings is a user's two-factor authentication setup. The secret is
+// stored once setup starts, but codes are only asked for once Enabled.
+type MFASettings struct {
+	Secret        string     `bson:"secret"` // base32 TOTP secret
+	Enabled       bool       `bson:"enabled"`
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty"`
+	RecoveryCodes []string   `bson:"recovery_codes,omitempty"` // hashes of the codes not yet used
+	LastStep      int64      `bson:"last_step"`                // last time step accepted, so a code can't be replayed
+}
+
+// MFAEnabled reports whether the user must enter a code to sign in
+func (u User) MFAEnabled() bool {
+	return u.MFA != nil && u.MFA.Enabled
+}
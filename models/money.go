@@ -0,0 +1,268 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is used whenever an amount is supplied without a currency code
+const DefaultCurrency = "NGN"
+
+// currencyExponents holds the number of minor-unit digits for each supported currency
+var currencyExponents = map[string]int{
+	"NGN": 2,
+	"USD": 2,
+	"GBP": 2,
+	"EUR": 2,
+}
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrTooManyDecimals  = errors.New("amount has too many decimal places")
+	ErrUnknownCurrency  = errors.New("unsupported currency")
+	ErrAmountOutOfRange = errors.New("amount is out of range")
+	ErrCurrencyMismatch = errors.New("currency mismatch")
+)
+
+// Money is an amount held in integer minor units (e.g. kobo) of a currency.
+// It is stored in Mongo as {amount, currency} and travels over JSON as
+// {"amount": "1500.50", "currency": "NGN"} so no value ever passes through a float.
+type Money struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+// IsSupportedCurrency reports whether amounts can be held in the given currency code
+func IsSupportedCurrency(currency string) bool {
+	_, ok := currencyExponents[currency]
+	return ok
+}
+
+// NewMoney creates a Money value from minor units
+func NewMoney(minor int64, currency string) Money {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return Money{Amount: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal string such as "1500.50" into minor units,
+// rejecting more decimal places than the currency allows
+func ParseMoney(s string, currency string) (Money, error) {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	currency = strings.ToUpper(currency)
+	exp, ok := currencyExponents[currency]
+	if !ok {
+		return Money{}, ErrUnknownCurrency
+	}
+
+	s = strings.TrimSpace(s)
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" && frac == "" || hasPoint && frac == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	// Trailing zeros beyond the minor unit are harmless ("1.500"), anything else is not
+	frac = strings.TrimRight(frac, "0")
+	if len(frac) > exp {
+		return Money{}, ErrTooManyDecimals
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, ErrAmountOutOfRange
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// currency returns the currency code, treating the zero value as the default currency
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+// CurrencyCode returns the currency code, treating the zero value as the default currency
+func (m Money) CurrencyCode() string {
+	return m.currency()
+}
+
+// String formats the amount as a decimal string without the currency code
+func (m Money) String() string {
+	exp := currencyExponents[m.currency()]
+	if exp == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	sign := ""
+	// Work in uint64 so math.MinInt64 can be negated safely
+	abs := uint64(m.Amount)
+	if m.Amount < 0 {
+		sign = "-"
+		abs = uint64(-(m.Amount + 1)) + 1
+	}
+	digits := strconv.FormatUint(abs, 10)
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	point := len(digits) - exp
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// SameCurrency reports whether both amounts are in the same currency
+func (m Money) SameCurrency(other Money) bool {
+	return m.currency() == other.currency()
+}
+
+func (m Money) mustMatch(other Money) {
+	if !m.SameCurrency(other) {
+		panic(fmt.Sprintf("%v: %s and %s", ErrCurrencyMismatch, m.currency(), other.currency()))
+	}
+}
+
+// Add returns m + other. Both amounts must share a currency.
+func (m Money) Add(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount + other.Amount, Currency: m.currency()}
+}
+
+// Sub returns m - other. Both amounts must share a currency.
+func (m Money) Sub(other Money) Money {
+	m.mustMatch(other)
+	return Money{Amount: m.Amount - other.Amount, Currency: m.currency()}
+}
+
+// Neg returns the amount with its sign flipped
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.currency()}
+}
+
+// Cmp compares two amounts of the same currency and returns -1, 0 or 1
+func (m Money) Cmp(other Money) int {
+	m.mustMatch(other)
+	switch {
+	case m.Amount < other.Amount:
+		return -1
+	case m.Amount > other.Amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (m Money) IsZero() bool     { return m.Amount == 0 }
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// MarshalJSON renders the amount as a decimal string alongside its currency
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.String(), m.currency()})
+}
+
+// UnmarshalJSON accepts a decimal string ("12.50"), a plain JSON number (12.50)
+// or the object form produced by MarshalJSON. Numbers are parsed from their
+// literal text, never through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '{' {
+		var obj struct {
+			Amount   json.RawMessage `json:"amount"`
+			Currency string          `json:"currency"`
+		}
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		text, err := amountText(obj.Amount)
+		if err != nil {
+			return err
+		}
+		parsed, err := ParseMoney(text, obj.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	}
+
+	text, err := amountText(data)
+	if err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(text, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// amountText extracts the decimal text from a JSON string or number literal
+func amountText(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "", ErrInvalidAmount
+	}
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", err
+		}
+		return s, nil
+	}
+	// Exponent notation can't be represented exactly, so only plain decimals are allowed
+	if bytes.ContainsAny(raw, "eE") {
+		return "", ErrInvalidAmount
+	}
+	return string(raw), nil
+}
+
+// MinorUnitsFromFloat converts a legacy float64 amount into minor units,
+// rounding to the nearest minor unit. Only used when migrating old data.
+func MinorUnitsFromFloat(amount float64, currency string) int64 {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	return int64(math.Round(amount * math.Pow10(currencyExponents[currency])))
+}
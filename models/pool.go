@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PoolForming   = "forming"   // taking members
+	PoolActive    = "active"    // collecting and paying out each cycle
+	PoolSettling  = "settling"  // every member has had a turn; collecting what is still owed
+	PoolCompleted = "completed" // every member has paid in and been paid out in full
+	PoolCancelled = "cancelled" // closed before it started
+)
+
+// Bounds on the size of a pool
+const (
+	MinPoolMembers = 2
+	MaxPoolMembers = 50
+)
+
+// PoolMember is one member of a pool and where they stand in it
+type PoolMember struct {
+	UserID              primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Position            int                `bson:"position" json:"position"` // turn in the payout rotation, from 1
+	JoinedAt            time.Time          `bson:"joined_at" json:"joined_at"`
+	PaidOut             bool               `bson:"paid_out" json:"paid_out"` // has had their turn
+	Arrears             Money              `bson:"arrears" json:"arrears"`   // contributions missed and not yet made up
+	Owed                Money              `bson:"owed" json:"owed"`         // payout still due because others paid short
+	MissedContributions int                `bson:"missed_contributions" json:"missed_contributions"`
+}
+
+// Pool is a rotating savings group (ajo/esusu): every cycle each member pays
+// in the contribution and one member, in rotation order, takes the whole pot.
+// Money collected and not yet paid out is held in the pool's ledger account.
+type Pool struct {
+	ID                 primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name               string             `bson:"name" json:"name"`
+	CreatorID          primitive.ObjectID `bson:"creator_id" json:"creator_id"`
+	ContributionAmount Money              `bson:"contribution_amount" json:"contribution_amount"`
+	Cycle              string             `bson:"cycle" json:"cycle"` // daily, weekly or monthly
+	MaxMembers         int                `bson:"max_members" json:"max_members"`
+	Members            []PoolMember       `bson:"members" json:"members"` // in rotation order once started
+	Status             string             `bson:"status" json:"status"`
+	Held               Money              `bson:"held" json:"held"`                             // collected and not yet paid out
+	CurrentCycle       int                `bson:"current_cycle" json:"current_cycle"`           // cycles run so far
+	StartAt            *time.Time         `bson:"start_at,omitempty" json:"start_at,omitempty"` // first cycle
+	NextCycleAt        *time.Time         `bson:"next_cycle_at,omitempty" json:"next_cycle_at,omitempty"`
+	CreatedAt          time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt          time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// Member returns the index of a user in the pool's members, or -1
+func (p Pool) Member(userID primitive.ObjectID) int {
+	for i, member := range p.Members {
+		if member.UserID == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Pot is what the recipient of a cycle is paid when everyone contributes
+func (p Pool) Pot() Money {
+	return NewMoney(p.ContributionAmount.Amount*int64(len(p.Members)), p.ContributionAmount.CurrencyCode())
+}
+
+// CycleAt is when the nth cycle (from 0) runs
+func (p Pool) CycleAt(n int) time.Time {
+	if p.StartAt == nil {
+		return time.Time{}
+	}
+	return occurrenceAt(*p.StartAt, p.Cycle, n)
+}
@@ -0,0 +1,49 @@
+package models
+
+// MinimumAge is how old a user must be to hold an account
+const MinimumAge = 18
+
+// DateOfBirthLayout is the format dates of birth are given and stored in
+const DateOfBirthLayout = "2006-01-02"
+
+// Address is a user's postal address
+type Address struct {
+	Line1      string `bson:"line1" json:"line1"`
+	Line2      string `bson:"line2,omitempty" json:"line2,omitempty"`
+	City       string `bson:"city" json:"city"`
+	State      string `bson:"state,omitempty" json:"state,omitempty"`
+	PostalCode string `bson:"postal_code,omitempty" json:"postal_code,omitempty"`
+	Country    string `bson:"country" json:"country"` // ISO 3166-1 alpha-2
+}
+
+// Profile is the personal details a user can change themselves
+type Profile struct {
+	Name        string
+	Phone       string
+	DateOfBirth string
+	Address     *Address
+}
+
+// Profile returns the user's current personal details
+func (u User) Profile() Profile {
+	return Profile{Name: u.Name, Phone: u.Phone, DateOfBirth: u.DateOfBirth, Address: u.Address}
+}
+
+// UserDetails is a user with the personal details that are left out when a
+// User is written as JSON. Only show it to the user themselves or to staff.
+type UserDetails struct {
+	User
+	Phone       string
+	DateOfBirth string
+	Address     *Address
+}
+
+// Details returns the user with their personal details
+func (u User) Details() UserDetails {
+	return UserDetails{User: u, Phone: u.Phone, DateOfBirth: u.DateOfBirth, Address: u.Address}
+}
+
+// IsClosed reports whether the user has closed their account
+func (u User) IsClosed() bool {
+	return u.ClosedAt != nil
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	RedemptionPending = "pending" // waiting out the notice period
+	RedemptionSettled = "settled"
+	RedemptionFailed  = "failed"
+)
+
+// RedemptionRequest asks to move money from the investment balance back to
+// savings. It settles once its notice period has passed, or straight away if
+// the tier has none or the user paid the early redemption penalty.
+type RedemptionRequest struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Full          bool               `bson:"full" json:"full"`       // redeem whatever is invested at settlement
+	Amount        Money              `bson:"amount" json:"amount"`   // requested, or settled for a full redemption
+	Early         bool               `bson:"early" json:"early"`     // skipped the notice period
+	Penalty       Money              `bson:"penalty" json:"penalty"` // kept from the amount for redeeming early
+	Status        string             `bson:"status" json:"status"`   // pending, settled or failed
+	FailureReason string             `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+	TransactionID primitive.ObjectID `bson:"transaction_id,omitempty" json:"transaction_id,omitempty"` // the redemption row in history
+	RequestedAt   time.Time          `bson:"requested_at" json:"requested_at"`
+	SettleAt      time.Time          `bson:"settle_at" json:"settle_at"`
+	SettledAt     *time.Time         `bson:"settled_at,omitempty" json:"settled_at,omitempty"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "sort"
+
+// Staff roles. A user with no roles is a customer.
+const (
+	RoleSupport    = "support"
+	RoleFinance    = "finance"
+	RoleCompliance = "compliance"
+	RoleSuperAdmin = "superadmin"
+)
+
+// Permissions checked by the admin routes
+const (
+	PermUsersRead      = "users:read"      // look up users and platform statistics
+	PermUsersManage    = "users:manage"    // change a user's product tier or allocation policy
+	PermLedgerRead     = "ledger:read"     // reconcile balances against the ledger
+	PermBalancesAdjust = "balances:adjust" // move money on a user's behalf
+	PermSettingsManage = "settings:manage" // change platform-wide policies and rates
+	PermKYCReview      = "kyc:review"      // approve or reject identity verification
+	PermFraudReview    = "fraud:review"    // release or reject payments held by fraud screening
+	PermAdminsManage   = "admins:manage"   // grant and revoke staff roles
+)
+
+// RolePermissions is what each role may do. Superadmins may do everything.
+var RolePermissions = map[string][]string{
+	RoleSupport:    {PermUsersRead},
+	RoleFinance:    {PermUsersRead, PermUsersManage, PermLedgerRead, PermBalancesAdjust, PermSettingsManage},
+	RoleCompliance: {PermUsersRead, PermLedgerRead, PermKYCReview, PermFraudReview},
+	RoleSuperAdmin: {PermUsersRead, PermUsersManage, PermLedgerRead, PermBalancesAdjust, PermSettingsManage, PermKYCReview, PermFraudReview, PermAdminsManage},
+}
+
+// ValidRole reports whether role is one of the staff roles
+func ValidRole(role string) bool {
+	_, ok := RolePermissions[role]
+	return ok
+}
+
+// HasPermission reports whether any of the roles grants the permission
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range RolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PermissionsFor lists everything the roles grant, sorted and without repeats
+func PermissionsFor(roles []string) []string {
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range RolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
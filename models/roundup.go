@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RoundUpIncrements are the whole-currency amounts a purchase can be rounded
+// up to the next multiple of
+var RoundUpIncrements = []int64{100, 1000}
+
+// The most a rule may multiply each round-up by
+const MaxRoundUpMultiplier = 10
+
+var ErrInvalidRoundUpRule = errors.New("invalid round-up rule")
+
+// RoundUpRule saves the spare change from a user's card purchases: each
+// purchase is rounded up to the next multiple of Nearest and the difference,
+// times Multiplier, goes into savings
+type RoundUpRule struct {
+	Nearest    int64     `bson:"nearest" json:"nearest"`                         // 100 or 1000 in whole currency units
+	Multiplier int       `bson:"multiplier" json:"multiplier"`                   // 1-10
+	DailyCap   *Money    `bson:"daily_cap,omitempty" json:"daily_cap,omitempty"` // most saved per day, no limit if unset
+	UpdatedAt  time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// Validate checks that a rule can be applied to purchases
+func (r RoundUpRule) Validate() error {
+	valid := false
+	for _, increment := range RoundUpIncrements {
+		valid = valid || r.Nearest == increment
+	}
+	if !valid {
+		return fmt.Errorf("%w: nearest must be 100 or 1000", ErrInvalidRoundUpRule)
+	}
+	if r.Multiplier < 1 || r.Multiplier > MaxRoundUpMultiplier {
+		return fmt.Errorf("%w: multiplier must be between 1 and %d", ErrInvalidRoundUpRule, MaxRoundUpMultiplier)
+	}
+	if r.DailyCap != nil && !r.DailyCap.IsPositive() {
+		return fmt.Errorf("%w: daily_cap must be positive", ErrInvalidRoundUpRule)
+	}
+	return nil
+}
+
+// RoundUpFor is what a purchase saves under the rule. A purchase that is
+// already a whole multiple saves nothing.
+func (r RoundUpRule) RoundUpFor(purchase Money) Money {
+	step := r.Nearest
+	for i := 0; i < currencyExponents[purchase.currency()]; i++ {
+		step *= 10
+	}
+	spare := int64(0)
+	if remainder := purchase.Amount % step; purchase.IsPositive() && remainder != 0 {
+		spare = (step - remainder) * int64(r.Multiplier)
+	}
+	return NewMoney(spare, purchase.CurrencyCode())
+}
+
+// Capped limits one day's total round-ups to the rule's daily cap
+func (r RoundUpRule) Capped(total Money) Money {
+	if r.DailyCap != nil && r.DailyCap.SameCurrency(total) && total.Cmp(*r.DailyCap) > 0 {
+		return *r.DailyCap
+	}
+	return total
+}
+
+const (
+	RoundUpPending = "pending" // waiting for the day's batch
+	RoundUpPosted  = "posted"
+)
+
+// RoundUpEvent is the round-up from one card purchase. Round-ups are saved
+// in one batch per user for each day (UTC) they arrive on, not purchase by
+// purchase.
+type RoundUpEvent struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EventID       string             `bson:"event_id" json:"event_id"` // the card processor's ID, unique
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Purchase      Money              `bson:"purchase" json:"purchase"`
+	Merchant      string             `bson:"merchant,omitempty" json:"merchant,omitempty"`
+	PurchasedAt   time.Time          `bson:"purchased_at" json:"purchased_at"`
+	Amount        Money              `bson:"amount" json:"amount"` // the round-up
+	Status        string             `bson:"status" json:"status"` // pending or posted
+	TransactionID primitive.ObjectID `bson:"transaction_id,omitempty" json:"transaction_id,omitempty"`
+	ReceivedAt    time.Time          `bson:"received_at" json:"received_at"`
+	BatchDate     time.Time          `bson:"batch_date" json:"batch_date"` // start of the day it is saved with
+	PostedAt      *time.Time         `bson:"posted_at,omitempty" json:"posted_at,omitempty"`
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often a scheduled deposit repeats
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+)
+
+const (
+	ScheduleActive    = "active"
+	SchedulePaused    = "paused"    // by the user, or after too many failed runs
+	ScheduleEnded     = "ended"     // past its end date
+	ScheduleCancelled = "cancelled" // by the user; kept for its run history
+)
+
+// A schedule is paused after this many runs in a row fail
+const MaxScheduleFailures = 3
+
+// ScheduleRetryDelay is how long after the first failed attempt a run is
+// retried; each further retry waits twice as long as the one before
+const ScheduleRetryDelay = time.Hour
+
+var ErrInvalidFrequency = errors.New("frequency must be daily, weekly or monthly")
+
+// DepositSchedule is a standing order that deposits a fixed amount into the
+// user's savings, or into one of their goals, on a repeating schedule
+type DepositSchedule struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID              primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Frequency           string             `bson:"frequency" json:"frequency"`
+	Amount              Money              `bson:"amount" json:"amount"`
+	GoalID              primitive.ObjectID `bson:"goal_id,omitempty" json:"goal_id,omitempty"` // deposit into this goal instead of savings
+	StartAt             time.Time          `bson:"start_at" json:"start_at"`                   // first run; later runs keep its time and day
+	EndAt               *time.Time         `bson:"end_at,omitempty" json:"end_at,omitempty"`   // no runs after this
+	Status              string             `bson:"status" json:"status"`
+	Occurrence          int                `bson:"occurrence" json:"occurrence"`   // index of the next run to make, from 0
+	NextRunAt           time.Time          `bson:"next_run_at" json:"next_run_at"` // when that run, or its retry, is due
+	ConsecutiveFailures int                `bson:"consecutive_failures" json:"consecutive_failures"`
+	LastRunAt           *time.Time         `bson:"last_run_at,omitempty" json:"last_run_at,omitempty"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// ValidFrequency reports whether frequency is one schedules support
+func ValidFrequency(frequency string) bool {
+	return frequency == FrequencyDaily || frequency == FrequencyWeekly || frequency == FrequencyMonthly
+}
+
+// OccurrenceAt is when the nth run (from 0) is due
+func (s DepositSchedule) OccurrenceAt(n int) time.Time {
+	return occurrenceAt(s.StartAt, s.Frequency, n)
+}
+
+// occurrenceAt is the nth repeat (from 0) of start at the given frequency.
+// Monthly repeats keep the start date's day of the month, falling back to the
+// last day of shorter months, so the 31st becomes 28 or 29 February.
+func occurrenceAt(start time.Time, frequency string, n int) time.Time {
+	switch frequency {
+	case FrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		year, month, day := start.Date()
+		firstOfMonth := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, start.Location())
+		if last := firstOfMonth.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		hour, minute, second := start.Clock()
+		return time.Date(firstOfMonth.Year(), firstOfMonth.Month(), day, hour, minute, second, start.Nanosecond(), start.Location())
+	default:
+		return start.AddDate(0, 0, n)
+	}
+}
+
+// FirstOccurrenceFrom is the index of the first run due at or after t
+func (s DepositSchedule) FirstOccurrenceFrom(t time.Time) int {
+	n := 0
+	for s.OccurrenceAt(n).Before(t) {
+		n++
+	}
+	return n
+}
+
+// HasEnded reports whether the nth run would fall after the end date
+func (s DepositSchedule) HasEnded(n int) bool {
+	return s.EndAt != nil && s.OccurrenceAt(n).After(*s.EndAt)
+}
+
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+)
+
+// ScheduleRun records one attempt at making a scheduled deposit
+type ScheduleRun struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID    primitive.ObjectID `bson:"schedule_id" json:"schedule_id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Occurrence    int                `bson:"occurrence" json:"occurrence"`
+	DueAt         time.Time          `bson:"due_at" json:"due_at"`
+	Attempt       int                `bson:"attempt" json:"attempt"` // 1 for the first try, higher for retries
+	Status        string             `bson:"status" json:"status"`   // succeeded or failed
+	Error         string             `bson:"error,omitempty" json:"error,omitempty"`
+	TransactionID primitive.ObjectID `bson:"transaction_id,omitempty" json:"transaction_id,omitempty"` // the deposit in history
+	RanAt         time.Time          `bson:"ran_at" json:"ran_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Why a session was revoked
+const (
+	SessionLoggedOut       = "logged_out"       // the user logged out on this device
+	SessionLoggedOutAll    = "logged_out_all"   // the user logged out on every device
+	SessionTokenReused     = "token_reused"     // a refresh token was presented twice
+	SessionRolesChanged    = "roles_changed"    // the user's staff roles changed
+	SessionPasswordChanged = "password_changed" // the user's password was reset or changed
+	SessionAccountClosed   = "account_closed"   // the user closed their account
+)
+
+// Session is one signed-in device. Its refresh tokens form a family: every
+// refresh swaps the current token for a new one, and presenting a token that
+// was already swapped revokes the whole session.
+type Session struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	UserAgent       string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	IP              string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	LastRefreshedAt time.Time          `bson:"last_refreshed_at" json:"last_refreshed_at"`
+	MFAVerifiedAt   *time.Time         `bson:"mfa_verified_at,omitempty" json:"mfa_verified_at,omitempty"` // last two-factor code entered on this device
+	RevokedAt       *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+	RevokedReason   string             `bson:"revoked_reason,omitempty" json:"revoked_reason,omitempty"`
+}
+
+// IsRevoked reports whether the session can no longer be used
+func (s Session) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
+
+// RefreshToken is one token in a session's family. Only a hash of the token
+// is stored, so a leaked database can't be used to sign in.
+type RefreshToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	SessionID primitive.ObjectID `bson:"session_id"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"`        // removed by a TTL index once past
+	UsedAt    *time.Time         `bson:"used_at,omitempty"` // swapped for a new token
+	CreatedAt time.Time          `bson:"created_at"`
+}
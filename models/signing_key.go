@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Algorithms access tokens can be signed with
+const (
+	AlgEdDSA = "EdDSA" // Ed25519, the default
+	AlgRS256 = "RS256" // RSA 2048, for verifiers without Ed25519 support
+)
+
+// SigningKey is a key pair access tokens are signed with. A new key verifies
+// as soon as it is created but only signs from ActiveFrom, once every
+// instance has loaded it; older ones keep verifying until the tokens they
+// signed have expired, so rotating keys doesn't log anyone out.
+type SigningKey struct {
+	ID          string     `bson:"_id"` // the kid header of tokens it signs
+	Algorithm   string     `bson:"algorithm"`
+	PrivateKey  []byte     `bson:"private_key"`         // PKCS #8, DER encoded; sealed with AES-GCM when Encrypted
+	Encrypted   bool       `bson:"encrypted,omitempty"` // false only for keys stored before encryption
+	PublicKey   []byte     `bson:"public_key"`          // PKIX, DER encoded
+	CreatedAt   time.Time  `bson:"created_at"`
+	ActiveFrom  time.Time  `bson:"active_from"`            // starts signing
+	RetiredAt   *time.Time `bson:"retired_at,omitempty"`   // stops signing
+	VerifyUntil *time.Time `bson:"verify_until,omitempty"` // stops verifying; removed by a TTL index once past
+}
+
+// Active reports whether the key signs new tokens at now
+func (k SigningKey) Active(now time.Time) bool {
+	return !now.Before(k.ActiveFrom) && (k.RetiredAt == nil || now.Before(*k.RetiredAt))
+}
+
+// Verifies reports whether tokens signed with the key are still accepted
+func (k SigningKey) Verifies(now time.Time) bool {
+	return k.VerifyUntil == nil || now.Before(*k.VerifyUntil)
+}
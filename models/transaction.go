@@ -7,10 +7,17 @@ import (
 )
 
 type Transaction struct {
-	ID        primitive.ObjectID    `bson:"_id,omitempty"`
-	UserID    primitive.ObjectID    `bson:"user_id"`
-	Type      string    			`bson:"type"` // withdrawal or deposit
-	Amount    float64   			`bson:"amount"`
-	CreatedAt time.Time 			`bson:"cretaed_at"`
-	UpdatedAt time.Time 			`bson:"updated_at"`
-}
\ No newline at end of file
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Type           string             `bson:"type" json:"type"` // withdrawal or deposit
+	Amount         Money              `bson:"amount" json:"amount"`
+	Direction      Direction          `bson:"direction,omitempty" json:"direction,omitempty"`             // debit or credit, set on transfers
+	Reference      string             `bson:"reference,omitempty" json:"reference,omitempty"`             // shared by both legs of a transfer
+	CounterpartyID primitive.ObjectID `bson:"counterparty_id,omitempty" json:"counterparty_id,omitempty"` // the other user in a transfer
+	Note           string             `bson:"note,omitempty" json:"note,omitempty"`
+	GoalID         primitive.ObjectID `bson:"goal_id,omitempty" json:"goal_id,omitempty"`                   // set when money moved in or out of a goal
+	BalanceAfter   *Money             `bson:"balance_after,omitempty" json:"balance_after,omitempty"`       // savings balance once this row was applied, unset if it did not change it
+	JournalEntryID primitive.ObjectID `bson:"journal_entry_id,omitempty" json:"journal_entry_id,omitempty"` // ledger entry that moved the money
+	CreatedAt      time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt      time.Time          `bson:"updated_at" json:"updated_at"`
+}
@@ -3,16 +3,23 @@ package models
 type TransactionType string
 
 const (
-	Deposit    TransactionType = "deposit"
-	Withdrawal TransactionType = "withdrawal"
-	Transfer   TransactionType = "transfer"
-	Investment TransactionType = "investment"
+	Deposit          TransactionType = "deposit"
+	Withdrawal       TransactionType = "withdrawal"
+	Transfer         TransactionType = "transfer"
+	Investment       TransactionType = "investment"
+	Interest         TransactionType = "interest"
+	Redemption       TransactionType = "redemption"
+	RoundUp          TransactionType = "roundup"
+	PoolContribution TransactionType = "pool_contribution"
+	PoolPayout       TransactionType = "pool_payout"
+	Hold             TransactionType = "hold"         // savings set aside while a withdrawal or transfer is reviewed
+	HoldRelease      TransactionType = "hold_release" // held savings given back after a rejected review
 )
 
 // IsValid checks if a transaction type is valid
 func (t TransactionType) IsValid() bool {
 	switch t {
-	case Deposit, Withdrawal, Transfer, Investment:
+	case Deposit, Withdrawal, Transfer, Investment, Interest, Redemption, RoundUp, PoolContribution, PoolPayout, Hold, HoldRelease:
 		return true
 	default:
 		return false
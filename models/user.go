@@ -8,14 +8,39 @@ import (
 
 // User represents a registered user
 type User struct {
-ID                primitive.ObjectID      `bson:"_id,omitempty"`
-Name              string    			  `bson:"name"`
-Email             string    			  `bson:"email"`
-PasswordHash      string    			  `bson:"password_hash"`
-SavingsBalance    float64   			  `bson:"savings_balance"`
-InvestmentBalance float64 			      `bson:"investment_balance"`
-LastTransactionAt time.Time               `bson:"last_transaction_at"`
-IsAdmin			  bool					  `bson:"is_admin"`
-CreatedAt         time.Time 			  `bson:"created_at"`
-UpdatedAt         time.Time 			  `bson:"updated_at"`
+	ID                primitive.ObjectID `bson:"_id,omitempty"`
+	Name              string             `bson:"name"`
+	Email             string             `bson:"email"`
+	Phone             string             `bson:"phone,omitempty" json:"-"`
+	DateOfBirth       string             `bson:"date_of_birth,omitempty" json:"-"` // DateOfBirthLayout
+	Address           *Address           `bson:"address,omitempty" json:"-"`
+	PasswordHash      string             `bson:"password_hash" json:"-"`
+	PasswordChangedAt *time.Time         `bson:"password_changed_at,omitempty"` // last reset or change; nil if never
+	EmailVerifiedAt   *time.Time         `bson:"email_verified_at,omitempty"`   // nil until the user proves they own the address
+	SavingsBalance    Money              `bson:"savings_balance"`
+	InvestmentBalance Money              `bson:"investment_balance"`
+	GoalsBalance      Money              `bson:"goals_balance"` // total saved across the user's goals
+	LastTransactionAt time.Time          `bson:"last_transaction_at"`
+	KYCTier           int                `bson:"kyc_tier"`                    // highest KYC tier approved
+	Roles             []string           `bson:"roles,omitempty"`             // staff roles; none for customers
+	MFA               *MFASettings       `bson:"mfa,omitempty" json:"-"`      // nil until two-factor setup starts
+	AllocationPolicy  *AllocationPolicy  `bson:"allocation_policy,omitempty"` // nil follows the global policy
+	VelocityLimits    *VelocityLimits    `bson:"velocity_limits,omitempty"`   // nil follows the global limits
+	ProductTier       string             `bson:"product_tier,omitempty"`      // interest tier, DefaultProductTier if empty
+	RoundUpRule       *RoundUpRule       `bson:"roundup_rule,omitempty"`      // nil when round-ups are off
+	Interest          InterestAccrual    `bson:"interest"`
+	ClosedAt          *time.Time         `bson:"closed_at,omitempty"` // set when the user closes their account
+	ClosureReason     string             `bson:"closure_reason,omitempty"`
+	CreatedAt         time.Time          `bson:"created_at"`
+	UpdatedAt         time.Time          `bson:"updated_at"`
+}
+
+// IsStaff reports whether the user holds any staff role
+func (u User) IsStaff() bool {
+	return len(u.Roles) > 0
+}
+
+// IsEmailVerified reports whether the user has confirmed their email address
+func (u User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// What a UserToken proves
+const (
+	TokenEmailVerification = "email_verification"
+	TokenPasswordReset     = "password_reset"
+	TokenEmailChange       = "email_change"  // sent to the new address; Email is the address to switch to
+	TokenMFAChallenge      = "mfa_challenge" // issued at login, swapped for a session with a valid code
+)
+
+// How long an emailed token can be used
+const (
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+	EmailChangeTTL       = 24 * time.Hour
+)
+
+// UserToken is a single-use secret emailed to a user to prove they own the
+// address. Only a hash is stored, and issuing a new token for the same
+// purpose spends any the user still holds.
+type UserToken struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `bson:"user_id"`
+	Purpose   string             `bson:"purpose"`
+	Email     string             `bson:"email"` // the address it was sent to
+	TokenHash string             `bson:"token_hash"`
+	ExpiresAt time.Time          `bson:"expires_at"` // removed by a TTL index once past
+	UsedAt    *time.Time         `bson:"used_at,omitempty"`
+	CreatedAt time.Time          `bson:"created_at"`
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Movements velocity limits apply to. Withdrawals include transfers out.
+const (
+	VelocityDeposit    = "deposit"
+	VelocityWithdrawal = "withdrawal"
+)
+
+// Windows velocity limits are summed over. Both roll: a day is the last 24
+// hours and a month the last 30 days, so allowance comes back gradually as
+// older transactions drop out.
+const (
+	VelocityDaily   = "daily"
+	VelocityMonthly = "monthly"
+)
+
+// VelocityWindows are the window lengths, shortest first
+var VelocityWindows = []struct {
+	Name   string
+	Length time.Duration
+}{
+	{VelocityDaily, 24 * time.Hour},
+	{VelocityMonthly, 30 * 24 * time.Hour},
+}
+
+// LongestVelocityWindow is how far back sums ever need to look
+const LongestVelocityWindow = 30 * 24 * time.Hour
+
+var ErrInvalidVelocityLimits = errors.New("invalid velocity limits")
+
+// VelocityLimit caps the total of one movement over each window; a nil cap
+// means no limit for that window
+type VelocityLimit struct {
+	Daily   *Money `bson:"daily,omitempty" json:"daily,omitempty"`
+	Monthly *Money `bson:"monthly,omitempty" json:"monthly,omitempty"`
+}
+
+// Window returns the cap for a window name, nil if there is none
+func (l VelocityLimit) Window(name string) *Money {
+	switch name {
+	case VelocityDaily:
+		return l.Daily
+	case VelocityMonthly:
+		return l.Monthly
+	}
+	return nil
+}
+
+// VelocityLimits cap how much a user can deposit and withdraw over time. One
+// set applies to everybody; admins may give a user their own.
+type VelocityLimits struct {
+	Deposit    VelocityLimit `bson:"deposit" json:"deposit"`
+	Withdrawal VelocityLimit `bson:"withdrawal" json:"withdrawal"`
+	UpdatedAt  time.Time     `bson:"updated_at" json:"updated_at"`
+}
+
+// DefaultVelocityLimits apply until an admin sets global limits
+func DefaultVelocityLimits() VelocityLimits {
+	return VelocityLimits{
+		Deposit: VelocityLimit{
+			Daily:   moneyPtr(NewMoney(500000000, DefaultCurrency)),  // 5,000,000.00
+			Monthly: moneyPtr(NewMoney(2000000000, DefaultCurrency)), // 20,000,000.00
+		},
+		Withdrawal: VelocityLimit{
+			Daily:   moneyPtr(NewMoney(200000000, DefaultCurrency)),  // 2,000,000.00
+			Monthly: moneyPtr(NewMoney(1000000000, DefaultCurrency)), // 10,000,000.00
+		},
+	}
+}
+
+// For returns the limit on a movement
+func (l VelocityLimits) For(movement string) VelocityLimit {
+	if movement == VelocityDeposit {
+		return l.Deposit
+	}
+	return l.Withdrawal
+}
+
+// Validate checks that every cap is positive and that a daily cap is not
+// above the monthly one
+func (l VelocityLimits) Validate() error {
+	for _, movement := range []string{VelocityDeposit, VelocityWithdrawal} {
+		limit := l.For(movement)
+		for _, window := range VelocityWindows {
+			if cap := limit.Window(window.Name); cap != nil && !cap.IsPositive() {
+				return fmt.Errorf("%w: %s.%s must be positive", ErrInvalidVelocityLimits, movement, window.Name)
+			}
+		}
+		if limit.Daily == nil || limit.Monthly == nil {
+			continue
+		}
+		if !limit.Daily.SameCurrency(*limit.Monthly) {
+			return fmt.Errorf("%w: %s limits must share a currency", ErrInvalidVelocityLimits, movement)
+		}
+		if limit.Daily.Cmp(*limit.Monthly) > 0 {
+			return fmt.Errorf("%w: %s.daily cannot be more than %s.monthly", ErrInvalidVelocityLimits, movement, movement)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// memoryData is everything an in-memory store holds
+type memoryData struct {
+	users           map[primitive.ObjectID]models.User
+	transactions    []models.Transaction
+	ledgerAccounts  map[string]models.LedgerAccount
+	journalEntries  []models.JournalEntry
+	idempotency     map[string]models.IdempotencyRecord
+	allocation      *models.AllocationPolicy
+	interestTiers   []models.InterestTier
+	stepUpThreshold *models.Money
+	velocityLimits  *models.VelocityLimits
+	redemptions     map[primitive.ObjectID]models.RedemptionRequest
+	goals           map[primitive.ObjectID]models.Goal
+	schedules       map[primitive.ObjectID]models.DepositSchedule
+	scheduleRuns    []models.ScheduleRun
+	roundUps        map[primitive.ObjectID]models.RoundUpEvent
+	pools           map[primitive.ObjectID]models.Pool
+	sessions        map[primitive.ObjectID]models.Session
+	refreshTokens   map[primitive.ObjectID]models.RefreshToken
+	userTokens      map[primitive.ObjectID]models.UserToken
+	kycSubmissions  map[primitive.ObjectID]models.KYCSubmission
+	fraudReviews    map[primitive.ObjectID]models.FraudReview
+	loginThrottles  map[string]models.LoginThrottle
+	signingKeys     map[string]models.SigningKey
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		users:          map[primitive.ObjectID]models.User{},
+		ledgerAccounts: map[string]models.LedgerAccount{},
+		idempotency:    map[string]models.IdempotencyRecord{},
+		redemptions:    map[primitive.ObjectID]models.RedemptionRequest{},
+		goals:          map[primitive.ObjectID]models.Goal{},
+		schedules:      map[primitive.ObjectID]models.DepositSchedule{},
+		roundUps:       map[primitive.ObjectID]models.RoundUpEvent{},
+		pools:          map[primitive.ObjectID]models.Pool{},
+		sessions:       map[primitive.ObjectID]models.Session{},
+		refreshTokens:  map[primitive.ObjectID]models.RefreshToken{},
+		userTokens:     map[primitive.ObjectID]models.UserToken{},
+		kycSubmissions: map[primitive.ObjectID]models.KYCSubmission{},
+		fraudReviews:   map[primitive.ObjectID]models.FraudReview{},
+		loginThrottles: map[string]models.LoginThrottle{},
+		signingKeys:    map[string]models.SigningKey{},
+	}
+}
+
+// clone copies the data so a failed transaction can be rolled back
+func (d *memoryData) clone() *memoryData {
+	c := newMemoryData()
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	c.transactions = append(c.transactions, d.transactions...)
+	for k, v := range d.ledgerAccounts {
+		c.ledgerAccounts[k] = v
+	}
+	c.journalEntries = append(c.journalEntries, d.journalEntries...)
+	for k, v := range d.idempotency {
+		c.idempotency[k] = v
+	}
+	c.allocation = d.allocation
+	c.interestTiers = d.interestTiers
+	c.stepUpThreshold = d.stepUpThreshold
+	c.velocityLimits = d.velocityLimits
+	for k, v := range d.redemptions {
+		c.redemptions[k] = v
+	}
+	for k, v := range d.goals {
+		c.goals[k] = v
+	}
+	for k, v := range d.schedules {
+		c.schedules[k] = v
+	}
+	c.scheduleRuns = append(c.scheduleRuns, d.scheduleRuns...)
+	for k, v := range d.roundUps {
+		c.roundUps[k] = v
+	}
+	for k, v := range d.pools {
+		c.pools[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.refreshTokens {
+		c.refreshTokens[k] = v
+	}
+	for k, v := range d.userTokens {
+		c.userTokens[k] = v
+	}
+	for k, v := range d.kycSubmissions {
+		c.kycSubmissions[k] = v
+	}
+	for k, v := range d.fraudReviews {
+		c.fraudReviews[k] = v
+	}
+	for k, v := range d.loginThrottles {
+		c.loginThrottles[k] = v
+	}
+	for k, v := range d.signingKeys {
+		c.signingKeys[k] = v
+	}
+	return c
+}
+
+// memoryStore guards all in-memory repositories with one mutex. A transaction
+// holds the mutex for its whole duration; calls made with the transaction's
+// context skip locking so they don't deadlock on it.
+type memoryStore struct {
+	mu   sync.Mutex
+	data *memoryData
+}
+
+type memoryTxKey struct{}
+
+func (s *memoryStore) lock(ctx context.Context) func() {
+	if owner, _ := ctx.Value(memoryTxKey{}).(*memoryStore); owner == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *memoryStore) withTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	// Nested transactions simply join the outer one
+	if owner, _ := ctx.Value(memoryTxKey{}).(*memoryStore); owner == s {
+		return fn(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.data.clone()
+	if err := fn(context.WithValue(ctx, memoryTxKey{}, s)); err != nil {
+		s.data = snapshot
+		return err
+	}
+	return nil
+}
+
+// NewMemoryStore returns an empty Store that keeps everything in memory.
+// It is safe for concurrent use and supports transactions with rollback.
+func NewMemoryStore() *Store {
+	s := &memoryStore{data: newMemoryData()}
+	return &Store{
+		Users:           &memoryUserRepository{s},
+		Transactions:    &memoryTransactionRepository{s},
+		Ledger:          &memoryLedgerRepository{s},
+		Idempotency:     &memoryIdempotencyRepository{s},
+		Settings:        &memorySettingsRepository{s},
+		Redemptions:     &memoryRedemptionRepository{s},
+		Goals:           &memoryGoalRepository{s},
+		Schedules:       &memoryScheduleRepository{s},
+		RoundUps:        &memoryRoundUpRepository{s},
+		Pools:           &memoryPoolRepository{s},
+		Sessions:        &memorySessionRepository{s},
+		UserTokens:      &memoryUserTokenRepository{s},
+		KYC:             &memoryKYCRepository{s},
+		FraudReviews:    &memoryFraudReviewRepository{s},
+		Logins:          &memoryLoginThrottleRepository{s},
+		SigningKeys:     &memorySigningKeyRepository{s},
+		withTransaction: s.withTransaction,
+	}
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryFraudReviewRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryFraudReviewRepository) Create(ctx context.Context, review *models.FraudReview) error {
+	defer r.store.lock(ctx)()
+
+	if review.ID.IsZero() {
+		review.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.fraudReviews[review.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.fraudReviews[review.ID] = copyFraudReview(*review)
+	return nil
+}
+
+func (r *memoryFraudReviewRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.FraudReview, error) {
+	defer r.store.lock(ctx)()
+
+	review, ok := r.store.data.fraudReviews[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	review = copyFraudReview(review)
+	return &review, nil
+}
+
+func (r *memoryFraudReviewRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.FraudReview, error) {
+	defer r.store.lock(ctx)()
+
+	reviews := []models.FraudReview{}
+	for _, review := range r.store.data.fraudReviews {
+		if review.UserID == userID {
+			reviews = append(reviews, copyFraudReview(review))
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.After(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+func (r *memoryFraudReviewRepository) ListByStatus(ctx context.Context, status string) ([]models.FraudReview, error) {
+	defer r.store.lock(ctx)()
+
+	reviews := []models.FraudReview{}
+	for _, review := range r.store.data.fraudReviews {
+		if review.Status == status {
+			reviews = append(reviews, copyFraudReview(review))
+		}
+	}
+	sort.Slice(reviews, func(i, j int) bool {
+		return reviews[i].CreatedAt.Before(reviews[j].CreatedAt)
+	})
+	return reviews, nil
+}
+
+func (r *memoryFraudReviewRepository) Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	review, ok := r.store.data.fraudReviews[id]
+	if !ok || review.Status != models.ReviewPending {
+		return ErrNotFound
+	}
+	review.Status = status
+	review.ReviewerID = &reviewerID
+	review.ReviewNote = note
+	review.ReviewedAt = &at
+	r.store.data.fraudReviews[id] = review
+	return nil
+}
+
+// copyFraudReview copies the flags too, so callers can't change the stored
+// review through the slice
+func copyFraudReview(review models.FraudReview) models.FraudReview {
+	review.Flags = append([]models.FraudFlag(nil), review.Flags...)
+	return review
+}
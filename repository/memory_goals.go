@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryGoalRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryGoalRepository) Create(ctx context.Context, goal *models.Goal) error {
+	defer r.store.lock(ctx)()
+
+	if goal.ID.IsZero() {
+		goal.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.goals[goal.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.goals[goal.ID] = *goal
+	return nil
+}
+
+func (r *memoryGoalRepository) GetByID(ctx context.Context, userID, goalID primitive.ObjectID) (*models.Goal, error) {
+	defer r.store.lock(ctx)()
+
+	goal, ok := r.store.data.goals[goalID]
+	if !ok || goal.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &goal, nil
+}
+
+func (r *memoryGoalRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Goal, error) {
+	defer r.store.lock(ctx)()
+
+	goals := []models.Goal{}
+	for _, goal := range r.store.data.goals {
+		if goal.UserID == userID {
+			goals = append(goals, goal)
+		}
+	}
+	sort.Slice(goals, func(i, j int) bool {
+		if !goals[i].CreatedAt.Equal(goals[j].CreatedAt) {
+			return goals[i].CreatedAt.Before(goals[j].CreatedAt)
+		}
+		return goals[i].ID.Hex() < goals[j].ID.Hex()
+	})
+	return goals, nil
+}
+
+func (r *memoryGoalRepository) Update(ctx context.Context, goal *models.Goal) error {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.data.goals[goal.ID]
+	if !ok || stored.UserID != goal.UserID {
+		return ErrNotFound
+	}
+	stored.Name = goal.Name
+	stored.TargetAmount = goal.TargetAmount
+	stored.Deadline = goal.Deadline
+	stored.LockedUntil = goal.LockedUntil
+	stored.UpdatedAt = goal.UpdatedAt
+	r.store.data.goals[goal.ID] = stored
+	return nil
+}
+
+func (r *memoryGoalRepository) AdjustSaved(ctx context.Context, goalID primitive.ObjectID, delta models.Money, at time.Time) (*models.Goal, error) {
+	defer r.store.lock(ctx)()
+
+	goal, ok := r.store.data.goals[goalID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !goal.SavedAmount.SameCurrency(delta) {
+		return nil, models.ErrCurrencyMismatch
+	}
+	updated := goal.SavedAmount.Add(delta)
+	if updated.IsNegative() {
+		return nil, ErrInsufficientFunds
+	}
+	goal.SavedAmount = updated
+	goal.UpdatedAt = at
+	r.store.data.goals[goalID] = goal
+	return &goal, nil
+}
+
+func (r *memoryGoalRepository) Delete(ctx context.Context, userID, goalID primitive.ObjectID) error {
+	defer r.store.lock(ctx)()
+
+	goal, ok := r.store.data.goals[goalID]
+	if !ok || goal.UserID != userID {
+		return ErrNotFound
+	}
+	if !goal.SavedAmount.IsZero() {
+		return ErrNotEmpty
+	}
+	delete(r.store.data.goals, goalID)
+	return nil
+}
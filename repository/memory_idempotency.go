@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/database"
+	"micro-savings-app/models"
+)
+
+type memoryIdempotencyRepository struct {
+	store *memoryStore
+}
+
+func idempotencyKey(userID, key string) string {
+	return userID + "\x00" + key
+}
+
+// expired mirrors the Mongo TTL index, which removes records after IdempotencyKeyTTL
+func expired(record models.IdempotencyRecord) bool {
+	return time.Since(record.CreatedAt) > database.IdempotencyKeyTTL
+}
+
+func (r *memoryIdempotencyRepository) Create(ctx context.Context, record *models.IdempotencyRecord) error {
+	defer r.store.lock(ctx)()
+
+	k := idempotencyKey(record.UserID, record.Key)
+	if existing, ok := r.store.data.idempotency[k]; ok && !expired(existing) {
+		return ErrDuplicateKey
+	}
+	r.store.data.idempotency[k] = *record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Get(ctx context.Context, userID, key string) (*models.IdempotencyRecord, error) {
+	defer r.store.lock(ctx)()
+
+	record, ok := r.store.data.idempotency[idempotencyKey(userID, key)]
+	if !ok || expired(record) {
+		return nil, ErrNotFound
+	}
+	return &record, nil
+}
+
+func (r *memoryIdempotencyRepository) Complete(ctx context.Context, userID, key string, status int, body []byte, contentType string) error {
+	defer r.store.lock(ctx)()
+
+	k := idempotencyKey(userID, key)
+	record, ok := r.store.data.idempotency[k]
+	if !ok {
+		return ErrNotFound
+	}
+	record.Status = models.IdempotencyCompleted
+	record.ResponseStatus = status
+	record.ResponseBody = append([]byte(nil), body...)
+	record.ContentType = contentType
+	record.UpdatedAt = time.Now()
+	r.store.data.idempotency[k] = record
+	return nil
+}
+
+func (r *memoryIdempotencyRepository) Delete(ctx context.Context, userID, key string) error {
+	defer r.store.lock(ctx)()
+
+	delete(r.store.data.idempotency, idempotencyKey(userID, key))
+	return nil
+}
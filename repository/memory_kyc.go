@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryKYCRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryKYCRepository) Create(ctx context.Context, submission *models.KYCSubmission) error {
+	defer r.store.lock(ctx)()
+
+	if submission.ID.IsZero() {
+		submission.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.kycSubmissions[submission.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.kycSubmissions[submission.ID] = copyKYCSubmission(*submission)
+	return nil
+}
+
+func (r *memoryKYCRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.KYCSubmission, error) {
+	defer r.store.lock(ctx)()
+
+	submission, ok := r.store.data.kycSubmissions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	submission = copyKYCSubmission(submission)
+	return &submission, nil
+}
+
+func (r *memoryKYCRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.KYCSubmission, error) {
+	defer r.store.lock(ctx)()
+
+	submissions := []models.KYCSubmission{}
+	for _, submission := range r.store.data.kycSubmissions {
+		if submission.UserID == userID {
+			submissions = append(submissions, copyKYCSubmission(submission))
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissions[i].SubmittedAt.After(submissions[j].SubmittedAt)
+	})
+	return submissions, nil
+}
+
+func (r *memoryKYCRepository) ListByStatus(ctx context.Context, status string) ([]models.KYCSubmission, error) {
+	defer r.store.lock(ctx)()
+
+	submissions := []models.KYCSubmission{}
+	for _, submission := range r.store.data.kycSubmissions {
+		if submission.Status == status {
+			submissions = append(submissions, copyKYCSubmission(submission))
+		}
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		return submissions[i].SubmittedAt.Before(submissions[j].SubmittedAt)
+	})
+	return submissions, nil
+}
+
+func (r *memoryKYCRepository) Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	submission, ok := r.store.data.kycSubmissions[id]
+	if !ok || submission.Status != models.KYCPending {
+		return ErrNotFound
+	}
+	submission.Status = status
+	submission.ReviewerID = &reviewerID
+	submission.ReviewNote = note
+	submission.ReviewedAt = &at
+	r.store.data.kycSubmissions[id] = submission
+	return nil
+}
+
+// copyKYCSubmission copies the documents too, so callers can't change the
+// stored submission through the slice
+func copyKYCSubmission(submission models.KYCSubmission) models.KYCSubmission {
+	submission.Documents = append([]models.KYCDocument(nil), submission.Documents...)
+	return submission
+}
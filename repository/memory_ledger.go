@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryLedgerRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryLedgerRepository) AdjustAccount(ctx context.Context, accountID string, accountType models.AccountType, delta models.Money, at time.Time) (models.Money, error) {
+	defer r.store.lock(ctx)()
+
+	account, ok := r.store.data.ledgerAccounts[accountID]
+	if !ok {
+		account = models.LedgerAccount{
+			ID:        accountID,
+			Type:      accountType,
+			Balance:   models.NewMoney(0, delta.CurrencyCode()),
+			CreatedAt: at,
+		}
+	}
+	account.Balance = account.Balance.Add(delta)
+	account.UpdatedAt = at
+	r.store.data.ledgerAccounts[accountID] = account
+	return account.Balance, nil
+}
+
+func (r *memoryLedgerRepository) InsertEntry(ctx context.Context, entry *models.JournalEntry) error {
+	defer r.store.lock(ctx)()
+
+	for _, existing := range r.store.data.journalEntries {
+		if existing.Reference == entry.Reference {
+			return ErrDuplicateKey
+		}
+	}
+	if entry.ID.IsZero() {
+		entry.ID = primitive.NewObjectID()
+	}
+	r.store.data.journalEntries = append(r.store.data.journalEntries, *entry)
+	return nil
+}
+
+func (r *memoryLedgerRepository) LineTotals(ctx context.Context, accountID, currency string) (int64, int64, error) {
+	defer r.store.lock(ctx)()
+
+	var debits, credits int64
+	for _, entry := range r.store.data.journalEntries {
+		for _, line := range entry.Lines {
+			if line.AccountID != accountID || line.Amount.CurrencyCode() != currency {
+				continue
+			}
+			if line.Direction == models.Debit {
+				debits += line.Amount.Amount
+			} else {
+				credits += line.Amount.Amount
+			}
+		}
+	}
+	return debits, credits, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+)
+
+type memoryLoginThrottleRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryLoginThrottleRepository) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	defer r.store.lock(ctx)()
+
+	throttle, ok := r.store.data.loginThrottles[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &throttle, nil
+}
+
+func (r *memoryLoginThrottleRepository) RecordFailure(ctx context.Context, key string, now, since time.Time) (*models.LoginThrottle, error) {
+	defer r.store.lock(ctx)()
+
+	throttle, ok := r.store.data.loginThrottles[key]
+	if !ok || throttle.LastFailureAt.Before(since) {
+		throttle = models.LoginThrottle{Key: key}
+	}
+	throttle.Failures++
+	throttle.LastFailureAt = now
+	r.store.data.loginThrottles[key] = throttle
+	return &throttle, nil
+}
+
+func (r *memoryLoginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	defer r.store.lock(ctx)()
+
+	throttle, ok := r.store.data.loginThrottles[key]
+	if !ok {
+		return ErrNotFound
+	}
+	throttle.LockedUntil = &until
+	r.store.data.loginThrottles[key] = throttle
+	return nil
+}
+
+func (r *memoryLoginThrottleRepository) Reset(ctx context.Context, key string) error {
+	defer r.store.lock(ctx)()
+
+	delete(r.store.data.loginThrottles, key)
+	return nil
+}
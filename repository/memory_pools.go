@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryPoolRepository struct {
+	store *memoryStore
+}
+
+// copyPool keeps callers from sharing the stored members slice
+func copyPool(pool models.Pool) models.Pool {
+	pool.Members = append([]models.PoolMember(nil), pool.Members...)
+	return pool
+}
+
+func (r *memoryPoolRepository) Create(ctx context.Context, pool *models.Pool) error {
+	defer r.store.lock(ctx)()
+
+	if pool.ID.IsZero() {
+		pool.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.pools[pool.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.pools[pool.ID] = copyPool(*pool)
+	return nil
+}
+
+func (r *memoryPoolRepository) GetByID(ctx context.Context, poolID primitive.ObjectID) (*models.Pool, error) {
+	defer r.store.lock(ctx)()
+
+	pool, ok := r.store.data.pools[poolID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	pool = copyPool(pool)
+	return &pool, nil
+}
+
+func (r *memoryPoolRepository) ListByMember(ctx context.Context, userID primitive.ObjectID) ([]models.Pool, error) {
+	defer r.store.lock(ctx)()
+
+	pools := []models.Pool{}
+	for _, pool := range r.store.data.pools {
+		if pool.Member(userID) >= 0 {
+			pools = append(pools, copyPool(pool))
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		if !pools[i].CreatedAt.Equal(pools[j].CreatedAt) {
+			return pools[i].CreatedAt.Before(pools[j].CreatedAt)
+		}
+		return pools[i].ID.Hex() < pools[j].ID.Hex()
+	})
+	return pools, nil
+}
+
+func (r *memoryPoolRepository) ListDue(ctx context.Context, at time.Time) ([]models.Pool, error) {
+	defer r.store.lock(ctx)()
+
+	pools := []models.Pool{}
+	for _, pool := range r.store.data.pools {
+		running := pool.Status == models.PoolActive || pool.Status == models.PoolSettling
+		if running && pool.NextCycleAt != nil && !pool.NextCycleAt.After(at) {
+			pools = append(pools, copyPool(pool))
+		}
+	}
+	sort.Slice(pools, func(i, j int) bool {
+		return pools[i].NextCycleAt.Before(*pools[j].NextCycleAt)
+	})
+	return pools, nil
+}
+
+func (r *memoryPoolRepository) Update(ctx context.Context, pool *models.Pool) error {
+	defer r.store.lock(ctx)()
+
+	if _, exists := r.store.data.pools[pool.ID]; !exists {
+		return ErrNotFound
+	}
+	r.store.data.pools[pool.ID] = copyPool(*pool)
+	return nil
+}
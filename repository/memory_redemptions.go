@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRedemptionRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryRedemptionRepository) Create(ctx context.Context, redemption *models.RedemptionRequest) error {
+	defer r.store.lock(ctx)()
+
+	if redemption.ID.IsZero() {
+		redemption.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.redemptions[redemption.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.redemptions[redemption.ID] = *redemption
+	return nil
+}
+
+func (r *memoryRedemptionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.RedemptionRequest, error) {
+	defer r.store.lock(ctx)()
+
+	redemptions := []models.RedemptionRequest{}
+	for _, redemption := range r.store.data.redemptions {
+		if redemption.UserID == userID {
+			redemptions = append(redemptions, redemption)
+		}
+	}
+	sort.Slice(redemptions, func(i, j int) bool {
+		if !redemptions[i].RequestedAt.Equal(redemptions[j].RequestedAt) {
+			return redemptions[i].RequestedAt.After(redemptions[j].RequestedAt)
+		}
+		return redemptions[i].ID.Hex() > redemptions[j].ID.Hex()
+	})
+	return redemptions, nil
+}
+
+func (r *memoryRedemptionRepository) ListDue(ctx context.Context, at time.Time) ([]models.RedemptionRequest, error) {
+	defer r.store.lock(ctx)()
+
+	redemptions := []models.RedemptionRequest{}
+	for _, redemption := range r.store.data.redemptions {
+		if redemption.Status == models.RedemptionPending && !redemption.SettleAt.After(at) {
+			redemptions = append(redemptions, redemption)
+		}
+	}
+	sort.Slice(redemptions, func(i, j int) bool {
+		return redemptions[i].SettleAt.Before(redemptions[j].SettleAt)
+	})
+	return redemptions, nil
+}
+
+func (r *memoryRedemptionRepository) Update(ctx context.Context, redemption *models.RedemptionRequest) error {
+	defer r.store.lock(ctx)()
+
+	if _, exists := r.store.data.redemptions[redemption.ID]; !exists {
+		return ErrNotFound
+	}
+	r.store.data.redemptions[redemption.ID] = *redemption
+	return nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRoundUpRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryRoundUpRepository) Create(ctx context.Context, event *models.RoundUpEvent) error {
+	defer r.store.lock(ctx)()
+
+	for _, existing := range r.store.data.roundUps {
+		if existing.EventID == event.EventID {
+			return ErrDuplicateKey
+		}
+	}
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	r.store.data.roundUps[event.ID] = *event
+	return nil
+}
+
+func (r *memoryRoundUpRepository) ListPending(ctx context.Context, before time.Time) ([]models.RoundUpEvent, error) {
+	defer r.store.lock(ctx)()
+
+	events := []models.RoundUpEvent{}
+	for _, event := range r.store.data.roundUps {
+		if event.Status == models.RoundUpPending && event.BatchDate.Before(before) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].ReceivedAt.Equal(events[j].ReceivedAt) {
+			return events[i].ReceivedAt.Before(events[j].ReceivedAt)
+		}
+		return events[i].ID.Hex() < events[j].ID.Hex()
+	})
+	return events, nil
+}
+
+func (r *memoryRoundUpRepository) ListByUser(ctx context.Context, userID primitive.ObjectID, status string) ([]models.RoundUpEvent, error) {
+	defer r.store.lock(ctx)()
+
+	events := []models.RoundUpEvent{}
+	for _, event := range r.store.data.roundUps {
+		if event.UserID == userID && event.Status == status {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].PurchasedAt.After(events[j].PurchasedAt)
+	})
+	return events, nil
+}
+
+func (r *memoryRoundUpRepository) MarkPosted(ctx context.Context, ids []primitive.ObjectID, transactionID primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	for _, id := range ids {
+		event, ok := r.store.data.roundUps[id]
+		if !ok {
+			continue
+		}
+		event.Status = models.RoundUpPosted
+		event.TransactionID = transactionID
+		event.PostedAt = &at
+		r.store.data.roundUps[id] = event
+	}
+	return nil
+}
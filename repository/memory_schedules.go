@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryScheduleRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryScheduleRepository) Create(ctx context.Context, schedule *models.DepositSchedule) error {
+	defer r.store.lock(ctx)()
+
+	if schedule.ID.IsZero() {
+		schedule.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.schedules[schedule.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memoryScheduleRepository) GetByID(ctx context.Context, userID, scheduleID primitive.ObjectID) (*models.DepositSchedule, error) {
+	defer r.store.lock(ctx)()
+
+	schedule, ok := r.store.data.schedules[scheduleID]
+	if !ok || schedule.UserID != userID {
+		return nil, ErrNotFound
+	}
+	return &schedule, nil
+}
+
+func (r *memoryScheduleRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.DepositSchedule, error) {
+	defer r.store.lock(ctx)()
+
+	schedules := []models.DepositSchedule{}
+	for _, schedule := range r.store.data.schedules {
+		if schedule.UserID == userID {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID.Hex() < schedules[j].ID.Hex()
+	})
+	return schedules, nil
+}
+
+func (r *memoryScheduleRepository) ListDue(ctx context.Context, at time.Time) ([]models.DepositSchedule, error) {
+	defer r.store.lock(ctx)()
+
+	schedules := []models.DepositSchedule{}
+	for _, schedule := range r.store.data.schedules {
+		if schedule.Status == models.ScheduleActive && !schedule.NextRunAt.After(at) {
+			schedules = append(schedules, schedule)
+		}
+	}
+	sort.Slice(schedules, func(i, j int) bool {
+		return schedules[i].NextRunAt.Before(schedules[j].NextRunAt)
+	})
+	return schedules, nil
+}
+
+func (r *memoryScheduleRepository) Update(ctx context.Context, schedule *models.DepositSchedule) error {
+	defer r.store.lock(ctx)()
+
+	stored, ok := r.store.data.schedules[schedule.ID]
+	if !ok || stored.UserID != schedule.UserID {
+		return ErrNotFound
+	}
+	r.store.data.schedules[schedule.ID] = *schedule
+	return nil
+}
+
+func (r *memoryScheduleRepository) AddRun(ctx context.Context, run *models.ScheduleRun) error {
+	defer r.store.lock(ctx)()
+
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	r.store.data.scheduleRuns = append(r.store.data.scheduleRuns, *run)
+	return nil
+}
+
+func (r *memoryScheduleRepository) ListRuns(ctx context.Context, scheduleID primitive.ObjectID, limit int64) ([]models.ScheduleRun, error) {
+	defer r.store.lock(ctx)()
+
+	runs := []models.ScheduleRun{}
+	for _, run := range r.store.data.scheduleRuns {
+		if run.ScheduleID == scheduleID {
+			runs = append(runs, run)
+		}
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		if !runs[i].RanAt.Equal(runs[j].RanAt) {
+			return runs[i].RanAt.After(runs[j].RanAt)
+		}
+		return runs[i].ID.Hex() > runs[j].ID.Hex()
+	})
+	if limit > 0 && int64(len(runs)) > limit {
+		runs = runs[:limit]
+	}
+	return runs, nil
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memorySessionRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	defer r.store.lock(ctx)()
+
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.sessions[session.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r *memorySessionRepository) GetByID(ctx context.Context, sessionID primitive.ObjectID) (*models.Session, error) {
+	defer r.store.lock(ctx)()
+
+	session, ok := r.store.data.sessions[sessionID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &session, nil
+}
+
+func (r *memorySessionRepository) SetMFAVerified(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	session, ok := r.store.data.sessions[sessionID]
+	if !ok {
+		return ErrNotFound
+	}
+	session.MFAVerifiedAt = &at
+	r.store.data.sessions[sessionID] = session
+	return nil
+}
+
+func (r *memorySessionRepository) Refreshed(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	session, ok := r.store.data.sessions[sessionID]
+	if !ok {
+		return ErrNotFound
+	}
+	session.LastRefreshedAt = at
+	r.store.data.sessions[sessionID] = session
+	return nil
+}
+
+func (r *memorySessionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	defer r.store.lock(ctx)()
+
+	sessions := []models.Session{}
+	for _, session := range r.store.data.sessions {
+		if session.UserID == userID {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+	return sessions, nil
+}
+
+func (r *memorySessionRepository) Revoke(ctx context.Context, sessionID primitive.ObjectID, reason string, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	session, ok := r.store.data.sessions[sessionID]
+	if !ok {
+		return ErrNotFound
+	}
+	if !session.IsRevoked() {
+		session.RevokedAt = &at
+		session.RevokedReason = reason
+		r.store.data.sessions[sessionID] = session
+	}
+	return nil
+}
+
+func (r *memorySessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID, reason string, at time.Time) (int64, error) {
+	defer r.store.lock(ctx)()
+
+	var revoked int64
+	for id, session := range r.store.data.sessions {
+		if session.UserID == userID && !session.IsRevoked() {
+			session.RevokedAt = &at
+			session.RevokedReason = reason
+			r.store.data.sessions[id] = session
+			revoked++
+		}
+	}
+	return revoked, nil
+}
+
+func (r *memorySessionRepository) AddRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	defer r.store.lock(ctx)()
+
+	for _, existing := range r.store.data.refreshTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicateKey
+		}
+	}
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	r.store.data.refreshTokens[token.ID] = *token
+	return nil
+}
+
+func (r *memorySessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer r.store.lock(ctx)()
+
+	for _, token := range r.store.data.refreshTokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memorySessionRepository) UseRefreshToken(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	token, ok := r.store.data.refreshTokens[tokenID]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &at
+	r.store.data.refreshTokens[tokenID] = token
+	return nil
+}
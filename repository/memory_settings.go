@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+
+	"micro-savings-app/models"
+)
+
+type memorySettingsRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySettingsRepository) GetAllocationPolicy(ctx context.Context) (*models.AllocationPolicy, error) {
+	defer r.store.lock(ctx)()
+
+	if r.store.data.allocation == nil {
+		return nil, ErrNotFound
+	}
+	policy := *r.store.data.allocation
+	return &policy, nil
+}
+
+func (r *memorySettingsRepository) SetAllocationPolicy(ctx context.Context, policy models.AllocationPolicy) error {
+	defer r.store.lock(ctx)()
+
+	r.store.data.allocation = &policy
+	return nil
+}
+
+func (r *memorySettingsRepository) GetInterestTiers(ctx context.Context) ([]models.InterestTier, error) {
+	defer r.store.lock(ctx)()
+
+	if r.store.data.interestTiers == nil {
+		return nil, ErrNotFound
+	}
+	return append([]models.InterestTier(nil), r.store.data.interestTiers...), nil
+}
+
+func (r *memorySettingsRepository) SetInterestTiers(ctx context.Context, tiers []models.InterestTier) error {
+	defer r.store.lock(ctx)()
+
+	r.store.data.interestTiers = append([]models.InterestTier{}, tiers...)
+	return nil
+}
+
+func (r *memorySettingsRepository) GetStepUpThreshold(ctx context.Context) (*models.Money, error) {
+	defer r.store.lock(ctx)()
+
+	if r.store.data.stepUpThreshold == nil {
+		return nil, ErrNotFound
+	}
+	threshold := *r.store.data.stepUpThreshold
+	return &threshold, nil
+}
+
+func (r *memorySettingsRepository) SetStepUpThreshold(ctx context.Context, threshold models.Money) error {
+	defer r.store.lock(ctx)()
+
+	r.store.data.stepUpThreshold = &threshold
+	return nil
+}
+
+func (r *memorySettingsRepository) GetVelocityLimits(ctx context.Context) (*models.VelocityLimits, error) {
+	defer r.store.lock(ctx)()
+
+	if r.store.data.velocityLimits == nil {
+		return nil, ErrNotFound
+	}
+	limits := *r.store.data.velocityLimits
+	return &limits, nil
+}
+
+func (r *memorySettingsRepository) SetVelocityLimits(ctx context.Context, limits models.VelocityLimits) error {
+	defer r.store.lock(ctx)()
+
+	r.store.data.velocityLimits = &limits
+	return nil
+}
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+)
+
+type memorySigningKeyRepository struct {
+	store *memoryStore
+}
+
+func (r *memorySigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	defer r.store.lock(ctx)()
+
+	if _, exists := r.store.data.signingKeys[key.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.signingKeys[key.ID] = *key
+	return nil
+}
+
+func (r *memorySigningKeyRepository) List(ctx context.Context) ([]models.SigningKey, error) {
+	defer r.store.lock(ctx)()
+
+	keys := make([]models.SigningKey, 0, len(r.store.data.signingKeys))
+	for _, key := range r.store.data.signingKeys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *memorySigningKeyRepository) RetireAllExcept(ctx context.Context, keyID string, at, verifyUntil time.Time) error {
+	defer r.store.lock(ctx)()
+
+	for id, key := range r.store.data.signingKeys {
+		if id != keyID && key.RetiredAt == nil {
+			key.RetiredAt = &at
+			key.VerifyUntil = &verifyUntil
+			r.store.data.signingKeys[id] = key
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"micro-savings-app/models"
+)
+
+type memoryTransactionRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryTransactionRepository) Insert(ctx context.Context, transactions ...models.Transaction) error {
+	defer r.store.lock(ctx)()
+
+	r.store.data.transactions = append(r.store.data.transactions, transactions...)
+	return nil
+}
+
+func (r *memoryTransactionRepository) List(ctx context.Context, f TransactionFilter) ([]models.Transaction, error) {
+	defer r.store.lock(ctx)()
+
+	matches := []models.Transaction{}
+	for _, tx := range r.store.data.transactions {
+		if tx.UserID != f.UserID ||
+			f.Type != "" && tx.Type != string(f.Type) ||
+			f.MinAmount != nil && tx.Amount.Amount < f.MinAmount.Amount ||
+			f.MaxAmount != nil && tx.Amount.Amount > f.MaxAmount.Amount ||
+			!f.From.IsZero() && tx.CreatedAt.Before(f.From) ||
+			!f.To.IsZero() && !tx.CreatedAt.Before(f.To) {
+			continue
+		}
+		if f.After != nil && !comesAfter(tx, *f.After, f.Ascending) {
+			continue
+		}
+		matches = append(matches, tx)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return comesAfter(matches[j], position(matches[i]), f.Ascending)
+	})
+	if f.Limit > 0 && len(matches) > f.Limit {
+		matches = matches[:f.Limit]
+	}
+	return matches, nil
+}
+
+func position(tx models.Transaction) TransactionPosition {
+	return TransactionPosition{CreatedAt: tx.CreatedAt, ID: tx.ID}
+}
+
+// comesAfter reports whether tx sorts after pos in the requested order
+func comesAfter(tx models.Transaction, pos TransactionPosition, ascending bool) bool {
+	less := tx.CreatedAt.Before(pos.CreatedAt) ||
+		tx.CreatedAt.Equal(pos.CreatedAt) && tx.ID.Hex() < pos.ID.Hex()
+	greater := tx.CreatedAt.After(pos.CreatedAt) ||
+		tx.CreatedAt.Equal(pos.CreatedAt) && tx.ID.Hex() > pos.ID.Hex()
+	if ascending {
+		return greater
+	}
+	return less
+}
+
+func (r *memoryTransactionRepository) CountByType(ctx context.Context, txType models.TransactionType) (int64, error) {
+	defer r.store.lock(ctx)()
+
+	var count int64
+	for _, tx := range r.store.data.transactions {
+		if tx.Type == string(txType) {
+			count++
+		}
+	}
+	return count, nil
+}
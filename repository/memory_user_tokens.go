@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUserTokenRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	defer r.store.lock(ctx)()
+
+	for _, existing := range r.store.data.userTokens {
+		if existing.TokenHash == token.TokenHash {
+			return ErrDuplicateKey
+		}
+	}
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	r.store.data.userTokens[token.ID] = *token
+	return nil
+}
+
+func (r *memoryUserTokenRepository) GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	defer r.store.lock(ctx)()
+
+	for _, token := range r.store.data.userTokens {
+		if token.Purpose == purpose && token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserTokenRepository) Use(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	token, ok := r.store.data.userTokens[tokenID]
+	if !ok || token.UsedAt != nil {
+		return ErrNotFound
+	}
+	token.UsedAt = &at
+	r.store.data.userTokens[tokenID] = token
+	return nil
+}
+
+func (r *memoryUserTokenRepository) UseAllForUser(ctx context.Context, userID primitive.ObjectID, purpose string, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	for id, token := range r.store.data.userTokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &at
+			r.store.data.userTokens[id] = token
+		}
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryUserRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	defer r.store.lock(ctx)()
+
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	if _, exists := r.store.data.users[user.ID]; exists {
+		return ErrDuplicateKey
+	}
+	r.store.data.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUserRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+func (r *memoryUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	defer r.store.lock(ctx)()
+
+	for _, user := range r.store.data.users {
+		if user.Email == email {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryUserRepository) SetRoles(ctx context.Context, id primitive.ObjectID, roles []string) error {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Roles = append([]string(nil), roles...)
+	user.UpdatedAt = time.Now()
+	r.store.data.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) SetLastTransactionAt(ctx context.Context, ids []primitive.ObjectID, at time.Time) error {
+	defer r.store.lock(ctx)()
+
+	for _, id := range ids {
+		if user, ok := r.store.data.users[id]; ok {
+			user.LastTransactionAt = at
+			r.store.data.users[id] = user
+		}
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) AdjustBalance(ctx context.Context, id primitive.ObjectID, field BalanceField, delta models.Money, at time.Time) (*models.User, error) {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	balance := field.Balance(&user)
+	if !balance.SameCurrency(delta) {
+		return nil, models.ErrCurrencyMismatch
+	}
+	updated := balance.Add(delta)
+	if updated.IsNegative() {
+		return nil, ErrInsufficientFunds
+	}
+
+	field.set(&user, updated)
+	user.UpdatedAt = at
+	r.store.data.users[id] = user
+	return &user, nil
+}
+
+func (r *memoryUserRepository) SetAllocationPolicy(ctx context.Context, id primitive.ObjectID, policy *models.AllocationPolicy) error {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if policy != nil {
+		copied := *policy
+		policy = &copied
+	}
+	user.AllocationPolicy = policy
+	user.UpdatedAt = time.Now()
+	r.store.data.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) SetVelocityLimits(ctx context.Context, id primitive.ObjectID, limits *models.VelocityLimits) error {
+	return r.update(ctx, id, func(user *models.User) {
+		if limits != nil {
+			copied := *limits
+			limits = &copied
+		}
+		user.VelocityLimits = limits
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetRoundUpRule(ctx context.Context, id primitive.ObjectID, rule *models.RoundUpRule) error {
+	return r.update(ctx, id, func(user *models.User) {
+		if rule != nil {
+			copied := *rule
+			rule = &copied
+		}
+		user.RoundUpRule = rule
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetProductTier(ctx context.Context, id primitive.ObjectID, tier string) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.ProductTier = tier
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, at *time.Time) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.EmailVerifiedAt = at
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetPasswordHash(ctx context.Context, id primitive.ObjectID, hash string) error {
+	return r.update(ctx, id, func(user *models.User) {
+		now := time.Now()
+		user.PasswordHash = hash
+		user.PasswordChangedAt = &now
+		user.UpdatedAt = now
+	})
+}
+
+func (r *memoryUserRepository) SetKYCTier(ctx context.Context, id primitive.ObjectID, tier int) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.KYCTier = tier
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetProfile(ctx context.Context, id primitive.ObjectID, profile models.Profile) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.Name = profile.Name
+		user.Phone = profile.Phone
+		user.DateOfBirth = profile.DateOfBirth
+		user.Address = nil
+		if profile.Address != nil {
+			address := *profile.Address
+			user.Address = &address
+		}
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) SetEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt *time.Time) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.Email = email
+		user.EmailVerifiedAt = verifiedAt
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) Close(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.ClosedAt = &at
+		user.ClosureReason = reason
+		user.UpdatedAt = at
+	})
+}
+
+func (r *memoryUserRepository) SetMFA(ctx context.Context, id primitive.ObjectID, mfa *models.MFASettings) error {
+	return r.update(ctx, id, func(user *models.User) {
+		if mfa != nil {
+			copied := *mfa
+			copied.RecoveryCodes = append([]string(nil), mfa.RecoveryCodes...)
+			mfa = &copied
+		}
+		user.MFA = mfa
+		user.UpdatedAt = time.Now()
+	})
+}
+
+func (r *memoryUserRepository) UseMFAStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok || user.MFA == nil || user.MFA.LastStep >= step {
+		return ErrNotFound
+	}
+	mfa := *user.MFA
+	mfa.LastStep = step
+	user.MFA = &mfa
+	r.store.data.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) error {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok || user.MFA == nil {
+		return ErrNotFound
+	}
+	for i, hash := range user.MFA.RecoveryCodes {
+		if hash == codeHash {
+			mfa := *user.MFA
+			mfa.RecoveryCodes = append(append([]string(nil), mfa.RecoveryCodes[:i]...), mfa.RecoveryCodes[i+1:]...)
+			user.MFA = &mfa
+			r.store.data.users[id] = user
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (r *memoryUserRepository) SetInterestAccrual(ctx context.Context, id primitive.ObjectID, accrual models.InterestAccrual) error {
+	return r.update(ctx, id, func(user *models.User) {
+		user.Interest = accrual
+	})
+}
+
+// update applies fn to a stored user
+func (r *memoryUserRepository) update(ctx context.Context, id primitive.ObjectID, fn func(user *models.User)) error {
+	defer r.store.lock(ctx)()
+
+	user, ok := r.store.data.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	fn(&user)
+	r.store.data.users[id] = user
+	return nil
+}
+
+func (r *memoryUserRepository) CountNonAdmins(ctx context.Context) (int64, error) {
+	defer r.store.lock(ctx)()
+
+	var count int64
+	for _, user := range r.store.data.users {
+		if !user.IsStaff() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *memoryUserRepository) FindIdle(ctx context.Context, idleSince time.Time) ([]models.User, error) {
+	defer r.store.lock(ctx)()
+
+	users := []models.User{}
+	for _, user := range r.store.data.users {
+		if user.SavingsBalance.IsPositive() && user.LastTransactionAt.Before(idleSince) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) FindInvested(ctx context.Context) ([]models.User, error) {
+	defer r.store.lock(ctx)()
+
+	users := []models.User{}
+	for _, user := range r.store.data.users {
+		if user.InvestmentBalance.IsPositive() || user.Interest.Accrued > 0 {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *memoryUserRepository) ForEach(ctx context.Context, fn func(user *models.User) error) error {
+	// Copy first so fn can call back into the store
+	unlock := r.store.lock(ctx)
+	users := make([]models.User, 0, len(r.store.data.users))
+	for _, user := range r.store.data.users {
+		users = append(users, user)
+	}
+	unlock()
+
+	for i := range users {
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"micro-savings-app/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewMongoStore returns a Store backed by the given Mongo database
+func NewMongoStore(db *mongo.Database) *Store {
+	return &Store{
+		Users:        &mongoUserRepository{collection: db.Collection("users")},
+		Transactions: &mongoTransactionRepository{collection: db.Collection("transactions")},
+		Ledger: &mongoLedgerRepository{
+			accounts: db.Collection("ledger_accounts"),
+			entries:  db.Collection("journal_entries"),
+		},
+		Idempotency: &mongoIdempotencyRepository{collection: db.Collection("idempotency_keys")},
+		Settings:    &mongoSettingsRepository{collection: db.Collection("settings")},
+		Redemptions: &mongoRedemptionRepository{collection: db.Collection("redemptions")},
+		Goals:       &mongoGoalRepository{collection: db.Collection("goals")},
+		Schedules: &mongoScheduleRepository{
+			collection: db.Collection("schedules"),
+			runs:       db.Collection("schedule_runs"),
+		},
+		RoundUps: &mongoRoundUpRepository{collection: db.Collection("roundups")},
+		Pools:    &mongoPoolRepository{collection: db.Collection("pools")},
+		Sessions: &mongoSessionRepository{
+			sessions: db.Collection("sessions"),
+			tokens:   db.Collection("refresh_tokens"),
+		},
+		UserTokens:   &mongoUserTokenRepository{collection: db.Collection("user_tokens")},
+		KYC:          &mongoKYCRepository{collection: db.Collection("kyc_submissions")},
+		FraudReviews: &mongoFraudReviewRepository{collection: db.Collection("fraud_reviews")},
+		Logins:       &mongoLoginThrottleRepository{collection: db.Collection("login_throttles")},
+		SigningKeys:  &mongoSigningKeyRepository{collection: db.Collection("signing_keys")},
+		withTransaction: func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return database.WithTransaction(ctx, db.Client(), func(sessCtx mongo.SessionContext) error {
+				return fn(sessCtx)
+			})
+		},
+	}
+}
+
+// notFound maps the driver's "no documents" error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// duplicate maps a unique index violation to ErrDuplicateKey
+func duplicate(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateKey
+	}
+	return err
+}
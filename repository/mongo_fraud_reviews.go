@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoFraudReviewRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoFraudReviewRepository) Create(ctx context.Context, review *models.FraudReview) error {
+	if review.ID.IsZero() {
+		review.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, review)
+	return duplicate(err)
+}
+
+func (r *mongoFraudReviewRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.FraudReview, error) {
+	var review models.FraudReview
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&review); err != nil {
+		return nil, notFound(err)
+	}
+	return &review, nil
+}
+
+func (r *mongoFraudReviewRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.FraudReview, error) {
+	return r.find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+}
+
+func (r *mongoFraudReviewRepository) ListByStatus(ctx context.Context, status string) ([]models.FraudReview, error) {
+	return r.find(ctx, bson.M{"status": status}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+}
+
+func (r *mongoFraudReviewRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.FraudReview, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	reviews := []models.FraudReview{}
+	if err := cursor.All(ctx, &reviews); err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r *mongoFraudReviewRepository) Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.ReviewPending},
+		bson.M{"$set": bson.M{"status": status, "reviewer_id": reviewerID, "review_note": note, "reviewed_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoGoalRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoGoalRepository) Create(ctx context.Context, goal *models.Goal) error {
+	if goal.ID.IsZero() {
+		goal.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, goal)
+	return duplicate(err)
+}
+
+func (r *mongoGoalRepository) GetByID(ctx context.Context, userID, goalID primitive.ObjectID) (*models.Goal, error) {
+	var goal models.Goal
+	if err := r.collection.FindOne(ctx, bson.M{"_id": goalID, "user_id": userID}).Decode(&goal); err != nil {
+		return nil, notFound(err)
+	}
+	return &goal, nil
+}
+
+func (r *mongoGoalRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Goal, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	goals := []models.Goal{}
+	if err := cursor.All(ctx, &goals); err != nil {
+		return nil, err
+	}
+	return goals, nil
+}
+
+func (r *mongoGoalRepository) Update(ctx context.Context, goal *models.Goal) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": goal.ID, "user_id": goal.UserID},
+		bson.M{"$set": bson.M{
+			"name":          goal.Name,
+			"target_amount": goal.TargetAmount,
+			"deadline":      goal.Deadline,
+			"locked_until":  goal.LockedUntil,
+			"updated_at":    goal.UpdatedAt,
+		}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoGoalRepository) AdjustSaved(ctx context.Context, goalID primitive.ObjectID, delta models.Money, at time.Time) (*models.Goal, error) {
+	filter := bson.M{"_id": goalID, "saved_amount.currency": delta.CurrencyCode()}
+	// As with balances, the guard lives in the filter
+	if delta.IsNegative() {
+		filter["saved_amount.amount"] = bson.M{"$gte": -delta.Amount}
+	}
+
+	var goal models.Goal
+	err := r.collection.FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$inc": bson.M{"saved_amount.amount": delta.Amount},
+			"$set": bson.M{"updated_at": at},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&goal)
+	if err == mongo.ErrNoDocuments {
+		var existing models.Goal
+		if err := r.collection.FindOne(ctx, bson.M{"_id": goalID}).Decode(&existing); err != nil {
+			return nil, notFound(err)
+		}
+		if !existing.SavedAmount.SameCurrency(delta) {
+			return nil, models.ErrCurrencyMismatch
+		}
+		return nil, ErrInsufficientFunds
+	} else if err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
+
+func (r *mongoGoalRepository) Delete(ctx context.Context, userID, goalID primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": goalID, "user_id": userID, "saved_amount.amount": 0})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		// Tell a missing goal apart from one that still holds money
+		if _, err := r.GetByID(ctx, userID, goalID); err != nil {
+			return err
+		}
+		return ErrNotEmpty
+	}
+	return nil
+}
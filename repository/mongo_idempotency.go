@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoIdempotencyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoIdempotencyRepository) Create(ctx context.Context, record *models.IdempotencyRecord) error {
+	_, err := r.collection.InsertOne(ctx, record)
+	return duplicate(err)
+}
+
+func (r *mongoIdempotencyRepository) Get(ctx context.Context, userID, key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	err := r.collection.FindOne(ctx, bson.M{"user_id": userID, "key": key}).Decode(&record)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &record, nil
+}
+
+func (r *mongoIdempotencyRepository) Complete(ctx context.Context, userID, key string, status int, body []byte, contentType string) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"user_id": userID, "key": key}, bson.M{"$set": bson.M{
+		"status":          models.IdempotencyCompleted,
+		"response_status": status,
+		"response_body":   body,
+		"content_type":    contentType,
+		"updated_at":      time.Now(),
+	}})
+	return err
+}
+
+func (r *mongoIdempotencyRepository) Delete(ctx context.Context, userID, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "key": key})
+	return err
+}
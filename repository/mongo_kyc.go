@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoKYCRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoKYCRepository) Create(ctx context.Context, submission *models.KYCSubmission) error {
+	if submission.ID.IsZero() {
+		submission.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, submission)
+	return duplicate(err)
+}
+
+func (r *mongoKYCRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.KYCSubmission, error) {
+	var submission models.KYCSubmission
+	if err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&submission); err != nil {
+		return nil, notFound(err)
+	}
+	return &submission, nil
+}
+
+func (r *mongoKYCRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.KYCSubmission, error) {
+	return r.find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "submitted_at", Value: -1}}))
+}
+
+func (r *mongoKYCRepository) ListByStatus(ctx context.Context, status string) ([]models.KYCSubmission, error) {
+	return r.find(ctx, bson.M{"status": status}, options.Find().SetSort(bson.D{{Key: "submitted_at", Value: 1}}))
+}
+
+func (r *mongoKYCRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.KYCSubmission, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	submissions := []models.KYCSubmission{}
+	if err := cursor.All(ctx, &submissions); err != nil {
+		return nil, err
+	}
+	return submissions, nil
+}
+
+func (r *mongoKYCRepository) Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.KYCPending},
+		bson.M{"$set": bson.M{"status": status, "reviewer_id": reviewerID, "review_note": note, "reviewed_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLedgerRepository struct {
+	accounts *mongo.Collection
+	entries  *mongo.Collection
+}
+
+func (r *mongoLedgerRepository) AdjustAccount(ctx context.Context, accountID string, accountType models.AccountType, delta models.Money, at time.Time) (models.Money, error) {
+	var account models.LedgerAccount
+	err := r.accounts.FindOneAndUpdate(ctx,
+		bson.M{"_id": accountID},
+		bson.M{
+			"$inc":         bson.M{"balance.amount": delta.Amount},
+			"$set":         bson.M{"updated_at": at},
+			"$setOnInsert": bson.M{"type": accountType, "balance.currency": delta.CurrencyCode(), "created_at": at},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&account)
+	if err != nil {
+		return models.Money{}, err
+	}
+	return account.Balance, nil
+}
+
+func (r *mongoLedgerRepository) InsertEntry(ctx context.Context, entry *models.JournalEntry) error {
+	_, err := r.entries.InsertOne(ctx, entry)
+	return duplicate(err)
+}
+
+func (r *mongoLedgerRepository) LineTotals(ctx context.Context, accountID, currency string) (int64, int64, error) {
+	cursor, err := r.entries.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"lines.account_id": accountID}}},
+		{{Key: "$unwind", Value: "$lines"}},
+		{{Key: "$match", Value: bson.M{"lines.account_id": accountID, "lines.amount.currency": currency}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   "$lines.direction",
+			"total": bson.M{"$sum": "$lines.amount.amount"},
+		}}},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var debits, credits int64
+	for cursor.Next(ctx) {
+		var total struct {
+			Direction models.Direction `bson:"_id"`
+			Total     int64            `bson:"total"`
+		}
+		if err := cursor.Decode(&total); err != nil {
+			return 0, 0, err
+		}
+		if total.Direction == models.Debit {
+			debits = total.Total
+		} else {
+			credits = total.Total
+		}
+	}
+	return debits, credits, cursor.Err()
+}
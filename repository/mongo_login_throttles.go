@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoLoginThrottleRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoLoginThrottleRepository) Get(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&throttle); err != nil {
+		return nil, notFound(err)
+	}
+	return &throttle, nil
+}
+
+func (r *mongoLoginThrottleRepository) RecordFailure(ctx context.Context, key string, now, since time.Time) (*models.LoginThrottle, error) {
+	// Forget failures from before the window first, so the increment below
+	// starts from zero
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key, "last_failure_at": bson.M{"$lt": since}})
+	if err != nil {
+		return nil, err
+	}
+
+	var throttle models.LoginThrottle
+	err = r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": key},
+		bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"last_failure_at": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&throttle)
+	if err != nil {
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func (r *mongoLoginThrottleRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{"$set": bson.M{"locked_until": until}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoLoginThrottleRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoPoolRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoPoolRepository) Create(ctx context.Context, pool *models.Pool) error {
+	if pool.ID.IsZero() {
+		pool.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, pool)
+	return duplicate(err)
+}
+
+func (r *mongoPoolRepository) GetByID(ctx context.Context, poolID primitive.ObjectID) (*models.Pool, error) {
+	var pool models.Pool
+	if err := r.collection.FindOne(ctx, bson.M{"_id": poolID}).Decode(&pool); err != nil {
+		return nil, notFound(err)
+	}
+	return &pool, nil
+}
+
+func (r *mongoPoolRepository) ListByMember(ctx context.Context, userID primitive.ObjectID) ([]models.Pool, error) {
+	return r.find(ctx, bson.M{"members.user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+func (r *mongoPoolRepository) ListDue(ctx context.Context, at time.Time) ([]models.Pool, error) {
+	return r.find(ctx, bson.M{
+		"status":        bson.M{"$in": bson.A{models.PoolActive, models.PoolSettling}},
+		"next_cycle_at": bson.M{"$lte": at},
+	}, options.Find().SetSort(bson.D{{Key: "next_cycle_at", Value: 1}}))
+}
+
+func (r *mongoPoolRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.Pool, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	pools := []models.Pool{}
+	if err := cursor.All(ctx, &pools); err != nil {
+		return nil, err
+	}
+	return pools, nil
+}
+
+func (r *mongoPoolRepository) Update(ctx context.Context, pool *models.Pool) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": pool.ID}, pool)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRedemptionRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRedemptionRepository) Create(ctx context.Context, redemption *models.RedemptionRequest) error {
+	if redemption.ID.IsZero() {
+		redemption.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, redemption)
+	return duplicate(err)
+}
+
+func (r *mongoRedemptionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.RedemptionRequest, error) {
+	return r.find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "requested_at", Value: -1}, {Key: "_id", Value: -1}}))
+}
+
+func (r *mongoRedemptionRepository) ListDue(ctx context.Context, at time.Time) ([]models.RedemptionRequest, error) {
+	return r.find(ctx, bson.M{"status": models.RedemptionPending, "settle_at": bson.M{"$lte": at}},
+		options.Find().SetSort(bson.D{{Key: "settle_at", Value: 1}}))
+}
+
+func (r *mongoRedemptionRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.RedemptionRequest, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	redemptions := []models.RedemptionRequest{}
+	if err := cursor.All(ctx, &redemptions); err != nil {
+		return nil, err
+	}
+	return redemptions, nil
+}
+
+func (r *mongoRedemptionRepository) Update(ctx context.Context, redemption *models.RedemptionRequest) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": redemption.ID}, redemption)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
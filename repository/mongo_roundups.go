@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoRoundUpRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRoundUpRepository) Create(ctx context.Context, event *models.RoundUpEvent) error {
+	if event.ID.IsZero() {
+		event.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, event)
+	return duplicate(err)
+}
+
+func (r *mongoRoundUpRepository) ListPending(ctx context.Context, before time.Time) ([]models.RoundUpEvent, error) {
+	return r.find(ctx, bson.M{"status": models.RoundUpPending, "batch_date": bson.M{"$lt": before}},
+		options.Find().SetSort(bson.D{{Key: "received_at", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+func (r *mongoRoundUpRepository) ListByUser(ctx context.Context, userID primitive.ObjectID, status string) ([]models.RoundUpEvent, error) {
+	return r.find(ctx, bson.M{"user_id": userID, "status": status},
+		options.Find().SetSort(bson.D{{Key: "purchased_at", Value: -1}}))
+}
+
+func (r *mongoRoundUpRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.RoundUpEvent, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	events := []models.RoundUpEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *mongoRoundUpRepository) MarkPosted(ctx context.Context, ids []primitive.ObjectID, transactionID primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"status": models.RoundUpPosted, "transaction_id": transactionID, "posted_at": at}})
+	return err
+}
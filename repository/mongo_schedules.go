@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoScheduleRepository struct {
+	collection *mongo.Collection
+	runs       *mongo.Collection
+}
+
+func (r *mongoScheduleRepository) Create(ctx context.Context, schedule *models.DepositSchedule) error {
+	if schedule.ID.IsZero() {
+		schedule.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, schedule)
+	return duplicate(err)
+}
+
+func (r *mongoScheduleRepository) GetByID(ctx context.Context, userID, scheduleID primitive.ObjectID) (*models.DepositSchedule, error) {
+	var schedule models.DepositSchedule
+	if err := r.collection.FindOne(ctx, bson.M{"_id": scheduleID, "user_id": userID}).Decode(&schedule); err != nil {
+		return nil, notFound(err)
+	}
+	return &schedule, nil
+}
+
+func (r *mongoScheduleRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.DepositSchedule, error) {
+	return r.find(ctx, bson.M{"user_id": userID},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}}))
+}
+
+func (r *mongoScheduleRepository) ListDue(ctx context.Context, at time.Time) ([]models.DepositSchedule, error) {
+	return r.find(ctx, bson.M{"status": models.ScheduleActive, "next_run_at": bson.M{"$lte": at}},
+		options.Find().SetSort(bson.D{{Key: "next_run_at", Value: 1}}))
+}
+
+func (r *mongoScheduleRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]models.DepositSchedule, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	schedules := []models.DepositSchedule{}
+	if err := cursor.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (r *mongoScheduleRepository) Update(ctx context.Context, schedule *models.DepositSchedule) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": schedule.ID, "user_id": schedule.UserID}, schedule)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoScheduleRepository) AddRun(ctx context.Context, run *models.ScheduleRun) error {
+	if run.ID.IsZero() {
+		run.ID = primitive.NewObjectID()
+	}
+	_, err := r.runs.InsertOne(ctx, run)
+	return duplicate(err)
+}
+
+func (r *mongoScheduleRepository) ListRuns(ctx context.Context, scheduleID primitive.ObjectID, limit int64) ([]models.ScheduleRun, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "ran_at", Value: -1}, {Key: "_id", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+	cursor, err := r.runs.Find(ctx, bson.M{"schedule_id": scheduleID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	runs := []models.ScheduleRun{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
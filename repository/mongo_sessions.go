@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSessionRepository struct {
+	sessions *mongo.Collection
+	tokens   *mongo.Collection
+}
+
+func (r *mongoSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	if session.ID.IsZero() {
+		session.ID = primitive.NewObjectID()
+	}
+	_, err := r.sessions.InsertOne(ctx, session)
+	return duplicate(err)
+}
+
+func (r *mongoSessionRepository) GetByID(ctx context.Context, sessionID primitive.ObjectID) (*models.Session, error) {
+	var session models.Session
+	if err := r.sessions.FindOne(ctx, bson.M{"_id": sessionID}).Decode(&session); err != nil {
+		return nil, notFound(err)
+	}
+	return &session, nil
+}
+
+func (r *mongoSessionRepository) ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	cursor, err := r.sessions.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []models.Session{}
+	if err := cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *mongoSessionRepository) SetMFAVerified(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error {
+	return r.set(ctx, sessionID, bson.M{"mfa_verified_at": at})
+}
+
+func (r *mongoSessionRepository) Refreshed(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error {
+	return r.set(ctx, sessionID, bson.M{"last_refreshed_at": at})
+}
+
+// set updates fields of one session
+func (r *mongoSessionRepository) set(ctx context.Context, sessionID primitive.ObjectID, fields bson.M) error {
+	result, err := r.sessions.UpdateOne(ctx, bson.M{"_id": sessionID}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSessionRepository) Revoke(ctx context.Context, sessionID primitive.ObjectID, reason string, at time.Time) error {
+	if _, err := r.GetByID(ctx, sessionID); err != nil {
+		return err
+	}
+	_, err := r.sessions.UpdateOne(ctx,
+		bson.M{"_id": sessionID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at, "revoked_reason": reason}})
+	return err
+}
+
+func (r *mongoSessionRepository) RevokeAllForUser(ctx context.Context, userID primitive.ObjectID, reason string, at time.Time) (int64, error) {
+	result, err := r.sessions.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at, "revoked_reason": reason}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *mongoSessionRepository) AddRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := r.tokens.InsertOne(ctx, token)
+	return duplicate(err)
+}
+
+func (r *mongoSessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.tokens.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token); err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r *mongoSessionRepository) UseRefreshToken(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error {
+	// Matching only unused tokens makes the swap atomic, so two refreshes
+	// racing with the same token can't both succeed
+	result, err := r.tokens.UpdateOne(ctx,
+		bson.M{"_id": tokenID, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Settings are stored one document per setting, keyed by name
+const (
+	allocationPolicySetting = "allocation_policy"
+	interestTiersSetting    = "interest_tiers"
+	stepUpThresholdSetting  = "step_up_threshold"
+	velocityLimitsSetting   = "velocity_limits"
+)
+
+type mongoSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoSettingsRepository) GetAllocationPolicy(ctx context.Context) (*models.AllocationPolicy, error) {
+	var policy models.AllocationPolicy
+	if err := r.get(ctx, allocationPolicySetting, &policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (r *mongoSettingsRepository) SetAllocationPolicy(ctx context.Context, policy models.AllocationPolicy) error {
+	return r.set(ctx, allocationPolicySetting, policy)
+}
+
+func (r *mongoSettingsRepository) GetInterestTiers(ctx context.Context) ([]models.InterestTier, error) {
+	var tiers []models.InterestTier
+	if err := r.get(ctx, interestTiersSetting, &tiers); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+func (r *mongoSettingsRepository) SetInterestTiers(ctx context.Context, tiers []models.InterestTier) error {
+	return r.set(ctx, interestTiersSetting, tiers)
+}
+
+func (r *mongoSettingsRepository) GetStepUpThreshold(ctx context.Context) (*models.Money, error) {
+	var threshold models.Money
+	if err := r.get(ctx, stepUpThresholdSetting, &threshold); err != nil {
+		return nil, err
+	}
+	return &threshold, nil
+}
+
+func (r *mongoSettingsRepository) SetStepUpThreshold(ctx context.Context, threshold models.Money) error {
+	return r.set(ctx, stepUpThresholdSetting, threshold)
+}
+
+func (r *mongoSettingsRepository) GetVelocityLimits(ctx context.Context) (*models.VelocityLimits, error) {
+	var limits models.VelocityLimits
+	if err := r.get(ctx, velocityLimitsSetting, &limits); err != nil {
+		return nil, err
+	}
+	return &limits, nil
+}
+
+func (r *mongoSettingsRepository) SetVelocityLimits(ctx context.Context, limits models.VelocityLimits) error {
+	return r.set(ctx, velocityLimitsSetting, limits)
+}
+
+// get decodes the value of a setting into out
+func (r *mongoSettingsRepository) get(ctx context.Context, name string, out interface{}) error {
+	var doc struct {
+		Value bson.RawValue `bson:"value"`
+	}
+	if err := r.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&doc); err != nil {
+		return notFound(err)
+	}
+	return doc.Value.Unmarshal(out)
+}
+
+func (r *mongoSettingsRepository) set(ctx context.Context, name string, value interface{}) error {
+	_, err := r.collection.UpdateByID(ctx, name,
+		bson.M{"$set": bson.M{"value": value}},
+		options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoSigningKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoSigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	return duplicate(err)
+}
+
+func (r *mongoSigningKeyRepository) List(ctx context.Context) ([]models.SigningKey, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	keys := []models.SigningKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *mongoSigningKeyRepository) RetireAllExcept(ctx context.Context, keyID string, at, verifyUntil time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$ne": keyID}, "retired_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"retired_at": at, "verify_until": verifyUntil}})
+	return err
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoTransactionRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoTransactionRepository) Insert(ctx context.Context, transactions ...models.Transaction) error {
+	if len(transactions) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(transactions))
+	for i := range transactions {
+		docs[i] = transactions[i]
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *mongoTransactionRepository) List(ctx context.Context, f TransactionFilter) ([]models.Transaction, error) {
+	filter := bson.M{"user_id": f.UserID}
+	if f.Type != "" {
+		filter["type"] = string(f.Type)
+	}
+
+	amount := bson.M{}
+	if f.MinAmount != nil {
+		amount["$gte"] = f.MinAmount.Amount
+	}
+	if f.MaxAmount != nil {
+		amount["$lte"] = f.MaxAmount.Amount
+	}
+	if len(amount) > 0 {
+		filter["amount.amount"] = amount
+	}
+
+	createdAt := bson.M{}
+	if !f.From.IsZero() {
+		createdAt["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		createdAt["$lt"] = f.To
+	}
+	if len(createdAt) > 0 {
+		filter["created_at"] = createdAt
+	}
+
+	// Continue strictly after the given row
+	if f.After != nil {
+		op := "$lt"
+		if f.Ascending {
+			op = "$gt"
+		}
+		filter["$or"] = bson.A{
+			bson.M{"created_at": bson.M{op: f.After.CreatedAt}},
+			bson.M{"created_at": f.After.CreatedAt, "_id": bson.M{op: f.After.ID}},
+		}
+	}
+
+	order := -1
+	if f.Ascending {
+		order = 1
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: order}, {Key: "_id", Value: order}})
+	if f.Limit > 0 {
+		opts.SetLimit(int64(f.Limit))
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	transactions := []models.Transaction{}
+	if err := cursor.All(ctx, &transactions); err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+func (r *mongoTransactionRepository) CountByType(ctx context.Context, txType models.TransactionType) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"type": string(txType)})
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type mongoUserTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserTokenRepository) Create(ctx context.Context, token *models.UserToken) error {
+	if token.ID.IsZero() {
+		token.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, token)
+	return duplicate(err)
+}
+
+func (r *mongoUserTokenRepository) GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := r.collection.FindOne(ctx, bson.M{"purpose": purpose, "token_hash": tokenHash}).Decode(&token); err != nil {
+		return nil, notFound(err)
+	}
+	return &token, nil
+}
+
+func (r *mongoUserTokenRepository) Use(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": tokenID, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": at}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserTokenRepository) UseAllForUser(ctx context.Context, userID primitive.ObjectID, purpose string, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": at}})
+	return err
+}
@@ -0,0 +1,314 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.ID.IsZero() {
+		user.ID = primitive.NewObjectID()
+	}
+	_, err := r.collection.InsertOne(ctx, user)
+	return duplicate(err)
+}
+
+func (r *mongoUserRepository) GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+func (r *mongoUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findOne(ctx, bson.M{"email": email})
+}
+
+func (r *mongoUserRepository) findOne(ctx context.Context, filter bson.M) (*models.User, error) {
+	var user models.User
+	if err := r.collection.FindOne(ctx, filter).Decode(&user); err != nil {
+		return nil, notFound(err)
+	}
+	return &user, nil
+}
+
+func (r *mongoUserRepository) SetRoles(ctx context.Context, id primitive.ObjectID, roles []string) error {
+	update := bson.M{"$set": bson.M{"roles": roles, "updated_at": time.Now()}}
+	if len(roles) == 0 {
+		update = bson.M{"$unset": bson.M{"roles": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+	result, err := r.collection.UpdateByID(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetLastTransactionAt(ctx context.Context, ids []primitive.ObjectID, at time.Time) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"last_transaction_at": at}})
+	return err
+}
+
+func (r *mongoUserRepository) AdjustBalance(ctx context.Context, id primitive.ObjectID, field BalanceField, delta models.Money, at time.Time) (*models.User, error) {
+	name := string(field)
+	filter := bson.M{"_id": id, name + ".currency": delta.CurrencyCode()}
+	// The guard lives in the filter so concurrent debits can't both pass it
+	if delta.IsNegative() {
+		filter[name+".amount"] = bson.M{"$gte": -delta.Amount}
+	}
+
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, filter,
+		bson.M{
+			"$inc": bson.M{name + ".amount": delta.Amount},
+			"$set": bson.M{"updated_at": at},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return nil, r.explainFailedAdjustment(ctx, id, field, delta)
+	} else if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// explainFailedAdjustment works out why a guarded balance update matched no document
+func (r *mongoUserRepository) explainFailedAdjustment(ctx context.Context, id primitive.ObjectID, field BalanceField, delta models.Money) error {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !field.Balance(user).SameCurrency(delta) {
+		return models.ErrCurrencyMismatch
+	}
+	return ErrInsufficientFunds
+}
+
+func (r *mongoUserRepository) SetAllocationPolicy(ctx context.Context, id primitive.ObjectID, policy *models.AllocationPolicy) error {
+	update := bson.M{"$set": bson.M{"allocation_policy": policy, "updated_at": time.Now()}}
+	if policy == nil {
+		update = bson.M{
+			"$unset": bson.M{"allocation_policy": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		}
+	}
+
+	result, err := r.collection.UpdateByID(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetVelocityLimits(ctx context.Context, id primitive.ObjectID, limits *models.VelocityLimits) error {
+	update := bson.M{"$set": bson.M{"velocity_limits": limits, "updated_at": time.Now()}}
+	if limits == nil {
+		update = bson.M{
+			"$unset": bson.M{"velocity_limits": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		}
+	}
+
+	result, err := r.collection.UpdateByID(ctx, id, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetRoundUpRule(ctx context.Context, id primitive.ObjectID, rule *models.RoundUpRule) error {
+	if rule == nil {
+		result, err := r.collection.UpdateByID(ctx, id, bson.M{
+			"$unset": bson.M{"roundup_rule": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}
+	return r.set(ctx, id, bson.M{"roundup_rule": rule, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) SetProductTier(ctx context.Context, id primitive.ObjectID, tier string) error {
+	return r.set(ctx, id, bson.M{"product_tier": tier, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) SetEmailVerified(ctx context.Context, id primitive.ObjectID, at *time.Time) error {
+	if at == nil {
+		result, err := r.collection.UpdateByID(ctx, id, bson.M{
+			"$unset": bson.M{"email_verified_at": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}
+	return r.set(ctx, id, bson.M{"email_verified_at": *at, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) SetPasswordHash(ctx context.Context, id primitive.ObjectID, hash string) error {
+	now := time.Now()
+	return r.set(ctx, id, bson.M{"password_hash": hash, "password_changed_at": now, "updated_at": now})
+}
+
+func (r *mongoUserRepository) SetKYCTier(ctx context.Context, id primitive.ObjectID, tier int) error {
+	return r.set(ctx, id, bson.M{"kyc_tier": tier, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) SetProfile(ctx context.Context, id primitive.ObjectID, profile models.Profile) error {
+	return r.set(ctx, id, bson.M{
+		"name":          profile.Name,
+		"phone":         profile.Phone,
+		"date_of_birth": profile.DateOfBirth,
+		"address":       profile.Address,
+		"updated_at":    time.Now(),
+	})
+}
+
+func (r *mongoUserRepository) SetEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt *time.Time) error {
+	return r.set(ctx, id, bson.M{"email": email, "email_verified_at": verifiedAt, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) Close(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error {
+	return r.set(ctx, id, bson.M{"closed_at": at, "closure_reason": reason, "updated_at": at})
+}
+
+func (r *mongoUserRepository) SetMFA(ctx context.Context, id primitive.ObjectID, mfa *models.MFASettings) error {
+	if mfa == nil {
+		result, err := r.collection.UpdateByID(ctx, id, bson.M{
+			"$unset": bson.M{"mfa": ""},
+			"$set":   bson.M{"updated_at": time.Now()},
+		})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	}
+	return r.set(ctx, id, bson.M{"mfa": mfa, "updated_at": time.Now()})
+}
+
+func (r *mongoUserRepository) UseMFAStep(ctx context.Context, id primitive.ObjectID, step int64) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "mfa.last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"mfa.last_step": step}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) error {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "mfa.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"mfa.recovery_codes": codeHash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) SetInterestAccrual(ctx context.Context, id primitive.ObjectID, accrual models.InterestAccrual) error {
+	return r.set(ctx, id, bson.M{"interest": accrual})
+}
+
+// set updates fields of one user
+func (r *mongoUserRepository) set(ctx context.Context, id primitive.ObjectID, fields bson.M) error {
+	result, err := r.collection.UpdateByID(ctx, id, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) CountNonAdmins(ctx context.Context) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"roles.0": bson.M{"$exists": false}})
+}
+
+func (r *mongoUserRepository) FindIdle(ctx context.Context, idleSince time.Time) ([]models.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{
+		"savings_balance.amount": bson.M{"$gt": 0},
+		"last_transaction_at":    bson.M{"$lt": idleSince},
+	})
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) FindInvested(ctx context.Context) ([]models.User, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"$or": bson.A{
+		bson.M{"investment_balance.amount": bson.M{"$gt": 0}},
+		bson.M{"interest.accrued": bson.M{"$gt": 0}},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	users := []models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *mongoUserRepository) ForEach(ctx context.Context, fn func(user *models.User) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,341 @@
+// Package repository hides how data is stored from handlers, services and jobs.
+// Each collection has an interface with a Mongo implementation for production
+// and a thread-safe in-memory implementation used by the test suite.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"micro-savings-app/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrDuplicateKey      = errors.New("duplicate key")
+	ErrInsufficientFunds = errors.New("insufficient balance")
+	ErrNotEmpty          = errors.New("still holds money")
+)
+
+// BalanceField names a cached balance on the user document
+type BalanceField string
+
+const (
+	SavingsBalance    BalanceField = "savings_balance"
+	InvestmentBalance BalanceField = "investment_balance"
+	GoalsBalance      BalanceField = "goals_balance"
+)
+
+// Balance returns the value of a cached balance field
+func (f BalanceField) Balance(user *models.User) models.Money {
+	switch f {
+	case InvestmentBalance:
+		return user.InvestmentBalance
+	case GoalsBalance:
+		return user.GoalsBalance
+	default:
+		return user.SavingsBalance
+	}
+}
+
+// set stores a new value in a cached balance field
+func (f BalanceField) set(user *models.User, balance models.Money) {
+	switch f {
+	case InvestmentBalance:
+		user.InvestmentBalance = balance
+	case GoalsBalance:
+		user.GoalsBalance = balance
+	default:
+		user.SavingsBalance = balance
+	}
+}
+
+type UserRepository interface {
+	// Create inserts a new user and sets its ID
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	// SetRoles replaces a user's staff roles; none makes them a customer
+	SetRoles(ctx context.Context, id primitive.ObjectID, roles []string) error
+	SetLastTransactionAt(ctx context.Context, ids []primitive.ObjectID, at time.Time) error
+	// SetEmailVerified records when the user's email was verified; nil clears it
+	SetEmailVerified(ctx context.Context, id primitive.ObjectID, at *time.Time) error
+	// SetPasswordHash replaces a user's password and records when it changed
+	SetPasswordHash(ctx context.Context, id primitive.ObjectID, hash string) error
+	// SetKYCTier records the highest KYC tier a user has been approved for
+	SetKYCTier(ctx context.Context, id primitive.ObjectID, tier int) error
+	// SetProfile replaces a user's personal details
+	SetProfile(ctx context.Context, id primitive.ObjectID, profile models.Profile) error
+	// SetEmail changes a user's email address and when it was verified
+	SetEmail(ctx context.Context, id primitive.ObjectID, email string, verifiedAt *time.Time) error
+	// Close marks a user's account closed with the reason given
+	Close(ctx context.Context, id primitive.ObjectID, reason string, at time.Time) error
+	// SetMFA replaces a user's two-factor settings; nil turns it off
+	SetMFA(ctx context.Context, id primitive.ObjectID, mfa *models.MFASettings) error
+	// UseMFAStep accepts a TOTP time step if it is later than the last one
+	// used; ErrNotFound if it isn't, so each code works once
+	UseMFAStep(ctx context.Context, id primitive.ObjectID, step int64) error
+	// UseRecoveryCode removes a recovery code hash; ErrNotFound if the user
+	// doesn't hold it
+	UseRecoveryCode(ctx context.Context, id primitive.ObjectID, codeHash string) error
+	// AdjustBalance adds delta to a cached balance and returns the updated user.
+	// It fails with ErrInsufficientFunds rather than let the balance go negative,
+	// ErrNotFound for an unknown user, and models.ErrCurrencyMismatch if the
+	// balance is held in another currency.
+	AdjustBalance(ctx context.Context, id primitive.ObjectID, field BalanceField, delta models.Money, at time.Time) (*models.User, error)
+	// SetAllocationPolicy replaces a user's own allocation policy; nil clears it
+	SetAllocationPolicy(ctx context.Context, id primitive.ObjectID, policy *models.AllocationPolicy) error
+	// SetVelocityLimits replaces a user's own velocity limits; nil clears them
+	SetVelocityLimits(ctx context.Context, id primitive.ObjectID, limits *models.VelocityLimits) error
+	// SetRoundUpRule replaces a user's round-up rule; nil turns round-ups off
+	SetRoundUpRule(ctx context.Context, id primitive.ObjectID, rule *models.RoundUpRule) error
+	SetProductTier(ctx context.Context, id primitive.ObjectID, tier string) error
+	SetInterestAccrual(ctx context.Context, id primitive.ObjectID, accrual models.InterestAccrual) error
+	CountNonAdmins(ctx context.Context) (int64, error)
+	// FindIdle returns users with savings whose last transaction is before idleSince
+	FindIdle(ctx context.Context, idleSince time.Time) ([]models.User, error)
+	// FindInvested returns users with an investment balance or unpaid interest
+	FindInvested(ctx context.Context) ([]models.User, error)
+	// ForEach calls fn for every user, stopping at the first error
+	ForEach(ctx context.Context, fn func(user *models.User) error) error
+}
+
+// TransactionPosition identifies a row in transaction history, for paging
+type TransactionPosition struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// TransactionFilter selects a user's transactions. Rows are ordered by creation
+// time then ID, and only rows strictly after After (in that order) are returned.
+type TransactionFilter struct {
+	UserID    primitive.ObjectID
+	Type      models.TransactionType
+	MinAmount *models.Money
+	MaxAmount *models.Money
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	Ascending bool
+	After     *TransactionPosition
+	Limit     int
+}
+
+type TransactionRepository interface {
+	Insert(ctx context.Context, transactions ...models.Transaction) error
+	List(ctx context.Context, filter TransactionFilter) ([]models.Transaction, error)
+	CountByType(ctx context.Context, txType models.TransactionType) (int64, error)
+}
+
+type LedgerRepository interface {
+	// AdjustAccount adds delta to a system account's cached balance, creating
+	// the account on first use, and returns the new balance
+	AdjustAccount(ctx context.Context, accountID string, accountType models.AccountType, delta models.Money, at time.Time) (models.Money, error)
+	// InsertEntry stores a journal entry; references are unique (ErrDuplicateKey)
+	InsertEntry(ctx context.Context, entry *models.JournalEntry) error
+	// LineTotals sums the debit and credit lines posted to an account
+	LineTotals(ctx context.Context, accountID, currency string) (debits, credits int64, err error)
+}
+
+type IdempotencyRepository interface {
+	// Create claims a key for a user; ErrDuplicateKey if it is already taken
+	Create(ctx context.Context, record *models.IdempotencyRecord) error
+	Get(ctx context.Context, userID, key string) (*models.IdempotencyRecord, error)
+	Complete(ctx context.Context, userID, key string, status int, body []byte, contentType string) error
+	Delete(ctx context.Context, userID, key string) error
+}
+
+type RedemptionRepository interface {
+	Create(ctx context.Context, redemption *models.RedemptionRequest) error
+	// ListByUser returns a user's redemptions, newest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.RedemptionRequest, error)
+	// ListDue returns pending redemptions due to settle at or before the given time
+	ListDue(ctx context.Context, at time.Time) ([]models.RedemptionRequest, error)
+	// Update saves the status and outcome of a redemption
+	Update(ctx context.Context, redemption *models.RedemptionRequest) error
+}
+
+type GoalRepository interface {
+	// Create inserts a new goal and sets its ID
+	Create(ctx context.Context, goal *models.Goal) error
+	// GetByID returns one of a user's goals
+	GetByID(ctx context.Context, userID, goalID primitive.ObjectID) (*models.Goal, error)
+	// ListByUser returns a user's goals, oldest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Goal, error)
+	// Update saves a goal's name, target, deadline and lock; the saved amount
+	// only changes through AdjustSaved
+	Update(ctx context.Context, goal *models.Goal) error
+	// AdjustSaved adds delta to a goal's saved amount, failing with
+	// ErrInsufficientFunds rather than let it go negative
+	AdjustSaved(ctx context.Context, goalID primitive.ObjectID, delta models.Money, at time.Time) (*models.Goal, error)
+	// Delete removes a goal, failing with ErrNotEmpty if anything is saved in it
+	Delete(ctx context.Context, userID, goalID primitive.ObjectID) error
+}
+
+type ScheduleRepository interface {
+	// Create inserts a new schedule and sets its ID
+	Create(ctx context.Context, schedule *models.DepositSchedule) error
+	// GetByID returns one of a user's schedules
+	GetByID(ctx context.Context, userID, scheduleID primitive.ObjectID) (*models.DepositSchedule, error)
+	// ListByUser returns a user's schedules, oldest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.DepositSchedule, error)
+	// ListDue returns active schedules with a run due at or before the given time
+	ListDue(ctx context.Context, at time.Time) ([]models.DepositSchedule, error)
+	Update(ctx context.Context, schedule *models.DepositSchedule) error
+	// AddRun records the outcome of one attempt at a scheduled deposit
+	AddRun(ctx context.Context, run *models.ScheduleRun) error
+	// ListRuns returns a schedule's runs, newest first; limit 0 means all
+	ListRuns(ctx context.Context, scheduleID primitive.ObjectID, limit int64) ([]models.ScheduleRun, error)
+}
+
+type RoundUpRepository interface {
+	// Create stores a round-up; ErrDuplicateKey if its event ID was seen before
+	Create(ctx context.Context, event *models.RoundUpEvent) error
+	// ListPending returns pending round-ups in batches for days before the
+	// given time, in the order they arrived
+	ListPending(ctx context.Context, before time.Time) ([]models.RoundUpEvent, error)
+	// ListByUser returns a user's round-ups with the given status, newest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID, status string) ([]models.RoundUpEvent, error)
+	// MarkPosted records that round-ups were saved by the given transaction
+	MarkPosted(ctx context.Context, ids []primitive.ObjectID, transactionID primitive.ObjectID, at time.Time) error
+}
+
+type PoolRepository interface {
+	// Create inserts a new pool and sets its ID
+	Create(ctx context.Context, pool *models.Pool) error
+	GetByID(ctx context.Context, poolID primitive.ObjectID) (*models.Pool, error)
+	// ListByMember returns the pools a user belongs to, oldest first
+	ListByMember(ctx context.Context, userID primitive.ObjectID) ([]models.Pool, error)
+	// ListDue returns running pools with a cycle due at or before the given time
+	ListDue(ctx context.Context, at time.Time) ([]models.Pool, error)
+	// Update saves the whole pool; read and write it inside
+	// store.WithTransaction so concurrent changes are not lost
+	Update(ctx context.Context, pool *models.Pool) error
+}
+
+type SessionRepository interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByID(ctx context.Context, sessionID primitive.ObjectID) (*models.Session, error)
+	// ListByUser returns every session of a user, revoked ones included,
+	// newest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error)
+	// SetMFAVerified records when a two-factor code was last entered in a session
+	SetMFAVerified(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error
+	// Refreshed records that a session swapped its refresh token
+	Refreshed(ctx context.Context, sessionID primitive.ObjectID, at time.Time) error
+	// Revoke ends a session; revoking one already revoked keeps the first reason
+	Revoke(ctx context.Context, sessionID primitive.ObjectID, reason string, at time.Time) error
+	// RevokeAllForUser ends every live session of a user and returns how many
+	RevokeAllForUser(ctx context.Context, userID primitive.ObjectID, reason string, at time.Time) (int64, error)
+	AddRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// UseRefreshToken marks a token swapped; ErrNotFound if it already was
+	UseRefreshToken(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error
+}
+
+type UserTokenRepository interface {
+	// Create stores a new token; ErrDuplicateKey if its hash is already taken
+	Create(ctx context.Context, token *models.UserToken) error
+	GetByHash(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error)
+	// Use marks a token spent; ErrNotFound if it already was
+	Use(ctx context.Context, tokenID primitive.ObjectID, at time.Time) error
+	// UseAllForUser spends every outstanding token of a user for a purpose
+	UseAllForUser(ctx context.Context, userID primitive.ObjectID, purpose string, at time.Time) error
+}
+
+type KYCRepository interface {
+	// Create inserts a new submission and sets its ID
+	Create(ctx context.Context, submission *models.KYCSubmission) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.KYCSubmission, error)
+	// ListByUser returns a user's submissions, newest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.KYCSubmission, error)
+	// ListByStatus returns submissions with the status, oldest first
+	ListByStatus(ctx context.Context, status string) ([]models.KYCSubmission, error)
+	// Review records the outcome of a pending submission; ErrNotFound if it
+	// has already been reviewed
+	Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error
+}
+
+type FraudReviewRepository interface {
+	// Create inserts a new review and sets its ID
+	Create(ctx context.Context, review *models.FraudReview) error
+	GetByID(ctx context.Context, id primitive.ObjectID) (*models.FraudReview, error)
+	// ListByUser returns a user's reviews, newest first
+	ListByUser(ctx context.Context, userID primitive.ObjectID) ([]models.FraudReview, error)
+	// ListByStatus returns reviews with the status, oldest first
+	ListByStatus(ctx context.Context, status string) ([]models.FraudReview, error)
+	// Review records the outcome of a pending review; ErrNotFound if it has
+	// already been decided
+	Review(ctx context.Context, id primitive.ObjectID, status string, reviewerID primitive.ObjectID, note string, at time.Time) error
+}
+
+// LoginThrottleRepository counts failed logins by account and IP address
+type LoginThrottleRepository interface {
+	// Get returns the counter for a key, ErrNotFound if it has none
+	Get(ctx context.Context, key string) (*models.LoginThrottle, error)
+	// RecordFailure counts a failed login against a key and returns the
+	// updated counter. A counter whose last failure was before since starts
+	// again from zero.
+	RecordFailure(ctx context.Context, key string, now, since time.Time) (*models.LoginThrottle, error)
+	// Lock blocks logins for a key until the given time
+	Lock(ctx context.Context, key string, until time.Time) error
+	// Reset clears the counter and any lock for a key
+	Reset(ctx context.Context, key string) error
+}
+
+// SigningKeyRepository holds the key pairs access tokens are signed with
+type SigningKeyRepository interface {
+	Create(ctx context.Context, key *models.SigningKey) error
+	// List returns every key not yet removed, newest first
+	List(ctx context.Context) ([]models.SigningKey, error)
+	// RetireAllExcept stops every other key that has not been retired
+	// signing at at, letting each verify until verifyUntil
+	RetireAllExcept(ctx context.Context, keyID string, at, verifyUntil time.Time) error
+}
+
+type SettingsRepository interface {
+	// GetAllocationPolicy returns the global allocation policy, ErrNotFound if never set
+	GetAllocationPolicy(ctx context.Context) (*models.AllocationPolicy, error)
+	SetAllocationPolicy(ctx context.Context, policy models.AllocationPolicy) error
+	// GetInterestTiers returns the configured interest tiers, ErrNotFound if never set
+	GetInterestTiers(ctx context.Context) ([]models.InterestTier, error)
+	SetInterestTiers(ctx context.Context, tiers []models.InterestTier) error
+	// GetStepUpThreshold returns the withdrawal amount above which a fresh
+	// two-factor code is needed, ErrNotFound if never set
+	GetStepUpThreshold(ctx context.Context) (*models.Money, error)
+	SetStepUpThreshold(ctx context.Context, threshold models.Money) error
+	// GetVelocityLimits returns the global velocity limits, ErrNotFound if never set
+	GetVelocityLimits(ctx context.Context) (*models.VelocityLimits, error)
+	SetVelocityLimits(ctx context.Context, limits models.VelocityLimits) error
+}
+
+// Store bundles the repositories the application needs
+type Store struct {
+	Users        UserRepository
+	Transactions TransactionRepository
+	Ledger       LedgerRepository
+	Idempotency  IdempotencyRepository
+	Settings     SettingsRepository
+	Redemptions  RedemptionRepository
+	Goals        GoalRepository
+	Schedules    ScheduleRepository
+	RoundUps     RoundUpRepository
+	Pools        PoolRepository
+	Sessions     SessionRepository
+	UserTokens   UserTokenRepository
+	KYC          KYCRepository
+	FraudReviews FraudReviewRepository
+	Logins       LoginThrottleRepository
+	SigningKeys  SigningKeyRepository
+
+	withTransaction func(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// WithTransaction runs fn atomically: every repository call made with the
+// context passed to fn commits together or not at all. fn may be retried, so
+// it must not have side effects outside the store.
+func (s *Store) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return s.withTransaction(ctx, fn)
+}
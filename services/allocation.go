@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Where the policy applied to a user comes from
+const (
+	PolicySourceGlobal = "global"
+	PolicySourceUser   = "user"
+)
+
+// GlobalAllocationPolicy returns the policy that applies to users without their
+// own, falling back to models.DefaultAllocationPolicy until an admin sets one
+func GlobalAllocationPolicy(ctx context.Context, store *repository.Store) (models.AllocationPolicy, error) {
+	policy, err := store.Settings.GetAllocationPolicy(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultAllocationPolicy(), nil
+	} else if err != nil {
+		return models.AllocationPolicy{}, err
+	}
+	return *policy, nil
+}
+
+// EffectiveAllocationPolicy returns the policy the allocation job uses for a
+// user and whether it is the user's own or the global one
+func EffectiveAllocationPolicy(ctx context.Context, store *repository.Store, user *models.User) (models.AllocationPolicy, string, error) {
+	if user.AllocationPolicy != nil {
+		return *user.AllocationPolicy, PolicySourceUser, nil
+	}
+	policy, err := GlobalAllocationPolicy(ctx, store)
+	return policy, PolicySourceGlobal, err
+}
+
+// SetGlobalAllocationPolicy validates and saves the global policy
+func SetGlobalAllocationPolicy(ctx context.Context, store *repository.Store, policy models.AllocationPolicy) (models.AllocationPolicy, error) {
+	policy = normalizePolicy(policy)
+	if err := policy.Validate(); err != nil {
+		return policy, err
+	}
+	return policy, store.Settings.SetAllocationPolicy(ctx, policy)
+}
+
+// SetUserAllocationPolicy validates and saves a user's own policy. A nil policy
+// puts the user back on the global one.
+func SetUserAllocationPolicy(ctx context.Context, store *repository.Store, userID primitive.ObjectID, policy *models.AllocationPolicy) error {
+	if policy != nil {
+		normalized := normalizePolicy(*policy)
+		if err := normalized.Validate(); err != nil {
+			return err
+		}
+		policy = &normalized
+	}
+
+	err := store.Users.SetAllocationPolicy(ctx, userID, policy)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// normalizePolicy fills in currencies and drops the setting the sweep mode ignores
+func normalizePolicy(policy models.AllocationPolicy) models.AllocationPolicy {
+	policy.MinLiquidBalance = models.NewMoney(policy.MinLiquidBalance.Amount, policy.MinLiquidBalance.CurrencyCode())
+	switch policy.SweepMode {
+	case models.SweepPercentage:
+		policy.SweepAmount = nil
+	case models.SweepFixed:
+		policy.SweepPercent = 0
+	}
+	policy.UpdatedAt = time.Now()
+	return policy
+}
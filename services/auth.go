@@ -5,56 +5,106 @@ import (
 	"os"
 	"time"
 
+	"micro-savings-app/models"
+
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/joho/godotenv"
 )
 
-// GenerateJWT generates a JWT token for a given user ID
-func GenerateJWT(userID string) (string, error) {
-	secret := []byte(getJWTSecret())
+// AccessTokenTTL is how long an access token is accepted. It is kept short
+// because a token can't be taken back before it expires; clients use their
+// refresh token to get a new one.
+const AccessTokenTTL = 15 * time.Minute
+
+// Defaults for the iss and aud claims, overridden by JWT_ISSUER and
+// JWT_AUDIENCE
+const (
+	defaultJWTIssuer   = "micro-savings-app"
+	defaultJWTAudience = "micro-savings-api"
+)
+
+// GenerateJWT generates an access token for a user's session, carrying the
+// user's staff roles. It is signed with the current signing key, named in
+// the kid header.
+func GenerateJWT(userID, sessionID string, roles []string) (string, error) {
+	key, err := signingKeys.current()
+	if err != nil {
+		return "", err
+	}
+	jti, err := newToken()
+	if err != nil {
+		return "", err
+	}
 
 	// Define the token claims
+	now := time.Now()
 	claims := jwt.MapClaims{
+		"iss":     jwtIssuer(),
+		"aud":     jwtAudience(),
+		"jti":     jti,
 		"user_id": userID,
-		"exp":     time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
+		"sid":     sessionID, // checked on every request so logging out takes effect at once
+		"roles":   roles,     // sessions are ended when roles change, so these are never stale
+		"iat":     now.Unix(),
+		"exp":     now.Add(AccessTokenTTL).Unix(),
 	}
 
-	// Create the token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	// Sign the token with the secret
-	return token.SignedString(secret)
+	// Create the token and sign it
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.algorithm), claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.private)
 }
 
-// ValidateJWT validates the provided token and extracts claims
+// ValidateJWT validates the provided token and extracts claims. The
+// signature must be from a key that still verifies, and the issuer,
+// audience, issue time, expiry and token ID must all be present and valid.
 func ValidateJWT(tokenString string) (jwt.MapClaims, error) {
-	secret := []byte(getJWTSecret())
-
 	// Parse and validate the token
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		kid, _ := token.Header["kid"].(string)
+		key, err := signingKeys.verifier(kid)
+		if err != nil {
+			return nil, err
+		}
+		if token.Method.Alg() != key.algorithm {
 			return nil, errors.New("unexpected signing method")
 		}
-		return secret, nil
-	})
+		return key.public, nil
+	},
+		jwt.WithValidMethods([]string{models.AlgEdDSA, models.AlgRS256}),
+		jwt.WithIssuer(jwtIssuer()),
+		jwt.WithAudience(jwtAudience()),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
 
 	// Extract and return the claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if _, ok := claims["iat"]; !ok {
+		return nil, errors.New("token has no issue time")
 	}
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return nil, errors.New("token has no ID")
+	}
+	return claims, nil
+}
 
-	return nil, errors.New("invalid token")
+func jwtIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return defaultJWTIssuer
 }
 
-func getJWTSecret() string {
-	// Load environment variables
-	err := godotenv.Load("../.env") // Remove the path if the .env file is in the same directory (after unit test)
-	if err != nil {
-		panic("Failed to load .env file")
+func jwtAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
 	}
-	return os.Getenv("JWT_SECRET") // Replace with the value from your .env file
+	return defaultJWTAudience
 }
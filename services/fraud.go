@@ -0,0 +1,378 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"micro-savings-app/fraud"
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPaymentBlocked      = errors.New("payment was blocked by fraud screening")
+	ErrInvalidFraudReview  = errors.New("invalid review")
+	ErrFraudReviewNotFound = errors.New("review not found")
+	ErrFraudReviewed       = errors.New("review has already been decided")
+	ErrFraudOwnReview      = errors.New("you cannot review your own payment")
+)
+
+// FraudEngine screens every withdrawal, transfer and pool contribution before
+// money leaves
+var FraudEngine = fraud.NewEngine(fraud.DefaultRules()...)
+
+// screenMovement runs FraudEngine over a money movement. sessionID is
+// the session making the request; a zero ID skips the device checks.
+func screenMovement(ctx context.Context, store *repository.Store, user *models.User, kind string, amount models.Money,
+	recipientID, sessionID primitive.ObjectID, now time.Time) (string, []models.FraudFlag, error) {
+	sessions, err := store.Sessions.ListByUser(ctx, user.ID)
+	if err != nil {
+		return "", nil, err
+	}
+	history, err := store.Transactions.List(ctx, repository.TransactionFilter{
+		UserID:    user.ID,
+		From:      now.Add(-fraud.HistoryWindow),
+		Ascending: true,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+
+	movement := &fraud.Movement{
+		Kind:        kind,
+		User:        user,
+		Amount:      amount,
+		RecipientID: recipientID,
+		Sessions:    sessions,
+		History:     history,
+		Now:         now,
+	}
+	for i := range sessions {
+		if !sessionID.IsZero() && sessions[i].ID == sessionID {
+			movement.Session = &sessions[i]
+		}
+	}
+
+	action, flags := FraudEngine.Screen(movement)
+	return action, flags, nil
+}
+
+// holdMovement queues a held withdrawal or transfer for review. post must
+// move the amount out of the user's savings or goal into the held funds
+// account under the reference it is given. Call it inside
+// store.WithTransaction.
+func holdMovement(ctx context.Context, store *repository.Store, review *models.FraudReview, now time.Time, post func(reference string) error) error {
+	review.ID = primitive.NewObjectID()
+	review.Status = models.ReviewPending
+	review.Reference = "HLD-" + review.ID.Hex()
+	review.CreatedAt = now
+
+	if err := post(review.Reference); err != nil {
+		return err
+	}
+	return store.FraudReviews.Create(ctx, review)
+}
+
+// holdSavings moves a held amount out of the user's savings
+func holdSavings(ctx context.Context, store *repository.Store, review *models.FraudReview, now time.Time) (*BalanceChange, error) {
+	var change *BalanceChange
+	err := holdMovement(ctx, store, review, now, func(reference string) error {
+		var err error
+		change, err = postSavingsChange(ctx, store, review.UserID, review.Amount, models.Hold, reference, []models.JournalLine{
+			ledger.DebitLine(ledger.UserSavingsAccount(review.UserID), review.Amount),
+			ledger.CreditLine(ledger.HeldFundsAccount, review.Amount),
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	change.Review = review
+	return change, nil
+}
+
+// recordBlocked keeps a blocked payment on file for reviewers. The payment
+// itself has already been refused, so failing to record it is only logged.
+func recordBlocked(ctx context.Context, store *repository.Store, review models.FraudReview, now time.Time) {
+	review.Status = models.ReviewBlocked
+	review.CreatedAt = now
+	if err := store.FraudReviews.Create(ctx, &review); err != nil {
+		log.Printf("Failed to record blocked %s for user %s: %v", review.Kind, review.UserID.Hex(), err)
+	}
+}
+
+// ListFraudReviews returns reviews with the status for reviewers, oldest first
+func ListFraudReviews(ctx context.Context, store *repository.Store, status string) ([]models.FraudReview, error) {
+	switch status {
+	case models.ReviewPending, models.ReviewApproved, models.ReviewRejected, models.ReviewBlocked:
+	default:
+		return nil, fmt.Errorf("%w: status must be %s, %s, %s or %s", ErrInvalidFraudReview,
+			models.ReviewPending, models.ReviewApproved, models.ReviewRejected, models.ReviewBlocked)
+	}
+	return store.FraudReviews.ListByStatus(ctx, status)
+}
+
+// ApproveFraudReview sends a held withdrawal, transfer or pool contribution
+// on its way
+func ApproveFraudReview(ctx context.Context, store *repository.Store, reviewerID, reviewID primitive.ObjectID, note string, now time.Time) (*models.FraudReview, error) {
+	var review *models.FraudReview
+	var user, recipient *models.User
+	var pool *models.Pool
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		review, user, err = decideFraudReview(ctx, store, reviewerID, reviewID, models.ReviewApproved, note, now)
+		if err != nil {
+			return err
+		}
+
+		reference := "REL-" + review.ID.Hex()
+		entryID := primitive.NewObjectID()
+		switch review.Kind {
+		case models.HeldTransfer:
+			recipient, err = getOpenUser(ctx, store, review.RecipientID)
+			if err != nil {
+				return err
+			}
+			if err := checkRecipientLimit(ctx, store, recipient.ID, review.Amount); err != nil {
+				return err
+			}
+			balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+				ID:          entryID,
+				Reference:   reference,
+				Description: string(models.Transfer),
+				Lines: []models.JournalLine{
+					ledger.DebitLine(ledger.HeldFundsAccount, review.Amount),
+					ledger.CreditLine(ledger.UserSavingsAccount(recipient.ID), review.Amount),
+				},
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			// The sender's savings already dropped when the transfer was held
+			debit := transferLeg(user.ID, recipient.ID, models.Debit, review.Amount, reference, entryID, review.Note, now)
+			credit := transferLeg(recipient.ID, user.ID, models.Credit, review.Amount, reference, entryID, review.Note, now)
+			recipientBalance := balances[ledger.UserSavingsAccount(recipient.ID)]
+			credit.BalanceAfter = &recipientBalance
+			if err := store.Transactions.Insert(ctx, debit, credit); err != nil {
+				return err
+			}
+			return store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID, recipient.ID}, now)
+		case models.HeldPoolContribution:
+			pool, err = getPool(ctx, store, review.PoolID)
+			if err != nil {
+				return err
+			}
+			_, err := ledger.Post(ctx, store, &models.JournalEntry{
+				ID:          entryID,
+				Reference:   reference,
+				Description: string(models.PoolContribution),
+				Lines: []models.JournalLine{
+					ledger.DebitLine(ledger.HeldFundsAccount, review.Amount),
+					ledger.CreditLine(ledger.PoolAccount(pool.ID), review.Amount),
+				},
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			// No balance_after: the user's savings already dropped when the
+			// contribution was held. The pool pays what it owes from it next
+			// cycle.
+			if err := store.Transactions.Insert(ctx, models.Transaction{
+				ID:             primitive.NewObjectID(),
+				UserID:         user.ID,
+				Type:           string(models.PoolContribution),
+				Amount:         review.Amount,
+				Reference:      reference,
+				JournalEntryID: entryID,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}); err != nil {
+				return err
+			}
+			pool.Held = pool.Held.Add(review.Amount)
+			pool.UpdatedAt = now
+			if err := store.Pools.Update(ctx, pool); err != nil {
+				return err
+			}
+			return store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID}, now)
+		default:
+			_, err := ledger.Post(ctx, store, &models.JournalEntry{
+				ID:          entryID,
+				Reference:   reference,
+				Description: string(models.Withdrawal),
+				Lines: []models.JournalLine{
+					ledger.DebitLine(ledger.HeldFundsAccount, review.Amount),
+					ledger.CreditLine(ledger.ExternalCashAccount, review.Amount),
+				},
+				CreatedAt: now,
+			})
+			if err != nil {
+				return err
+			}
+
+			// No balance_after: the user's savings or goal already dropped
+			// when the withdrawal was held
+			if err := store.Transactions.Insert(ctx, models.Transaction{
+				ID:             primitive.NewObjectID(),
+				UserID:         user.ID,
+				Type:           string(models.Withdrawal),
+				Amount:         review.Amount,
+				Reference:      reference,
+				GoalID:         review.GoalID,
+				JournalEntryID: entryID,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			}); err != nil {
+				return err
+			}
+			return store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID}, now)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	amount := review.Amount
+	switch review.Kind {
+	case models.HeldTransfer:
+		NotifyByEmail(user.Email, "Transfer sent",
+			fmt.Sprintf("You sent %s %s to %s. Reference: REL-%s", amount.CurrencyCode(), amount, recipient.Name, review.ID.Hex()))
+		NotifyByEmail(recipient.Email, "Transfer received",
+			fmt.Sprintf("You received %s %s from %s. Reference: REL-%s", amount.CurrencyCode(), amount, user.Name, review.ID.Hex()))
+	case models.HeldPoolContribution:
+		NotifyByEmail(user.Email, "Pool contribution approved",
+			fmt.Sprintf("Your contribution of %s %s to %s has been approved and paid in.", amount.CurrencyCode(), amount, pool.Name))
+	default:
+		NotifyByEmail(user.Email, "Withdrawal approved",
+			fmt.Sprintf("Your withdrawal of %s %s has been approved and is on its way.", amount.CurrencyCode(), amount))
+	}
+	return review, nil
+}
+
+// RejectFraudReview cancels a held withdrawal, transfer or pool contribution
+// and gives the money back to the user's savings, including money held from
+// a goal, which may have been deleted since. A rejected pool contribution is
+// still owed to the pool, so it is added to the member's arrears. The note is
+// for other reviewers and is not sent to the user.
+func RejectFraudReview(ctx context.Context, store *repository.Store, reviewerID, reviewID primitive.ObjectID, note string, now time.Time) (*models.FraudReview, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("%w: a note explaining the rejection is required", ErrInvalidFraudReview)
+	}
+
+	var review *models.FraudReview
+	var user *models.User
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		review, user, err = decideFraudReview(ctx, store, reviewerID, reviewID, models.ReviewRejected, note, now)
+		if err != nil {
+			return err
+		}
+		_, err = postSavingsChange(ctx, store, user.ID, review.Amount, models.HoldRelease, "REL-"+review.ID.Hex(), []models.JournalLine{
+			ledger.DebitLine(ledger.HeldFundsAccount, review.Amount),
+			ledger.CreditLine(ledger.UserSavingsAccount(user.ID), review.Amount),
+		})
+		if err != nil || review.Kind != models.HeldPoolContribution {
+			return err
+		}
+
+		pool, err := getPool(ctx, store, review.PoolID)
+		if err != nil {
+			return err
+		}
+		if i := pool.Member(user.ID); i >= 0 {
+			pool.Members[i].Arrears = pool.Members[i].Arrears.Add(review.Amount)
+		}
+		pool.UpdatedAt = now
+		return store.Pools.Update(ctx, pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if review.Kind == models.HeldPoolContribution {
+		NotifyByEmail(user.Email, "Payment not approved",
+			fmt.Sprintf("Your pool contribution of %s %s could not be completed and the money is back in your savings. It is still owed to the pool. Contact support if you have questions.",
+				review.Amount.CurrencyCode(), review.Amount))
+	} else {
+		NotifyByEmail(user.Email, "Payment not approved",
+			fmt.Sprintf("Your %s of %s %s could not be completed and the money is back in your savings. Contact support if you have questions.",
+				review.Kind, review.Amount.CurrencyCode(), review.Amount))
+	}
+	return review, nil
+}
+
+// decideFraudReview records the outcome of a pending review and returns it
+// with the user whose payment it holds. Call it inside store.WithTransaction.
+func decideFraudReview(ctx context.Context, store *repository.Store, reviewerID, reviewID primitive.ObjectID, status, note string,
+	now time.Time) (*models.FraudReview, *models.User, error) {
+	review, err := store.FraudReviews.GetByID(ctx, reviewID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrFraudReviewNotFound
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if review.UserID == reviewerID {
+		return nil, nil, ErrFraudOwnReview
+	}
+	if review.Status != models.ReviewPending {
+		return nil, nil, ErrFraudReviewed
+	}
+	user, err := getUser(ctx, store, review.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	note = strings.TrimSpace(note)
+	err = store.FraudReviews.Review(ctx, reviewID, status, reviewerID, note, now)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil, ErrFraudReviewed
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	review.Status = status
+	review.ReviewerID = &reviewerID
+	review.ReviewNote = note
+	review.ReviewedAt = &now
+	return review, user, nil
+}
+
+// checkNoHeldPayments fails with ErrPaymentsHeld while any of the user's
+// payments are waiting for review
+func checkNoHeldPayments(ctx context.Context, store *repository.Store, userID primitive.ObjectID) error {
+	pending, err := pendingHoldReferences(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		return ErrPaymentsHeld
+	}
+	return nil
+}
+
+// pendingHoldReferences returns the journal references of the user's
+// payments that are waiting for review
+func pendingHoldReferences(ctx context.Context, store *repository.Store, userID primitive.ObjectID) (map[string]bool, error) {
+	reviews, err := store.FraudReviews.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	pending := map[string]bool{}
+	for _, review := range reviews {
+		if review.Status == models.ReviewPending {
+			pending[review.Reference] = true
+		}
+	}
+	return pending, nil
+}
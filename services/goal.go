@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrGoalNotFound = errors.New("goal not found")
+	ErrGoalLocked   = errors.New("goal is locked")
+	ErrGoalHasFunds = errors.New("goal still holds savings")
+	ErrInvalidGoal  = errors.New("invalid goal")
+)
+
+// GoalProgress is a goal together with how far along it is
+type GoalProgress struct {
+	models.Goal
+	ProgressPercent     float64    `json:"progress_percent"`
+	Completed           bool       `json:"completed"`
+	Locked              bool       `json:"locked"`
+	ProjectedCompletion *time.Time `json:"projected_completion,omitempty"` // at the average rate saved so far
+	OnTrack             *bool      `json:"on_track,omitempty"`             // projected to finish by the deadline
+}
+
+// NewGoalProgress works out a goal's progress as of now
+func NewGoalProgress(goal models.Goal, now time.Time) GoalProgress {
+	progress := GoalProgress{
+		Goal:                goal,
+		ProgressPercent:     goal.ProgressPercent(),
+		Completed:           goal.IsComplete(),
+		Locked:              goal.IsLocked(now),
+		ProjectedCompletion: goal.ProjectedCompletion(now),
+	}
+	if goal.Deadline != nil && progress.ProjectedCompletion != nil {
+		onTrack := !progress.ProjectedCompletion.After(*goal.Deadline)
+		progress.OnTrack = &onTrack
+	}
+	return progress
+}
+
+// GoalChanges are the editable fields of a goal; nil fields are left alone
+type GoalChanges struct {
+	Name         *string
+	TargetAmount *models.Money
+	Deadline     *time.Time
+	LockedUntil  *time.Time
+}
+
+// CreateGoal starts a new, empty goal for a user
+func CreateGoal(ctx context.Context, store *repository.Store, userID primitive.ObjectID, changes GoalChanges, now time.Time) (*models.Goal, error) {
+	if changes.Name == nil || changes.TargetAmount == nil {
+		return nil, fmt.Errorf("%w: name and target_amount are required", ErrInvalidGoal)
+	}
+	goal := &models.Goal{
+		UserID:      userID,
+		SavedAmount: models.NewMoney(0, changes.TargetAmount.CurrencyCode()),
+		CreatedAt:   now,
+	}
+	if err := applyGoalChanges(goal, changes, now); err != nil {
+		return nil, err
+	}
+	if err := store.Goals.Create(ctx, goal); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// UpdateGoal edits a goal. While a goal is locked its lock can be extended
+// but not brought forward.
+func UpdateGoal(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID, changes GoalChanges, now time.Time) (*models.Goal, error) {
+	goal, err := getGoal(ctx, store, userID, goalID)
+	if err != nil {
+		return nil, err
+	}
+	if changes.LockedUntil != nil && goal.IsLocked(now) && changes.LockedUntil.Before(*goal.LockedUntil) {
+		return nil, ErrGoalLocked
+	}
+	if changes.TargetAmount != nil && !changes.TargetAmount.SameCurrency(goal.SavedAmount) {
+		return nil, models.ErrCurrencyMismatch
+	}
+
+	if err := applyGoalChanges(goal, changes, now); err != nil {
+		return nil, err
+	}
+	if err := store.Goals.Update(ctx, goal); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+func applyGoalChanges(goal *models.Goal, changes GoalChanges, now time.Time) error {
+	if changes.Name != nil {
+		if *changes.Name == "" {
+			return fmt.Errorf("%w: name cannot be empty", ErrInvalidGoal)
+		}
+		goal.Name = *changes.Name
+	}
+	if changes.TargetAmount != nil {
+		if !changes.TargetAmount.IsPositive() {
+			return fmt.Errorf("%w: target_amount must be greater than zero", ErrInvalidGoal)
+		}
+		goal.TargetAmount = *changes.TargetAmount
+	}
+	if changes.Deadline != nil {
+		if !changes.Deadline.After(now) {
+			return fmt.Errorf("%w: deadline must be in the future", ErrInvalidGoal)
+		}
+		goal.Deadline = changes.Deadline
+	}
+	if changes.LockedUntil != nil {
+		goal.LockedUntil = changes.LockedUntil
+	}
+	goal.UpdatedAt = now
+	return nil
+}
+
+// DeleteGoal removes an empty goal and cancels the schedules paying into it.
+// The check and the delete run in one transaction, so a deposit can't land
+// in between.
+func DeleteGoal(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID, now time.Time) error {
+	return store.WithTransaction(ctx, func(ctx context.Context) error {
+		goal, err := getGoal(ctx, store, userID, goalID)
+		if err != nil {
+			return err
+		}
+		if !goal.SavedAmount.IsZero() {
+			return ErrGoalHasFunds
+		}
+		if err := store.Goals.Delete(ctx, userID, goalID); errors.Is(err, repository.ErrNotEmpty) {
+			return ErrGoalHasFunds
+		} else if err != nil {
+			return err
+		}
+
+		schedules, err := store.Schedules.ListByUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, schedule := range schedules {
+			if schedule.GoalID != goalID || schedule.Status == models.ScheduleCancelled {
+				continue
+			}
+			schedule.Status = models.ScheduleCancelled
+			schedule.UpdatedAt = now
+			if err := store.Schedules.Update(ctx, &schedule); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DepositToGoal pays money from outside straight into a goal. The deposit and
+// the balance it leaves must both be within the user's KYC tier limits, and
+// the deposit must fit within their daily and monthly velocity limits.
+func DepositToGoal(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID, amount models.Money) (*models.Goal, error) {
+	checkLimits := func(ctx context.Context, user *models.User) error {
+		if err := checkDepositLimit(user, amount); err != nil {
+			return err
+		}
+		if err := checkBalanceLimit(user, amount); err != nil {
+			return err
+		}
+		return checkVelocityLimit(ctx, store, user, models.VelocityDeposit, amount, time.Now())
+	}
+
+	return applyGoalChange(ctx, store, userID, goalID, amount, models.Deposit, checkLimits, []models.JournalLine{
+		ledger.DebitLine(ledger.ExternalCashAccount, amount),
+		ledger.CreditLine(ledger.UserGoalsAccount(userID), amount),
+	})
+}
+
+// GoalWithdrawal describes a withdrawal from a goal
+type GoalWithdrawal struct {
+	Goal   *models.Goal
+	Review *models.FraudReview // set when fraud screening held the withdrawal
+}
+
+// WithdrawFromGoal pays money out of an unlocked goal. Users who have not
+// verified their email can't withdraw, and the amount must be
+// within the user's KYC tier withdrawal limit and their daily and monthly
+// velocity limits. Like withdrawals from savings, fraud screening can hold it
+// for review or refuse it with ErrPaymentBlocked; sessionID is the session
+// making the request.
+func WithdrawFromGoal(ctx context.Context, store *repository.Store, userID, goalID, sessionID primitive.ObjectID, amount models.Money) (*GoalWithdrawal, error) {
+	if !amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	var result *GoalWithdrawal
+	var flags []models.FraudFlag
+	now := time.Now()
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := getUser(ctx, store, userID)
+		if err != nil {
+			return err
+		}
+		if !user.IsEmailVerified() {
+			return ErrEmailNotVerified
+		}
+		if err := checkWithdrawalLimit(user, amount); err != nil {
+			return err
+		}
+		if err := checkVelocityLimit(ctx, store, user, models.VelocityWithdrawal, amount, now); err != nil {
+			return err
+		}
+
+		var action string
+		action, flags, err = screenMovement(ctx, store, user, models.HeldWithdrawal, amount, primitive.NilObjectID, sessionID, now)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case models.FraudBlock:
+			return ErrPaymentBlocked
+		case models.FraudHold:
+			review := &models.FraudReview{
+				UserID: userID,
+				Kind:   models.HeldWithdrawal,
+				Amount: amount,
+				GoalID: goalID,
+				Flags:  flags,
+			}
+			return holdMovement(ctx, store, review, now, func(reference string) error {
+				goal, _, err := postGoalChange(ctx, store, userID, goalID, amount.Neg(), models.Hold, reference, []models.JournalLine{
+					ledger.DebitLine(ledger.UserGoalsAccount(userID), amount),
+					ledger.CreditLine(ledger.HeldFundsAccount, amount),
+				})
+				result = &GoalWithdrawal{Goal: goal, Review: review}
+				return err
+			})
+		}
+
+		goal, _, err := postGoalChange(ctx, store, userID, goalID, amount.Neg(), models.Withdrawal, "", []models.JournalLine{
+			ledger.DebitLine(ledger.UserGoalsAccount(userID), amount),
+			ledger.CreditLine(ledger.ExternalCashAccount, amount),
+		})
+		result = &GoalWithdrawal{Goal: goal}
+		return err
+	})
+	if errors.Is(err, ErrPaymentBlocked) {
+		recordBlocked(ctx, store, models.FraudReview{UserID: userID, Kind: models.HeldWithdrawal, Amount: amount, GoalID: goalID, Flags: flags}, now)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if result.Review != nil {
+		if user, err := store.Users.GetByID(ctx, userID); err == nil {
+			NotifyByEmail(user.Email, "Withdrawal under review",
+				fmt.Sprintf("Your withdrawal of %s %s from %s is being reviewed. The money has been set aside and we will email you once it has been checked.",
+					amount.CurrencyCode(), amount, result.Goal.Name))
+		}
+	}
+	return result, nil
+}
+
+// applyGoalChange posts a movement into a goal, updating the goal's
+// saved amount in the same transaction as the ledger. checkLimits sees the
+// user as loaded inside that transaction.
+func applyGoalChange(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID, delta models.Money, txType models.TransactionType,
+	checkLimits func(ctx context.Context, user *models.User) error, lines []models.JournalLine) (*models.Goal, error) {
+	if delta.IsZero() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	var updated *models.Goal
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := getUser(ctx, store, userID)
+		if err != nil {
+			return err
+		}
+		if err := checkLimits(ctx, user); err != nil {
+			return err
+		}
+
+		updated, _, err = postGoalChange(ctx, store, userID, goalID, delta, txType, "", lines)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// postGoalChange does the work of applyGoalChange, returning the goal and the
+// transaction recorded in history. A non-empty reference becomes the journal
+// entry's reference. Call it inside store.WithTransaction.
+func postGoalChange(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID, delta models.Money, txType models.TransactionType, reference string, lines []models.JournalLine) (*models.Goal, *models.Transaction, error) {
+	amount := delta
+	if amount.IsNegative() {
+		amount = amount.Neg()
+	}
+
+	now := time.Now()
+	goal, err := getGoal(ctx, store, userID, goalID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if delta.IsNegative() && goal.IsLocked(now) {
+		return nil, nil, ErrGoalLocked
+	}
+
+	updated, err := store.Goals.AdjustSaved(ctx, goalID, delta, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	transaction := models.Transaction{
+		ID:             primitive.NewObjectID(),
+		UserID:         userID,
+		Type:           string(txType),
+		Amount:         amount,
+		Reference:      reference,
+		GoalID:         goalID,
+		Note:           goal.Name,
+		JournalEntryID: primitive.NewObjectID(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if reference == "" {
+		reference = transaction.ID.Hex()
+	}
+	if _, err := ledger.Post(ctx, store, &models.JournalEntry{
+		ID:          transaction.JournalEntryID,
+		Reference:   reference,
+		Description: string(txType) + " goal",
+		Lines:       lines,
+		CreatedAt:   now,
+	}); err != nil {
+		return nil, nil, err
+	}
+	if err := store.Transactions.Insert(ctx, transaction); err != nil {
+		return nil, nil, err
+	}
+	return updated, &transaction, nil
+}
+
+func getGoal(ctx context.Context, store *repository.Store, userID, goalID primitive.ObjectID) (*models.Goal, error) {
+	goal, err := store.Goals.GetByID(ctx, userID, goalID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrGoalNotFound
+	}
+	return goal, err
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultHistoryPageSize = 20
+	MaxHistoryPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TransactionQuery filters and pages through a user's transaction history
+type TransactionQuery struct {
+	Type      models.TransactionType
+	MinAmount *models.Money
+	MaxAmount *models.Money
+	From      time.Time
+	To        time.Time
+	Ascending bool
+	Limit     int
+	Cursor    string
+}
+
+// TransactionPage is one page of history plus the cursor for the next page
+type TransactionPage struct {
+	Transactions []models.Transaction `json:"transactions"`
+	NextCursor   string               `json:"next_cursor,omitempty"`
+}
+
+// ListTransactions returns a page of the user's transactions ordered by creation
+// time (then ID, so rows created in the same instant still page deterministically).
+// Each row carries the savings balance recorded when it was applied.
+func ListTransactions(ctx context.Context, store *repository.Store, userID primitive.ObjectID, query TransactionQuery) (*TransactionPage, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryPageSize
+	}
+	if limit > MaxHistoryPageSize {
+		limit = MaxHistoryPageSize
+	}
+
+	filter := repository.TransactionFilter{
+		UserID:    userID,
+		Type:      query.Type,
+		MinAmount: query.MinAmount,
+		MaxAmount: query.MaxAmount,
+		From:      query.From,
+		To:        query.To,
+		Ascending: query.Ascending,
+		// Fetch one extra row to learn whether another page exists
+		Limit: limit + 1,
+	}
+
+	// Continue strictly after the last row of the previous page
+	if query.Cursor != "" {
+		after, afterID, err := decodeHistoryCursor(query.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		filter.After = &repository.TransactionPosition{CreatedAt: after, ID: afterID}
+	}
+
+	transactions, err := store.Transactions.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeHistoryCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// Cursors are opaque to clients: base64("<unix nanos>:<object id>")
+func encodeHistoryCursor(createdAt time.Time, id primitive.ObjectID) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + ":" + id.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeHistoryCursor(cursor string) (time.Time, primitive.ObjectID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	nanos, idHex, found := strings.Cut(string(raw), ":")
+	if !found {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	ns, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	id, err := primitive.ObjectIDFromHex(idHex)
+	if err != nil {
+		return time.Time{}, primitive.NilObjectID, ErrInvalidCursor
+	}
+	return time.Unix(0, ns).UTC(), id, nil
+}
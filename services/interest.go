@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InterestTiers returns the configured interest tiers keyed by name, falling
+// back to models.DefaultInterestTiers until an admin sets them
+func InterestTiers(ctx context.Context, store *repository.Store) (map[string]models.InterestTier, error) {
+	tiers, err := store.Settings.GetInterestTiers(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		tiers = models.DefaultInterestTiers()
+	} else if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]models.InterestTier, len(tiers))
+	for _, tier := range tiers {
+		byName[tier.Name] = tier
+	}
+	return byName, nil
+}
+
+// SetInterestTiers validates and replaces the full set of interest tiers
+func SetInterestTiers(ctx context.Context, store *repository.Store, tiers []models.InterestTier) error {
+	if err := models.ValidateInterestTiers(tiers); err != nil {
+		return err
+	}
+	return store.Settings.SetInterestTiers(ctx, tiers)
+}
+
+// SetProductTier moves a user onto another interest tier. Interest already
+// accrued is kept and paid at the new tier's next capitalisation.
+func SetProductTier(ctx context.Context, store *repository.Store, userID primitive.ObjectID, tier string) error {
+	tiers, err := InterestTiers(ctx, store)
+	if err != nil {
+		return err
+	}
+	if _, ok := tiers[tier]; !ok {
+		return models.ErrInvalidTier
+	}
+
+	err = store.Users.SetProductTier(ctx, userID, tier)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// tierFor returns the tier a user's investment balance earns at
+func tierFor(user *models.User, tiers map[string]models.InterestTier) models.InterestTier {
+	if tier, ok := tiers[user.ProductTier]; ok {
+		return tier
+	}
+	return tiers[models.DefaultProductTier]
+}
+
+// AccrueInterest accrues a user's interest for every whole day since the last
+// accrual up to and including the day before now (in UTC), so a missed run is
+// caught up on the next one. Interest is capitalised into the investment
+// balance, as an `interest` transaction, whenever the user's tier compounds;
+// later days then earn on the larger balance. It returns the total paid in.
+func AccrueInterest(ctx context.Context, store *repository.Store, userID primitive.ObjectID, tiers map[string]models.InterestTier, now time.Time) (models.Money, error) {
+	var paid models.Money
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := store.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		tier := tierFor(user, tiers)
+		balance := user.InvestmentBalance
+		accrual := user.Interest
+		paid = models.NewMoney(0, balance.CurrencyCode())
+
+		lastDay := startOfDay(now).AddDate(0, 0, -1)
+		if accrual.AccruedThrough.IsZero() {
+			// Start earning from today; nothing is owed for the past
+			accrual.AccruedThrough = lastDay
+		}
+
+		for day := startOfDay(accrual.AccruedThrough).AddDate(0, 0, 1); !day.After(lastDay); day = day.AddDate(0, 0, 1) {
+			accrual.Accrued += tier.DailyInterest(balance)
+			accrual.AccruedThrough = day
+
+			whole := accrual.Accrued / models.InterestPrecision
+			if !tier.CapitalisesAfter(day) || whole == 0 {
+				continue
+			}
+			amount := models.NewMoney(whole, balance.CurrencyCode())
+			balance, err = capitaliseInterest(ctx, store, userID, amount, day)
+			if err != nil {
+				return err
+			}
+			accrual.Accrued -= whole * models.InterestPrecision
+			paid = paid.Add(amount)
+		}
+
+		return store.Users.SetInterestAccrual(ctx, userID, accrual)
+	})
+	return paid, err
+}
+
+// StartInterestAccrual starts a user's accrual afresh from the day of now. Call
+// it when an empty investment balance is funded so the days it sat at zero,
+// which the accrual job skips, are not accrued at the new balance.
+func StartInterestAccrual(ctx context.Context, store *repository.Store, userID primitive.ObjectID, now time.Time) error {
+	return store.Users.SetInterestAccrual(ctx, userID, models.InterestAccrual{
+		AccruedThrough: startOfDay(now).AddDate(0, 0, -1),
+	})
+}
+
+// capitaliseInterest pays interest accrued up to and including day into the
+// user's investment balance and returns the new balance
+func capitaliseInterest(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, day time.Time) (models.Money, error) {
+	paidAt := day.AddDate(0, 0, 1)
+	transaction := models.Transaction{
+		ID:             primitive.NewObjectID(),
+		UserID:         userID,
+		Type:           string(models.Interest),
+		Amount:         amount,
+		Direction:      models.Credit,
+		JournalEntryID: primitive.NewObjectID(),
+		CreatedAt:      paidAt,
+		UpdatedAt:      paidAt,
+	}
+
+	// One capitalisation per user per day, so a rerun can't pay twice
+	balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+		ID:          transaction.JournalEntryID,
+		Reference:   "INT-" + userID.Hex() + "-" + day.Format("20060102"),
+		Description: string(models.Interest),
+		Lines: []models.JournalLine{
+			ledger.DebitLine(ledger.InterestExpenseAccount, amount),
+			ledger.CreditLine(ledger.UserInvestmentAccount(userID), amount),
+		},
+		CreatedAt: paidAt,
+	})
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	if err := store.Transactions.Insert(ctx, transaction); err != nil {
+		return models.Money{}, err
+	}
+	return balances[ledger.UserInvestmentAccount(userID)], nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
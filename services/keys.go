@@ -0,0 +1,371 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+)
+
+// keyRefreshInterval is how often keys are re-read from the store, so a
+// rotation made by another instance or the rotate-jwt-key command is
+// picked up
+const keyRefreshInterval = time.Minute
+
+// KeyActivationDelay is how long a rotated key only verifies before it
+// starts signing. Two refresh intervals let every instance load it first,
+// with room for their clocks to differ.
+var KeyActivationDelay = 2 * keyRefreshInterval
+
+// keyEncryptionKeyEnv names the environment variable holding the key private
+// signing keys are encrypted with in the store: 32 bytes, base64 encoded
+const keyEncryptionKeyEnv = "SIGNING_KEY_ENCRYPTION_KEY"
+
+var (
+	ErrNoSigningKey           = errors.New("no signing key loaded")
+	ErrUnsupportedAlgorithm   = fmt.Errorf("signing algorithm must be %s or %s", models.AlgEdDSA, models.AlgRS256)
+	ErrNoKeyEncryptionKey     = fmt.Errorf("%s must hold a base64 encoded 32-byte key", keyEncryptionKeyEnv)
+	errUnknownVerificationKey = errors.New("unknown signing key")
+)
+
+// loadedKey is a signing key parsed for use
+type loadedKey struct {
+	id        string
+	algorithm string
+	private   crypto.Signer
+	public    crypto.PublicKey
+	record    models.SigningKey // when it signs
+}
+
+// keyRing holds the keys access tokens are signed and verified with
+type keyRing struct {
+	mu       sync.RWMutex
+	store    *repository.Store
+	keys     []*loadedKey // every key that verifies, newest first
+	verify   map[string]*loadedKey
+	loadedAt time.Time
+}
+
+var signingKeys = &keyRing{}
+
+// LoadSigningKeys reads the signing keys from the store, creating the
+// first one if there are none and rotating out a key stored before private
+// keys were encrypted. It must be called before tokens are issued or
+// checked.
+func LoadSigningKeys(ctx context.Context, store *repository.Store) error {
+	signingKeys.mu.Lock()
+	signingKeys.store = store
+	signingKeys.mu.Unlock()
+
+	keys, err := store.SigningKeys.List(ctx)
+	if err != nil {
+		return err
+	}
+	var newest *models.SigningKey // the key signing now, or about to
+	for i := range keys {
+		if keys[i].RetiredAt == nil {
+			newest = &keys[i]
+			break
+		}
+	}
+	now := time.Now()
+	switch {
+	case newest == nil:
+		// No instance has signed anything, so the first key can sign at once
+		if _, err := addSigningKey(ctx, store, models.AlgEdDSA, now, now); err != nil {
+			return err
+		}
+	case !newest.Encrypted:
+		if _, err := RotateSigningKey(ctx, store, newest.Algorithm, now); err != nil {
+			return err
+		}
+	}
+	return signingKeys.reload(ctx)
+}
+
+// RotateSigningKey creates a new key that verifies tokens at once and signs
+// them from KeyActivationDelay after now, by when every instance has loaded
+// it. The keys it replaces sign until then and keep verifying until the
+// tokens they signed expire.
+func RotateSigningKey(ctx context.Context, store *repository.Store, algorithm string, now time.Time) (*models.SigningKey, error) {
+	return addSigningKey(ctx, store, algorithm, now, now.Add(KeyActivationDelay))
+}
+
+// addSigningKey creates a key that signs from activeFrom, when the keys it
+// replaces stop signing
+func addSigningKey(ctx context.Context, store *repository.Store, algorithm string, now, activeFrom time.Time) (*models.SigningKey, error) {
+	key, err := newSigningKey(algorithm, now, activeFrom)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SigningKeys.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	if err := store.SigningKeys.RetireAllExcept(ctx, key.ID, activeFrom, activeFrom.Add(AccessTokenTTL)); err != nil {
+		return nil, err
+	}
+
+	// Start verifying with it straight away if this process has keys loaded
+	signingKeys.mu.RLock()
+	loaded := signingKeys.store == store
+	signingKeys.mu.RUnlock()
+	if loaded {
+		if err := signingKeys.reload(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// JWK is a public key in JSON Web Key form
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"` // Ed25519 keys
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"` // RSA keys
+	E         string `json:"e,omitempty"`
+}
+
+// JWKS returns the public keys tokens may currently be signed with, for
+// other services to verify them
+func JWKS() ([]JWK, error) {
+	if err := signingKeys.refresh(context.Background(), false); err != nil {
+		return nil, err
+	}
+	signingKeys.mu.RLock()
+	defer signingKeys.mu.RUnlock()
+
+	jwks := make([]JWK, 0, len(signingKeys.verify))
+	for _, key := range signingKeys.verify {
+		jwk := JWK{KeyID: key.id, Algorithm: key.algorithm, Use: "sig"}
+		switch public := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(public)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks, nil
+}
+
+// current returns the key to sign with
+func (r *keyRing) current() (*loadedKey, error) {
+	if err := r.refresh(context.Background(), false); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	now := time.Now()
+	for _, key := range r.keys {
+		if key.record.Active(now) {
+			return key, nil
+		}
+	}
+	return nil, ErrNoSigningKey
+}
+
+// verifier returns the key with the given ID. An unknown ID may belong to a
+// key another instance just created, so the keys are re-read once.
+func (r *keyRing) verifier(id string) (*loadedKey, error) {
+	if err := r.refresh(context.Background(), false); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	key, ok := r.verify[id]
+	r.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	if err := r.refresh(context.Background(), true); err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if key, ok := r.verify[id]; ok {
+		return key, nil
+	}
+	return nil, errUnknownVerificationKey
+}
+
+// refresh re-reads the keys if they are older than keyRefreshInterval, or
+// if forced but not within the last second
+func (r *keyRing) refresh(ctx context.Context, force bool) error {
+	r.mu.RLock()
+	store, age := r.store, time.Since(r.loadedAt)
+	r.mu.RUnlock()
+	if store == nil {
+		return ErrNoSigningKey
+	}
+	if age < keyRefreshInterval && (!force || age < time.Second) {
+		return nil
+	}
+	return r.reload(ctx)
+}
+
+// reload reads every key that still verifies from the store
+func (r *keyRing) reload(ctx context.Context) error {
+	r.mu.RLock()
+	store := r.store
+	r.mu.RUnlock()
+	if store == nil {
+		return ErrNoSigningKey
+	}
+
+	keys, err := store.SigningKeys.List(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var loaded []*loadedKey
+	verify := map[string]*loadedKey{}
+	for _, key := range keys {
+		if !key.Verifies(now) {
+			continue
+		}
+		parsed, err := parseSigningKey(key)
+		if err != nil {
+			return fmt.Errorf("signing key %s: %w", key.ID, err)
+		}
+		loaded = append(loaded, parsed)
+		verify[key.ID] = parsed
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys, r.verify, r.loadedAt = loaded, verify, now
+	return nil
+}
+
+// newSigningKey generates a key pair for the algorithm that signs from
+// activeFrom, with the private key encrypted for storing
+func newSigningKey(algorithm string, now, activeFrom time.Time) (*models.SigningKey, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case models.AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case models.AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, ErrUnsupportedAlgorithm
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	id, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := sealPrivateKey(id[:16], privateDER)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SigningKey{
+		ID:         id[:16],
+		Algorithm:  algorithm,
+		PrivateKey: sealed,
+		Encrypted:  true,
+		PublicKey:  publicDER,
+		CreatedAt:  now,
+		ActiveFrom: activeFrom,
+	}, nil
+}
+
+// parseSigningKey decodes a stored key pair
+func parseSigningKey(key models.SigningKey) (*loadedKey, error) {
+	privateDER := key.PrivateKey
+	if key.Encrypted {
+		var err error
+		if privateDER, err = openPrivateKey(key.ID, key.PrivateKey); err != nil {
+			return nil, err
+		}
+	}
+	private, err := x509.ParsePKCS8PrivateKey(privateDER)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedAlgorithm
+	}
+	public, err := x509.ParsePKIXPublicKey(key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	return &loadedKey{id: key.ID, algorithm: key.Algorithm, private: signer, public: public, record: key}, nil
+}
+
+// sealPrivateKey encrypts a private key for storing. The key ID is
+// authenticated with it, so a sealed key can't be swapped onto another
+// record.
+func sealPrivateKey(keyID string, privateDER []byte) ([]byte, error) {
+	aead, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, privateDER, []byte(keyID)), nil
+}
+
+// openPrivateKey decrypts a private key sealed by sealPrivateKey
+func openPrivateKey(keyID string, sealed []byte) ([]byte, error) {
+	aead, err := keyEncryptionCipher()
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed private key is too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(keyID))
+}
+
+// keyEncryptionCipher returns AES-256-GCM keyed from keyEncryptionKeyEnv
+func keyEncryptionCipher() (cipher.AEAD, error) {
+	secret, err := base64.StdEncoding.DecodeString(os.Getenv(keyEncryptionKeyEnv))
+	if err != nil || len(secret) != 32 {
+		return nil, ErrNoKeyEncryptionKey
+	}
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
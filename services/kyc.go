@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidKYC       = errors.New("invalid KYC submission")
+	ErrKYCPending       = errors.New("a KYC submission is already waiting for review")
+	ErrKYCMaxTier       = errors.New("account is already at the highest KYC tier")
+	ErrKYCNotFound      = errors.New("KYC submission not found")
+	ErrKYCReviewed      = errors.New("KYC submission has already been reviewed")
+	ErrKYCOwnSubmission = errors.New("you cannot review your own KYC submission")
+	ErrKYCLimitExceeded = errors.New("amount is over the limit for your verification tier")
+)
+
+// KYCRequest is what a user submits to move up to their next tier
+type KYCRequest struct {
+	IdentifierType string
+	Identifier     string
+	Documents      []models.KYCDocument
+}
+
+// KYCStatus is a user's current tier, what it lets them do, and what they
+// have submitted
+type KYCStatus struct {
+	Tier        int                    `json:"tier"`
+	Limits      models.KYCTierLimits   `json:"limits"`
+	Submissions []models.KYCSubmission `json:"submissions"`
+}
+
+// SubmitKYC queues a request to move the user up one tier. Tier 1 needs a
+// BVN or NIN and a complete profile, tier 2 a government photo ID and tier 3
+// proof of address plus the address itself on the profile.
+func SubmitKYC(ctx context.Context, store *repository.Store, userID primitive.ObjectID, request KYCRequest, now time.Time) (*models.KYCSubmission, error) {
+	user, err := getOpenUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.KYCTier >= models.KYCTierMax {
+		return nil, ErrKYCMaxTier
+	}
+
+	submissions, err := store.KYC.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, submission := range submissions {
+		if submission.Status == models.KYCPending {
+			return nil, ErrKYCPending
+		}
+	}
+
+	submission := &models.KYCSubmission{
+		UserID:      userID,
+		Tier:        user.KYCTier + 1,
+		Status:      models.KYCPending,
+		SubmittedAt: now,
+	}
+	switch submission.Tier {
+	case models.KYCTier1:
+		if user.Phone == "" || user.DateOfBirth == "" {
+			return nil, fmt.Errorf("%w: add your phone number and date of birth to your profile first", ErrInvalidKYC)
+		}
+		identifierType := strings.ToLower(strings.TrimSpace(request.IdentifierType))
+		if identifierType != models.IdentifierBVN && identifierType != models.IdentifierNIN {
+			return nil, fmt.Errorf("%w: identifier_type must be %s or %s", ErrInvalidKYC, models.IdentifierBVN, models.IdentifierNIN)
+		}
+		identifier := strings.TrimSpace(request.Identifier)
+		if len(identifier) != 11 || strings.Trim(identifier, "0123456789") != "" {
+			return nil, fmt.Errorf("%w: identifier must be 11 digits", ErrInvalidKYC)
+		}
+		submission.IdentifierType = identifierType
+		submission.Identifier = identifier
+		submission.IdentifierLast = identifier[len(identifier)-4:]
+	default:
+		if submission.Tier == models.KYCTier3 && user.Address == nil {
+			return nil, fmt.Errorf("%w: add your address to your profile first", ErrInvalidKYC)
+		}
+		documents, err := checkKYCDocuments(request.Documents, submission.Tier, now)
+		if err != nil {
+			return nil, err
+		}
+		submission.Documents = documents
+	}
+
+	if err := store.KYC.Create(ctx, submission); err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+func checkKYCDocuments(documents []models.KYCDocument, tier int, now time.Time) ([]models.KYCDocument, error) {
+	accepted := models.KYCDocumentsForTier[tier]
+	if len(documents) == 0 {
+		return nil, fmt.Errorf("%w: tier %d needs one of: %s", ErrInvalidKYC, tier, strings.Join(accepted, ", "))
+	}
+
+	checked := make([]models.KYCDocument, 0, len(documents))
+	for _, document := range documents {
+		document.Type = strings.ToLower(strings.TrimSpace(document.Type))
+		document.FileRef = strings.TrimSpace(document.FileRef)
+		if !slices.Contains(accepted, document.Type) {
+			return nil, fmt.Errorf("%w: tier %d accepts only: %s", ErrInvalidKYC, tier, strings.Join(accepted, ", "))
+		}
+		if document.FileRef == "" {
+			return nil, fmt.Errorf("%w: file_ref is required for each document", ErrInvalidKYC)
+		}
+		if document.ExpiresAt != nil && !document.ExpiresAt.After(now) {
+			return nil, fmt.Errorf("%w: %s has expired", ErrInvalidKYC, document.Type)
+		}
+		if document.IssuedAt != nil && document.IssuedAt.After(now) {
+			return nil, fmt.Errorf("%w: %s is issued in the future", ErrInvalidKYC, document.Type)
+		}
+		document.UploadedAt = now
+		checked = append(checked, document)
+	}
+	return checked, nil
+}
+
+// GetKYC returns the user's tier, its limits and their submissions
+func GetKYC(ctx context.Context, store *repository.Store, userID primitive.ObjectID) (*KYCStatus, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	submissions, err := store.KYC.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &KYCStatus{
+		Tier:        user.KYCTier,
+		Limits:      models.KYCLimits[user.KYCTier],
+		Submissions: submissions,
+	}, nil
+}
+
+// ListKYCSubmissions returns submissions with the status for reviewers,
+// oldest first
+func ListKYCSubmissions(ctx context.Context, store *repository.Store, status string) ([]models.KYCSubmission, error) {
+	switch status {
+	case models.KYCPending, models.KYCApproved, models.KYCRejected:
+	default:
+		return nil, fmt.Errorf("%w: status must be %s, %s or %s", ErrInvalidKYC, models.KYCPending, models.KYCApproved, models.KYCRejected)
+	}
+	return store.KYC.ListByStatus(ctx, status)
+}
+
+// ApproveKYC approves a pending submission and moves the user up to its tier
+func ApproveKYC(ctx context.Context, store *repository.Store, reviewerID, submissionID primitive.ObjectID, note string, now time.Time) (*models.KYCSubmission, error) {
+	return reviewKYC(ctx, store, reviewerID, submissionID, models.KYCApproved, note, now)
+}
+
+// RejectKYC rejects a pending submission. The note is sent to the user so
+// they know what to fix.
+func RejectKYC(ctx context.Context, store *repository.Store, reviewerID, submissionID primitive.ObjectID, note string, now time.Time) (*models.KYCSubmission, error) {
+	if strings.TrimSpace(note) == "" {
+		return nil, fmt.Errorf("%w: a note explaining the rejection is required", ErrInvalidKYC)
+	}
+	return reviewKYC(ctx, store, reviewerID, submissionID, models.KYCRejected, note, now)
+}
+
+func reviewKYC(ctx context.Context, store *repository.Store, reviewerID, submissionID primitive.ObjectID, status, note string, now time.Time) (*models.KYCSubmission, error) {
+	note = strings.TrimSpace(note)
+
+	var submission *models.KYCSubmission
+	var user *models.User
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		submission, err = store.KYC.GetByID(ctx, submissionID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrKYCNotFound
+		} else if err != nil {
+			return err
+		}
+		if submission.UserID == reviewerID {
+			return ErrKYCOwnSubmission
+		}
+		if submission.Status != models.KYCPending {
+			return ErrKYCReviewed
+		}
+		user, err = getUser(ctx, store, submission.UserID)
+		if err != nil {
+			return err
+		}
+
+		err = store.KYC.Review(ctx, submissionID, status, reviewerID, note, now)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrKYCReviewed
+		} else if err != nil {
+			return err
+		}
+		// A user is never moved down by approving an older submission
+		if status == models.KYCApproved && submission.Tier > user.KYCTier {
+			if err := store.Users.SetKYCTier(ctx, user.ID, submission.Tier); err != nil {
+				return err
+			}
+		}
+
+		submission.Status = status
+		submission.ReviewerID = &reviewerID
+		submission.ReviewNote = note
+		submission.ReviewedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if status == models.KYCApproved {
+		NotifyByEmail(user.Email, "Verification approved",
+			fmt.Sprintf("Your account has been verified to tier %d, which raises your deposit, withdrawal and balance limits.", submission.Tier))
+	} else {
+		NotifyByEmail(user.Email, "Verification not approved",
+			fmt.Sprintf("We could not verify your account to tier %d: %s. You can submit again once this is fixed.", submission.Tier, note))
+	}
+	return submission, nil
+}
+
+// checkDepositLimit fails with ErrKYCLimitExceeded if the user's tier does
+// not allow a single deposit of the amount
+func checkDepositLimit(user *models.User, amount models.Money) error {
+	limit := models.KYCLimits[user.KYCTier].MaxDeposit
+	if err := checkKYCCurrency(user, amount, limit); err != nil {
+		return err
+	}
+	if amount.SameCurrency(limit) && amount.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: deposits at tier %d are limited to %s %s", ErrKYCLimitExceeded, user.KYCTier, limit.CurrencyCode(), limit)
+	}
+	return nil
+}
+
+// checkWithdrawalLimit fails with ErrKYCLimitExceeded if the user's tier
+// does not allow the amount to leave their savings in one go
+func checkWithdrawalLimit(user *models.User, amount models.Money) error {
+	limit := models.KYCLimits[user.KYCTier].MaxWithdrawal
+	if err := checkKYCCurrency(user, amount, limit); err != nil {
+		return err
+	}
+	if amount.SameCurrency(limit) && amount.Cmp(limit) > 0 {
+		return fmt.Errorf("%w: withdrawals and transfers at tier %d are limited to %s %s", ErrKYCLimitExceeded, user.KYCTier, limit.CurrencyCode(), limit)
+	}
+	return nil
+}
+
+// checkBalanceLimit fails with ErrKYCLimitExceeded if receiving the amount
+// would take what the user holds across savings, goals and investments over
+// their tier's cap
+func checkBalanceLimit(user *models.User, incoming models.Money) error {
+	limit := models.KYCLimits[user.KYCTier].MaxBalance
+	if limit == nil {
+		return nil
+	}
+	if err := checkKYCCurrency(user, incoming, *limit); err != nil {
+		return err
+	}
+
+	total := incoming
+	for _, balance := range []models.Money{user.SavingsBalance, user.GoalsBalance, user.InvestmentBalance} {
+		if balance.SameCurrency(total) {
+			total = total.Add(balance)
+		}
+	}
+	if total.SameCurrency(*limit) && total.Cmp(*limit) > 0 {
+		return fmt.Errorf("%w: balances at tier %d are limited to %s %s", ErrKYCLimitExceeded, user.KYCTier, limit.CurrencyCode(), limit)
+	}
+	return nil
+}
+
+// receivableAmount returns how much of amount the user can receive before
+// what they hold reaches their tier's cap
+func receivableAmount(user *models.User, amount models.Money) models.Money {
+	limit := models.KYCLimits[user.KYCTier].MaxBalance
+	if limit == nil {
+		return amount
+	}
+	none := models.NewMoney(0, amount.CurrencyCode())
+	if err := checkKYCCurrency(user, amount, *limit); err != nil {
+		return none
+	}
+	if !amount.SameCurrency(*limit) {
+		return amount
+	}
+
+	room := *limit
+	for _, balance := range []models.Money{user.SavingsBalance, user.GoalsBalance, user.InvestmentBalance} {
+		if balance.SameCurrency(room) {
+			room = room.Sub(balance)
+		}
+	}
+	if !room.IsPositive() {
+		return none
+	}
+	return minMoney(amount, room)
+}
+
+// checkKYCCurrency only lets users at the top tier move money in currencies
+// the tier limits aren't set in
+func checkKYCCurrency(user *models.User, amount, limit models.Money) error {
+	if amount.SameCurrency(limit) || user.KYCTier >= models.KYCTierMax {
+		return nil
+	}
+	return fmt.Errorf("%w: %s amounts need tier %d verification", ErrKYCLimitExceeded, amount.CurrencyCode(), models.KYCTierMax)
+}
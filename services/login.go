@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown email and a wrong
+	// password alike, so logins can't be used to find out who has an account
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrTooManyLoginAttempts = errors.New("too many failed login attempts")
+)
+
+// LoginBlockedError is returned while an account or IP address has to wait
+// before trying again. It matches ErrTooManyLoginAttempts.
+type LoginBlockedError struct {
+	RetryAt time.Time
+}
+
+func (e *LoginBlockedError) Error() string {
+	return fmt.Sprintf("%s; try again after %s", ErrTooManyLoginAttempts, e.RetryAt.Format(time.RFC3339))
+}
+
+func (e *LoginBlockedError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// Authenticate checks an email and password. Failures are counted against
+// the account and the IP address they came from; each slows further
+// attempts down and enough of them lock the account for a while, which the
+// user is told about by email.
+func Authenticate(ctx context.Context, store *repository.Store, email, password, ip string, now time.Time) (*models.User, error) {
+	accountKey, ipKey := accountLoginKey(email), ipLoginKey(ip)
+	if err := checkLoginThrottle(ctx, store, accountKey, models.AccountLoginPolicy, now); err != nil {
+		return nil, err
+	}
+	if err := checkLoginThrottle(ctx, store, ipKey, models.IPLoginPolicy, now); err != nil {
+		return nil, err
+	}
+
+	user, err := store.Users.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	hash := dummyPasswordHash()
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	// Compare even for unknown emails so the response takes as long either way
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || user == nil {
+		if err := recordLoginFailure(ctx, store, accountKey, ipKey, user, now); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := store.Logins.Reset(ctx, accountKey); err != nil {
+		return nil, err
+	}
+	// Only said once the password is right, so it gives nothing away
+	if user.IsClosed() {
+		return nil, ErrAccountClosed
+	}
+	return user, nil
+}
+
+// UnlockAccount clears a user's failed login and two-factor code counts and
+// any lockout
+func UnlockAccount(ctx context.Context, store *repository.Store, userID primitive.ObjectID) error {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if err := store.Logins.Reset(ctx, accountLoginKey(user.Email)); err != nil {
+		return err
+	}
+	return store.Logins.Reset(ctx, mfaCodeKey(user.ID))
+}
+
+// checkLoginThrottle fails with a LoginBlockedError if the key has to wait
+// before trying again
+func checkLoginThrottle(ctx context.Context, store *repository.Store, key string, policy models.LoginThrottlePolicy, now time.Time) error {
+	if key == "" {
+		return nil
+	}
+	throttle, err := store.Logins.Get(ctx, key)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if throttle.LastFailureAt.Before(now.Add(-models.LoginFailureWindow)) {
+		return nil
+	}
+	if retryAt := throttle.BlockedUntil(policy); now.Before(retryAt) {
+		return &LoginBlockedError{RetryAt: retryAt}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login against the account and IP
+// address, locking either once it has failed too often
+func recordLoginFailure(ctx context.Context, store *repository.Store, accountKey, ipKey string, user *models.User, now time.Time) error {
+	since := now.Add(-models.LoginFailureWindow)
+	throttle, err := store.Logins.RecordFailure(ctx, accountKey, now, since)
+	if err != nil {
+		return err
+	}
+	if policy := models.AccountLoginPolicy; throttle.Failures >= policy.LockoutAfter {
+		until := now.Add(policy.LockoutFor)
+		if err := store.Logins.Lock(ctx, accountKey, until); err != nil {
+			return err
+		}
+		if user != nil {
+			NotifyByEmail(user.Email, "Your account has been locked",
+				fmt.Sprintf("There were %d failed attempts to sign in to your account, so sign-ins are blocked for %s. ",
+					throttle.Failures, policy.LockoutFor)+
+					"If this wasn't you, someone may be trying to guess your password; reset it to unlock your account now.")
+		}
+	}
+
+	if ipKey == "" {
+		return nil
+	}
+	throttle, err = store.Logins.RecordFailure(ctx, ipKey, now, since)
+	if err != nil {
+		return err
+	}
+	if policy := models.IPLoginPolicy; throttle.Failures >= policy.LockoutAfter {
+		return store.Logins.Lock(ctx, ipKey, now.Add(policy.LockoutFor))
+	}
+	return nil
+}
+
+func accountLoginKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// ipLoginKey is empty when the address isn't known, which skips the IP count
+func ipLoginKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// dummyPasswordHash is compared against when the email is unknown
+func dummyPasswordHash() []byte {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not-a-real-password"), bcrypt.DefaultCost)
+	})
+	return dummyHash
+}
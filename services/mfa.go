@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already on")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not on")
+	ErrMFANotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidStepUp     = errors.New("step-up threshold must be a positive amount")
+	ErrStepUpRequired    = errors.New("enter a two-factor code to continue")
+)
+
+// MFASetup is what a user adds to their authenticator app
+type MFASetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"` // shown as a QR code
+}
+
+// SetupMFA starts two-factor enrolment with a new secret. Codes aren't asked
+// for until EnableMFA confirms the app shows the right ones; calling this
+// again replaces the pending secret.
+func SetupMFA(ctx context.Context, store *repository.Store, userID primitive.ObjectID) (*MFASetup, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Users.SetMFA(ctx, user.ID, &models.MFASettings{Secret: secret}); err != nil {
+		return nil, err
+	}
+	return &MFASetup{Secret: secret, URI: totpURI(user.Email, secret)}, nil
+}
+
+// EnableMFA turns two-factor on once the user enters a code from their app,
+// and returns their recovery codes. They are only ever shown this once.
+func EnableMFA(ctx context.Context, store *repository.Store, userID primitive.ObjectID, code string, now time.Time) ([]string, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.MFAEnabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.MFA == nil {
+		return nil, ErrMFANotSetUp
+	}
+	step, ok := matchTOTP(user.MFA.Secret, normalizeMFACode(code), now)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	err = store.Users.SetMFA(ctx, user.ID, &models.MFASettings{
+		Secret:        user.MFA.Secret,
+		Enabled:       true,
+		EnabledAt:     &now,
+		RecoveryCodes: hashes,
+		LastStep:      step,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	NotifyByEmail(user.Email, "Two-factor authentication is on",
+		"You'll now be asked for a code from your authenticator app when you sign in and for large withdrawals. "+
+			"If this wasn't you, reset your password straight away.")
+	return codes, nil
+}
+
+// DisableMFA turns two-factor off. It takes a code, so someone holding only
+// an access token can't remove it.
+func DisableMFA(ctx context.Context, store *repository.Store, userID primitive.ObjectID, code string, now time.Time) error {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if err := verifyMFACode(ctx, store, user, code, now); err != nil {
+		return err
+	}
+	if err := store.Users.SetMFA(ctx, user.ID, nil); err != nil {
+		return err
+	}
+
+	NotifyByEmail(user.Email, "Two-factor authentication is off",
+		"Two-factor authentication was turned off for your account. If this wasn't you, reset your password "+
+			"and turn it back on straight away.")
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes, so the old
+// ones stop working
+func RegenerateRecoveryCodes(ctx context.Context, store *repository.Store, userID primitive.ObjectID, code string, now time.Time) ([]string, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMFACode(ctx, store, user, code, now); err != nil {
+		return nil, err
+	}
+
+	// Read again so the step the code just used is kept
+	user, err = getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	mfa := *user.MFA
+	mfa.RecoveryCodes = hashes
+	if err := store.Users.SetMFA(ctx, user.ID, &mfa); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// StartMFAChallenge is the first half of signing in with two-factor on: the
+// password was right, and the returned token can be swapped for a session
+// with a code from CompleteMFALogin
+func StartMFAChallenge(ctx context.Context, store *repository.Store, user *models.User, now time.Time) (string, error) {
+	return issueUserToken(ctx, store, user, models.TokenMFAChallenge, models.MFAChallengeTTL, now)
+}
+
+// CompleteMFALogin swaps a login challenge and a code for a session. The
+// challenge is spent even when the code is wrong, so guessing means entering
+// the password again each time.
+func CompleteMFALogin(ctx context.Context, store *repository.Store, challenge, code, userAgent, ip string, now time.Time) (*TokenPair, error) {
+	userToken, err := useUserToken(ctx, store, models.TokenMFAChallenge, challenge, now)
+	if err != nil {
+		return nil, err
+	}
+	user, err := store.Users.GetByID(ctx, userToken.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	if err := verifyMFACode(ctx, store, user, code, now); err != nil {
+		return nil, err
+	}
+	return startSession(ctx, store, user.ID, userAgent, ip, &now, now)
+}
+
+// StepUp records a fresh code against the current session, which lets it
+// make large withdrawals and admin requests for models.StepUpTTL
+func StepUp(ctx context.Context, store *repository.Store, userID, sessionID primitive.ObjectID, code string, now time.Time) error {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if err := verifyMFACode(ctx, store, user, code, now); err != nil {
+		return err
+	}
+	return store.Sessions.SetMFAVerified(ctx, sessionID, now)
+}
+
+// SteppedUp reports whether a session entered a code recently enough to
+// make sensitive requests
+func SteppedUp(mfaVerifiedAt *time.Time, now time.Time) bool {
+	return mfaVerifiedAt != nil && now.Sub(*mfaVerifiedAt) < models.StepUpTTL
+}
+
+// StepUpThreshold returns the withdrawal amount above which a fresh code is
+// needed, falling back to models.DefaultStepUpWithdrawalThreshold until an
+// admin sets one
+func StepUpThreshold(ctx context.Context, store *repository.Store) (models.Money, error) {
+	threshold, err := store.Settings.GetStepUpThreshold(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultStepUpWithdrawalThreshold, nil
+	} else if err != nil {
+		return models.Money{}, err
+	}
+	return *threshold, nil
+}
+
+// SetStepUpThreshold changes the withdrawal step-up threshold
+func SetStepUpThreshold(ctx context.Context, store *repository.Store, threshold models.Money) error {
+	if !threshold.IsPositive() || !models.IsSupportedCurrency(threshold.CurrencyCode()) {
+		return ErrInvalidStepUp
+	}
+	return store.Settings.SetStepUpThreshold(ctx, threshold)
+}
+
+// CheckWithdrawalStepUp fails with ErrStepUpRequired when a user with
+// two-factor on moves more than the threshold out of their account, whether
+// by withdrawal, transfer or goal withdrawal, without having entered a code
+// recently. Amounts in another currency than the threshold always
+// need one.
+func CheckWithdrawalStepUp(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, mfaVerifiedAt *time.Time, now time.Time) error {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if !user.MFAEnabled() || SteppedUp(mfaVerifiedAt, now) {
+		return nil
+	}
+	threshold, err := StepUpThreshold(ctx, store)
+	if err != nil {
+		return err
+	}
+	if amount.SameCurrency(threshold) && amount.Cmp(threshold) <= 0 {
+		return nil
+	}
+	return ErrStepUpRequired
+}
+
+// verifyMFACode accepts a code from the user's app or one of their recovery
+// codes. Either works once. Wrong codes are counted against the user like
+// failed logins, so a stolen session can't be used to guess them; enough
+// of them block codes with a LoginBlockedError for a while.
+func verifyMFACode(ctx context.Context, store *repository.Store, user *models.User, code string, now time.Time) error {
+	if !user.MFAEnabled() {
+		return ErrMFANotEnabled
+	}
+	key := mfaCodeKey(user.ID)
+	if err := checkLoginThrottle(ctx, store, key, models.MFACodePolicy, now); err != nil {
+		return err
+	}
+
+	err := useMFACode(ctx, store, user, code, now)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if err := recordMFAFailure(ctx, store, user, now); err != nil {
+			return err
+		}
+		return ErrInvalidMFACode
+	} else if err != nil {
+		return err
+	}
+	return store.Logins.Reset(ctx, key)
+}
+
+// useMFACode spends a code from the user's app or a recovery code
+func useMFACode(ctx context.Context, store *repository.Store, user *models.User, code string, now time.Time) error {
+	code = normalizeMFACode(code)
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(user.MFA.Secret, code, now)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		if err := store.Users.UseMFAStep(ctx, user.ID, step); errors.Is(err, repository.ErrNotFound) {
+			return ErrInvalidMFACode
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if err := store.Users.UseRecoveryCode(ctx, user.ID, hashToken(code)); errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidMFACode
+	} else if err != nil {
+		return err
+	}
+	NotifyByEmail(user.Email, "A recovery code was used",
+		"One of your two-factor recovery codes was just used. If this wasn't you, reset your password straight away.")
+	return nil
+}
+
+// recordMFAFailure counts a wrong code, locking codes out under
+// models.MFACodePolicy once there have been too many
+func recordMFAFailure(ctx context.Context, store *repository.Store, user *models.User, now time.Time) error {
+	key := mfaCodeKey(user.ID)
+	throttle, err := store.Logins.RecordFailure(ctx, key, now, now.Add(-models.LoginFailureWindow))
+	if err != nil {
+		return err
+	}
+	policy := models.MFACodePolicy
+	if throttle.Failures < policy.LockoutAfter {
+		return nil
+	}
+	if err := store.Logins.Lock(ctx, key, now.Add(policy.LockoutFor)); err != nil {
+		return err
+	}
+	NotifyByEmail(user.Email, "Two-factor codes are locked",
+		fmt.Sprintf("There were %d wrong two-factor codes entered for your account, so codes are blocked for %s. ",
+			throttle.Failures, policy.LockoutFor)+
+			"If this wasn't you, someone may be signed in as you; reset your password straight away.")
+	return nil
+}
+
+func mfaCodeKey(userID primitive.ObjectID) string {
+	return "mfa:" + userID.Hex()
+}
+
+// normalizeMFACode drops the spaces and dashes people type codes with
+func normalizeMFACode(code string) string {
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return strings.ToLower(code)
+}
+
+// newRecoveryCodes returns recovery codes to show the user, formatted
+// xxxxx-xxxxx, and the hashes to store
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < models.RecoveryCodeCount; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(raw))[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashToken(code))
+	}
+	return codes, hashes, nil
+}
+
+// getUser loads a user, mapping a missing one to ErrUserNotFound
+func getUser(ctx context.Context, store *repository.Store, userID primitive.ObjectID) (*models.User, error) {
+	user, err := store.Users.GetByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"fmt"
+	"log"
 	"os"
 
 	"github.com/sendgrid/sendgrid-go"
@@ -10,23 +11,35 @@ import (
 	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
+// EmailSender delivers notification emails; tests replace it to capture messages
+var EmailSender = SendEmail
+
+// NotifyByEmail sends an email in the background so a slow provider never holds up a request
+func NotifyByEmail(toEmail, subject, content string) {
+	go func() {
+		if err := EmailSender(toEmail, subject, content); err != nil {
+			log.Printf("Failed to send %q email to %s: %v", subject, toEmail, err)
+		}
+	}()
+}
+
 // SendEmailNotification sends an email using SendGrid
 func SendEmail(toEmail, subject, content string) error {
 	from := mail.NewEmail("Your App Name", os.Getenv("SENDGRID_FROM_EMAIL"))
 	to := mail.NewEmail("", toEmail)
 	message := mail.NewSingleEmail(from, subject, to, content, content)
-	
+
 	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
 	response, err := client.Send(message)
-	
+
 	if err != nil {
 		return fmt.Errorf("failed to send email: %v", err)
 	}
-	
+
 	if response.StatusCode >= 400 {
 		return fmt.Errorf("error sending email, status code: %v", response.StatusCode)
 	}
-	
+
 	return nil
 }
 
@@ -49,4 +62,4 @@ func SendSMS(toPhone, message string) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,501 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrPoolNotFound   = errors.New("pool not found")
+	ErrInvalidPool    = errors.New("invalid pool")
+	ErrPoolFull       = errors.New("pool is full")
+	ErrPoolStarted    = errors.New("pool has already started")
+	ErrAlreadyMember  = errors.New("already a member of this pool")
+	ErrNotPoolCreator = errors.New("only the pool's creator can do this")
+)
+
+// PoolSettings are what a user chooses when creating a pool
+type PoolSettings struct {
+	Name               string
+	ContributionAmount models.Money
+	Cycle              string
+	MaxMembers         int
+}
+
+// CreatePool sets up a new pool with its creator as the first member. It
+// takes members until the creator starts it.
+func CreatePool(ctx context.Context, store *repository.Store, creatorID primitive.ObjectID, settings PoolSettings, now time.Time) (*models.Pool, error) {
+	settings.Name = strings.TrimSpace(settings.Name)
+	if settings.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidPool)
+	}
+	if !settings.ContributionAmount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+	if !models.ValidFrequency(settings.Cycle) {
+		return nil, fmt.Errorf("%w: cycle must be daily, weekly or monthly", ErrInvalidPool)
+	}
+	if settings.MaxMembers < models.MinPoolMembers || settings.MaxMembers > models.MaxPoolMembers {
+		return nil, fmt.Errorf("%w: max_members must be between %d and %d", ErrInvalidPool, models.MinPoolMembers, models.MaxPoolMembers)
+	}
+	if err := checkPoolMember(ctx, store, creatorID, settings.ContributionAmount, settings.ContributionAmount); err != nil {
+		return nil, err
+	}
+
+	zero := models.NewMoney(0, settings.ContributionAmount.CurrencyCode())
+	pool := &models.Pool{
+		Name:               settings.Name,
+		CreatorID:          creatorID,
+		ContributionAmount: settings.ContributionAmount,
+		Cycle:              settings.Cycle,
+		MaxMembers:         settings.MaxMembers,
+		Members:            []models.PoolMember{newPoolMember(creatorID, 1, zero, now)},
+		Status:             models.PoolForming,
+		Held:               zero,
+		CreatedAt:          now,
+		UpdatedAt:          now,
+	}
+	if err := store.Pools.Create(ctx, pool); err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// GetPool returns a pool the user belongs to. Pools are private to their
+// members, so anyone else gets ErrPoolNotFound.
+func GetPool(ctx context.Context, store *repository.Store, userID, poolID primitive.ObjectID) (*models.Pool, error) {
+	pool, err := getPool(ctx, store, poolID)
+	if err != nil {
+		return nil, err
+	}
+	if pool.Member(userID) < 0 {
+		return nil, ErrPoolNotFound
+	}
+	return pool, nil
+}
+
+// JoinPool adds a user to the end of a forming pool's rotation. Their
+// savings must be held in the pool's currency and their KYC tier must let
+// them hold the pot.
+func JoinPool(ctx context.Context, store *repository.Store, userID, poolID primitive.ObjectID, now time.Time) (*models.Pool, error) {
+	var pool *models.Pool
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		pool, err = getPool(ctx, store, poolID)
+		if err != nil {
+			return err
+		}
+		if pool.Status != models.PoolForming {
+			return ErrPoolStarted
+		}
+		if pool.Member(userID) >= 0 {
+			return ErrAlreadyMember
+		}
+		if len(pool.Members) >= pool.MaxMembers {
+			return ErrPoolFull
+		}
+		pot := models.NewMoney(pool.ContributionAmount.Amount*int64(len(pool.Members)+1), pool.ContributionAmount.CurrencyCode())
+		if err := checkPoolMember(ctx, store, userID, pool.ContributionAmount, pot); err != nil {
+			return err
+		}
+
+		zero := models.NewMoney(0, pool.ContributionAmount.CurrencyCode())
+		pool.Members = append(pool.Members, newPoolMember(userID, len(pool.Members)+1, zero, now))
+		pool.UpdatedAt = now
+		return store.Pools.Update(ctx, pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// LeavePool takes a user out of a pool that has not started. When the
+// creator leaves, the next member in line takes over; when the last member
+// leaves, the pool is cancelled.
+func LeavePool(ctx context.Context, store *repository.Store, userID, poolID primitive.ObjectID, now time.Time) (*models.Pool, error) {
+	var pool *models.Pool
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		pool, err = GetPool(ctx, store, userID, poolID)
+		if err != nil {
+			return err
+		}
+		if pool.Status != models.PoolForming {
+			return ErrPoolStarted
+		}
+
+		i := pool.Member(userID)
+		pool.Members = append(pool.Members[:i], pool.Members[i+1:]...)
+		for j := range pool.Members {
+			pool.Members[j].Position = j + 1
+		}
+		if len(pool.Members) == 0 {
+			pool.Status = models.PoolCancelled
+		} else if pool.CreatorID == userID {
+			pool.CreatorID = pool.Members[0].UserID
+		}
+		pool.UpdatedAt = now
+		return store.Pools.Update(ctx, pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// StartPool closes a pool to new members and sets the payout rotation. order
+// lists every member's ID in the order they are paid out; without it members
+// are paid in the order they joined. The first cycle runs at startAt, or now
+// when it is nil. Every member's KYC tier must let them hold the pot.
+func StartPool(ctx context.Context, store *repository.Store, userID, poolID primitive.ObjectID, order []primitive.ObjectID, startAt *time.Time, now time.Time) (*models.Pool, error) {
+	if startAt != nil && startAt.Before(now) {
+		return nil, fmt.Errorf("%w: start_at must not be in the past", ErrInvalidPool)
+	}
+
+	var pool *models.Pool
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		pool, err = GetPool(ctx, store, userID, poolID)
+		if err != nil {
+			return err
+		}
+		if pool.CreatorID != userID {
+			return ErrNotPoolCreator
+		}
+		if pool.Status != models.PoolForming {
+			return ErrPoolStarted
+		}
+		if len(pool.Members) < models.MinPoolMembers {
+			return fmt.Errorf("%w: a pool needs at least %d members to start", ErrInvalidPool, models.MinPoolMembers)
+		}
+		for _, member := range pool.Members {
+			if err := checkPoolMember(ctx, store, member.UserID, pool.ContributionAmount, pool.Pot()); err != nil {
+				return err
+			}
+		}
+
+		if len(order) > 0 {
+			if len(order) != len(pool.Members) {
+				return fmt.Errorf("%w: order must list every member once", ErrInvalidPool)
+			}
+			positions := map[primitive.ObjectID]int{}
+			for i, memberID := range order {
+				if _, seen := positions[memberID]; seen || pool.Member(memberID) < 0 {
+					return fmt.Errorf("%w: order must list every member once", ErrInvalidPool)
+				}
+				positions[memberID] = i + 1
+			}
+			for i := range pool.Members {
+				pool.Members[i].Position = positions[pool.Members[i].UserID]
+			}
+			sort.Slice(pool.Members, func(i, j int) bool { return pool.Members[i].Position < pool.Members[j].Position })
+		}
+
+		first := now
+		if startAt != nil {
+			first = *startAt
+		}
+		pool.Status = models.PoolActive
+		pool.StartAt = &first
+		pool.NextCycleAt = &first
+		pool.UpdatedAt = now
+		return store.Pools.Update(ctx, pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pool, nil
+}
+
+// RunDuePools runs every pool cycle due by now, at most one per pool per
+// call. Each cycle collects the contribution, and any arrears, from every
+// member's savings and pays the pot to the member whose turn it is. Members
+// short of funds pay what they can; the rest is carried as arrears and the
+// member is emailed. A recipient who is paid short because of that is owed
+// the difference, which is paid from later collections. Once everyone has
+// had a turn the pool keeps collecting arrears until everyone is square.
+// Contributions are fraud screened like transfers: a held contribution waits
+// for a reviewer before it reaches the pool, and a blocked one is carried as
+// arrears.
+func RunDuePools(ctx context.Context, store *repository.Store, now time.Time) (cycles, shortfalls int, err error) {
+	due, err := store.Pools.ListDue(ctx, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var firstErr error
+	for _, pool := range due {
+		short, err := runPoolCycle(ctx, store, pool.ID, now)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("pool %s: %w", pool.ID.Hex(), err)
+			}
+			continue
+		}
+		cycles++
+		shortfalls += len(short)
+	}
+	return cycles, shortfalls, firstErr
+}
+
+// poolShortfall is a member who could not pay all they were due to in a cycle
+type poolShortfall struct {
+	userID  primitive.ObjectID
+	arrears models.Money
+	blocked *models.FraudReview // set when fraud screening refused the contribution
+}
+
+// runPoolCycle runs a pool's next cycle in one transaction. Every movement is
+// referenced by the pool, cycle and member, so a cycle retried after a
+// failure never moves the same money twice.
+func runPoolCycle(ctx context.Context, store *repository.Store, poolID primitive.ObjectID, now time.Time) ([]poolShortfall, error) {
+	var short []poolShortfall
+	var held []*models.FraudReview
+	var pool *models.Pool
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		short, held = nil, nil
+		var err error
+		pool, err = getPool(ctx, store, poolID)
+		if err != nil {
+			return err
+		}
+		// Another run may have got here first
+		running := pool.Status == models.PoolActive || pool.Status == models.PoolSettling
+		if !running || pool.NextCycleAt == nil || pool.NextCycleAt.After(now) {
+			return nil
+		}
+
+		cycle := pool.CurrentCycle
+		reference := func(kind string, userID primitive.ObjectID) string {
+			return fmt.Sprintf("POOL-%s-%d-%s-%s", pool.ID.Hex(), cycle, kind, userID.Hex())
+		}
+
+		for i := range pool.Members {
+			member := &pool.Members[i]
+			due := member.Arrears
+			if pool.Status == models.PoolActive {
+				due = due.Add(pool.ContributionAmount)
+			}
+			if !due.IsPositive() {
+				continue
+			}
+
+			user, err := store.Users.GetByID(ctx, member.UserID)
+			if err != nil {
+				return err
+			}
+			take := models.NewMoney(0, due.CurrencyCode())
+			if user.SavingsBalance.SameCurrency(due) && user.SavingsBalance.IsPositive() {
+				take = minMoney(user.SavingsBalance, due)
+			}
+			var blocked *models.FraudReview
+			if take.IsPositive() {
+				action, flags, err := screenMovement(ctx, store, user, models.HeldPoolContribution, take, primitive.NilObjectID, primitive.NilObjectID, now)
+				if err != nil {
+					return err
+				}
+				review := &models.FraudReview{UserID: member.UserID, Kind: models.HeldPoolContribution, Amount: take, PoolID: pool.ID, Flags: flags}
+				switch action {
+				case models.FraudBlock:
+					blocked = review
+					take = models.NewMoney(0, due.CurrencyCode())
+				case models.FraudHold:
+					// The member has paid; the pool gets the money once a
+					// reviewer approves it, and owes it to the recipient until then
+					if _, err := holdSavings(ctx, store, review, now); err != nil {
+						return err
+					}
+					held = append(held, review)
+				default:
+					_, err := postSavingsChange(ctx, store, member.UserID, take, models.PoolContribution, reference("in", member.UserID), []models.JournalLine{
+						ledger.DebitLine(ledger.UserSavingsAccount(member.UserID), take),
+						ledger.CreditLine(ledger.PoolAccount(pool.ID), take),
+					})
+					if err != nil {
+						return err
+					}
+					pool.Held = pool.Held.Add(take)
+				}
+			}
+
+			member.Arrears = due.Sub(take)
+			if member.Arrears.IsPositive() {
+				if pool.Status == models.PoolActive {
+					member.MissedContributions++
+				}
+				short = append(short, poolShortfall{member.UserID, member.Arrears, blocked})
+			}
+		}
+
+		// Settle what earlier recipients are still owed before this cycle's
+		// payout, netting off anything they themselves have not paid in
+		for i := range pool.Members {
+			member := &pool.Members[i]
+			if !member.Owed.IsPositive() {
+				continue
+			}
+			if member.Arrears.IsPositive() {
+				offset := minMoney(member.Arrears, member.Owed)
+				member.Arrears = member.Arrears.Sub(offset)
+				member.Owed = member.Owed.Sub(offset)
+			}
+			if err := payPoolMember(ctx, store, pool, member, member.Owed, reference("owed", member.UserID)); err != nil {
+				return err
+			}
+		}
+
+		if pool.Status == models.PoolActive {
+			if i := cycle; i < len(pool.Members) {
+				recipient := &pool.Members[i]
+				entitled := pool.Pot()
+				if recipient.Arrears.IsPositive() {
+					withheld := minMoney(recipient.Arrears, entitled)
+					recipient.Arrears = recipient.Arrears.Sub(withheld)
+					entitled = entitled.Sub(withheld)
+				}
+				recipient.Owed = recipient.Owed.Add(entitled)
+				recipient.PaidOut = true
+				if err := payPoolMember(ctx, store, pool, recipient, recipient.Owed, reference("out", recipient.UserID)); err != nil {
+					return err
+				}
+			}
+		}
+
+		pool.CurrentCycle++
+		if pool.CurrentCycle >= len(pool.Members) {
+			pool.Status = models.PoolSettling
+			if poolSquare(pool) {
+				pool.Status = models.PoolCompleted
+			}
+		}
+		if pool.Status == models.PoolCompleted {
+			pool.NextCycleAt = nil
+		} else {
+			next := pool.CycleAt(pool.CurrentCycle)
+			pool.NextCycleAt = &next
+		}
+		pool.UpdatedAt = now
+		return store.Pools.Update(ctx, pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, shortfall := range short {
+		if shortfall.blocked != nil {
+			recordBlocked(ctx, store, *shortfall.blocked, now)
+		}
+		user, err := store.Users.GetByID(ctx, shortfall.userID)
+		if err != nil {
+			continue
+		}
+		if shortfall.blocked != nil {
+			// Don't tell whoever is behind it which rule they tripped
+			NotifyByEmail(user.Email, "Pool contribution missed",
+				fmt.Sprintf("Your contribution to %s can't be made. You are %s %s behind. Contact support if you think this is a mistake.",
+					pool.Name, shortfall.arrears.CurrencyCode(), shortfall.arrears))
+		} else {
+			NotifyByEmail(user.Email, "Pool contribution missed",
+				fmt.Sprintf("Your savings could not cover your contribution to %s. You are %s %s behind; keep enough in savings and it will be collected next cycle.",
+					pool.Name, shortfall.arrears.CurrencyCode(), shortfall.arrears))
+		}
+	}
+	for _, review := range held {
+		if user, err := store.Users.GetByID(ctx, review.UserID); err == nil {
+			NotifyByEmail(user.Email, "Pool contribution under review",
+				fmt.Sprintf("Your contribution of %s %s to %s is being reviewed. The money has been set aside and we will email you once it has been checked.",
+					review.Amount.CurrencyCode(), review.Amount, pool.Name))
+		}
+	}
+	return short, nil
+}
+
+// payPoolMember pays a member as much of amount as the pool holds and their
+// KYC tier lets them hold, leaving the rest owed to them
+func payPoolMember(ctx context.Context, store *repository.Store, pool *models.Pool, member *models.PoolMember, amount models.Money, reference string) error {
+	user, err := store.Users.GetByID(ctx, member.UserID)
+	if err != nil {
+		return err
+	}
+	pay := minMoney(pool.Held, receivableAmount(user, amount))
+	if pay.IsPositive() {
+		_, err := postSavingsChange(ctx, store, member.UserID, pay, models.PoolPayout, reference, []models.JournalLine{
+			ledger.DebitLine(ledger.PoolAccount(pool.ID), pay),
+			ledger.CreditLine(ledger.UserSavingsAccount(member.UserID), pay),
+		})
+		if err != nil {
+			return err
+		}
+		pool.Held = pool.Held.Sub(pay)
+		member.Owed = amount.Sub(pay)
+	} else {
+		member.Owed = amount
+	}
+	return nil
+}
+
+// poolSquare reports whether no member owes the pool or is owed by it
+func poolSquare(pool *models.Pool) bool {
+	for _, member := range pool.Members {
+		if member.Arrears.IsPositive() || member.Owed.IsPositive() {
+			return false
+		}
+	}
+	return true
+}
+
+func newPoolMember(userID primitive.ObjectID, position int, zero models.Money, now time.Time) models.PoolMember {
+	return models.PoolMember{
+		UserID:   userID,
+		Position: position,
+		JoinedAt: now,
+		Arrears:  zero,
+		Owed:     zero,
+	}
+}
+
+// checkPoolMember makes sure a user's savings are held in the pool's
+// currency, since contributions come out of them, and that their KYC tier
+// lets them hold the pot when it is paid out to them
+func checkPoolMember(ctx context.Context, store *repository.Store, userID primitive.ObjectID, contribution, pot models.Money) error {
+	user, err := store.Users.GetByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	if !user.SavingsBalance.SameCurrency(contribution) {
+		return models.ErrCurrencyMismatch
+	}
+	if limit := models.KYCLimits[user.KYCTier].MaxBalance; limit != nil && pot.SameCurrency(*limit) && pot.Cmp(*limit) > 0 {
+		return fmt.Errorf("%w: a payout of %s %s is over the %s %s balance limit at tier %d",
+			ErrKYCLimitExceeded, pot.CurrencyCode(), pot, limit.CurrencyCode(), limit, user.KYCTier)
+	}
+	return nil
+}
+
+func minMoney(a, b models.Money) models.Money {
+	if a.Cmp(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func getPool(ctx context.Context, store *repository.Store, poolID primitive.ObjectID) (*models.Pool, error) {
+	pool, err := store.Pools.GetByID(ctx, poolID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrPoolNotFound
+	}
+	return pool, err
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidProfile   = errors.New("invalid profile")
+	ErrWrongPassword    = errors.New("current password is wrong")
+	ErrEmailTaken       = errors.New("email address is already registered")
+	ErrInvalidEmail     = errors.New("invalid email address")
+	ErrAccountClosed    = errors.New("account is closed")
+	ErrBalanceNotZero   = errors.New("withdraw or transfer your savings, investments and goals before closing your account")
+	ErrAccountInUse     = errors.New("finish or leave your savings pools before closing your account")
+	ErrPaymentsHeld     = errors.New("wait for your held payments to be reviewed before closing your account")
+	ErrInterestPending  = errors.New("wait for your accrued interest to be paid before closing your account")
+	ErrClosureNotNeeded = errors.New("account is already closed")
+)
+
+// phonePattern accepts international numbers like +2348012345678
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{7,15}$`)
+
+// ProfileChanges are the fields of a profile update; nil fields are left as
+// they are, and an empty address removes it
+type ProfileChanges struct {
+	Name        *string
+	Phone       *string
+	DateOfBirth *string
+	Address     *models.Address
+}
+
+// UpdateProfile changes a user's personal details
+func UpdateProfile(ctx context.Context, store *repository.Store, userID primitive.ObjectID, changes ProfileChanges, now time.Time) (*models.User, error) {
+	user, err := getOpenUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.Profile()
+	if err := applyProfileChanges(&profile, changes, now); err != nil {
+		return nil, err
+	}
+	if err := store.Users.SetProfile(ctx, user.ID, profile); err != nil {
+		return nil, err
+	}
+	return getUser(ctx, store, userID)
+}
+
+func applyProfileChanges(profile *models.Profile, changes ProfileChanges, now time.Time) error {
+	if changes.Name != nil {
+		name := strings.TrimSpace(*changes.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name cannot be empty", ErrInvalidProfile)
+		}
+		profile.Name = name
+	}
+	if changes.Phone != nil {
+		phone := strings.NewReplacer(" ", "", "-", "").Replace(*changes.Phone)
+		if phone != "" && !phonePattern.MatchString(phone) {
+			return fmt.Errorf("%w: phone must be an international number such as +2348012345678", ErrInvalidProfile)
+		}
+		profile.Phone = phone
+	}
+	if changes.DateOfBirth != nil {
+		born, err := time.Parse(models.DateOfBirthLayout, *changes.DateOfBirth)
+		if err != nil {
+			return fmt.Errorf("%w: date_of_birth must be formatted YYYY-MM-DD", ErrInvalidProfile)
+		}
+		if born.AddDate(models.MinimumAge, 0, 0).After(now) {
+			return fmt.Errorf("%w: you must be at least %d years old", ErrInvalidProfile, models.MinimumAge)
+		}
+		profile.DateOfBirth = *changes.DateOfBirth
+	}
+	if changes.Address != nil {
+		address := *changes.Address
+		address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+		switch {
+		case address == models.Address{}:
+			profile.Address = nil
+			return nil
+		case address.Line1 == "" || address.City == "":
+			return fmt.Errorf("%w: address needs line1 and city", ErrInvalidProfile)
+		case len(address.Country) != 2:
+			return fmt.Errorf("%w: address country must be a two-letter code", ErrInvalidProfile)
+		}
+		profile.Address = &address
+	}
+	return nil
+}
+
+// ChangePassword sets a new password once the current one is confirmed.
+// Every session is logged out in case someone else knew the old password,
+// and a new one is started for the device that made the change.
+func ChangePassword(ctx context.Context, store *repository.Store, userID primitive.ObjectID, current, password, userAgent, ip string, now time.Time) (*TokenPair, error) {
+	user, err := getOpenUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(current)) != nil {
+		return nil, ErrWrongPassword
+	}
+	if len(password) < MinPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	if err := store.Users.SetPasswordHash(ctx, user.ID, string(hash)); err != nil {
+		return nil, err
+	}
+	if _, err := store.Sessions.RevokeAllForUser(ctx, user.ID, models.SessionPasswordChanged, now); err != nil {
+		return nil, err
+	}
+
+	NotifyByEmail(user.Email, "Your password was changed",
+		"The password for your account was just changed and every other device was signed out. "+
+			"If this wasn't you, reset your password and contact support straight away.")
+	return StartSession(ctx, store, user.ID, userAgent, ip, now)
+}
+
+// RequestEmailChange emails a confirmation link to the new address. The
+// address only changes once the link is used, so a typo can't lock the user
+// out.
+func RequestEmailChange(ctx context.Context, store *repository.Store, userID primitive.ObjectID, password, email string, now time.Time) error {
+	user, err := getOpenUser(ctx, store, userID)
+	if err != nil {
+		return err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return ErrInvalidEmail
+	}
+	if err := checkEmailFree(ctx, store, user.ID, email); err != nil {
+		return err
+	}
+
+	token, err := issueUserTokenTo(ctx, store, user, email, models.TokenEmailChange, models.EmailChangeTTL, now)
+	if err != nil {
+		return err
+	}
+	NotifyByEmail(email, "Confirm your new email address",
+		"Confirm this address to use it for your savings account. "+
+			tokenInstructions("confirm-email-change", token, models.EmailChangeTTL))
+	return nil
+}
+
+// ConfirmEmailChange spends an email change token and switches the user to
+// the new address, which counts as verified. The old address is told.
+func ConfirmEmailChange(ctx context.Context, store *repository.Store, token string, now time.Time) error {
+	userToken, err := useUserToken(ctx, store, models.TokenEmailChange, token, now)
+	if err != nil {
+		return err
+	}
+	user, err := store.Users.GetByID(ctx, userToken.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidToken
+	} else if err != nil {
+		return err
+	}
+	if user.IsClosed() {
+		return ErrInvalidToken
+	}
+	if err := checkEmailFree(ctx, store, user.ID, userToken.Email); err != nil {
+		return err
+	}
+	if err := store.Users.SetEmail(ctx, user.ID, userToken.Email, &now); err != nil {
+		return err
+	}
+
+	NotifyByEmail(user.Email, "Your email address was changed",
+		fmt.Sprintf("Your account now uses %s. If this wasn't you, contact support straight away.", userToken.Email))
+	return nil
+}
+
+// CloseAccount closes a user's account once the password is confirmed. It
+// is refused while the user still has money with us, including interest
+// accrued but not yet paid. The checks and the closure run in one
+// transaction, so nothing can be paid in between. Closing logs them out
+// everywhere, stops their scheduled deposits and round-ups, and blocks
+// logging in again.
+func CloseAccount(ctx context.Context, store *repository.Store, userID primitive.ObjectID, password, reason string, now time.Time) error {
+	var user *models.User
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		user, err = getUser(ctx, store, userID)
+		if err != nil {
+			return err
+		}
+		if user.IsClosed() {
+			return ErrClosureNotNeeded
+		}
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+			return ErrWrongPassword
+		}
+		for _, balance := range []models.Money{user.SavingsBalance, user.InvestmentBalance, user.GoalsBalance} {
+			if !balance.IsZero() {
+				return ErrBalanceNotZero
+			}
+		}
+		// Less than a minor unit can never be paid, so only that is written off
+		if user.Interest.Accrued >= models.InterestPrecision {
+			return ErrInterestPending
+		}
+		if err := checkNoHeldPayments(ctx, store, user.ID); err != nil {
+			return err
+		}
+		pools, err := store.Pools.ListByMember(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, pool := range pools {
+			if pool.Status != models.PoolCompleted && pool.Status != models.PoolCancelled {
+				return ErrAccountInUse
+			}
+		}
+
+		schedules, err := store.Schedules.ListByUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		for _, schedule := range schedules {
+			if schedule.Status == models.ScheduleCancelled {
+				continue
+			}
+			schedule.Status = models.ScheduleCancelled
+			schedule.UpdatedAt = now
+			if err := store.Schedules.Update(ctx, &schedule); err != nil {
+				return err
+			}
+		}
+		if user.RoundUpRule != nil {
+			if err := store.Users.SetRoundUpRule(ctx, user.ID, nil); err != nil {
+				return err
+			}
+		}
+		if user.Interest.Accrued != 0 {
+			if err := store.Users.SetInterestAccrual(ctx, user.ID, models.InterestAccrual{}); err != nil {
+				return err
+			}
+		}
+		if err := store.Users.Close(ctx, user.ID, strings.TrimSpace(reason), now); err != nil {
+			return err
+		}
+		_, err = store.Sessions.RevokeAllForUser(ctx, user.ID, models.SessionAccountClosed, now)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	NotifyByEmail(user.Email, "Your account has been closed",
+		"Your savings account has been closed as you asked. If this wasn't you, contact support straight away.")
+	return nil
+}
+
+// getOpenUser loads a user, failing with ErrAccountClosed if they closed
+// their account
+func getOpenUser(ctx context.Context, store *repository.Store, userID primitive.ObjectID) (*models.User, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.IsClosed() {
+		return nil, ErrAccountClosed
+	}
+	return user, nil
+}
+
+// checkEmailFree fails with ErrEmailTaken if another user has the address
+func checkEmailFree(ctx context.Context, store *repository.Store, userID primitive.ObjectID, email string) error {
+	existing, err := store.Users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if existing.ID != userID {
+		return ErrEmailTaken
+	}
+	return fmt.Errorf("%w: that is already your address", ErrInvalidEmail)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNothingToRedeem   = errors.New("nothing to redeem")
+	ErrRedemptionPending = errors.New("another redemption is already pending")
+)
+
+// RequestRedemption asks to move amount (or, if full, everything) from the
+// user's investment balance back to savings. Without a notice period on the
+// user's tier, or when early is set, it settles at once and early redemption
+// costs the tier's penalty; otherwise it is scheduled to settle when the
+// notice period ends. Money already promised to pending redemptions can't be
+// redeemed twice.
+func RequestRedemption(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, full, early bool, now time.Time) (*models.RedemptionRequest, error) {
+	if !full && !amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	tiers, err := InterestTiers(ctx, store)
+	if err != nil {
+		return nil, err
+	}
+
+	var redemption *models.RedemptionRequest
+	err = store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := store.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		tier := tierFor(user, tiers)
+
+		available, err := unpromisedInvestment(ctx, store, user, full)
+		if err != nil {
+			return err
+		}
+		if full {
+			if !available.IsPositive() {
+				return ErrNothingToRedeem
+			}
+			amount = models.NewMoney(0, available.CurrencyCode())
+		} else if !amount.SameCurrency(available) {
+			return models.ErrCurrencyMismatch
+		} else if amount.Cmp(available) > 0 {
+			return ErrInsufficientBalance
+		}
+
+		redemption = &models.RedemptionRequest{
+			ID:          primitive.NewObjectID(),
+			UserID:      userID,
+			Full:        full,
+			Amount:      amount,
+			Early:       early && tier.NoticeDays > 0,
+			Penalty:     models.NewMoney(0, amount.CurrencyCode()),
+			Status:      models.RedemptionPending,
+			RequestedAt: now,
+			SettleAt:    now,
+		}
+		if !redemption.Early {
+			redemption.SettleAt = now.AddDate(0, 0, tier.NoticeDays)
+		}
+		if err := store.Redemptions.Create(ctx, redemption); err != nil {
+			return err
+		}
+
+		if redemption.SettleAt.After(now) {
+			return nil
+		}
+		return settleRedemption(ctx, store, redemption, tier, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redemption, nil
+}
+
+// unpromisedInvestment is the user's investment balance less what pending
+// redemptions will take. A full redemption can't be combined with others.
+func unpromisedInvestment(ctx context.Context, store *repository.Store, user *models.User, full bool) (models.Money, error) {
+	redemptions, err := store.Redemptions.ListByUser(ctx, user.ID)
+	if err != nil {
+		return models.Money{}, err
+	}
+
+	available := user.InvestmentBalance
+	for _, pending := range redemptions {
+		if pending.Status != models.RedemptionPending {
+			continue
+		}
+		if pending.Full || full {
+			return models.Money{}, ErrRedemptionPending
+		}
+		if pending.Amount.SameCurrency(available) {
+			available = available.Sub(pending.Amount)
+		}
+	}
+	return available, nil
+}
+
+// SettleDueRedemptions settles every pending redemption whose notice period has
+// ended by now. A redemption that can no longer be paid, e.g. because the
+// balance was redeemed early in the meantime, is marked failed; one that
+// fails for any other reason, such as a database error, stays pending and
+// is tried again on the next run.
+func SettleDueRedemptions(ctx context.Context, store *repository.Store, now time.Time) (settled, failed int, err error) {
+	tiers, err := InterestTiers(ctx, store)
+	if err != nil {
+		return 0, 0, err
+	}
+	due, err := store.Redemptions.ListDue(ctx, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range due {
+		redemption := due[i]
+		var user *models.User
+		err := store.WithTransaction(ctx, func(ctx context.Context) error {
+			found, err := store.Users.GetByID(ctx, redemption.UserID)
+			if err != nil {
+				return err
+			}
+			user = found
+			return settleRedemption(ctx, store, &redemption, tierFor(user, tiers), now)
+		})
+		if err != nil && !errors.Is(err, ErrNothingToRedeem) && !errors.Is(err, ErrInsufficientBalance) {
+			// Left pending so the next run tries again
+			log.Printf("Failed to settle redemption %s: %v", redemption.ID.Hex(), err)
+			continue
+		} else if err != nil {
+			failed++
+			redemption.Status = models.RedemptionFailed
+			redemption.FailureReason = err.Error()
+			if err := store.Redemptions.Update(ctx, &redemption); err != nil {
+				return settled, failed, err
+			}
+			continue
+		}
+
+		settled++
+		NotifyByEmail(user.Email, "Redemption settled",
+			fmt.Sprintf("%s %s from your investments is now in your savings.", redemption.Amount.CurrencyCode(), redemption.Amount.Sub(redemption.Penalty)))
+	}
+	return settled, failed, nil
+}
+
+// settleRedemption moves a redemption's money from investments to savings,
+// keeping any early redemption penalty, and marks it settled. Call it inside
+// store.WithTransaction.
+func settleRedemption(ctx context.Context, store *repository.Store, redemption *models.RedemptionRequest, tier models.InterestTier, now time.Time) error {
+	user, err := store.Users.GetByID(ctx, redemption.UserID)
+	if err != nil {
+		return err
+	}
+
+	gross := redemption.Amount
+	if redemption.Full {
+		gross = user.InvestmentBalance
+	}
+	if !gross.IsPositive() {
+		return ErrNothingToRedeem
+	}
+	penalty := models.NewMoney(0, gross.CurrencyCode())
+	if redemption.Early {
+		penalty = tier.EarlyPenalty(gross)
+	}
+	net := gross.Sub(penalty)
+
+	savingsAccount := ledger.UserSavingsAccount(user.ID)
+	lines := []models.JournalLine{ledger.DebitLine(ledger.UserInvestmentAccount(user.ID), gross)}
+	if net.IsPositive() {
+		lines = append(lines, ledger.CreditLine(savingsAccount, net))
+	}
+	if penalty.IsPositive() {
+		lines = append(lines, ledger.CreditLine(ledger.FeeIncomeAccount, penalty))
+	}
+
+	transaction := models.Transaction{
+		ID:             primitive.NewObjectID(),
+		UserID:         user.ID,
+		Type:           string(models.Redemption),
+		Amount:         net,
+		Direction:      models.Credit,
+		Reference:      "RDM-" + redemption.ID.Hex(),
+		JournalEntryID: primitive.NewObjectID(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if penalty.IsPositive() {
+		transaction.Note = fmt.Sprintf("early redemption penalty %s %s", penalty.CurrencyCode(), penalty)
+	}
+
+	// The reference is unique, so a redemption can only ever settle once
+	balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+		ID:          transaction.JournalEntryID,
+		Reference:   transaction.Reference,
+		Description: string(models.Redemption),
+		Lines:       lines,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Redeemed savings count as used, so the allocation job leaves them be
+	if err := store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID}, now); err != nil {
+		return err
+	}
+	savingsBalance := balances[savingsAccount]
+	if !net.IsPositive() {
+		savingsBalance = user.SavingsBalance
+	}
+	transaction.BalanceAfter = &savingsBalance
+	if err := store.Transactions.Insert(ctx, transaction); err != nil {
+		return err
+	}
+
+	settledAt := now
+	redemption.Amount = gross
+	redemption.Penalty = penalty
+	redemption.Status = models.RedemptionSettled
+	redemption.SettledAt = &settledAt
+	redemption.TransactionID = transaction.ID
+	return store.Redemptions.Update(ctx, redemption)
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrInvalidRole = errors.New("invalid role")
+	ErrOwnRoles    = errors.New("staff can't change their own roles")
+)
+
+// SetUserRoles replaces a user's staff roles; none makes them a customer
+// again. The user's sessions are ended so their next login carries the new
+// roles. Staff can't change their own roles, so nobody can lock themselves
+// out or raise their own access.
+func SetUserRoles(ctx context.Context, store *repository.Store, actorID, userID primitive.ObjectID, roles []string, now time.Time) ([]string, error) {
+	if actorID == userID {
+		return nil, ErrOwnRoles
+	}
+	unique := map[string]bool{}
+	for _, role := range roles {
+		if !models.ValidRole(role) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+		}
+		unique[role] = true
+	}
+	roles = make([]string, 0, len(unique))
+	for role := range unique {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	if err := store.Users.SetRoles(ctx, userID, roles); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if _, err := store.Sessions.RevokeAllForUser(ctx, userID, models.SessionRolesChanged, now); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
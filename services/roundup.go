@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrRoundUpsOff       = errors.New("round-ups are not turned on")
+	ErrDuplicatePurchase = errors.New("purchase already received")
+)
+
+// CardPurchase is a purchase reported by the card processor's webhook
+type CardPurchase struct {
+	EventID     string
+	UserID      primitive.ObjectID
+	Amount      models.Money
+	Merchant    string
+	PurchasedAt time.Time
+}
+
+// SetRoundUpRule turns round-ups on for a user, or changes their rule; nil
+// turns them off. Round-ups already received are still saved.
+func SetRoundUpRule(ctx context.Context, store *repository.Store, userID primitive.ObjectID, rule *models.RoundUpRule, now time.Time) error {
+	if rule != nil {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		user, err := store.Users.GetByID(ctx, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if rule.DailyCap != nil && !rule.DailyCap.SameCurrency(user.SavingsBalance) {
+			return fmt.Errorf("%w: daily_cap must be in the savings currency", models.ErrInvalidRoundUpRule)
+		}
+		rule.UpdatedAt = now
+	}
+
+	err := store.Users.SetRoundUpRule(ctx, userID, rule)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// RecordPurchase works out the round-up on a card purchase under the user's
+// rule and holds it for the day's batch. Each event ID is only ever counted
+// once; a repeat fails with ErrDuplicatePurchase.
+func RecordPurchase(ctx context.Context, store *repository.Store, purchase CardPurchase, now time.Time) (*models.RoundUpEvent, error) {
+	if !purchase.Amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+	user, err := store.Users.GetByID(ctx, purchase.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if user.RoundUpRule == nil {
+		return nil, ErrRoundUpsOff
+	}
+	if !purchase.Amount.SameCurrency(user.SavingsBalance) {
+		return nil, models.ErrCurrencyMismatch
+	}
+
+	event := &models.RoundUpEvent{
+		EventID:     purchase.EventID,
+		UserID:      user.ID,
+		Purchase:    purchase.Amount,
+		Merchant:    purchase.Merchant,
+		PurchasedAt: purchase.PurchasedAt,
+		Amount:      user.RoundUpRule.RoundUpFor(purchase.Amount),
+		Status:      models.RoundUpPending,
+		ReceivedAt:  now,
+		BatchDate:   startOfDay(now),
+	}
+	if err := store.RoundUps.Create(ctx, event); errors.Is(err, repository.ErrDuplicateKey) {
+		return nil, ErrDuplicatePurchase
+	} else if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// PostRoundUps saves the round-ups of every day before now's, one ledger
+// entry and one roundup transaction per user per day, limited to the user's
+// daily cap. A batch that fails is left pending for the next run.
+func PostRoundUps(ctx context.Context, store *repository.Store, now time.Time) (posted int, err error) {
+	pending, err := store.RoundUps.ListPending(ctx, startOfDay(now))
+	if err != nil {
+		return 0, err
+	}
+
+	type batchKey struct {
+		userID primitive.ObjectID
+		day    time.Time
+	}
+	batches := map[batchKey][]models.RoundUpEvent{}
+	var keys []batchKey
+	for _, event := range pending {
+		key := batchKey{event.UserID, event.BatchDate}
+		if _, seen := batches[key]; !seen {
+			keys = append(keys, key)
+		}
+		batches[key] = append(batches[key], event)
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].day.Before(keys[j].day) })
+
+	var firstErr error
+	for _, key := range keys {
+		if err := postRoundUpBatch(ctx, store, batches[key], now); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("round-ups for %s on %s: %w", key.userID.Hex(), key.day.Format(time.DateOnly), err)
+			}
+			continue
+		}
+		posted++
+	}
+	return posted, firstErr
+}
+
+// postRoundUpBatch saves one user's round-ups for one day. The batch is
+// referenced by its first round-up, which can only be posted once, so the
+// same round-ups are never saved twice.
+func postRoundUpBatch(ctx context.Context, store *repository.Store, events []models.RoundUpEvent, now time.Time) error {
+	userID := events[0].UserID
+	ids := make([]primitive.ObjectID, 0, len(events))
+	total := models.NewMoney(0, events[0].Amount.CurrencyCode())
+	for _, event := range events {
+		ids = append(ids, event.ID)
+		if event.Amount.SameCurrency(total) {
+			total = total.Add(event.Amount)
+		}
+	}
+
+	return store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := store.Users.GetByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		amount := total
+		if user.RoundUpRule != nil {
+			amount = user.RoundUpRule.Capped(total)
+		}
+		// Like round-ups over the daily cap, a batch that would take the user
+		// over their tier's balance cap isn't saved; retrying would never help
+		if amount.IsPositive() && checkBalanceLimit(user, amount) != nil {
+			amount = models.NewMoney(0, amount.CurrencyCode())
+		}
+
+		transactionID := primitive.NilObjectID
+		if amount.IsPositive() {
+			change, err := postSavingsChange(ctx, store, userID, amount, models.RoundUp, "RUP-"+events[0].ID.Hex(), []models.JournalLine{
+				ledger.DebitLine(ledger.ExternalCashAccount, amount),
+				ledger.CreditLine(ledger.UserSavingsAccount(userID), amount),
+			})
+			if err != nil {
+				return err
+			}
+			transactionID = change.Transaction.ID
+		}
+		return store.RoundUps.MarkPosted(ctx, ids, transactionID, now)
+	})
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+// ScheduledDepositCharger collects a scheduled deposit from the user's funding
+// source before it is credited. The reference is the same on every retry of a
+// run so a payment provider can refuse to charge twice. Until a provider is
+// wired in every charge succeeds; tests replace it to make runs fail.
+var ScheduledDepositCharger = func(ctx context.Context, schedule models.DepositSchedule, reference string) error {
+	return nil
+}
+
+// ScheduleChanges are the fields of a schedule a user sets; nil fields are
+// left alone. Frequency, start and goal are fixed once a schedule is created.
+type ScheduleChanges struct {
+	Frequency *string
+	Amount    *models.Money
+	GoalID    *primitive.ObjectID
+	StartAt   *time.Time
+	EndAt     *time.Time
+	Status    *string // active or paused
+}
+
+// CreateSchedule sets up a standing order. Runs fall on the start time and
+// repeat at the frequency from there; a start in the past only anchors the
+// schedule, and its first run is the next one due from now.
+func CreateSchedule(ctx context.Context, store *repository.Store, userID primitive.ObjectID, changes ScheduleChanges, now time.Time) (*models.DepositSchedule, error) {
+	if changes.Frequency == nil || changes.Amount == nil {
+		return nil, fmt.Errorf("%w: frequency and amount are required", ErrInvalidSchedule)
+	}
+	if !models.ValidFrequency(*changes.Frequency) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSchedule, models.ErrInvalidFrequency)
+	}
+	if !changes.Amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	schedule := &models.DepositSchedule{
+		UserID:    userID,
+		Frequency: *changes.Frequency,
+		Amount:    *changes.Amount,
+		StartAt:   now,
+		EndAt:     changes.EndAt,
+		Status:    models.ScheduleActive,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if changes.StartAt != nil {
+		schedule.StartAt = *changes.StartAt
+	}
+	if changes.GoalID != nil && !changes.GoalID.IsZero() {
+		goal, err := getGoal(ctx, store, userID, *changes.GoalID)
+		if err != nil {
+			return nil, err
+		}
+		if !goal.SavedAmount.SameCurrency(schedule.Amount) {
+			return nil, models.ErrCurrencyMismatch
+		}
+		schedule.GoalID = goal.ID
+	}
+
+	schedule.Occurrence = schedule.FirstOccurrenceFrom(now)
+	if schedule.HasEnded(schedule.Occurrence) {
+		return nil, fmt.Errorf("%w: end_at is before the first run", ErrInvalidSchedule)
+	}
+	schedule.NextRunAt = schedule.OccurrenceAt(schedule.Occurrence)
+
+	if err := store.Schedules.Create(ctx, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// UpdateSchedule changes a schedule's amount or end date, or pauses or resumes
+// it. Resuming starts afresh from the next run due; runs missed while paused
+// are skipped rather than made up.
+func UpdateSchedule(ctx context.Context, store *repository.Store, userID, scheduleID primitive.ObjectID, changes ScheduleChanges, now time.Time) (*models.DepositSchedule, error) {
+	var schedule *models.DepositSchedule
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		schedule, err = getSchedule(ctx, store, userID, scheduleID)
+		if err != nil {
+			return err
+		}
+		if schedule.Status == models.ScheduleCancelled || schedule.Status == models.ScheduleEnded {
+			return fmt.Errorf("%w: a %s schedule can't be changed", ErrInvalidSchedule, schedule.Status)
+		}
+
+		if changes.Amount != nil {
+			if !changes.Amount.IsPositive() {
+				return models.ErrInvalidAmount
+			}
+			if !changes.Amount.SameCurrency(schedule.Amount) {
+				return models.ErrCurrencyMismatch
+			}
+			schedule.Amount = *changes.Amount
+		}
+		if changes.Status != nil {
+			switch *changes.Status {
+			case models.SchedulePaused:
+				schedule.Status = models.SchedulePaused
+			case models.ScheduleActive:
+				if schedule.Status == models.SchedulePaused {
+					schedule.Status = models.ScheduleActive
+					schedule.ConsecutiveFailures = 0
+					if next := schedule.FirstOccurrenceFrom(now); next > schedule.Occurrence {
+						schedule.Occurrence = next
+					}
+					schedule.NextRunAt = schedule.OccurrenceAt(schedule.Occurrence)
+				}
+			default:
+				return fmt.Errorf("%w: status must be active or paused", ErrInvalidSchedule)
+			}
+		}
+		if changes.EndAt != nil {
+			if !changes.EndAt.After(now) {
+				return fmt.Errorf("%w: end_at must be in the future", ErrInvalidSchedule)
+			}
+			schedule.EndAt = changes.EndAt
+		}
+		if schedule.HasEnded(schedule.Occurrence) {
+			schedule.Status = models.ScheduleEnded
+		}
+
+		schedule.UpdatedAt = now
+		return store.Schedules.Update(ctx, schedule)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// CancelSchedule stops a schedule for good. It is kept so its runs can still
+// be looked up.
+func CancelSchedule(ctx context.Context, store *repository.Store, userID, scheduleID primitive.ObjectID, now time.Time) error {
+	return store.WithTransaction(ctx, func(ctx context.Context) error {
+		schedule, err := getSchedule(ctx, store, userID, scheduleID)
+		if err != nil {
+			return err
+		}
+		schedule.Status = models.ScheduleCancelled
+		schedule.UpdatedAt = now
+		return store.Schedules.Update(ctx, schedule)
+	})
+}
+
+// RunDueSchedules makes every scheduled deposit due by now, recording the
+// outcome of each attempt. A failed run is retried with a growing delay, and
+// after MaxScheduleFailures failures in a row the schedule is paused and the
+// user told. Each schedule makes at most one run per call, so missed runs are
+// caught up one call at a time.
+func RunDueSchedules(ctx context.Context, store *repository.Store, now time.Time) (succeeded, failed int, err error) {
+	due, err := store.Schedules.ListDue(ctx, now)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	for i := range due {
+		schedule := due[i]
+		if schedule.HasEnded(schedule.Occurrence) {
+			schedule.Status = models.ScheduleEnded
+			schedule.UpdatedAt = now
+			if err := store.Schedules.Update(ctx, &schedule); err != nil {
+				return succeeded, failed, err
+			}
+			continue
+		}
+
+		run, err := runSchedule(ctx, store, schedule, now)
+		if err != nil {
+			return succeeded, failed, err
+		}
+		if run.Status == models.RunSucceeded {
+			succeeded++
+		} else {
+			failed++
+		}
+	}
+	return succeeded, failed, nil
+}
+
+// runSchedule makes one attempt at a schedule's next run. An error from the
+// charge or the deposit is recorded on the run; only a failure to record the
+// outcome is returned.
+func runSchedule(ctx context.Context, store *repository.Store, schedule models.DepositSchedule, now time.Time) (*models.ScheduleRun, error) {
+	reference := fmt.Sprintf("SCH-%s-%d", schedule.ID.Hex(), schedule.Occurrence)
+	run := &models.ScheduleRun{
+		ID:         primitive.NewObjectID(),
+		ScheduleID: schedule.ID,
+		UserID:     schedule.UserID,
+		Occurrence: schedule.Occurrence,
+		DueAt:      schedule.OccurrenceAt(schedule.Occurrence),
+		Attempt:    schedule.ConsecutiveFailures + 1,
+		RanAt:      now,
+	}
+
+	// Don't charge for a deposit the user's limits won't let us credit
+	runErr := checkScheduledDepositLimits(ctx, store, schedule, now)
+	if runErr == nil {
+		runErr = ScheduledDepositCharger(ctx, schedule, reference)
+	}
+	if runErr == nil {
+		runErr = store.WithTransaction(ctx, func(ctx context.Context) error {
+			transactionID, err := postScheduledDeposit(ctx, store, schedule, reference, now)
+			if err != nil {
+				return err
+			}
+			run.Status = models.RunSucceeded
+			run.TransactionID = transactionID
+
+			// Re-read so changes the user made since the schedule was listed
+			// are kept
+			current, err := getSchedule(ctx, store, schedule.UserID, schedule.ID)
+			if err != nil {
+				return err
+			}
+			current.Occurrence = schedule.Occurrence + 1
+			current.NextRunAt = current.OccurrenceAt(current.Occurrence)
+			current.ConsecutiveFailures = 0
+			current.LastRunAt = &now
+			current.UpdatedAt = now
+			if current.Status == models.ScheduleActive && current.HasEnded(current.Occurrence) {
+				current.Status = models.ScheduleEnded
+			}
+			if err := store.Schedules.Update(ctx, current); err != nil {
+				return err
+			}
+			return store.Schedules.AddRun(ctx, run)
+		})
+	}
+	if runErr == nil {
+		return run, nil
+	}
+
+	run.Status = models.RunFailed
+	run.Error = runErr.Error()
+	run.TransactionID = primitive.NilObjectID
+	var paused *models.DepositSchedule
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := getSchedule(ctx, store, schedule.UserID, schedule.ID)
+		if err != nil {
+			return err
+		}
+		current.ConsecutiveFailures++
+		current.LastRunAt = &now
+		current.UpdatedAt = now
+		if current.ConsecutiveFailures >= models.MaxScheduleFailures {
+			if current.Status == models.ScheduleActive {
+				current.Status = models.SchedulePaused
+				paused = current
+			}
+		} else {
+			current.NextRunAt = now.Add(models.ScheduleRetryDelay << (current.ConsecutiveFailures - 1))
+		}
+		if err := store.Schedules.Update(ctx, current); err != nil {
+			return err
+		}
+		return store.Schedules.AddRun(ctx, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if paused != nil {
+		if user, err := store.Users.GetByID(ctx, paused.UserID); err == nil {
+			NotifyByEmail(user.Email, "Scheduled deposit paused",
+				fmt.Sprintf("Your %s deposit of %s %s failed %d times in a row and has been paused. Resume it once the problem is fixed.",
+					paused.Frequency, paused.Amount.CurrencyCode(), paused.Amount, paused.ConsecutiveFailures))
+		}
+	}
+	return run, nil
+}
+
+// postScheduledDeposit credits a scheduled deposit to savings or the
+// schedule's goal. The run's reference keeps it from being credited twice.
+func postScheduledDeposit(ctx context.Context, store *repository.Store, schedule models.DepositSchedule, reference string, now time.Time) (primitive.ObjectID, error) {
+	if err := checkScheduledDepositLimits(ctx, store, schedule, now); err != nil {
+		return primitive.NilObjectID, err
+	}
+
+	if schedule.GoalID.IsZero() {
+		change, err := postSavingsChange(ctx, store, schedule.UserID, schedule.Amount, models.Deposit, reference, []models.JournalLine{
+			ledger.DebitLine(ledger.ExternalCashAccount, schedule.Amount),
+			ledger.CreditLine(ledger.UserSavingsAccount(schedule.UserID), schedule.Amount),
+		})
+		if err != nil {
+			return primitive.NilObjectID, err
+		}
+		return change.Transaction.ID, nil
+	}
+
+	_, transaction, err := postGoalChange(ctx, store, schedule.UserID, schedule.GoalID, schedule.Amount, models.Deposit, reference, []models.JournalLine{
+		ledger.DebitLine(ledger.ExternalCashAccount, schedule.Amount),
+		ledger.CreditLine(ledger.UserGoalsAccount(schedule.UserID), schedule.Amount),
+	})
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return transaction.ID, nil
+}
+
+// checkScheduledDepositLimits checks that the deposit and the balance it
+// leaves are within the user's KYC tier limits, and that the deposit fits
+// within their daily and monthly velocity limits
+func checkScheduledDepositLimits(ctx context.Context, store *repository.Store, schedule models.DepositSchedule, now time.Time) error {
+	user, err := getUser(ctx, store, schedule.UserID)
+	if err != nil {
+		return err
+	}
+	if err := checkDepositLimit(user, schedule.Amount); err != nil {
+		return err
+	}
+	if err := checkBalanceLimit(user, schedule.Amount); err != nil {
+		return err
+	}
+	return checkVelocityLimit(ctx, store, user, models.VelocityDeposit, schedule.Amount, now)
+}
+
+func getSchedule(ctx context.Context, store *repository.Store, userID, scheduleID primitive.ObjectID) (*models.DepositSchedule, error) {
+	schedule, err := store.Schedules.GetByID(ctx, userID, scheduleID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, err
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshTokenTTL is how long a refresh token can be swapped for a new pair.
+// Each refresh starts the clock again, so a device in regular use stays
+// signed in.
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token already used")
+	ErrSessionRevoked      = errors.New("session has ended")
+)
+
+// TokenPair is what a client gets on login and on every refresh
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // seconds until the access token expires
+}
+
+// StartSession signs a user in on a new device
+func StartSession(ctx context.Context, store *repository.Store, userID primitive.ObjectID, userAgent, ip string, now time.Time) (*TokenPair, error) {
+	return startSession(ctx, store, userID, userAgent, ip, nil, now)
+}
+
+// startSession creates a session, marked as having entered a two-factor
+// code when mfaVerifiedAt is set
+func startSession(ctx context.Context, store *repository.Store, userID primitive.ObjectID, userAgent, ip string, mfaVerifiedAt *time.Time, now time.Time) (*TokenPair, error) {
+	session := &models.Session{
+		UserID:          userID,
+		UserAgent:       userAgent,
+		IP:              ip,
+		CreatedAt:       now,
+		LastRefreshedAt: now,
+		MFAVerifiedAt:   mfaVerifiedAt,
+	}
+	if err := store.Sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, store, session, now)
+}
+
+// RefreshSession swaps a refresh token for a new access and refresh token.
+// A refresh token works once: presenting one that was already swapped means
+// it was copied, so the whole session is revoked and the user told.
+func RefreshSession(ctx context.Context, store *repository.Store, refreshToken string, now time.Time) (*TokenPair, error) {
+	token, err := store.Sessions.GetRefreshToken(ctx, hashToken(refreshToken))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+	if token.UsedAt != nil {
+		return nil, revokeReusedSession(ctx, store, token, now)
+	}
+	if !now.Before(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, err := store.Sessions.GetByID(ctx, token.SessionID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	} else if err != nil {
+		return nil, err
+	}
+	if session.IsRevoked() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Another refresh got in first with the same token
+	if err := store.Sessions.UseRefreshToken(ctx, token.ID, now); errors.Is(err, repository.ErrNotFound) {
+		return nil, revokeReusedSession(ctx, store, token, now)
+	} else if err != nil {
+		return nil, err
+	}
+	if err := store.Sessions.Refreshed(ctx, session.ID, now); err != nil {
+		return nil, err
+	}
+	return issueTokens(ctx, store, session, now)
+}
+
+// EndSession logs a user out on one device
+func EndSession(ctx context.Context, store *repository.Store, userID, sessionID primitive.ObjectID, now time.Time) error {
+	session, err := store.Sessions.GetByID(ctx, sessionID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && session.UserID != userID) {
+		return ErrSessionRevoked
+	} else if err != nil {
+		return err
+	}
+	return store.Sessions.Revoke(ctx, sessionID, models.SessionLoggedOut, now)
+}
+
+// EndAllSessions logs a user out on every device and returns how many
+// sessions were ended
+func EndAllSessions(ctx context.Context, store *repository.Store, userID primitive.ObjectID, now time.Time) (int64, error) {
+	return store.Sessions.RevokeAllForUser(ctx, userID, models.SessionLoggedOutAll, now)
+}
+
+// CheckSession makes sure the session an access token was issued for is
+// still live, so logging out takes effect before the token expires, and
+// returns it
+func CheckSession(ctx context.Context, store *repository.Store, userID, sessionID string) (*models.Session, error) {
+	sessionObjectID, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return nil, ErrSessionRevoked
+	}
+	session, err := store.Sessions.GetByID(ctx, sessionObjectID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrSessionRevoked
+	} else if err != nil {
+		return nil, err
+	}
+	if session.IsRevoked() || session.UserID.Hex() != userID {
+		return nil, ErrSessionRevoked
+	}
+	return session, nil
+}
+
+// issueTokens signs a new access token for a session and adds a new refresh
+// token to its family
+func issueTokens(ctx context.Context, store *repository.Store, session *models.Session, now time.Time) (*TokenPair, error) {
+	// Read the user each time so a refreshed token carries their current roles
+	user, err := store.Users.GetByID(ctx, session.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	accessToken, err := GenerateJWT(user.ID.Hex(), session.ID.Hex(), user.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	err = store.Sessions.AddRefreshToken(ctx, &models.RefreshToken{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeReusedSession ends the session a reused refresh token belongs to and
+// warns the user that someone else may have had their token
+func revokeReusedSession(ctx context.Context, store *repository.Store, token *models.RefreshToken, now time.Time) error {
+	if err := store.Sessions.Revoke(ctx, token.SessionID, models.SessionTokenReused, now); err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	if user, err := store.Users.GetByID(ctx, token.UserID); err == nil {
+		NotifyByEmail(user.Email, "You were signed out",
+			"A sign-in token for your account was used twice, which can mean someone else has a copy of it. "+
+				"We signed that device out to be safe. Sign in again, and change your password if this wasn't you.")
+	}
+	return ErrRefreshTokenReused
+}
+
+// newToken returns a random token safe to put in a URL
+func newToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashToken is how refresh and emailed tokens are stored. They are long and random, so
+// a plain SHA-256 is enough to keep them safe at rest.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,199 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrSelfTransfer = errors.New("cannot transfer to yourself")
+
+// TransferResult describes a completed peer-to-peer transfer
+type TransferResult struct {
+	Reference       string
+	PreviousBalance models.Money
+	NewBalance      models.Money
+	Debit           models.Transaction
+	Credit          models.Transaction
+	Review          *models.FraudReview // set when fraud screening held the transfer; Debit and Credit are then empty
+}
+
+// Transfer moves savings balance from one user to another. The journal entry,
+// both balances and the linked debit/credit transaction rows commit together.
+// Senders who have not verified their email can't transfer.
+// The amount must be within the sender's KYC withdrawal limit and velocity
+// limits, and must not take the recipient over their tier's balance cap. A
+// recipient who has closed their account fails with ErrAccountClosed.
+// Fraud screening can hold the transfer for review or refuse it with
+// ErrPaymentBlocked; sessionID is the session making the request.
+func Transfer(ctx context.Context, store *repository.Store, sender, recipient *models.User, sessionID primitive.ObjectID, amount models.Money, note string) (*TransferResult, error) {
+	if !amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+	if sender.ID == recipient.ID {
+		return nil, ErrSelfTransfer
+	}
+
+	senderAccount := ledger.UserSavingsAccount(sender.ID)
+
+	var result *TransferResult
+	var flags []models.FraudFlag
+	now := time.Now()
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := checkTransferLimits(ctx, store, sender.ID, recipient.ID, amount)
+		if err != nil {
+			return err
+		}
+
+		var action string
+		action, flags, err = screenMovement(ctx, store, current, models.HeldTransfer, amount, recipient.ID, sessionID, now)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case models.FraudBlock:
+			return ErrPaymentBlocked
+		case models.FraudHold:
+			change, err := holdSavings(ctx, store, &models.FraudReview{
+				UserID:      sender.ID,
+				Kind:        models.HeldTransfer,
+				Amount:      amount,
+				RecipientID: recipient.ID,
+				Note:        note,
+				Flags:       flags,
+			}, now)
+			if err != nil {
+				return err
+			}
+			result = &TransferResult{
+				Reference:       change.Review.Reference,
+				PreviousBalance: change.PreviousBalance,
+				NewBalance:      change.NewBalance,
+				Review:          change.Review,
+			}
+			return nil
+		}
+
+		entryID := primitive.NewObjectID()
+		reference := "TRF-" + entryID.Hex()
+
+		balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+			ID:          entryID,
+			Reference:   reference,
+			Description: string(models.Transfer),
+			Lines: []models.JournalLine{
+				ledger.DebitLine(senderAccount, amount),
+				ledger.CreditLine(ledger.UserSavingsAccount(recipient.ID), amount),
+			},
+			CreatedAt: now,
+		})
+		if err != nil {
+			return err
+		}
+
+		debit := transferLeg(sender.ID, recipient.ID, models.Debit, amount, reference, entryID, note, now)
+		credit := transferLeg(recipient.ID, sender.ID, models.Credit, amount, reference, entryID, note, now)
+		senderBalance := balances[senderAccount]
+		recipientBalance := balances[ledger.UserSavingsAccount(recipient.ID)]
+		debit.BalanceAfter = &senderBalance
+		credit.BalanceAfter = &recipientBalance
+		if err := store.Transactions.Insert(ctx, debit, credit); err != nil {
+			return err
+		}
+		if err := store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{sender.ID, recipient.ID}, now); err != nil {
+			return err
+		}
+
+		result = &TransferResult{
+			Reference:       reference,
+			PreviousBalance: senderBalance.Add(amount),
+			NewBalance:      senderBalance,
+			Debit:           debit,
+			Credit:          credit,
+		}
+		return nil
+	})
+	if errors.Is(err, ErrPaymentBlocked) {
+		recordBlocked(ctx, store, models.FraudReview{
+			UserID: sender.ID, Kind: models.HeldTransfer, Amount: amount, RecipientID: recipient.ID, Note: note, Flags: flags,
+		}, now)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if result.Review != nil {
+		NotifyByEmail(sender.Email, "Transfer under review",
+			fmt.Sprintf("Your transfer of %s %s to %s is being reviewed. The money has been set aside and we will email you once it has been checked.",
+				amount.CurrencyCode(), amount, recipient.Name))
+		return result, nil
+	}
+
+	NotifyByEmail(sender.Email, "Transfer sent",
+		fmt.Sprintf("You sent %s %s to %s. Reference: %s", amount.CurrencyCode(), amount, recipient.Name, result.Reference))
+	NotifyByEmail(recipient.Email, "Transfer received",
+		fmt.Sprintf("You received %s %s from %s. Reference: %s", amount.CurrencyCode(), amount, sender.Name, result.Reference))
+
+	return result, nil
+}
+
+// checkTransferLimits checks the limits of both sides against the users
+// as they are inside the transfer's transaction, and returns the sender
+// as loaded there
+func checkTransferLimits(ctx context.Context, store *repository.Store, senderID, recipientID primitive.ObjectID, amount models.Money) (*models.User, error) {
+	sender, err := getUser(ctx, store, senderID)
+	if err != nil {
+		return nil, err
+	}
+	// Like withdrawals, transfers out need a verified email address
+	if !sender.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+	if err := checkWithdrawalLimit(sender, amount); err != nil {
+		return nil, err
+	}
+	if err := checkVelocityLimit(ctx, store, sender, models.VelocityWithdrawal, amount, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := checkRecipientLimit(ctx, store, recipientID, amount); err != nil {
+		return nil, err
+	}
+	return sender, nil
+}
+
+// checkRecipientLimit checks that the recipient's account is still open and
+// that the amount won't take them over their tier's balance cap
+func checkRecipientLimit(ctx context.Context, store *repository.Store, recipientID primitive.ObjectID, amount models.Money) error {
+	recipient, err := getOpenUser(ctx, store, recipientID)
+	if err != nil {
+		return err
+	}
+	if err := checkBalanceLimit(recipient, amount); err != nil {
+		return fmt.Errorf("%w: the recipient cannot receive this amount", ErrKYCLimitExceeded)
+	}
+	return nil
+}
+
+func transferLeg(userID, counterpartyID primitive.ObjectID, direction models.Direction, amount models.Money,
+	reference string, entryID primitive.ObjectID, note string, now time.Time) models.Transaction {
+	return models.Transaction{
+		ID:             primitive.NewObjectID(),
+		UserID:         userID,
+		Type:           string(models.Transfer),
+		Amount:         amount,
+		Direction:      direction,
+		Reference:      reference,
+		CounterpartyID: counterpartyID,
+		Note:           note,
+		JournalEntryID: entryID,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
@@ -6,16 +6,13 @@ import (
 	"time"
 
 	"micro-savings-app/models"
-	"micro-savings-app/database"
+	"micro-savings-app/repository"
 
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // GetUserByID fetches a user from the database by ID
-func GetUserByID(userID string) (*models.User, error) {
-	collection := database.GetCollection("users") // Ensure the correct collection name
-
+func GetUserByID(store *repository.Store, userID string) (*models.User, error) {
 	// Convert string ID to MongoDB ObjectID
 	objID, err := primitive.ObjectIDFromHex(userID)
 	if err != nil {
@@ -27,11 +24,23 @@ func GetUserByID(userID string) (*models.User, error) {
 	defer cancel()
 
 	// Query the database
-	var user models.User
-	err = collection.FindOne(ctx, bson.M{"_id": objID}).Decode(&user)
+	user, err := store.Users.GetByID(ctx, objID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	return user, nil
+}
+
+// GetUserByEmail fetches a user from the database by email address
+func GetUserByEmail(store *repository.Store, email string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, err := store.Users.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, errors.New("user not found")
 	}
 
-	return &user, nil
+	return user, nil
 }
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrVelocityLimitExceeded = errors.New("velocity limit exceeded")
+
+// VelocityUsage is how much of one velocity limit a user has used. ResetsAt
+// is when everything counted against it will have rolled out of the window,
+// nil if nothing has.
+type VelocityUsage struct {
+	Movement  string       `json:"movement"`
+	Window    string       `json:"window"`
+	Limit     models.Money `json:"limit"`
+	Used      models.Money `json:"used"`
+	Remaining models.Money `json:"remaining"`
+	ResetsAt  *time.Time   `json:"resets_at,omitempty"`
+}
+
+// VelocityLimitError is returned when a deposit or withdrawal would take a
+// user over a velocity limit. It matches ErrVelocityLimitExceeded.
+type VelocityLimitError struct {
+	Usage  VelocityUsage
+	Amount models.Money
+	// RetryAt is when enough will have rolled out of the window for Amount
+	// to fit; zero if Amount is over the limit on its own
+	RetryAt time.Time
+}
+
+func (e *VelocityLimitError) Error() string {
+	return fmt.Sprintf("%s: %s %s limit is %s %s and %s %s is left", ErrVelocityLimitExceeded,
+		e.Usage.Window, e.Usage.Movement, e.Usage.Limit.CurrencyCode(), e.Usage.Limit, e.Usage.Remaining.CurrencyCode(), e.Usage.Remaining)
+}
+
+func (e *VelocityLimitError) Unwrap() error {
+	return ErrVelocityLimitExceeded
+}
+
+// Retryable reports whether the same amount will fit once older movements
+// roll out of the window
+func (e *VelocityLimitError) Retryable() bool {
+	return !e.RetryAt.IsZero()
+}
+
+// GlobalVelocityLimits returns the limits that apply to users without their
+// own, falling back to models.DefaultVelocityLimits until an admin sets some
+func GlobalVelocityLimits(ctx context.Context, store *repository.Store) (models.VelocityLimits, error) {
+	limits, err := store.Settings.GetVelocityLimits(ctx)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.DefaultVelocityLimits(), nil
+	} else if err != nil {
+		return models.VelocityLimits{}, err
+	}
+	return *limits, nil
+}
+
+// EffectiveVelocityLimits returns the limits that apply to a user and whether
+// they are the user's own or the global ones
+func EffectiveVelocityLimits(ctx context.Context, store *repository.Store, user *models.User) (models.VelocityLimits, string, error) {
+	if user.VelocityLimits != nil {
+		return *user.VelocityLimits, PolicySourceUser, nil
+	}
+	limits, err := GlobalVelocityLimits(ctx, store)
+	return limits, PolicySourceGlobal, err
+}
+
+// SetGlobalVelocityLimits validates and saves the global limits
+func SetGlobalVelocityLimits(ctx context.Context, store *repository.Store, limits models.VelocityLimits) (models.VelocityLimits, error) {
+	limits = normalizeVelocityLimits(limits)
+	if err := limits.Validate(); err != nil {
+		return limits, err
+	}
+	return limits, store.Settings.SetVelocityLimits(ctx, limits)
+}
+
+// SetUserVelocityLimits validates and saves a user's own limits. Nil limits
+// put the user back on the global ones.
+func SetUserVelocityLimits(ctx context.Context, store *repository.Store, userID primitive.ObjectID, limits *models.VelocityLimits) error {
+	if limits != nil {
+		normalized := normalizeVelocityLimits(*limits)
+		if err := normalized.Validate(); err != nil {
+			return err
+		}
+		limits = &normalized
+	}
+
+	err := store.Users.SetVelocityLimits(ctx, userID, limits)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+// GetVelocityUsage returns how much of each of a user's velocity limits is
+// used and whether the limits are their own or the global ones
+func GetVelocityUsage(ctx context.Context, store *repository.Store, userID primitive.ObjectID, now time.Time) ([]VelocityUsage, string, error) {
+	user, err := getUser(ctx, store, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	limits, source, err := EffectiveVelocityLimits(ctx, store, user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	usages := []VelocityUsage{}
+	for _, movement := range []string{models.VelocityDeposit, models.VelocityWithdrawal} {
+		movements, err := velocityMovements(ctx, store, userID, movement, now.Add(-models.LongestVelocityWindow))
+		if err != nil {
+			return nil, "", err
+		}
+		for _, window := range models.VelocityWindows {
+			limit := limits.For(movement).Window(window.Name)
+			if limit == nil {
+				continue
+			}
+			usage, _ := velocityUsage(movements, movement, window.Name, window.Length, *limit, now)
+			usages = append(usages, usage)
+		}
+	}
+	return usages, source, nil
+}
+
+// checkVelocityLimit fails with a VelocityLimitError if moving the amount
+// would take the user over any of their limits on the movement. Limits set in
+// another currency than the amount don't apply to it.
+func checkVelocityLimit(ctx context.Context, store *repository.Store, user *models.User, movement string, amount models.Money, now time.Time) error {
+	limits, _, err := EffectiveVelocityLimits(ctx, store, user)
+	if err != nil {
+		return err
+	}
+	limit := limits.For(movement)
+	if limit.Daily == nil && limit.Monthly == nil {
+		return nil
+	}
+
+	movements, err := velocityMovements(ctx, store, user.ID, movement, now.Add(-models.LongestVelocityWindow))
+	if err != nil {
+		return err
+	}
+
+	// Report the limit that keeps the amount out longest
+	var worst *VelocityLimitError
+	for _, window := range models.VelocityWindows {
+		windowLimit := limit.Window(window.Name)
+		if windowLimit == nil || !windowLimit.SameCurrency(amount) {
+			continue
+		}
+		usage, counted := velocityUsage(movements, movement, window.Name, window.Length, *windowLimit, now)
+		if amount.Cmp(usage.Remaining) <= 0 {
+			continue
+		}
+
+		exceeded := &VelocityLimitError{Usage: usage, Amount: amount}
+		if amount.Cmp(*windowLimit) <= 0 {
+			// Free up the oldest movements until the amount fits
+			left := usage.Limit.Sub(usage.Used)
+			for _, older := range counted {
+				left = left.Add(older.Amount)
+				if amount.Cmp(left) <= 0 {
+					exceeded.RetryAt = older.CreatedAt.Add(window.Length)
+					break
+				}
+			}
+		}
+		if worst == nil || (worst.Retryable() && (!exceeded.Retryable() || exceeded.RetryAt.After(worst.RetryAt))) {
+			worst = exceeded
+		}
+	}
+	if worst != nil {
+		return worst
+	}
+	return nil
+}
+
+// velocityUsage sums the movements inside the window ending at now that are
+// in the limit's currency. It also returns those movements, oldest first.
+func velocityUsage(movements []models.Transaction, movement, window string, length time.Duration, limit models.Money, now time.Time) (VelocityUsage, []models.Transaction) {
+	usage := VelocityUsage{
+		Movement: movement,
+		Window:   window,
+		Limit:    limit,
+		Used:     models.NewMoney(0, limit.CurrencyCode()),
+	}
+
+	var counted []models.Transaction
+	start := now.Add(-length)
+	for _, transaction := range movements {
+		if !transaction.CreatedAt.After(start) || !transaction.Amount.SameCurrency(limit) {
+			continue
+		}
+		usage.Used = usage.Used.Add(transaction.Amount)
+		counted = append(counted, transaction)
+	}
+
+	usage.Remaining = limit.Sub(usage.Used)
+	if usage.Remaining.IsNegative() {
+		// Limits can be lowered below what was already moved
+		usage.Remaining = models.NewMoney(0, limit.CurrencyCode())
+	}
+	if len(counted) > 0 {
+		resetsAt := counted[len(counted)-1].CreatedAt.Add(length)
+		usage.ResetsAt = &resetsAt
+	}
+	return usage, counted
+}
+
+// velocityMovements returns a user's transactions of the movement since the
+// time, oldest first. Withdrawals include transfers out and payments held for
+// review.
+func velocityMovements(ctx context.Context, store *repository.Store, userID primitive.ObjectID, movement string, since time.Time) ([]models.Transaction, error) {
+	if movement == models.VelocityDeposit {
+		return store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Deposit, From: since, Ascending: true})
+	}
+
+	withdrawals, err := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Withdrawal, From: since, Ascending: true})
+	if err != nil {
+		return nil, err
+	}
+	transfers, err := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Transfer, From: since, Ascending: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, transfer := range transfers {
+		if transfer.Direction == models.Debit {
+			withdrawals = append(withdrawals, transfer)
+		}
+	}
+
+	// Payments held for review count until a reviewer decides. Approved ones
+	// are then counted by the withdrawal or transfer they became, and
+	// rejected ones never left.
+	holds, err := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Hold, From: since, Ascending: true})
+	if err != nil {
+		return nil, err
+	}
+	if len(holds) > 0 {
+		pending, err := pendingHoldReferences(ctx, store, userID)
+		if err != nil {
+			return nil, err
+		}
+		for _, hold := range holds {
+			if pending[hold.Reference] {
+				withdrawals = append(withdrawals, hold)
+			}
+		}
+	}
+	sort.SliceStable(withdrawals, func(i, j int) bool {
+		return withdrawals[i].CreatedAt.Before(withdrawals[j].CreatedAt)
+	})
+	return withdrawals, nil
+}
+
+// normalizeVelocityLimits fills in currencies
+func normalizeVelocityLimits(limits models.VelocityLimits) models.VelocityLimits {
+	normalize := func(m *models.Money) *models.Money {
+		if m == nil {
+			return nil
+		}
+		normalized := models.NewMoney(m.Amount, m.CurrencyCode())
+		return &normalized
+	}
+	for _, limit := range []*models.VelocityLimit{&limits.Deposit, &limits.Withdrawal} {
+		limit.Daily = normalize(limit.Daily)
+		limit.Monthly = normalize(limit.Monthly)
+	}
+	limits.UpdatedAt = time.Now()
+	return limits
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MinPasswordLength is the shortest password a user can set
+const MinPasswordLength = 8
+
+var (
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrEmailAlreadyVerified = errors.New("email is already verified")
+	ErrEmailNotVerified     = errors.New("email address has not been verified")
+	ErrWeakPassword         = fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+)
+
+// SendEmailVerification emails the user a link to verify their address.
+// Links sent earlier stop working.
+func SendEmailVerification(ctx context.Context, store *repository.Store, userID primitive.ObjectID, now time.Time) error {
+	user, err := store.Users.GetByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrUserNotFound
+	} else if err != nil {
+		return err
+	}
+	if user.IsEmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := issueUserToken(ctx, store, user, models.TokenEmailVerification, models.EmailVerificationTTL, now)
+	if err != nil {
+		return err
+	}
+	NotifyByEmail(user.Email, "Verify your email address",
+		"Confirm this is your email address so you can withdraw your savings. "+
+			tokenInstructions("verify-email", token, models.EmailVerificationTTL))
+	return nil
+}
+
+// VerifyEmail spends a verification token and marks the address it was sent
+// to as verified. A token sent to an address the user has since changed
+// no longer counts.
+func VerifyEmail(ctx context.Context, store *repository.Store, token string, now time.Time) error {
+	userToken, err := useUserToken(ctx, store, models.TokenEmailVerification, token, now)
+	if err != nil {
+		return err
+	}
+	user, err := store.Users.GetByID(ctx, userToken.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidToken
+	} else if err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Email, userToken.Email) {
+		return ErrInvalidToken
+	}
+	return store.Users.SetEmailVerified(ctx, user.ID, &now)
+}
+
+// RequestPasswordReset emails a reset link to the address if it belongs to
+// a user. It succeeds either way so nobody can use it to find out who has
+// an account.
+func RequestPasswordReset(ctx context.Context, store *repository.Store, email string, now time.Time) error {
+	user, err := store.Users.GetByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if user.IsClosed() {
+		return nil
+	}
+
+	token, err := issueUserToken(ctx, store, user, models.TokenPasswordReset, models.PasswordResetTTL, now)
+	if err != nil {
+		return err
+	}
+	NotifyByEmail(user.Email, "Reset your password",
+		"Someone asked to reset the password for your account. If it wasn't you, ignore this email. "+
+			tokenInstructions("reset-password", token, models.PasswordResetTTL))
+	return nil
+}
+
+// ResetPassword spends a reset token and sets a new password. Every session
+// is logged out, since whoever knew the old password may still be signed in.
+// Receiving the reset email proves the user owns the address, so it is
+// marked verified too and any login lockout is lifted.
+func ResetPassword(ctx context.Context, store *repository.Store, token, password string, now time.Time) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	userToken, err := useUserToken(ctx, store, models.TokenPasswordReset, token, now)
+	if err != nil {
+		return err
+	}
+	user, err := store.Users.GetByID(ctx, userToken.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrInvalidToken
+	} else if err != nil {
+		return err
+	}
+	if !strings.EqualFold(user.Email, userToken.Email) {
+		return ErrInvalidToken
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := store.Users.SetPasswordHash(ctx, user.ID, string(hash)); err != nil {
+		return err
+	}
+	if !user.IsEmailVerified() {
+		if err := store.Users.SetEmailVerified(ctx, user.ID, &now); err != nil {
+			return err
+		}
+	}
+	if _, err := store.Sessions.RevokeAllForUser(ctx, user.ID, models.SessionPasswordChanged, now); err != nil {
+		return err
+	}
+	// Proving they own the address is enough to lift a lockout
+	if err := store.Logins.Reset(ctx, accountLoginKey(user.Email)); err != nil {
+		return err
+	}
+
+	NotifyByEmail(user.Email, "Your password was changed",
+		"The password for your account was just reset and every device was signed out. "+
+			"If this wasn't you, contact support straight away.")
+	return nil
+}
+
+// issueUserToken spends any tokens the user already holds for the purpose
+// and stores a new one, returning it in the clear to be emailed
+func issueUserToken(ctx context.Context, store *repository.Store, user *models.User, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	return issueUserTokenTo(ctx, store, user, user.Email, purpose, ttl, now)
+}
+
+// issueUserTokenTo is issueUserToken for a token sent to another address
+// than the user's own
+func issueUserTokenTo(ctx context.Context, store *repository.Store, user *models.User, email, purpose string, ttl time.Duration, now time.Time) (string, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", err
+	}
+	if err := store.UserTokens.UseAllForUser(ctx, user.ID, purpose, now); err != nil {
+		return "", err
+	}
+	err = store.UserTokens.Create(ctx, &models.UserToken{
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Email:     email,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// useUserToken spends a token, failing with ErrInvalidToken if it is
+// unknown, expired or already spent
+func useUserToken(ctx context.Context, store *repository.Store, purpose, token string, now time.Time) (*models.UserToken, error) {
+	userToken, err := store.UserTokens.GetByHash(ctx, purpose, hashToken(token))
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	if userToken.UsedAt != nil || !now.Before(userToken.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if err := store.UserTokens.Use(ctx, userToken.ID, now); errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrInvalidToken
+	} else if err != nil {
+		return nil, err
+	}
+	return userToken, nil
+}
+
+// tokenInstructions tells the user how to use an emailed token: a link when
+// APP_URL is configured, the bare token otherwise
+func tokenInstructions(page, token string, ttl time.Duration) string {
+	expiry := fmt.Sprintf("It expires in %s.", ttl)
+	if base := os.Getenv("APP_URL"); base != "" {
+		return fmt.Sprintf("Open %s/%s?token=%s to continue. %s", strings.TrimRight(base, "/"), page, token, expiry)
+	}
+	return fmt.Sprintf("Your token is %s. %s", token, expiry)
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrUserNotFound        = ledger.ErrAccountNotFound
+	ErrInsufficientBalance = ledger.ErrInsufficientFunds
+)
+
+// BalanceChange describes the effect of a deposit or withdrawal on a savings balance
+type BalanceChange struct {
+	PreviousBalance models.Money
+	NewBalance      models.Money
+	Transaction     models.Transaction
+	Review          *models.FraudReview // set when fraud screening held the withdrawal
+}
+
+// Deposit credits the user's savings balance and records the transaction atomically.
+// The deposit and the balance it leaves must both be within the user's KYC tier limits,
+// and the deposit must fit within their daily and monthly velocity limits.
+func Deposit(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money) (*BalanceChange, error) {
+	checkLimits := func(ctx context.Context, user *models.User) error {
+		if err := checkDepositLimit(user, amount); err != nil {
+			return err
+		}
+		if err := checkBalanceLimit(user, amount); err != nil {
+			return err
+		}
+		return checkVelocityLimit(ctx, store, user, models.VelocityDeposit, amount, time.Now())
+	}
+
+	// Cash comes in from outside and we now owe it to the user
+	return applySavingsChange(ctx, store, userID, amount, models.Deposit, checkLimits, []models.JournalLine{
+		ledger.DebitLine(ledger.ExternalCashAccount, amount),
+		ledger.CreditLine(ledger.UserSavingsAccount(userID), amount),
+	})
+}
+
+// Withdraw debits the user's savings balance and records the transaction atomically.
+// Users who have not verified their email can't withdraw, and the amount must be within their KYC tier limit
+// and their daily and monthly velocity limits.
+// Fraud screening then decides whether the money leaves now, is held for review
+// (the returned change carries the review) or is refused with ErrPaymentBlocked.
+// sessionID is the session making the request, used to recognise new devices.
+// The balance check happens inside the ledger's guarded update, so concurrent
+// withdrawals can never take the balance below zero.
+func Withdraw(ctx context.Context, store *repository.Store, userID, sessionID primitive.ObjectID, amount models.Money) (*BalanceChange, error) {
+	if !amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	// Money only leaves the platform for users who have proved they own
+	// their email address
+	user, err := store.Users.GetByID(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if !user.IsEmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	var change *BalanceChange
+	var flags []models.FraudFlag
+	now := time.Now()
+	err = store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := getUser(ctx, store, userID)
+		if err != nil {
+			return err
+		}
+		if err := checkWithdrawalLimit(user, amount); err != nil {
+			return err
+		}
+		if err := checkVelocityLimit(ctx, store, user, models.VelocityWithdrawal, amount, now); err != nil {
+			return err
+		}
+
+		var action string
+		action, flags, err = screenMovement(ctx, store, user, models.HeldWithdrawal, amount, primitive.NilObjectID, sessionID, now)
+		if err != nil {
+			return err
+		}
+		switch action {
+		case models.FraudBlock:
+			return ErrPaymentBlocked
+		case models.FraudHold:
+			change, err = holdSavings(ctx, store, &models.FraudReview{
+				UserID: userID,
+				Kind:   models.HeldWithdrawal,
+				Amount: amount,
+				Flags:  flags,
+			}, now)
+			return err
+		}
+
+		change, err = postSavingsChange(ctx, store, userID, amount, models.Withdrawal, "", []models.JournalLine{
+			ledger.DebitLine(ledger.UserSavingsAccount(userID), amount),
+			ledger.CreditLine(ledger.ExternalCashAccount, amount),
+		})
+		return err
+	})
+	if errors.Is(err, ErrPaymentBlocked) {
+		recordBlocked(ctx, store, models.FraudReview{UserID: userID, Kind: models.HeldWithdrawal, Amount: amount, Flags: flags}, now)
+		return nil, err
+	} else if err != nil {
+		return nil, err
+	}
+
+	if change.Review != nil {
+		NotifyByEmail(user.Email, "Withdrawal under review",
+			fmt.Sprintf("Your withdrawal of %s %s is being reviewed. The money has been set aside and we will email you once it has been checked.",
+				amount.CurrencyCode(), amount))
+	}
+	return change, nil
+}
+
+// applySavingsChange posts a deposit or withdrawal in its own transaction.
+// checkLimits sees the user as loaded inside that transaction, so it checks
+// against the balances the change is applied to.
+func applySavingsChange(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, txType models.TransactionType,
+	checkLimits func(ctx context.Context, user *models.User) error, lines []models.JournalLine) (*BalanceChange, error) {
+	if !amount.IsPositive() {
+		return nil, models.ErrInvalidAmount
+	}
+
+	var change *BalanceChange
+	err := store.WithTransaction(ctx, func(ctx context.Context) error {
+		user, err := store.Users.GetByID(ctx, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := checkLimits(ctx, user); err != nil {
+			return err
+		}
+
+		change, err = postSavingsChange(ctx, store, userID, amount, txType, "", lines)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// postSavingsChange posts a movement in or out of savings and records it in
+// history. A non-empty reference becomes the journal entry's reference, so the
+// same movement can never be posted twice. Call it inside store.WithTransaction.
+func postSavingsChange(ctx context.Context, store *repository.Store, userID primitive.ObjectID, amount models.Money, txType models.TransactionType, reference string, lines []models.JournalLine) (*BalanceChange, error) {
+	savingsAccount := ledger.UserSavingsAccount(userID)
+
+	now := time.Now()
+	transaction := models.Transaction{
+		ID:             primitive.NewObjectID(),
+		UserID:         userID,
+		Type:           string(txType),
+		Amount:         amount,
+		Reference:      reference,
+		JournalEntryID: primitive.NewObjectID(),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if reference == "" {
+		reference = transaction.ID.Hex()
+	}
+
+	balances, err := ledger.Post(ctx, store, &models.JournalEntry{
+		ID:          transaction.JournalEntryID,
+		Reference:   reference,
+		Description: string(txType),
+		Lines:       lines,
+		CreatedAt:   now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newBalance := balances[savingsAccount]
+	transaction.BalanceAfter = &newBalance
+
+	if err := store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{userID}, now); err != nil {
+		return nil, err
+	}
+	if err := store.Transactions.Insert(ctx, transaction); err != nil {
+		return nil, err
+	}
+
+	delta := amount
+	if txType == models.Withdrawal || txType == models.PoolContribution || txType == models.Hold {
+		delta = amount.Neg()
+	}
+	return &BalanceChange{
+		PreviousBalance: newBalance.Sub(delta),
+		NewBalance:      newBalance,
+		Transaction:     transaction,
+	}, nil
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/jobs"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAllocationPolicySweepFrom(t *testing.T) {
+	policy := models.DefaultAllocationPolicy()
+	assert.Equal(t, int64(100000), policy.SweepFrom(models.NewMoney(100000, "NGN")).Amount)
+
+	policy.SweepPercent = 33
+	policy.MinLiquidBalance = models.NewMoney(10000, "NGN")
+	// 33% of the 900.01 above the liquid minimum, rounded down
+	assert.Equal(t, int64(29700), policy.SweepFrom(models.NewMoney(100001, "NGN")).Amount)
+	assert.True(t, policy.SweepFrom(models.NewMoney(5000, "NGN")).IsZero())
+
+	fixed := models.NewMoney(20000, "NGN")
+	policy.SweepMode, policy.SweepAmount = models.SweepFixed, &fixed
+	assert.Equal(t, int64(20000), policy.SweepFrom(models.NewMoney(100000, "NGN")).Amount)
+	assert.Equal(t, int64(5000), policy.SweepFrom(models.NewMoney(15000, "NGN")).Amount)
+
+	policy.OptOut = true
+	assert.True(t, policy.SweepFrom(models.NewMoney(100000, "NGN")).IsZero())
+}
+
+func TestAllocateIdleBalancesHonoursPolicies(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	idleSince := time.Now().AddDate(0, 0, -10)
+
+	newIdleUser := func(policy *models.AllocationPolicy) primitive.ObjectID {
+		user := models.User{
+			SavingsBalance:    models.NewMoney(100000, "NGN"),
+			InvestmentBalance: models.NewMoney(0, "NGN"),
+			LastTransactionAt: idleSince,
+			AllocationPolicy:  policy,
+		}
+		_ = store.Users.Create(ctx, &user)
+		return user.ID
+	}
+
+	// The global policy sweeps half of anything idle for a week
+	global := models.DefaultAllocationPolicy()
+	global.IdleDays, global.SweepPercent = 7, 50
+	_ = store.Settings.SetAllocationPolicy(ctx, global)
+
+	own := models.DefaultAllocationPolicy()
+	own.IdleDays, own.MinLiquidBalance = 5, models.NewMoney(40000, "NGN")
+	optedOut := models.DefaultAllocationPolicy()
+	optedOut.OptOut = true
+	notYetIdle := models.DefaultAllocationPolicy() // 30 days
+
+	globalUser := newIdleUser(nil)
+	ownUser := newIdleUser(&own)
+	optedOutUser := newIdleUser(&optedOut)
+	notYetIdleUser := newIdleUser(&notYetIdle)
+
+	jobs.AllocateIdleBalances(store)
+
+	for userID, expected := range map[primitive.ObjectID]int64{
+		globalUser:     50000,
+		ownUser:        40000,
+		optedOutUser:   100000,
+		notYetIdleUser: 100000,
+	} {
+		user, _ := store.Users.GetByID(ctx, userID)
+		assert.Equal(t, expected, user.SavingsBalance.Amount)
+		assert.Equal(t, 100000-expected, user.InvestmentBalance.Amount)
+	}
+}
+
+func TestUpdateAllocationPolicyValidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := setupUserForTransaction()
+
+	update := func(body string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPut, "/user/allocation-policy", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+		handlers.UpdateAllocationPolicy(testStore)(c)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusBadRequest, update(`{"idle_days": 0, "sweep_mode": "percentage", "sweep_percent": 50}`))
+	assert.Equal(t, http.StatusBadRequest, update(`{"idle_days": 14, "sweep_mode": "fixed"}`))
+	assert.Equal(t, http.StatusOK, update(`{"idle_days": 14, "sweep_mode": "fixed", "sweep_amount": "250.00", "min_liquid_balance": "100.00"}`))
+
+	user := getTestUser(userID)
+	if assert.NotNil(t, user.AllocationPolicy) {
+		assert.Equal(t, 14, user.AllocationPolicy.IdleDays)
+		assert.Equal(t, int64(25000), user.AllocationPolicy.SweepAmount.Amount)
+	}
+}
@@ -1,18 +1,31 @@
 package tests
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"testing"
 	"time"
 
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
 	"micro-savings-app/services"
 
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGenerateJWT(t *testing.T) {
 	userID := "123456"
 
-	token, err := services.GenerateJWT(userID)
+	token, err := services.GenerateJWT(userID, "session-1", nil)
 	assert.Nil(t, err)
 	assert.NoError(t, err)
 	assert.NotEmpty(t, token)
@@ -20,11 +33,134 @@ func TestGenerateJWT(t *testing.T) {
 
 func TestValidateJWT(t *testing.T) {
 	var userID = "123456"
-	token, _ := services.GenerateJWT(userID)
+	token, _ := services.GenerateJWT(userID, "session-1", []string{"support"})
 
 	claims, err := services.ValidateJWT(token)
 	assert.Nil(t, err)
 	assert.NoError(t, err)
 	assert.Equal(t, userID, claims["user_id"])
+	assert.Equal(t, []interface{}{"support"}, claims["roles"])
 	assert.Greater(t, int64(claims["exp"].(float64)), time.Now().Unix())
-}
\ No newline at end of file
+}
+
+func TestJWTClaimsAreValidated(t *testing.T) {
+	token, _ := services.GenerateJWT("123456", "session-1", nil)
+	claims, err := services.ValidateJWT(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "micro-savings-app", claims["iss"])
+	assert.NotEmpty(t, claims["jti"])
+
+	// Tokens meant for another audience are refused
+	os.Setenv("JWT_AUDIENCE", "another-service")
+	_, err = services.ValidateJWT(token)
+	os.Unsetenv("JWT_AUDIENCE")
+	assert.Error(t, err)
+
+	// So are tokens signed with a shared secret
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	forgedString, _ := forged.SignedString([]byte("test-secret"))
+	_, err = services.ValidateJWT(forgedString)
+	assert.Error(t, err)
+}
+
+func TestSigningKeyRotation(t *testing.T) {
+	before, _ := services.GenerateJWT("123456", "session-1", nil)
+	beforeHeader, _, _ := jwt.NewParser().ParseUnverified(before, jwt.MapClaims{})
+
+	// A new key is published before it signs, so instances that have not
+	// loaded it yet never see tokens they can't verify
+	pending, err := services.RotateSigningKey(context.Background(), testStore, models.AlgEdDSA, time.Now())
+	assert.NoError(t, err)
+	unchanged, _ := services.GenerateJWT("123456", "session-1", nil)
+	unchangedHeader, _, _ := jwt.NewParser().ParseUnverified(unchanged, jwt.MapClaims{})
+	assert.Equal(t, beforeHeader.Header["kid"], unchangedHeader.Header["kid"])
+	jwks, err := services.JWKS()
+	assert.NoError(t, err)
+	assert.Contains(t, jwkIDs(jwks), pending.ID)
+
+	// Without the delay the new key signs straight away
+	delay := services.KeyActivationDelay
+	services.KeyActivationDelay = 0
+	key, err := services.RotateSigningKey(context.Background(), testStore, models.AlgRS256, time.Now())
+	services.KeyActivationDelay = delay
+	assert.NoError(t, err)
+	after, _ := services.GenerateJWT("123456", "session-1", nil)
+
+	// Tokens signed with the old key keep working until they expire
+	_, err = services.ValidateJWT(before)
+	assert.NoError(t, err)
+	_, err = services.ValidateJWT(after)
+	assert.NoError(t, err)
+
+	parsed, _, _ := jwt.NewParser().ParseUnverified(after, jwt.MapClaims{})
+	assert.Equal(t, key.ID, parsed.Header["kid"])
+	assert.Equal(t, models.AlgRS256, parsed.Method.Alg())
+
+	jwks, err = services.JWKS()
+	assert.NoError(t, err)
+	assert.Contains(t, jwkIDs(jwks), key.ID)
+	assert.GreaterOrEqual(t, len(jwks), 2)
+
+	_, err = services.RotateSigningKey(context.Background(), testStore, "HS256", time.Now())
+	assert.ErrorIs(t, err, services.ErrUnsupportedAlgorithm)
+}
+
+func jwkIDs(jwks []services.JWK) []string {
+	kids := []string{}
+	for _, jwk := range jwks {
+		kids = append(kids, jwk.KeyID)
+	}
+	return kids
+}
+
+func TestSigningKeysEncryptedAtRest(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	t.Cleanup(func() { _ = services.LoadSigningKeys(ctx, testStore) })
+
+	// A key stored before encryption is rotated out when keys are loaded
+	legacy := models.SigningKey{ID: "legacy-key", Algorithm: models.AlgEdDSA, CreatedAt: time.Now().Add(-time.Hour)}
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	legacy.PrivateKey, _ = x509.MarshalPKCS8PrivateKey(private)
+	legacy.PublicKey, _ = x509.MarshalPKIXPublicKey(private.Public())
+	assert.NoError(t, store.SigningKeys.Create(ctx, &legacy))
+	assert.NoError(t, services.LoadSigningKeys(ctx, store))
+
+	keys, err := store.SigningKeys.List(ctx)
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.True(t, keys[0].Encrypted)
+		_, err = x509.ParsePKCS8PrivateKey(keys[0].PrivateKey)
+		assert.Error(t, err)
+		assert.NotNil(t, keys[1].RetiredAt)
+	}
+
+	// Keys can't be created or read without the encryption key
+	secret := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+	os.Unsetenv("SIGNING_KEY_ENCRYPTION_KEY")
+	defer os.Setenv("SIGNING_KEY_ENCRYPTION_KEY", secret)
+	_, err = services.RotateSigningKey(ctx, store, models.AlgEdDSA, time.Now())
+	assert.ErrorIs(t, err, services.ErrNoKeyEncryptionKey)
+	assert.ErrorIs(t, services.LoadSigningKeys(ctx, store), services.ErrNoKeyEncryptionKey)
+}
+
+func TestJWKSEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/.well-known/jwks.json", handlers.JWKS())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Keys []services.JWK `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.Keys)
+	for _, key := range response.Keys {
+		assert.NotEmpty(t, key.KeyID)
+		assert.Equal(t, "sig", key.Use)
+	}
+}
@@ -0,0 +1,338 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"micro-savings-app/fraud"
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createScreenedUser creates a verified top-tier user whose password was
+// changed ago
+func createScreenedUser(t *testing.T, savings int64, passwordChangedAgo time.Duration) *models.User {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("a-password"), bcrypt.MinCost)
+	now := time.Now()
+	changedAt := now.Add(-passwordChangedAgo)
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		PasswordHash:      string(hash),
+		PasswordChangedAt: &changedAt,
+		EmailVerifiedAt:   &now,
+		SavingsBalance:    models.NewMoney(savings, "NGN"),
+		KYCTier:           models.KYCTierMax,
+	}
+	assert.NoError(t, testStore.Users.Create(context.Background(), &user))
+	return &user
+}
+
+func TestFraudRules(t *testing.T) {
+	now := time.Now()
+	user := &models.User{SavingsBalance: models.NewMoney(100000, "NGN")}
+	movement := func(minor int64) *fraud.Movement {
+		return &fraud.Movement{Kind: models.HeldWithdrawal, User: user, Amount: models.NewMoney(minor, "NGN"), Now: now}
+	}
+
+	// Recent password changes block, then hold, then stop mattering
+	rule := fraud.RecentPasswordChange{BlockWithin: time.Hour, HoldWithin: 24 * time.Hour}
+	action, _ := rule.Check(movement(100))
+	assert.Equal(t, models.FraudAllow, action)
+	for ago, want := range map[time.Duration]string{
+		10 * time.Minute: models.FraudBlock, 5 * time.Hour: models.FraudHold, 48 * time.Hour: models.FraudAllow,
+	} {
+		changedAt := now.Add(-ago)
+		user.PasswordChangedAt = &changedAt
+		action, _ = rule.Check(movement(100))
+		assert.Equal(t, want, action, ago)
+	}
+	user.PasswordChangedAt = nil
+
+	// A new device only counts once the user has signed in from another one
+	newDevice := fraud.NewDevice{Within: 72 * time.Hour}
+	laptop := models.Session{UserAgent: "laptop", CreatedAt: now.Add(-time.Hour)}
+	m := movement(100)
+	m.Session, m.Sessions = &laptop, []models.Session{laptop}
+	action, _ = newDevice.Check(m)
+	assert.Equal(t, models.FraudAllow, action)
+	m.Sessions = append(m.Sessions, models.Session{UserAgent: "phone", CreatedAt: now.Add(-30 * 24 * time.Hour)})
+	action, reason := newDevice.Check(m)
+	assert.Equal(t, models.FraudHold, action)
+	assert.Contains(t, reason, "laptop")
+	m.Sessions = append(m.Sessions, models.Session{UserAgent: "laptop", CreatedAt: now.Add(-10 * 24 * time.Hour)})
+	action, _ = newDevice.Check(m)
+	assert.Equal(t, models.FraudAllow, action)
+
+	// Amounts are compared with the average once there is enough history
+	unusual := fraud.UnusualAmount{Multiple: 5, MinHistory: 3}
+	m = movement(60000)
+	for _, minor := range []int64{10000, 10000} {
+		m.History = append(m.History, models.Transaction{Type: string(models.Withdrawal), Amount: models.NewMoney(minor, "NGN")})
+	}
+	action, _ = unusual.Check(m)
+	assert.Equal(t, models.FraudAllow, action)
+	m.History = append(m.History, models.Transaction{Type: string(models.Transfer), Direction: models.Debit, Amount: models.NewMoney(10000, "NGN")})
+	action, _ = unusual.Check(m)
+	assert.Equal(t, models.FraudHold, action)
+	m.Amount = models.NewMoney(50000, "NGN")
+	action, _ = unusual.Check(m)
+	assert.Equal(t, models.FraudAllow, action)
+
+	// Many deposits then nearly the whole balance out
+	drain := fraud.DepositsThenDrain{Within: 48 * time.Hour, MinDeposits: 3, DrainPercent: 90}
+	m = movement(95000)
+	for i := 0; i < 3; i++ {
+		m.History = append(m.History, models.Transaction{Type: string(models.Deposit), Amount: models.NewMoney(30000, "NGN"), CreatedAt: now.Add(-time.Hour)})
+	}
+	action, _ = drain.Check(m)
+	assert.Equal(t, models.FraudHold, action)
+	m.Amount = models.NewMoney(50000, "NGN")
+	action, _ = drain.Check(m)
+	assert.Equal(t, models.FraudAllow, action)
+
+	// The engine acts on the most severe rule and reports every flag
+	engine := fraud.NewEngine(rule, drain)
+	changedAt := now.Add(-time.Minute)
+	user.PasswordChangedAt = &changedAt
+	m.Amount = models.NewMoney(95000, "NGN")
+	action, flags := engine.Screen(m)
+	assert.Equal(t, models.FraudBlock, action)
+	assert.Len(t, flags, 2)
+	user.PasswordChangedAt = nil
+}
+
+func TestHeldWithdrawalApproved(t *testing.T) {
+	ctx := context.Background()
+	user := createScreenedUser(t, 500000, 5*time.Hour)
+
+	change, err := services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(500000, "NGN"))
+	assert.NoError(t, err)
+	assert.NotNil(t, change.Review)
+	assert.Equal(t, string(models.Hold), change.Transaction.Type)
+	assert.Equal(t, "recent_password_change", change.Review.Flags[0].Rule)
+	assert.True(t, getTestUser(user.ID).SavingsBalance.IsZero())
+	waitForEmail(t, user.Email, "Withdrawal under review")
+
+	// The money is set aside, so the account can't be closed around it
+	err = services.CloseAccount(ctx, testStore, user.ID, "a-password", "", time.Now())
+	assert.ErrorIs(t, err, services.ErrPaymentsHeld)
+
+	pending, err := services.ListFraudReviews(ctx, testStore, models.ReviewPending)
+	assert.NoError(t, err)
+	assert.Contains(t, reviewIDs(pending), change.Review.ID)
+
+	_, err = services.ApproveFraudReview(ctx, testStore, user.ID, change.Review.ID, "", time.Now())
+	assert.ErrorIs(t, err, services.ErrFraudOwnReview)
+
+	reviewerID := primitive.NewObjectID()
+	review, err := services.ApproveFraudReview(ctx, testStore, reviewerID, change.Review.ID, "Called the user", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReviewApproved, review.Status)
+	waitForEmail(t, user.Email, "Withdrawal approved")
+
+	withdrawals, err := testStore.Transactions.List(ctx, repository.TransactionFilter{UserID: user.ID, Type: models.Withdrawal})
+	assert.NoError(t, err)
+	assert.Len(t, withdrawals, 1)
+	assert.Equal(t, "REL-"+review.ID.Hex(), withdrawals[0].Reference)
+	assert.True(t, getTestUser(user.ID).SavingsBalance.IsZero())
+
+	_, err = services.RejectFraudReview(ctx, testStore, reviewerID, change.Review.ID, "Too late", time.Now())
+	assert.ErrorIs(t, err, services.ErrFraudReviewed)
+}
+
+func TestHeldPaymentsCountTowardsVelocityLimits(t *testing.T) {
+	ctx := context.Background()
+	user := createScreenedUser(t, 500000, 5*time.Hour)
+	recipient := createKYCUser(t, models.KYCTierMax, 0)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{
+		Withdrawal: models.VelocityLimit{Daily: ngn(100000)},
+	}))
+
+	held, err := services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(80000, "NGN"))
+	assert.NoError(t, err)
+	assert.NotNil(t, held.Review)
+
+	// Queuing more held payments can't get around the limit
+	_, err = services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(30000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+	sender, to := getTestUser(user.ID), getTestUser(recipient.ID)
+	_, err = services.Transfer(ctx, testStore, &sender, &to, primitive.NilObjectID, models.NewMoney(30000, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+	usages, _, err := services.GetVelocityUsage(ctx, testStore, user.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80000), usages[0].Used.Amount)
+
+	// Once rejected the hold no longer counts
+	_, err = services.RejectFraudReview(ctx, testStore, primitive.NewObjectID(), held.Review.ID, "Not the user", time.Now())
+	assert.NoError(t, err)
+	_, err = services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(30000, "NGN"))
+	assert.NoError(t, err)
+}
+
+func TestHeldTransferRejected(t *testing.T) {
+	ctx := context.Background()
+	sender := createScreenedUser(t, 1000000, 30*24*time.Hour)
+	recipient := createKYCUser(t, models.KYCTierMax, 0)
+	for i := 0; i < 5; i++ {
+		recordPastTransaction(t, sender.ID, models.Deposit, "", 200000, time.Duration(i+1)*time.Hour)
+	}
+
+	senderUser, recipientUser := getTestUser(sender.ID), getTestUser(recipient.ID)
+	result, err := services.Transfer(ctx, testStore, &senderUser, &recipientUser, primitive.NilObjectID, models.NewMoney(950000, "NGN"), "rent")
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Review)
+	assert.Equal(t, "deposits_then_drain", result.Review.Flags[0].Rule)
+	assert.Equal(t, int64(50000), getTestUser(sender.ID).SavingsBalance.Amount)
+	assert.True(t, getTestUser(recipient.ID).SavingsBalance.IsZero())
+
+	reviewerID := primitive.NewObjectID()
+	_, err = services.RejectFraudReview(ctx, testStore, reviewerID, result.Review.ID, " ", time.Now())
+	assert.ErrorIs(t, err, services.ErrInvalidFraudReview)
+	review, err := services.RejectFraudReview(ctx, testStore, reviewerID, result.Review.ID, "Deposits came from a flagged card", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.ReviewRejected, review.Status)
+	waitForEmail(t, sender.Email, "Payment not approved")
+
+	// The money is back and the recipient never saw it
+	assert.Equal(t, int64(1000000), getTestUser(sender.ID).SavingsBalance.Amount)
+	assert.True(t, getTestUser(recipient.ID).SavingsBalance.IsZero())
+	releases, err := testStore.Transactions.List(ctx, repository.TransactionFilter{UserID: sender.ID, Type: models.HoldRelease})
+	assert.NoError(t, err)
+	assert.Len(t, releases, 1)
+
+	_, err = services.ApproveFraudReview(ctx, testStore, reviewerID, primitive.NewObjectID(), "", time.Now())
+	assert.ErrorIs(t, err, services.ErrFraudReviewNotFound)
+}
+
+func TestHeldTransferFromNewDevice(t *testing.T) {
+	ctx := context.Background()
+	sender := createScreenedUser(t, 100000, 30*24*time.Hour)
+	recipient := createKYCUser(t, models.KYCTierMax, 0)
+	_, err := services.StartSession(ctx, testStore, sender.ID, "phone", "198.51.100.7", time.Now().Add(-30*24*time.Hour))
+	assert.NoError(t, err)
+	tokens, err := services.StartSession(ctx, testStore, sender.ID, "laptop", "203.0.113.9", time.Now())
+	assert.NoError(t, err)
+	sessionID, err := primitive.ObjectIDFromHex(sessionIDOf(t, tokens))
+	assert.NoError(t, err)
+
+	senderUser, recipientUser := getTestUser(sender.ID), getTestUser(recipient.ID)
+	result, err := services.Transfer(ctx, testStore, &senderUser, &recipientUser, sessionID, models.NewMoney(10000, "NGN"), "")
+	assert.NoError(t, err)
+	assert.NotNil(t, result.Review)
+	assert.Equal(t, "new_device", result.Review.Flags[0].Rule)
+
+	// Approving sends it on to the recipient
+	_, err = services.ApproveFraudReview(ctx, testStore, primitive.NewObjectID(), result.Review.ID, "", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), getTestUser(recipient.ID).SavingsBalance.Amount)
+	assert.Equal(t, int64(90000), getTestUser(sender.ID).SavingsBalance.Amount)
+	waitForEmail(t, recipient.Email, "Transfer received")
+
+	// Requests without a known session skip the device check
+	result, err = services.Transfer(ctx, testStore, &senderUser, &recipientUser, primitive.NilObjectID, models.NewMoney(10000, "NGN"), "")
+	assert.NoError(t, err)
+	assert.Nil(t, result.Review)
+}
+
+func TestHeldGoalWithdrawal(t *testing.T) {
+	ctx := context.Background()
+	user := createScreenedUser(t, 0, 5*time.Hour)
+	name, target := "School fees", models.NewMoney(500000, "NGN")
+	goal, err := services.CreateGoal(ctx, testStore, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	assert.NoError(t, err)
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(300000, "NGN"))
+	assert.NoError(t, err)
+
+	// Goal withdrawals go through the same screening as savings ones
+	w := performGoalRequest(handlers.WithdrawFromGoal(testStore), user.ID, goal.ID.Hex(), `{"amount": "1000.00"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"review_id"`)
+	assert.Equal(t, int64(200000), getTestUser(user.ID).GoalsBalance.Amount)
+
+	reviews, err := testStore.FraudReviews.ListByUser(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, goal.ID, reviews[0].GoalID)
+
+	// Rejected goal withdrawals come back to savings
+	_, err = services.RejectFraudReview(ctx, testStore, primitive.NewObjectID(), reviews[0].ID, "Not the user", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100000), getTestUser(user.ID).SavingsBalance.Amount)
+
+	// A password changed moments ago blocks them outright
+	recent := time.Now()
+	assert.NoError(t, testStore.Users.SetPasswordHash(ctx, user.ID, "new-hash"))
+	_, err = services.WithdrawFromGoal(ctx, testStore, user.ID, goal.ID, primitive.NilObjectID, models.NewMoney(1000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrPaymentBlocked)
+	assert.WithinDuration(t, recent, *getTestUser(user.ID).PasswordChangedAt, time.Second)
+}
+
+func TestBlockedWithdrawal(t *testing.T) {
+	ctx := context.Background()
+	user := createScreenedUser(t, 500000, 10*time.Minute)
+
+	_, err := services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(1000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrPaymentBlocked)
+	assert.Equal(t, int64(500000), getTestUser(user.ID).SavingsBalance.Amount)
+
+	// Blocked payments are kept on file but there is nothing to decide
+	reviews, err := testStore.FraudReviews.ListByUser(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Len(t, reviews, 1)
+	assert.Equal(t, models.ReviewBlocked, reviews[0].Status)
+	_, err = services.ApproveFraudReview(ctx, testStore, primitive.NewObjectID(), reviews[0].ID, "", time.Now())
+	assert.ErrorIs(t, err, services.ErrFraudReviewed)
+
+	// The response doesn't say which rule tripped
+	w := postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "10.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "password")
+}
+
+func TestAdminReviewRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/admin")
+	admin.Use(middlewares.AuthMiddleware(testStore), middlewares.AdminAuthMiddleware(testStore), middlewares.RequireStepUp())
+	admin.GET("/reviews", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminListReviews(testStore))
+	admin.POST("/reviews/:review_id/approve", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminApproveReview(testStore))
+	admin.POST("/reviews/:review_id/reject", middlewares.RequirePermission(models.PermFraudReview), handlers.AdminRejectReview(testStore))
+
+	// A held withdrawal is accepted for review rather than refused
+	user := createScreenedUser(t, 500000, 5*time.Hour)
+	w := postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "100.00"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Contains(t, w.Body.String(), `"review_id"`)
+	reviews, err := testStore.FraudReviews.ListByUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+	reviewPath := "/admin/reviews/" + reviews[0].ID.Hex()
+
+	_, support := signInStaff(t, models.RoleSupport)
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/reviews", support, ""))
+
+	_, compliance := signInStaff(t, models.RoleCompliance)
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/reviews", compliance, ""))
+	assert.Equal(t, http.StatusBadRequest, sendAdminRequest(router, http.MethodGet, "/admin/reviews?status=lost", compliance, ""))
+	assert.Equal(t, http.StatusBadRequest, sendAdminRequest(router, http.MethodPost, reviewPath+"/reject", compliance, ""))
+	assert.Equal(t, http.StatusNotFound, sendAdminRequest(router, http.MethodPost, "/admin/reviews/"+primitive.NewObjectID().Hex()+"/approve", compliance, ""))
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodPost, reviewPath+"/reject", compliance, `{"note": "User did not recognise it"}`))
+	assert.Equal(t, http.StatusConflict, sendAdminRequest(router, http.MethodPost, reviewPath+"/approve", compliance, ""))
+	assert.Equal(t, int64(500000), getTestUser(user.ID).SavingsBalance.Amount)
+}
+
+func reviewIDs(reviews []models.FraudReview) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(reviews))
+	for i, review := range reviews {
+		ids[i] = review.ID
+	}
+	return ids
+}
@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/jobs"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// performGoalRequest calls a goal handler as userID, with goalID as the
+// :goal_id route parameter when it is set
+func performGoalRequest(handler gin.HandlerFunc, userID primitive.ObjectID, goalID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/user/goals", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", userID.Hex()) // Simulate authentication
+	if goalID != "" {
+		c.Params = gin.Params{{Key: "goal_id", Value: goalID}}
+	}
+
+	handler(c)
+	return w
+}
+
+func TestGoalProgressAndProjection(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	goal := models.Goal{
+		TargetAmount: models.NewMoney(300000, "NGN"),
+		SavedAmount:  models.NewMoney(100000, "NGN"),
+		CreatedAt:    created,
+	}
+	now := created.AddDate(0, 0, 10)
+
+	assert.Equal(t, 33.33, goal.ProgressPercent())
+	assert.False(t, goal.IsComplete())
+	// 1000.00 in 10 days leaves 2000.00 to go at 100.00 a day
+	if projected := goal.ProjectedCompletion(now); assert.NotNil(t, projected) {
+		assert.Equal(t, now.AddDate(0, 0, 20), *projected)
+	}
+
+	deadline := created.AddDate(0, 0, 25)
+	goal.Deadline = &deadline
+	progress := services.NewGoalProgress(goal, now)
+	if assert.NotNil(t, progress.OnTrack) {
+		assert.False(t, *progress.OnTrack)
+	}
+
+	goal.SavedAmount = models.NewMoney(350000, "NGN")
+	assert.Equal(t, 100.0, goal.ProgressPercent())
+	assert.True(t, goal.IsComplete())
+}
+
+func TestGoalDepositAndLockedWithdrawal(t *testing.T) {
+	userID := setupUserForTransaction()
+	lockedUntil := time.Now().AddDate(0, 1, 0).Format(time.DateOnly)
+
+	w := performGoalRequest(handlers.CreateGoal(testStore), userID, "",
+		`{"name": "Rent", "target_amount": "5000.00", "locked_until": "`+lockedUntil+`"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created services.GoalProgress
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	goalID := created.ID.Hex()
+	assert.True(t, created.Locked)
+
+	w = performGoalRequest(handlers.DepositToGoal(testStore), userID, goalID, `{"amount": "1250.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	goal, err := testStore.Goals.GetByID(context.Background(), userID, created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(125000), goal.SavedAmount.Amount)
+	assert.Equal(t, 25.0, services.NewGoalProgress(*goal, time.Now()).ProgressPercent)
+
+	// Goal money is kept apart from savings
+	user := getTestUser(userID)
+	assert.Equal(t, int64(100000), user.SavingsBalance.Amount)
+	assert.Equal(t, int64(125000), user.GoalsBalance.Amount)
+
+	w = performGoalRequest(handlers.WithdrawFromGoal(testStore), userID, goalID, `{"amount": "100.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// A lock can't be brought forward while it holds
+	w = performGoalRequest(handlers.UpdateGoal(testStore), userID, goalID, `{"locked_until": "2020-01-01"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = performGoalRequest(handlers.DeleteGoal(testStore), userID, goalID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	rows, _ := testStore.Transactions.List(context.Background(),
+		repository.TransactionFilter{UserID: userID, Type: models.Deposit})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, created.ID, rows[0].GoalID)
+	}
+}
+
+func TestGoalWithdrawalAfterLock(t *testing.T) {
+	ctx := context.Background()
+	userID := setupUserForTransaction()
+	name, target := "Laptop", models.NewMoney(200000, "NGN")
+	goal, err := services.CreateGoal(ctx, testStore, userID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	assert.NoError(t, err)
+
+	_, err = services.DepositToGoal(ctx, testStore, userID, goal.ID, models.NewMoney(50000, "NGN"))
+	assert.NoError(t, err)
+
+	w := performGoalRequest(handlers.WithdrawFromGoal(testStore), userID, goal.ID.Hex(), `{"amount": "500.01"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performGoalRequest(handlers.WithdrawFromGoal(testStore), userID, goal.ID.Hex(), `{"amount": "500.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, getTestUser(userID).GoalsBalance.IsZero())
+
+	w = performGoalRequest(handlers.DeleteGoal(testStore), userID, goal.ID.Hex(), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = performGoalRequest(handlers.GetGoal(testStore), userID, goal.ID.Hex(), "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteGoalCancelsItsSchedules(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+	now := time.Now()
+
+	name, target := "Holiday", models.NewMoney(100000, "NGN")
+	goal, _ := services.CreateGoal(ctx, store, userID, services.GoalChanges{Name: &name, TargetAmount: &target}, now)
+	weekly, amount := models.FrequencyWeekly, models.NewMoney(5000, "NGN")
+	intoGoal, err := services.CreateSchedule(ctx, store, userID, services.ScheduleChanges{Frequency: &weekly, Amount: &amount, GoalID: &goal.ID}, now)
+	assert.NoError(t, err)
+	intoSavings, err := services.CreateSchedule(ctx, store, userID, services.ScheduleChanges{Frequency: &weekly, Amount: &amount}, now)
+	assert.NoError(t, err)
+
+	assert.NoError(t, services.DeleteGoal(ctx, store, userID, goal.ID, now))
+	cancelled, _ := store.Schedules.GetByID(ctx, userID, intoGoal.ID)
+	assert.Equal(t, models.ScheduleCancelled, cancelled.Status)
+	kept, _ := store.Schedules.GetByID(ctx, userID, intoSavings.ID)
+	assert.Equal(t, models.ScheduleActive, kept.Status)
+}
+
+func TestGoalRepositoryOnlyDeletesEmptyGoals(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+
+	name, target := "Car", models.NewMoney(100000, "NGN")
+	goal, _ := services.CreateGoal(ctx, store, userID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	_, err := store.Goals.AdjustSaved(ctx, goal.ID, models.NewMoney(100, "NGN"), time.Now())
+	assert.NoError(t, err)
+
+	// Even without the service's check, a goal holding money isn't deleted
+	assert.ErrorIs(t, store.Goals.Delete(ctx, userID, goal.ID), repository.ErrNotEmpty)
+	assert.ErrorIs(t, store.Goals.Delete(ctx, userID, primitive.NewObjectID()), repository.ErrNotFound)
+	_, err = store.Goals.GetByID(ctx, userID, goal.ID)
+	assert.NoError(t, err)
+}
+
+func TestAllocateIdleBalancesLeavesGoalsAlone(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	user := models.User{
+		SavingsBalance:    models.NewMoney(100000, "NGN"),
+		InvestmentBalance: models.NewMoney(0, "NGN"),
+		GoalsBalance:      models.NewMoney(0, "NGN"),
+	}
+	_ = store.Users.Create(ctx, &user)
+
+	name, target := "Holiday", models.NewMoney(500000, "NGN")
+	goal, _ := services.CreateGoal(ctx, store, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	_, err := services.DepositToGoal(ctx, store, user.ID, goal.ID, models.NewMoney(80000, "NGN"))
+	assert.NoError(t, err)
+	_ = store.Users.SetLastTransactionAt(ctx, []primitive.ObjectID{user.ID}, time.Now().AddDate(0, 0, -60))
+
+	jobs.AllocateIdleBalances(store)
+
+	updated, _ := store.Users.GetByID(ctx, user.ID)
+	assert.Equal(t, int64(0), updated.SavingsBalance.Amount)
+	assert.Equal(t, int64(100000), updated.InvestmentBalance.Amount)
+	assert.Equal(t, int64(80000), updated.GoalsBalance.Amount)
+}
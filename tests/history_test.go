@@ -0,0 +1,62 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func fetchHistory(userID primitive.ObjectID, query string) (int, services.TransactionPage) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodGet, "/user/transactions?"+query, nil)
+	c.Set("user_id", userID.Hex()) // Simulate authentication
+
+	handlers.GetTransactions(testStore)(c)
+
+	var page services.TransactionPage
+	_ = json.Unmarshal(w.Body.Bytes(), &page)
+	return w.Code, page
+}
+
+func TestTransactionHistoryPagination(t *testing.T) {
+	userID := setupUserForTransaction() // balance of 1000.00
+	for _, body := range []string{`{"amount": "10.00"}`, `{"amount": "20.00"}`, `{"amount": "30.00"}`} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/user/deposit", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", userID.Hex())
+		handlers.Deposit(testStore)(c)
+	}
+
+	code, first := fetchHistory(userID, "limit=2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, first.Transactions, 2)
+	assert.NotEmpty(t, first.NextCursor)
+	// Newest first, each row carrying the balance after it
+	assert.Equal(t, int64(3000), first.Transactions[0].Amount.Amount)
+	assert.Equal(t, int64(106000), first.Transactions[0].BalanceAfter.Amount)
+
+	code, second := fetchHistory(userID, "limit=2&cursor="+first.NextCursor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, second.Transactions, 1)
+	assert.Empty(t, second.NextCursor)
+	assert.Equal(t, int64(1000), second.Transactions[0].Amount.Amount)
+
+	_, filtered := fetchHistory(userID, "type=deposit&min_amount=15&max_amount=25")
+	assert.Len(t, filtered.Transactions, 1)
+
+	code, _ = fetchHistory(userID, "type=bogus")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
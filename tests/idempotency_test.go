@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func setupIdempotentDepositRouter(userID primitive.ObjectID) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/user/deposit", func(c *gin.Context) {
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+	}, middlewares.IdempotencyMiddleware(testStore), handlers.Deposit(testStore))
+	return router
+}
+
+func sendDeposit(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/user/deposit", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentDepositIsReplayed(t *testing.T) {
+	userID := setupUserForTransaction() // balance of 1000.00
+	router := setupIdempotentDepositRouter(userID)
+
+	first := sendDeposit(router, "deposit-1", `{"amount": "250.00"}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	retry := sendDeposit(router, "deposit-1", `{"amount": "250.00"}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+
+	// The balance was only credited once
+	user := getTestUser(userID)
+	assert.Equal(t, int64(125000), user.SavingsBalance.Amount)
+}
+
+func TestIdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	userID := setupUserForTransaction()
+	router := setupIdempotentDepositRouter(userID)
+
+	assert.Equal(t, http.StatusOK, sendDeposit(router, "deposit-2", `{"amount": "100.00"}`).Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, sendDeposit(router, "deposit-2", `{"amount": "999.00"}`).Code)
+}
+
+func TestIdempotencyKeyReleasedWhenHandlerPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := primitive.NewObjectID()
+	panics := true
+	router := gin.New()
+	router.Use(gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, _ any) {
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	router.POST("/user/deposit", func(c *gin.Context) {
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+	}, middlewares.IdempotencyMiddleware(testStore), func(c *gin.Context) {
+		if panics {
+			panic("handler bug")
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Deposit successful"})
+	})
+
+	assert.Equal(t, http.StatusInternalServerError, sendDeposit(router, "deposit-3", `{"amount": "100.00"}`).Code)
+
+	// The retry runs the handler instead of being told the key is in use
+	panics = false
+	assert.Equal(t, http.StatusOK, sendDeposit(router, "deposit-3", `{"amount": "100.00"}`).Code)
+}
+
+func TestIdempotencyKeyReleasedWhenRateLimited(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTierMax, 1000000)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{
+		Withdrawal: models.VelocityLimit{Daily: ngn(100000)},
+	}))
+	recordPastTransaction(t, user.ID, models.Withdrawal, "", 80000, time.Hour)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/user/withdraw", func(c *gin.Context) {
+		c.Set("user_id", user.ID.Hex()) // Simulate authentication
+	}, middlewares.IdempotencyMiddleware(testStore), handlers.Withdraw(testStore))
+	withdraw := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/user/withdraw", bytes.NewBufferString(`{"amount": "500.00"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middlewares.IdempotencyKeyHeader, "withdraw-after-wait")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusTooManyRequests, withdraw())
+
+	// Once the allowance is there again the same key can be used
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, nil))
+	assert.Equal(t, http.StatusOK, withdraw())
+	assert.Equal(t, int64(950000), getTestUser(user.ID).SavingsBalance.Amount)
+}
@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"micro-savings-app/clock"
+	"micro-savings-app/jobs"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupInvestor creates a store holding one user with 1,000,000.00 invested,
+// accrued up to the end of 2025, on a single 10% tier with the given compounding
+func setupInvestor(compounding string) (*repository.Store, primitive.ObjectID) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	_ = store.Settings.SetInterestTiers(ctx, []models.InterestTier{
+		{Name: models.DefaultProductTier, AnnualRateBps: 1000, Compounding: compounding},
+	})
+
+	user := models.User{
+		SavingsBalance:    models.NewMoney(0, "NGN"),
+		InvestmentBalance: models.NewMoney(100000000, "NGN"),
+		Interest:          models.InterestAccrual{AccruedThrough: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}
+	_ = store.Users.Create(ctx, &user)
+	return store, user.ID
+}
+
+func TestInterestAccruesDailyAndCapitalisesMonthly(t *testing.T) {
+	store, userID := setupInvestor(models.CompoundMonthly)
+	ctx := context.Background()
+	clk := clock.NewFake(time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	// Mid-month the interest is accrued but not paid
+	jobs.AccrueInterest(store, clk)
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(100000000), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(14*27397260273), user.Interest.Accrued) // 14 days at 10%/365 in millionths of a kobo
+
+	// On the 1st, all 31 days of January are paid in and the fraction carried
+	clk.Set(time.Date(2026, 2, 1, 0, 5, 0, 0, time.UTC))
+	jobs.AccrueInterest(store, clk)
+	jobs.AccrueInterest(store, clk) // a rerun the same day changes nothing
+	user, _ = store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(100849315), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(68463), user.Interest.Accrued)
+	assert.Equal(t, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), user.Interest.AccruedThrough)
+
+	rows, _ := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Interest})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, int64(849315), rows[0].Amount.Amount)
+	}
+}
+
+func TestInterestCompoundsDaily(t *testing.T) {
+	store, userID := setupInvestor(models.CompoundDaily)
+	ctx := context.Background()
+
+	// A missed run is caught up: two days, the second earning on the first's interest
+	jobs.AccrueInterest(store, clock.NewFake(time.Date(2026, 1, 3, 0, 5, 0, 0, time.UTC)))
+
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(100000000+27397+27405), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(26574), user.Interest.Accrued)
+
+	rows, _ := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Interest})
+	assert.Len(t, rows, 2)
+}
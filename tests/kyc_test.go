@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createKYCUser creates a verified user at the tier with the savings balance
+// in minor units
+func createKYCUser(t *testing.T, tier int, savings int64) *models.User {
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:           primitive.NewObjectID().Hex() + "@example.com",
+		EmailVerifiedAt: &verifiedAt,
+		SavingsBalance:  models.NewMoney(savings, "NGN"),
+		KYCTier:         tier,
+	}
+	assert.NoError(t, testStore.Users.Create(context.Background(), &user))
+	return &user
+}
+
+func TestSubmitKYCTier1(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+	request := services.KYCRequest{IdentifierType: "BVN", Identifier: "22123456789"}
+
+	// Tier 1 needs a phone number and date of birth on the profile
+	_, err := services.SubmitKYC(ctx, testStore, user.ID, request, time.Now())
+	assert.ErrorIs(t, err, services.ErrInvalidKYC)
+	phone, born := "+2348012345678", "1990-04-01"
+	_, err = services.UpdateProfile(ctx, testStore, user.ID, services.ProfileChanges{Phone: &phone, DateOfBirth: &born}, time.Now())
+	assert.NoError(t, err)
+
+	for _, bad := range []services.KYCRequest{
+		{IdentifierType: "passport", Identifier: "22123456789"},
+		{IdentifierType: "nin", Identifier: "1234"},
+		{IdentifierType: "nin", Identifier: "2212345678x"},
+	} {
+		_, err = services.SubmitKYC(ctx, testStore, user.ID, bad, time.Now())
+		assert.ErrorIs(t, err, services.ErrInvalidKYC, bad)
+	}
+
+	submission, err := services.SubmitKYC(ctx, testStore, user.ID, request, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.KYCTier1, submission.Tier)
+	assert.Equal(t, models.KYCPending, submission.Status)
+	assert.Equal(t, models.IdentifierBVN, submission.IdentifierType)
+	assert.Equal(t, "6789", submission.IdentifierLast)
+
+	// The full identifier is never sent back
+	body, _ := json.Marshal(submission)
+	assert.NotContains(t, string(body), "22123456789")
+
+	_, err = services.SubmitKYC(ctx, testStore, user.ID, request, time.Now())
+	assert.ErrorIs(t, err, services.ErrKYCPending)
+}
+
+func TestSubmitKYCDocuments(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTier1, 0)
+	expired := time.Now().AddDate(0, -1, 0)
+
+	for _, bad := range [][]models.KYCDocument{
+		nil,
+		{{Type: models.DocumentUtilityBill, FileRef: "docs/bill.pdf"}},
+		{{Type: models.DocumentPassport}},
+		{{Type: models.DocumentPassport, FileRef: "docs/passport.jpg", ExpiresAt: &expired}},
+	} {
+		_, err := services.SubmitKYC(ctx, testStore, user.ID, services.KYCRequest{Documents: bad}, time.Now())
+		assert.ErrorIs(t, err, services.ErrInvalidKYC)
+	}
+
+	submission, err := services.SubmitKYC(ctx, testStore, user.ID, services.KYCRequest{Documents: []models.KYCDocument{
+		{Type: "Passport", Number: "A01234567", FileRef: "docs/passport.jpg"},
+	}}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.KYCTier2, submission.Tier)
+	assert.Equal(t, models.DocumentPassport, submission.Documents[0].Type)
+
+	// Tier 3 also needs the address on the profile
+	other := createKYCUser(t, models.KYCTier2, 0)
+	_, err = services.SubmitKYC(ctx, testStore, other.ID, services.KYCRequest{Documents: []models.KYCDocument{
+		{Type: models.DocumentUtilityBill, FileRef: "docs/bill.pdf"},
+	}}, time.Now())
+	assert.ErrorIs(t, err, services.ErrInvalidKYC)
+
+	_, err = services.SubmitKYC(ctx, testStore, createKYCUser(t, models.KYCTierMax, 0).ID, services.KYCRequest{}, time.Now())
+	assert.ErrorIs(t, err, services.ErrKYCMaxTier)
+}
+
+func TestReviewKYC(t *testing.T) {
+	ctx := context.Background()
+	reviewerID := primitive.NewObjectID()
+	user := createKYCUser(t, models.KYCTier1, 0)
+	passport := services.KYCRequest{Documents: []models.KYCDocument{{Type: models.DocumentPassport, FileRef: "docs/passport.jpg"}}}
+
+	submission, err := services.SubmitKYC(ctx, testStore, user.ID, passport, time.Now())
+	assert.NoError(t, err)
+	_, err = services.ApproveKYC(ctx, testStore, user.ID, submission.ID, "", time.Now())
+	assert.ErrorIs(t, err, services.ErrKYCOwnSubmission)
+	_, err = services.RejectKYC(ctx, testStore, reviewerID, submission.ID, " ", time.Now())
+	assert.ErrorIs(t, err, services.ErrInvalidKYC)
+
+	rejected, err := services.RejectKYC(ctx, testStore, reviewerID, submission.ID, "photo is blurred", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.KYCRejected, rejected.Status)
+	assert.Equal(t, models.KYCTier1, getTestUser(user.ID).KYCTier)
+	assert.Contains(t, waitForEmail(t, user.Email, "Verification not approved"), "photo is blurred")
+
+	// A rejected user can try again
+	submission, err = services.SubmitKYC(ctx, testStore, user.ID, passport, time.Now())
+	assert.NoError(t, err)
+	approved, err := services.ApproveKYC(ctx, testStore, reviewerID, submission.ID, "", time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, models.KYCApproved, approved.Status)
+	assert.Equal(t, reviewerID, *approved.ReviewerID)
+	assert.Equal(t, models.KYCTier2, getTestUser(user.ID).KYCTier)
+	waitForEmail(t, user.Email, "Verification approved")
+
+	_, err = services.ApproveKYC(ctx, testStore, reviewerID, submission.ID, "", time.Now())
+	assert.ErrorIs(t, err, services.ErrKYCReviewed)
+	_, err = services.ApproveKYC(ctx, testStore, reviewerID, primitive.NewObjectID(), "", time.Now())
+	assert.ErrorIs(t, err, services.ErrKYCNotFound)
+
+	status, err := services.GetKYC(ctx, testStore, user.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.KYCTier2, status.Tier)
+	assert.Equal(t, models.KYCLimits[models.KYCTier2], status.Limits)
+	assert.Len(t, status.Submissions, 2)
+}
+
+func TestAdminKYCRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/admin")
+	admin.Use(middlewares.AuthMiddleware(testStore), middlewares.AdminAuthMiddleware(testStore), middlewares.RequireStepUp())
+	admin.GET("/kyc", middlewares.RequirePermission(models.PermKYCReview), handlers.AdminListKYC(testStore))
+	admin.POST("/kyc/:submission_id/approve", middlewares.RequirePermission(models.PermKYCReview), handlers.AdminApproveKYC(testStore))
+
+	user := createKYCUser(t, models.KYCTier1, 0)
+	submission, err := services.SubmitKYC(context.Background(), testStore, user.ID, services.KYCRequest{
+		Documents: []models.KYCDocument{{Type: models.DocumentNationalID, FileRef: "docs/nin-slip.jpg"}},
+	}, time.Now())
+	assert.NoError(t, err)
+
+	_, support := signInStaff(t, models.RoleSupport)
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/kyc", support, ""))
+
+	_, compliance := signInStaff(t, models.RoleCompliance)
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/kyc", compliance, ""))
+	assert.Equal(t, http.StatusBadRequest, sendAdminRequest(router, http.MethodGet, "/admin/kyc?status=lost", compliance, ""))
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodPost, "/admin/kyc/"+submission.ID.Hex()+"/approve", compliance, ""))
+	assert.Equal(t, http.StatusConflict, sendAdminRequest(router, http.MethodPost, "/admin/kyc/"+submission.ID.Hex()+"/approve", compliance, ""))
+	assert.Equal(t, models.KYCTier2, getTestUser(user.ID).KYCTier)
+}
+
+func TestKYCDepositLimits(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTier0, 0)
+
+	_, err := services.Deposit(ctx, testStore, user.ID, models.NewMoney(1000001, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	_, err = services.Deposit(ctx, testStore, user.ID, models.NewMoney(1000000, "NGN"))
+	assert.NoError(t, err)
+
+	// Other currencies need the top tier
+	_, err = services.Deposit(ctx, testStore, user.ID, models.NewMoney(100, "USD"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+
+	// The balance cap counts goals and investments too
+	full := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		SavingsBalance:    models.NewMoney(3000000, "NGN"),
+		GoalsBalance:      models.NewMoney(1000000, "NGN"),
+		InvestmentBalance: models.NewMoney(500000, "NGN"),
+	}
+	assert.NoError(t, testStore.Users.Create(ctx, &full))
+	_, err = services.Deposit(ctx, testStore, full.ID, models.NewMoney(600000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	_, err = services.Deposit(ctx, testStore, full.ID, models.NewMoney(500000, "NGN"))
+	assert.NoError(t, err)
+
+	// The handler turns the limit into a 403 that says what it is
+	w := postJSON(handlers.Deposit(testStore), user.ID, `{"amount": "20000.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "tier 0")
+}
+
+func TestKYCWithdrawalAndTransferLimits(t *testing.T) {
+	ctx := context.Background()
+	sender := createKYCUser(t, models.KYCTier1, 4000000)
+
+	_, err := services.Withdraw(ctx, testStore, sender.ID, primitive.NilObjectID, models.NewMoney(3000000, "NGN"))
+	assert.NoError(t, err)
+	assert.NoError(t, testStore.Users.SetKYCTier(ctx, sender.ID, models.KYCTier0))
+	_, err = services.Withdraw(ctx, testStore, sender.ID, primitive.NilObjectID, models.NewMoney(1000001, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	assert.Equal(t, int64(1000000), getTestUser(sender.ID).SavingsBalance.Amount)
+
+	// Transfers are capped by the sender's withdrawal limit and the
+	// recipient's balance cap
+	recipient := createKYCUser(t, models.KYCTier0, 4500000)
+	senderUser, recipientUser := getTestUser(sender.ID), getTestUser(recipient.ID)
+	_, err = services.Transfer(ctx, testStore, &senderUser, &recipientUser, primitive.NilObjectID, models.NewMoney(600000, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	_, err = services.Transfer(ctx, testStore, &senderUser, &recipientUser, primitive.NilObjectID, models.NewMoney(500000, "NGN"), "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5000000), getTestUser(recipient.ID).SavingsBalance.Amount)
+}
+
+func TestKYCGoalLimits(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTier0, 2500000)
+	name, target := "Car", models.NewMoney(10000000, "NGN")
+	goal, err := services.CreateGoal(ctx, testStore, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	assert.NoError(t, err)
+
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(1000001, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	for i := 0; i < 2; i++ {
+		_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(1000000, "NGN"))
+		assert.NoError(t, err)
+	}
+	// Savings and goals together are capped at 50,000.00
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(600000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+
+	_, err = services.WithdrawFromGoal(ctx, testStore, user.ID, goal.ID, primitive.NilObjectID, models.NewMoney(1000001, "NGN"))
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+	assert.Equal(t, int64(2000000), getTestUser(user.ID).GoalsBalance.Amount)
+}
+
+func TestKYCBalanceCapOnScheduledDepositsAndRoundUps(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:           primitive.NewObjectID().Hex() + "@example.com",
+		EmailVerifiedAt: &verifiedAt,
+		SavingsBalance:  models.NewMoney(4995000, "NGN"),
+	}
+	assert.NoError(t, store.Users.Create(ctx, &user))
+
+	// The card isn't charged for a deposit that can't be credited
+	charged := 0
+	charger := services.ScheduledDepositCharger
+	services.ScheduledDepositCharger = func(context.Context, models.DepositSchedule, string) error {
+		charged++
+		return nil
+	}
+	defer func() { services.ScheduledDepositCharger = charger }()
+
+	now := time.Now()
+	daily, amount := models.FrequencyDaily, models.NewMoney(100000, "NGN")
+	_, err := services.CreateSchedule(ctx, store, user.ID, services.ScheduleChanges{Frequency: &daily, Amount: &amount}, now)
+	assert.NoError(t, err)
+	succeeded, failed, err := services.RunDueSchedules(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, succeeded)
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, 0, charged)
+
+	// Round-ups that would go over the cap are dropped rather than retried
+	assert.NoError(t, services.SetRoundUpRule(ctx, store, user.ID, &models.RoundUpRule{Nearest: 100, Multiplier: 1}, now))
+	_, err = services.RecordPurchase(ctx, store, services.CardPurchase{
+		EventID: primitive.NewObjectID().Hex(), UserID: user.ID, Amount: models.NewMoney(123450, "NGN"), PurchasedAt: now,
+	}, now)
+	assert.NoError(t, err)
+	posted, err := services.PostRoundUps(ctx, store, now.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, posted)
+
+	saved, _ := store.Users.GetByID(ctx, user.ID)
+	assert.Equal(t, int64(4995000), saved.SavingsBalance.Amount)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"testing"
+
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestValidateJournalEntry(t *testing.T) {
+	userID := primitive.NewObjectID()
+	amount := models.NewMoney(50000, "NGN")
+
+	deposit := &models.JournalEntry{
+		Reference: "deposit-1",
+		Lines: []models.JournalLine{
+			ledger.DebitLine(ledger.ExternalCashAccount, amount),
+			ledger.CreditLine(ledger.UserSavingsAccount(userID), amount),
+		},
+	}
+	assert.NoError(t, ledger.Validate(deposit))
+
+	unbalanced := &models.JournalEntry{
+		Reference: "deposit-2",
+		Lines: []models.JournalLine{
+			ledger.DebitLine(ledger.ExternalCashAccount, amount),
+			ledger.CreditLine(ledger.UserSavingsAccount(userID), models.NewMoney(49999, "NGN")),
+		},
+	}
+	assert.ErrorIs(t, ledger.Validate(unbalanced), ledger.ErrUnbalanced)
+
+	oneSided := &models.JournalEntry{
+		Reference: "deposit-3",
+		Lines:     []models.JournalLine{ledger.DebitLine(ledger.ExternalCashAccount, amount)},
+	}
+	assert.ErrorIs(t, ledger.Validate(oneSided), ledger.ErrInvalidEntry)
+
+	unknownAccount := &models.JournalEntry{
+		Reference: "deposit-4",
+		Lines: []models.JournalLine{
+			ledger.DebitLine("system:nowhere", amount),
+			ledger.CreditLine(ledger.UserSavingsAccount(userID), amount),
+		},
+	}
+	assert.ErrorIs(t, ledger.Validate(unknownAccount), ledger.ErrUnknownAccount)
+}
+
+func TestLedgerAccountTypes(t *testing.T) {
+	userID := primitive.NewObjectID()
+
+	accountType, err := ledger.AccountType(ledger.UserSavingsAccount(userID))
+	assert.NoError(t, err)
+	assert.Equal(t, models.Liability, accountType)
+
+	accountType, err = ledger.AccountType(ledger.ExternalCashAccount)
+	assert.NoError(t, err)
+	assert.True(t, accountType.DebitNormal())
+
+	accountType, err = ledger.AccountType(ledger.InterestExpenseAccount)
+	assert.NoError(t, err)
+	assert.Equal(t, models.Expense, accountType)
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// createLoginUser creates a user who can log in with "a-password"
+func createLoginUser(t *testing.T) *models.User {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("a-password"), bcrypt.MinCost)
+	user := models.User{
+		Email:          primitive.NewObjectID().Hex() + "@example.com",
+		PasswordHash:   string(hash),
+		SavingsBalance: models.NewMoney(0, "NGN"),
+	}
+	assert.NoError(t, testStore.Users.Create(context.Background(), &user))
+	return &user
+}
+
+func sendLogin(email, password, ip string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/user/login", handlers.Login(testStore))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/user/login", bytes.NewBufferString(`{"email": "`+email+`", "password": "`+password+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = ip + ":5000"
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginErrorsAreUniform(t *testing.T) {
+	user := createLoginUser(t)
+
+	unknown := sendLogin("nobody-"+user.Email, "a-password", "198.51.100.1")
+	wrong := sendLogin(user.Email, "not-the-password", "198.51.100.1")
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, http.StatusUnauthorized, wrong.Code)
+	assert.Equal(t, unknown.Body.String(), wrong.Body.String())
+}
+
+func TestLoginBackoffAndLockout(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+	now := time.Now()
+
+	// The first few failures don't have to wait
+	for i := 0; i < models.AccountLoginPolicy.FreeAttempts; i++ {
+		_, err := services.Authenticate(ctx, testStore, user.Email, "wrong", "", now)
+		assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+	_, err := services.Authenticate(ctx, testStore, user.Email, "a-password", "", now)
+	assert.ErrorIs(t, err, services.ErrTooManyLoginAttempts)
+
+	for i := models.AccountLoginPolicy.FreeAttempts; i < models.AccountLoginPolicy.LockoutAfter; i++ {
+		now = now.Add(models.AccountLoginPolicy.MaxBackoff)
+		_, err := services.Authenticate(ctx, testStore, user.Email, "wrong", "", now)
+		assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+	waitForEmail(t, user.Email, "Your account has been locked")
+
+	// Locked well past the backoff, even with the right password
+	_, err = services.Authenticate(ctx, testStore, user.Email, "a-password", "", now.Add(models.AccountLoginPolicy.MaxBackoff))
+	var blocked *services.LoginBlockedError
+	assert.ErrorAs(t, err, &blocked)
+	assert.Equal(t, now.Add(models.AccountLoginPolicy.LockoutFor).Unix(), blocked.RetryAt.Unix())
+
+	assert.NoError(t, services.UnlockAccount(ctx, testStore, user.ID))
+	authenticated, err := services.Authenticate(ctx, testStore, user.Email, "a-password", "", now)
+	assert.NoError(t, err)
+	assert.Equal(t, user.ID, authenticated.ID)
+}
+
+func TestLoginThrottledByIP(t *testing.T) {
+	ctx := context.Background()
+	ip := "203.0.113.7"
+	now := time.Now()
+
+	// Guessing across many accounts from one address is slowed down too
+	for i := 0; i < models.IPLoginPolicy.FreeAttempts; i++ {
+		_, err := services.Authenticate(ctx, testStore, primitive.NewObjectID().Hex()+"@example.com", "wrong", ip, now)
+		assert.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+	user := createLoginUser(t)
+	_, err := services.Authenticate(ctx, testStore, user.Email, "a-password", ip, now)
+	assert.ErrorIs(t, err, services.ErrTooManyLoginAttempts)
+	_, err = services.Authenticate(ctx, testStore, user.Email, "a-password", "198.51.100.2", now)
+	assert.NoError(t, err)
+}
+
+func TestLoginTooManyAttemptsResponse(t *testing.T) {
+	user := createLoginUser(t)
+	for i := 0; i < models.AccountLoginPolicy.FreeAttempts; i++ {
+		sendLogin(user.Email, "wrong", "198.51.100.3")
+	}
+
+	w := sendLogin(user.Email, "a-password", "198.51.100.3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
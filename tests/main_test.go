@@ -2,37 +2,79 @@ package tests
 
 import (
 	"context"
-	"log"
-	"micro-savings-app/database"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/joho/godotenv"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
 )
 
+// testStore backs every handler under test, so the suite needs no database
+var testStore *repository.Store
+
 func TestMain(m *testing.M) {
-	// Load .env file before running tests
-	err := godotenv.Load("../.env") // Adjust path if needed
-	if err != nil {
-		panic("Failed to load .env file")
-	}
+	// Configuration normally read from .env
+	os.Setenv("ADMIN_SECRET", "test-admin-secret")
+	os.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "dGVzdC1zaWduaW5nLWtleS1lbmNyeXB0aW9uLWtleSE=")
 
-	// Connect to MongoDB test database
-	clientOptions := options.Client().ApplyURI(os.Getenv("TEST_MONGO_URI")) // Ensure TEST_MONGO_URI is set in .env
-	client, err := mongo.Connect(context.Background(), clientOptions)
-	if err != nil {
-		log.Fatal("Failed to connect to test database:", err)
+	testStore = repository.NewMemoryStore()
+	if err := services.LoadSigningKeys(context.Background(), testStore); err != nil {
+		panic(err)
 	}
 
-	database.ConnectTestDB() // Custom function to set test DB
+	// Notifications are kept in the test mailbox instead of being sent
+	services.EmailSender = testMailbox.deliver
+
+	os.Exit(m.Run())
+}
+
+// mailbox collects the emails sent during the tests
+type mailbox struct {
+	mu       sync.Mutex
+	messages []sentEmail
+}
 
-	exitCode := m.Run()
-	//os.Exit(m.Run()) // Run tests
+type sentEmail struct {
+	to, subject, content string
+}
 
-	// Clean up
-	client.Disconnect(context.Background())
+var testMailbox = &mailbox{}
+
+func (m *mailbox) deliver(toEmail, subject, content string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, sentEmail{toEmail, subject, content})
+	return nil
+}
+
+// waitForEmail returns the content of the latest email with the subject sent
+// to the address, waiting briefly since emails are sent in the background
+func waitForEmail(t *testing.T, toEmail, subject string) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		m := testMailbox
+		m.mu.Lock()
+		for i := len(m.messages) - 1; i >= 0; i-- {
+			if m.messages[i].to == toEmail && m.messages[i].subject == subject {
+				content := m.messages[i].content
+				m.mu.Unlock()
+				return content
+			}
+		}
+		m.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no %q email sent to %s", subject, toEmail)
+	return ""
+}
 
-	os.Exit(exitCode)
-}
\ No newline at end of file
+// emailedToken pulls the token out of an email sent without APP_URL set
+func emailedToken(content string) string {
+	_, rest, _ := strings.Cut(content, "Your token is ")
+	token, _, _ := strings.Cut(rest, ". ")
+	return token
+}
@@ -0,0 +1,301 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testTOTPSecret is the RFC 6238 test key, "12345678901234567890", in base32
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// setupMFARouter serves the login and two-factor routes the way main does
+func setupMFARouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/user/login", handlers.Login(testStore))
+	router.POST("/user/login/mfa", handlers.LoginMFA(testStore))
+	protected := router.Group("/user")
+	protected.Use(middlewares.AuthMiddleware(testStore))
+	protected.POST("/withdraw", middlewares.IdempotencyMiddleware(testStore), handlers.Withdraw(testStore))
+	protected.POST("/transfer", middlewares.IdempotencyMiddleware(testStore), handlers.Transfer(testStore))
+	protected.POST("/goals/:goal_id/withdraw", middlewares.IdempotencyMiddleware(testStore), handlers.WithdrawFromGoal(testStore))
+	protected.POST("/mfa/verify", handlers.VerifyMFA(testStore))
+	return router
+}
+
+func sendMFARequest(router *gin.Engine, path, accessToken, body string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+// enrolMFA creates a user with a password and turns two-factor on for them,
+// returning the user, their authenticator secret and recovery codes
+func enrolMFA(t *testing.T) (*models.User, string, []string) {
+	ctx := context.Background()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("a-password"), bcrypt.MinCost)
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:           primitive.NewObjectID().Hex() + "@example.com",
+		PasswordHash:    string(hash),
+		EmailVerifiedAt: &verifiedAt,
+		SavingsBalance:  models.NewMoney(10000000, "NGN"),
+		KYCTier:         models.KYCTierMax,
+	}
+	assert.NoError(t, testStore.Users.Create(ctx, &user))
+
+	setup, err := services.SetupMFA(ctx, testStore, user.ID)
+	assert.NoError(t, err)
+	assert.Contains(t, setup.URI, "otpauth://totp/")
+	assert.Contains(t, setup.URI, "secret="+setup.Secret)
+
+	code, _ := services.TOTPCode(setup.Secret, time.Now())
+	codes, err := services.EnableMFA(ctx, testStore, user.ID, code, time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, codes, models.RecoveryCodeCount)
+	return &user, setup.Secret, codes
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	code, err := services.TOTPCode(testTOTPSecret, time.Unix(59, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "287082", code)
+	code, _ = services.TOTPCode(testTOTPSecret, time.Unix(1111111109, 0))
+	assert.Equal(t, "081804", code)
+}
+
+func TestMFAEnrolment(t *testing.T) {
+	ctx := context.Background()
+	user, secret, _ := enrolMFA(t)
+
+	_, err := services.SetupMFA(ctx, testStore, user.ID)
+	assert.ErrorIs(t, err, services.ErrMFAAlreadyEnabled)
+
+	// The code used to turn it on can't be used again
+	lastStep := getTestUser(user.ID).MFA.LastStep
+	code, _ := services.TOTPCode(secret, time.Unix(lastStep*30, 0))
+	assert.ErrorIs(t, services.DisableMFA(ctx, testStore, user.ID, code, time.Now()), services.ErrInvalidMFACode)
+
+	code, _ = services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	assert.NoError(t, services.DisableMFA(ctx, testStore, user.ID, code, time.Now()))
+	assert.False(t, getTestUser(user.ID).MFAEnabled())
+	waitForEmail(t, user.Email, "Two-factor authentication is off")
+}
+
+func TestMFALogin(t *testing.T) {
+	router := setupMFARouter()
+	user, secret, _ := enrolMFA(t)
+	login := `{"email": "` + user.Email + `", "password": "a-password"}`
+
+	status, response := sendMFARequest(router, "/user/login", "", login)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "mfa_required", response["status"])
+	assert.Nil(t, response["token"])
+	challenge, _ := response["mfa_token"].(string)
+
+	// A wrong code spends the challenge
+	status, _ = sendMFARequest(router, "/user/login/mfa", "", `{"mfa_token": "`+challenge+`", "code": "000000"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	code, _ := services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	status, _ = sendMFARequest(router, "/user/login/mfa", "", `{"mfa_token": "`+challenge+`", "code": "`+code+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	_, response = sendMFARequest(router, "/user/login", "", login)
+	challenge, _ = response["mfa_token"].(string)
+	status, response = sendMFARequest(router, "/user/login/mfa", "", `{"mfa_token": "`+challenge+`", "code": "`+code+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.NotEmpty(t, response["token"])
+	assert.NotEmpty(t, response["refresh_token"])
+}
+
+func TestMFARecoveryCodes(t *testing.T) {
+	router := setupMFARouter()
+	user, _, codes := enrolMFA(t)
+	login := `{"email": "` + user.Email + `", "password": "a-password"}`
+
+	_, response := sendMFARequest(router, "/user/login", "", login)
+	challenge, _ := response["mfa_token"].(string)
+	status, _ := sendMFARequest(router, "/user/login/mfa", "", `{"mfa_token": "`+challenge+`", "code": "`+codes[0]+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+	waitForEmail(t, user.Email, "A recovery code was used")
+
+	// Each recovery code works once
+	_, response = sendMFARequest(router, "/user/login", "", login)
+	challenge, _ = response["mfa_token"].(string)
+	status, _ = sendMFARequest(router, "/user/login/mfa", "", `{"mfa_token": "`+challenge+`", "code": "`+codes[0]+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// New codes replace the old ones
+	fresh, err := services.RegenerateRecoveryCodes(context.Background(), testStore, user.ID, codes[1], time.Now())
+	assert.NoError(t, err)
+	assert.ErrorIs(t, services.DisableMFA(context.Background(), testStore, user.ID, codes[2], time.Now()), services.ErrInvalidMFACode)
+	assert.NoError(t, services.DisableMFA(context.Background(), testStore, user.ID, fresh[0], time.Now()))
+}
+
+func TestWithdrawStepUp(t *testing.T) {
+	router := setupMFARouter()
+	user, secret, _ := enrolMFA(t)
+	tokens, err := services.StartSession(context.Background(), testStore, user.ID, "", "", time.Now())
+	assert.NoError(t, err)
+
+	// Small withdrawals go through; large ones need a fresh code
+	status, _ := sendMFARequest(router, "/user/withdraw", tokens.AccessToken, `{"amount": "100.00"}`)
+	assert.Equal(t, http.StatusOK, status)
+	large := `{"amount": "60000.00"}`
+	status, response := sendMFARequest(router, "/user/withdraw", tokens.AccessToken, large)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, true, response["step_up_required"])
+
+	code, _ := services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	status, _ = sendMFARequest(router, "/user/mfa/verify", tokens.AccessToken, `{"code": "`+code+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendMFARequest(router, "/user/withdraw", tokens.AccessToken, large)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestStepUpRetryWithSameIdempotencyKey(t *testing.T) {
+	router := setupMFARouter()
+	user, secret, _ := enrolMFA(t)
+	tokens, err := services.StartSession(context.Background(), testStore, user.ID, "", "", time.Now())
+	assert.NoError(t, err)
+
+	withdraw := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/user/withdraw", bytes.NewBufferString(`{"amount": "60000.00"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		req.Header.Set(middlewares.IdempotencyKeyHeader, "withdraw-after-step-up")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusForbidden, withdraw().Code)
+
+	// Once a code is entered, the retry with the same key goes through
+	// rather than replaying the 403
+	code, _ := services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	status, _ := sendMFARequest(router, "/user/mfa/verify", tokens.AccessToken, `{"code": "`+code+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+	w := withdraw()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int64(4000000), getTestUser(user.ID).SavingsBalance.Amount)
+
+	// From then on the success is what gets replayed
+	w = withdraw()
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int64(4000000), getTestUser(user.ID).SavingsBalance.Amount)
+}
+
+func TestTransferAndGoalWithdrawalStepUp(t *testing.T) {
+	ctx := context.Background()
+	router := setupMFARouter()
+	user, secret, _ := enrolMFA(t)
+	recipient := createKYCUser(t, models.KYCTierMax, 0)
+	tokens, err := services.StartSession(ctx, testStore, user.ID, "", "", time.Now())
+	assert.NoError(t, err)
+
+	name, target := "Rent", models.NewMoney(10000000, "NGN")
+	goal, _ := services.CreateGoal(ctx, testStore, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(7000000, "NGN"))
+	assert.NoError(t, err)
+
+	transfer := `{"recipient": "` + recipient.ID.Hex() + `", "amount": "60000.00"}`
+	status, response := sendMFARequest(router, "/user/transfer", tokens.AccessToken, transfer)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, true, response["step_up_required"])
+	goalWithdrawal := "/user/goals/" + goal.ID.Hex() + "/withdraw"
+	status, response = sendMFARequest(router, goalWithdrawal, tokens.AccessToken, `{"amount": "60000.00"}`)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, true, response["step_up_required"])
+	assert.Equal(t, int64(10000000), getTestUser(user.ID).SavingsBalance.Amount)
+
+	code, _ := services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	status, _ = sendMFARequest(router, "/user/mfa/verify", tokens.AccessToken, `{"code": "`+code+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendMFARequest(router, "/user/transfer", tokens.AccessToken, transfer)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = sendMFARequest(router, goalWithdrawal, tokens.AccessToken, `{"amount": "60000.00"}`)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestWrongMFACodesLockOut(t *testing.T) {
+	ctx := context.Background()
+	router := setupMFARouter()
+	user, secret, codes := enrolMFA(t)
+	tokens, err := services.StartSession(ctx, testStore, user.ID, "", "", time.Now())
+	assert.NoError(t, err)
+
+	// Wrong codes are counted against the user, so a stolen session can't
+	// guess its way past step-up or turn two-factor off
+	now := time.Now()
+	for i := 0; i < models.MFACodePolicy.LockoutAfter; i++ {
+		err := services.DisableMFA(ctx, testStore, user.ID, "000000", now)
+		assert.ErrorIs(t, err, services.ErrInvalidMFACode)
+		now = now.Add(models.MFACodePolicy.MaxBackoff)
+	}
+	waitForEmail(t, user.Email, "Two-factor codes are locked")
+
+	code, _ := services.TOTPCode(secret, time.Now().Add(30*time.Second))
+	var blocked *services.LoginBlockedError
+	assert.ErrorAs(t, services.DisableMFA(ctx, testStore, user.ID, code, time.Now()), &blocked)
+	status, response := sendMFARequest(router, "/user/mfa/verify", tokens.AccessToken, `{"code": "`+codes[0]+`"}`)
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.NotNil(t, response["retry_after"])
+	assert.True(t, getTestUser(user.ID).MFAEnabled())
+
+	// An admin unlock clears the count
+	assert.NoError(t, services.UnlockAccount(ctx, testStore, user.ID))
+	status, _ = sendMFARequest(router, "/user/mfa/verify", tokens.AccessToken, `{"code": "`+code+`"}`)
+	assert.Equal(t, http.StatusOK, status)
+}
+
+func TestAdminRequiresStepUp(t *testing.T) {
+	ctx := context.Background()
+	router := setupAdminRouter()
+	staffID, _ := signInStaff(t, models.RoleSupport)
+
+	// Signed in, but no code entered in this session
+	tokens, _ := services.StartSession(ctx, testStore, staffID, "", "", time.Now())
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", tokens.AccessToken, ""))
+
+	// A code entered too long ago no longer counts
+	sessionID, _ := primitive.ObjectIDFromHex(sessionIDOf(t, tokens))
+	assert.NoError(t, testStore.Sessions.SetMFAVerified(ctx, sessionID, time.Now().Add(-models.StepUpTTL)))
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", tokens.AccessToken, ""))
+
+	code, _ := services.TOTPCode(testTOTPSecret, time.Now())
+	assert.NoError(t, services.StepUp(ctx, testStore, staffID, sessionID, code, time.Now()))
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", tokens.AccessToken, ""))
+
+	// Staff without two-factor can't step up at all
+	user := models.User{Email: primitive.NewObjectID().Hex() + "@example.com", Roles: []string{models.RoleSupport}}
+	assert.NoError(t, testStore.Users.Create(ctx, &user))
+	tokens, _ = services.StartSession(ctx, testStore, user.ID, "", "", time.Now())
+	assert.ErrorIs(t, services.StepUp(ctx, testStore, user.ID, sessionID, code, time.Now()), services.ErrMFANotEnabled)
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", tokens.AccessToken, ""))
+}
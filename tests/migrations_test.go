@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"micro-savings-app/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// memoryHistory keeps applied migrations in a map instead of Mongo
+type memoryHistory map[int]migrations.AppliedMigration
+
+func (h memoryHistory) Applied(context.Context) (map[int]migrations.AppliedMigration, error) {
+	applied := make(map[int]migrations.AppliedMigration, len(h))
+	for version, record := range h {
+		applied[version] = record
+	}
+	return applied, nil
+}
+
+func (h memoryHistory) Record(_ context.Context, applied migrations.AppliedMigration) error {
+	h[applied.Version] = applied
+	return nil
+}
+
+func (h memoryHistory) Remove(_ context.Context, version int) error {
+	delete(h, version)
+	return nil
+}
+
+// newTestRunner returns a runner over migrations that only log what they do.
+// They are registered out of order on purpose.
+func newTestRunner(history memoryHistory, ran *[]string, failing int) *migrations.Runner {
+	step := func(version int, name string) migrations.Migration {
+		return migrations.Migration{
+			Version: version,
+			Name:    name,
+			Up: func(context.Context, *mongo.Database) error {
+				if version == failing {
+					return errors.New("boom")
+				}
+				*ran = append(*ran, "up "+name)
+				return nil
+			},
+			Down: func(context.Context, *mongo.Database) error {
+				*ran = append(*ran, "down "+name)
+				return nil
+			},
+		}
+	}
+	return &migrations.Runner{
+		History:    history,
+		Migrations: []migrations.Migration{step(3, "third"), step(1, "first"), step(2, "second")},
+	}
+}
+
+func TestMigrationsUpInVersionOrder(t *testing.T) {
+	ctx := context.Background()
+	history := memoryHistory{}
+	var ran []string
+	runner := newTestRunner(history, &ran, 0)
+
+	applied, err := runner.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, applied)
+	assert.Equal(t, []string{"up first", "up second", "up third"}, ran)
+	assert.Equal(t, "second", history[2].Name)
+
+	// Applied steps are never run twice
+	applied, err = runner.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, applied)
+	assert.Len(t, ran, 3)
+}
+
+func TestMigrationsUpSkipsAppliedAndStopsAtFailure(t *testing.T) {
+	ctx := context.Background()
+	history := memoryHistory{1: {Version: 1, Name: "first", AppliedAt: time.Now()}}
+	var ran []string
+
+	applied, err := newTestRunner(history, &ran, 3).Up(ctx)
+	assert.ErrorContains(t, err, "migration 3 third")
+	assert.Equal(t, 1, applied)
+	assert.Equal(t, []string{"up second"}, ran)
+	assert.Contains(t, history, 2)
+	assert.NotContains(t, history, 3)
+}
+
+func TestMigrationsStatus(t *testing.T) {
+	appliedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	history := memoryHistory{2: {Version: 2, Name: "second", AppliedAt: appliedAt}}
+	var ran []string
+
+	statuses, err := newTestRunner(history, &ran, 0).Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 3) {
+		assert.Equal(t, 1, statuses[0].Version)
+		assert.Nil(t, statuses[0].AppliedAt)
+		assert.Equal(t, "second", statuses[1].Name)
+		if assert.NotNil(t, statuses[1].AppliedAt) {
+			assert.Equal(t, appliedAt, *statuses[1].AppliedAt)
+		}
+		assert.Equal(t, 3, statuses[2].Version)
+	}
+	assert.Empty(t, ran)
+}
+
+func TestMigrationsDownNewestFirst(t *testing.T) {
+	ctx := context.Background()
+	history := memoryHistory{}
+	var ran []string
+	runner := newTestRunner(history, &ran, 0)
+	_, err := runner.Up(ctx)
+	assert.NoError(t, err)
+	ran = nil
+
+	reverted, err := runner.Down(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, reverted)
+	assert.Equal(t, []string{"down third", "down second"}, ran)
+	assert.Contains(t, history, 1)
+	assert.Len(t, history, 1)
+
+	// Asking for more steps than are applied reverts what there is
+	reverted, err = runner.Down(ctx, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+	assert.Empty(t, history)
+}
+
+func TestMigrationsDownRefusesUnknownVersions(t *testing.T) {
+	history := memoryHistory{
+		1: {Version: 1, Name: "first"},
+		9: {Version: 9, Name: "from a newer build"},
+	}
+	var ran []string
+
+	reverted, err := newTestRunner(history, &ran, 0).Down(context.Background(), 1)
+	assert.ErrorIs(t, err, migrations.ErrUnknownVersion)
+	assert.Equal(t, 0, reverted)
+	assert.Empty(t, ran)
+	assert.Len(t, history, 2)
+}
+
+func TestRegisteredMigrations(t *testing.T) {
+	seen := map[int]bool{}
+	for i, m := range migrations.Registered() {
+		assert.Equal(t, i+1, m.Version, "versions run from 1 without gaps")
+		assert.False(t, seen[m.Version])
+		seen[m.Version] = true
+		assert.NotEmpty(t, m.Name)
+		assert.NotNil(t, m.Up)
+		assert.NotNil(t, m.Down)
+	}
+}
@@ -0,0 +1,78 @@
+package tests
+
+import (
+	"encoding/json"
+	"testing"
+
+	"micro-savings-app/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]int64{
+		"1500":    150000,
+		"1500.5":  150050,
+		"1500.50": 150050,
+		"0.01":    1,
+		".75":     75,
+		"2.500":   250,
+		"-3.10":   -310,
+	}
+	for input, expected := range cases {
+		money, err := models.ParseMoney(input, "NGN")
+		assert.NoError(t, err, input)
+		assert.Equal(t, expected, money.Amount, input)
+		assert.Equal(t, "NGN", money.Currency)
+	}
+
+	_, err := models.ParseMoney("10.005", "NGN")
+	assert.ErrorIs(t, err, models.ErrTooManyDecimals)
+
+	for _, input := range []string{"", ".", "5.", "1,000", "abc", "1.2.3"} {
+		_, err := models.ParseMoney(input, "NGN")
+		assert.Error(t, err, input)
+	}
+
+	_, err = models.ParseMoney("10", "XYZ")
+	assert.ErrorIs(t, err, models.ErrUnknownCurrency)
+}
+
+func TestMoneyString(t *testing.T) {
+	assert.Equal(t, "1500.50", models.NewMoney(150050, "NGN").String())
+	assert.Equal(t, "0.05", models.NewMoney(5, "NGN").String())
+	assert.Equal(t, "-0.05", models.NewMoney(-5, "NGN").String())
+	assert.Equal(t, "0.00", models.Money{}.String())
+}
+
+func TestMoneyJSON(t *testing.T) {
+	data, err := json.Marshal(models.NewMoney(150050, "NGN"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"amount": "1500.50", "currency": "NGN"}`, string(data))
+
+	var request struct {
+		Amount models.Money `json:"amount"`
+	}
+	for _, body := range []string{
+		`{"amount": "1500.50"}`,
+		`{"amount": 1500.50}`,
+		`{"amount": {"amount": "1500.50", "currency": "NGN"}}`,
+	} {
+		assert.NoError(t, json.Unmarshal([]byte(body), &request), body)
+		assert.Equal(t, models.NewMoney(150050, "NGN"), request.Amount, body)
+	}
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": "0.001"}`), &request))
+	assert.Error(t, json.Unmarshal([]byte(`{"amount": 1e3}`), &request))
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	a := models.NewMoney(1000, "NGN")
+	b := models.NewMoney(250, "NGN")
+
+	assert.Equal(t, int64(1250), a.Add(b).Amount)
+	assert.Equal(t, int64(750), a.Sub(b).Amount)
+	assert.Equal(t, 1, a.Cmp(b))
+	assert.True(t, models.Money{}.SameCurrency(a))
+	assert.Panics(t, func() { a.Add(models.NewMoney(1, "USD")) })
+}
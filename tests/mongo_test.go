@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// newMongoTestStore returns a store backed by a scratch database on the
+// replica set at MONGO_TEST_URI, dropped when the test ends. The memory store
+// serialises everything behind one lock, so only Mongo can show what the
+// guarded updates do under real concurrency; without a replica set (needed
+// for transactions) the test is skipped.
+func newMongoTestStore(t *testing.T) *repository.Store {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Skipf("can't connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Disconnect(context.Background()) })
+
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		t.Skipf("can't reach MongoDB: %v", err)
+	}
+	if _, ok := hello["setName"]; !ok {
+		t.Skip("MongoDB is not running as a replica set")
+	}
+
+	db := client.Database("micro_savings_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() { _ = db.Drop(context.Background()) })
+	return repository.NewMongoStore(db)
+}
+
+// createMongoTestUser creates a verified user holding savings (in kobo)
+func createMongoTestUser(t *testing.T, store *repository.Store, savings int64) primitive.ObjectID {
+	t.Helper()
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		EmailVerifiedAt:   &verifiedAt,
+		SavingsBalance:    models.NewMoney(savings, "NGN"),
+		InvestmentBalance: models.NewMoney(0, "NGN"),
+		GoalsBalance:      models.NewMoney(0, "NGN"),
+	}
+	assert.NoError(t, store.Users.Create(context.Background(), &user))
+	return user.ID
+}
+
+func TestMongoConcurrentDebitsNeverOverdraw(t *testing.T) {
+	store := newMongoTestStore(t)
+	ctx := context.Background()
+	userID := createMongoTestUser(t, store, 100000) // 1000.00
+
+	// 150 debits of 10.00 race for a balance that only covers 100 of them
+	const attempts = 150
+	var wg sync.WaitGroup
+	var succeeded, refused int64
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := store.Users.AdjustBalance(ctx, userID, repository.SavingsBalance, models.NewMoney(-1000, "NGN"), time.Now())
+			switch {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case assert.ErrorIs(t, err, repository.ErrInsufficientFunds):
+				atomic.AddInt64(&refused, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), succeeded)
+	assert.Equal(t, int64(attempts-100), refused)
+	user, err := store.Users.GetByID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), user.SavingsBalance.Amount)
+}
+
+func TestMongoConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	store := newMongoTestStore(t)
+	ctx := context.Background()
+	userID := createMongoTestUser(t, store, 20000) // 200.00
+
+	// 50 withdrawals of 10.00, each in its own transaction, race for a
+	// balance that only covers 20 of them
+	const attempts = 50
+	var wg sync.WaitGroup
+	var succeeded, refused int64
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := services.Withdraw(ctx, store, userID, primitive.NilObjectID, models.NewMoney(1000, "NGN"))
+			switch {
+			case err == nil:
+				atomic.AddInt64(&succeeded, 1)
+			case assert.ErrorIs(t, err, services.ErrInsufficientBalance):
+				atomic.AddInt64(&refused, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(20), succeeded)
+	assert.Equal(t, int64(attempts-20), refused)
+	user, err := store.Users.GetByID(ctx, userID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), user.SavingsBalance.Amount)
+
+	// Aborted attempts left nothing behind: one row per withdrawal made
+	rows, err := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Withdrawal})
+	assert.NoError(t, err)
+	assert.Len(t, rows, 20)
+}
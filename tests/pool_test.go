@@ -0,0 +1,319 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newPoolMember creates a user holding savings (in kobo) for pool tests
+func newPoolMember(store *repository.Store, savings int64) primitive.ObjectID {
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		SavingsBalance:    models.NewMoney(savings, "NGN"),
+		InvestmentBalance: models.NewMoney(0, "NGN"),
+		GoalsBalance:      models.NewMoney(0, "NGN"),
+	}
+	_ = store.Users.Create(context.Background(), &user)
+	return user.ID
+}
+
+// startWeeklyPool creates a pool of the given members contributing 100.00 a
+// week, paid out in the order given, and starts it at now
+func startWeeklyPool(t *testing.T, store *repository.Store, now time.Time, members ...primitive.ObjectID) *models.Pool {
+	ctx := context.Background()
+	pool, err := services.CreatePool(ctx, store, members[0], services.PoolSettings{
+		Name:               "Market women",
+		ContributionAmount: models.NewMoney(10000, "NGN"),
+		Cycle:              models.FrequencyWeekly,
+		MaxMembers:         len(members),
+	}, now)
+	assert.NoError(t, err)
+	for _, memberID := range members[1:] {
+		_, err := services.JoinPool(ctx, store, memberID, pool.ID, now)
+		assert.NoError(t, err)
+	}
+	pool, err = services.StartPool(ctx, store, members[0], pool.ID, members, nil, now)
+	assert.NoError(t, err)
+	return pool
+}
+
+func savingsOf(store *repository.Store, userID primitive.ObjectID) int64 {
+	user, _ := store.Users.GetByID(context.Background(), userID)
+	return user.SavingsBalance.Amount
+}
+
+func TestPoolRotationPaysEachMemberOnce(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b, c := newPoolMember(store, 50000), newPoolMember(store, 50000), newPoolMember(store, 50000)
+	pool := startWeeklyPool(t, store, now, c, a, b)
+
+	for week := 0; week < 3; week++ {
+		cycles, shortfalls, err := services.RunDuePools(ctx, store, now.AddDate(0, 0, 7*week))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, cycles)
+		assert.Equal(t, 0, shortfalls)
+	}
+
+	// Nothing is due once every member has had a turn
+	cycles, _, _ := services.RunDuePools(ctx, store, now.AddDate(0, 0, 21))
+	assert.Equal(t, 0, cycles)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, models.PoolCompleted, pool.Status)
+	assert.True(t, pool.Held.IsZero())
+	for _, member := range pool.Members {
+		assert.True(t, member.PaidOut)
+		assert.Equal(t, int64(50000), savingsOf(store, member.UserID))
+	}
+
+	// c went first, so their payout came before they had paid in twice more
+	rows, _ := store.Transactions.List(ctx, repository.TransactionFilter{UserID: c, Type: models.PoolPayout})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, int64(30000), rows[0].Amount.Amount)
+	}
+	rows, _ = store.Transactions.List(ctx, repository.TransactionFilter{UserID: c, Type: models.PoolContribution})
+	assert.Len(t, rows, 3)
+}
+
+func TestPoolMemberShortOfFundsCatchesUp(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newPoolMember(store, 50000), newPoolMember(store, 0)
+	pool := startWeeklyPool(t, store, now, a, b)
+
+	// b can't pay, so a's payout is short by b's contribution
+	_, shortfalls, err := services.RunDuePools(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, shortfalls)
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, int64(10000), pool.Members[0].Owed.Amount)
+	assert.Equal(t, int64(10000), pool.Members[1].Arrears.Amount)
+	assert.Equal(t, 1, pool.Members[1].MissedContributions)
+	assert.Equal(t, int64(50000), savingsOf(store, a))
+
+	// b's turn: their arrears are withheld from the pot and a is paid in full
+	_, err = store.Users.AdjustBalance(ctx, b, repository.SavingsBalance, models.NewMoney(5000, "NGN"), now)
+	assert.NoError(t, err)
+	_, _, err = services.RunDuePools(ctx, store, now.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, models.PoolCompleted, pool.Status)
+	assert.True(t, pool.Held.IsZero())
+	assert.Equal(t, int64(50000), savingsOf(store, a))
+	assert.Equal(t, int64(5000), savingsOf(store, b))
+}
+
+func TestPoolMembership(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := repository.NewMemoryStore()
+	now := time.Now()
+	creator, joiner, outsider := newPoolMember(store, 0), newPoolMember(store, 0), newPoolMember(store, 0)
+
+	perform := func(handler gin.HandlerFunc, userID primitive.ObjectID, poolID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/user/pools", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "pool_id", Value: poolID}}
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+		handler(c)
+		return w
+	}
+
+	w := perform(handlers.CreatePool(store), creator, "", `{"name": "Esusu", "contribution_amount": "100.00", "cycle": "fortnightly", "max_members": 2}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	pool, err := services.CreatePool(context.Background(), store, creator, services.PoolSettings{
+		Name: "Esusu", ContributionAmount: models.NewMoney(10000, "NGN"), Cycle: models.FrequencyMonthly, MaxMembers: 2,
+	}, now)
+	assert.NoError(t, err)
+	id := pool.ID.Hex()
+
+	assert.Equal(t, http.StatusBadRequest, perform(handlers.StartPool(store), creator, id, "").Code)
+	assert.Equal(t, http.StatusOK, perform(handlers.JoinPool(store), joiner, id, "").Code)
+	assert.Equal(t, http.StatusConflict, perform(handlers.JoinPool(store), joiner, id, "").Code)
+	assert.Equal(t, http.StatusConflict, perform(handlers.JoinPool(store), outsider, id, "").Code)
+	assert.Equal(t, http.StatusNotFound, perform(handlers.GetPool(store), outsider, id, "").Code)
+	assert.Equal(t, http.StatusForbidden, perform(handlers.StartPool(store), joiner, id, "").Code)
+
+	// The creator can leave before the start; the next member takes over
+	assert.Equal(t, http.StatusOK, perform(handlers.LeavePool(store), creator, id, "").Code)
+	assert.Equal(t, http.StatusOK, perform(handlers.JoinPool(store), outsider, id, "").Code)
+	assert.Equal(t, http.StatusOK, perform(handlers.StartPool(store), joiner, id, "").Code)
+	assert.Equal(t, http.StatusConflict, perform(handlers.LeavePool(store), outsider, id, "").Code)
+
+	started, _ := store.Pools.GetByID(context.Background(), pool.ID)
+	assert.Equal(t, models.PoolActive, started.Status)
+	assert.Equal(t, joiner, started.CreatorID)
+	assert.Equal(t, 2, started.Members[1].Position)
+}
+
+func TestPoolPayoutKeptWithinBalanceLimit(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newPoolMember(store, 4990000), newPoolMember(store, 50000)
+	pool := startWeeklyPool(t, store, now, a, b)
+
+	// a moves money into a goal after the start, leaving room for only 50.00
+	// of the 200.00 pot under the tier 0 cap
+	_, err := store.Users.AdjustBalance(ctx, a, repository.GoalsBalance, models.NewMoney(15000, "NGN"), now)
+	assert.NoError(t, err)
+	_, _, err = services.RunDuePools(ctx, store, now)
+	assert.NoError(t, err)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, int64(15000), pool.Members[0].Owed.Amount)
+	assert.Equal(t, int64(15000), pool.Held.Amount)
+	assert.Equal(t, int64(4985000), savingsOf(store, a))
+
+	// Once a has room again the rest is paid with the next cycle
+	_, err = store.Users.AdjustBalance(ctx, a, repository.GoalsBalance, models.NewMoney(-15000, "NGN"), now)
+	assert.NoError(t, err)
+	_, _, err = services.RunDuePools(ctx, store, now.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, models.PoolCompleted, pool.Status)
+	assert.True(t, pool.Held.IsZero())
+	assert.Equal(t, int64(4990000), savingsOf(store, a))
+	assert.Equal(t, int64(50000), savingsOf(store, b))
+}
+
+func TestPoolRejectsPotOverBalanceLimit(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	settings := services.PoolSettings{
+		Name: "Esusu", ContributionAmount: models.NewMoney(3000000, "NGN"), Cycle: models.FrequencyMonthly, MaxMembers: 2,
+	}
+
+	// A pot of 60,000.00 is over the 50,000.00 tier 0 cap of whoever joins
+	creator, joiner := newPoolMember(store, 0), newPoolMember(store, 0)
+	pool, err := services.CreatePool(ctx, store, creator, settings, now)
+	assert.NoError(t, err)
+	_, err = services.JoinPool(ctx, store, joiner, pool.ID, now)
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+
+	// A tier 1 member can join, but the tier 0 creator could not take the pot
+	assert.NoError(t, store.Users.SetKYCTier(ctx, joiner, models.KYCTier1))
+	_, err = services.JoinPool(ctx, store, joiner, pool.ID, now)
+	assert.NoError(t, err)
+	_, err = services.StartPool(ctx, store, creator, pool.ID, nil, nil, now)
+	assert.ErrorIs(t, err, services.ErrKYCLimitExceeded)
+
+	assert.NoError(t, store.Users.SetKYCTier(ctx, creator, models.KYCTier1))
+	_, err = services.StartPool(ctx, store, creator, pool.ID, nil, nil, now)
+	assert.NoError(t, err)
+}
+
+// newScreenedPoolMember creates a pool member whose password was changed ago,
+// which fraud screening holds or blocks payments for
+func newScreenedPoolMember(t *testing.T, store *repository.Store, savings int64, passwordChangedAgo time.Duration) primitive.ObjectID {
+	changedAt := time.Now().Add(-passwordChangedAgo)
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		PasswordChangedAt: &changedAt,
+		SavingsBalance:    models.NewMoney(savings, "NGN"),
+		InvestmentBalance: models.NewMoney(0, "NGN"),
+		GoalsBalance:      models.NewMoney(0, "NGN"),
+	}
+	assert.NoError(t, store.Users.Create(context.Background(), &user))
+	return user.ID
+}
+
+func TestPoolContributionHeldForReview(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newScreenedPoolMember(t, store, 50000, 5*time.Hour), newPoolMember(store, 50000)
+	pool := startWeeklyPool(t, store, now, b, a)
+
+	// a's contribution is set aside for review, so b is owed it
+	_, shortfalls, err := services.RunDuePools(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, shortfalls)
+	reviews, _ := store.FraudReviews.ListByUser(ctx, a)
+	if assert.Len(t, reviews, 1) {
+		assert.Equal(t, models.ReviewPending, reviews[0].Status)
+		assert.Equal(t, models.HeldPoolContribution, reviews[0].Kind)
+		assert.Equal(t, pool.ID, reviews[0].PoolID)
+	}
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.True(t, pool.Members[1].Arrears.IsZero())
+	assert.Equal(t, int64(10000), pool.Members[0].Owed.Amount)
+	assert.Equal(t, int64(40000), savingsOf(store, a))
+
+	// Once approved the contribution reaches the pool and b is paid in full
+	_, err = services.ApproveFraudReview(ctx, store, primitive.NewObjectID(), reviews[0].ID, "", now)
+	assert.NoError(t, err)
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, int64(10000), pool.Held.Amount)
+	_, _, err = services.RunDuePools(ctx, store, now.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, models.PoolCompleted, pool.Status)
+	assert.True(t, pool.Held.IsZero())
+	assert.Equal(t, int64(50000), savingsOf(store, a))
+	assert.Equal(t, int64(50000), savingsOf(store, b))
+}
+
+func TestRejectedPoolContributionIsOwed(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newScreenedPoolMember(t, store, 50000, 5*time.Hour), newPoolMember(store, 50000)
+	pool := startWeeklyPool(t, store, now, b, a)
+
+	_, _, err := services.RunDuePools(ctx, store, now)
+	assert.NoError(t, err)
+	reviews, _ := store.FraudReviews.ListByUser(ctx, a)
+	assert.Len(t, reviews, 1)
+	_, err = services.RejectFraudReview(ctx, store, primitive.NewObjectID(), reviews[0].ID, "Not the user", now)
+	assert.NoError(t, err)
+
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, int64(10000), pool.Members[1].Arrears.Amount)
+	assert.Equal(t, int64(50000), savingsOf(store, a))
+}
+
+func TestPoolContributionBlocked(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	a, b := newScreenedPoolMember(t, store, 50000, 10*time.Minute), newPoolMember(store, 50000)
+	pool := startWeeklyPool(t, store, now, b, a)
+
+	// Nothing leaves a's savings; the contribution is carried as arrears
+	_, shortfalls, err := services.RunDuePools(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, shortfalls)
+	assert.Equal(t, int64(50000), savingsOf(store, a))
+	pool, _ = store.Pools.GetByID(ctx, pool.ID)
+	assert.Equal(t, int64(10000), pool.Members[1].Arrears.Amount)
+	assert.Equal(t, 1, pool.Members[1].MissedContributions)
+
+	reviews, _ := store.FraudReviews.ListByUser(ctx, a)
+	if assert.Len(t, reviews, 1) {
+		assert.Equal(t, models.ReviewBlocked, reviews[0].Status)
+		assert.Equal(t, models.HeldPoolContribution, reviews[0].Kind)
+	}
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUpdateProfile(t *testing.T) {
+	user := createLoginUser(t)
+
+	w := postJSON(handlers.UpdateProfile(testStore), user.ID, `{
+		"name": "Ada Obi",
+		"phone": "+234 801 234 5678",
+		"date_of_birth": "1990-04-01",
+		"address": {"line1": "1 Marina", "city": "Lagos", "country": "ng"}
+	}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	updated := getTestUser(user.ID)
+	assert.Equal(t, "Ada Obi", updated.Name)
+	assert.Equal(t, "+2348012345678", updated.Phone)
+	assert.Equal(t, "1990-04-01", updated.DateOfBirth)
+	assert.Equal(t, "NG", updated.Address.Country)
+
+	// Fields left out are kept
+	w = postJSON(handlers.UpdateProfile(testStore), user.ID, `{"name": "Ada O."}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "+2348012345678", getTestUser(user.ID).Phone)
+
+	tooYoung := time.Now().AddDate(-models.MinimumAge+1, 0, 0).Format(models.DateOfBirthLayout)
+	for _, body := range []string{
+		`{"name": " "}`,
+		`{"phone": "call me"}`,
+		`{"date_of_birth": "01/04/1990"}`,
+		`{"date_of_birth": "` + tooYoung + `"}`,
+		`{"address": {"line1": "1 Marina", "country": "NG"}}`,
+	} {
+		w = postJSON(handlers.UpdateProfile(testStore), user.ID, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+	old, _ := services.StartSession(ctx, testStore, user.ID, "", "", time.Now())
+
+	_, err := services.ChangePassword(ctx, testStore, user.ID, "wrong", "a-new-password", "", "", time.Now())
+	assert.ErrorIs(t, err, services.ErrWrongPassword)
+	_, err = services.ChangePassword(ctx, testStore, user.ID, "a-password", "short", "", "", time.Now())
+	assert.ErrorIs(t, err, services.ErrWeakPassword)
+
+	tokens, err := services.ChangePassword(ctx, testStore, user.ID, "a-password", "a-new-password", "", "", time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(getTestUser(user.ID).PasswordHash), []byte("a-new-password")))
+
+	// Other devices are signed out; the one that made the change gets a new session
+	_, err = services.CheckSession(ctx, testStore, user.ID.Hex(), sessionIDOf(t, old))
+	assert.ErrorIs(t, err, services.ErrSessionRevoked)
+	_, err = services.CheckSession(ctx, testStore, user.ID.Hex(), sessionIDOf(t, tokens))
+	assert.NoError(t, err)
+	waitForEmail(t, user.Email, "Your password was changed")
+}
+
+func TestChangeEmail(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+	other := createLoginUser(t)
+	newEmail := primitive.NewObjectID().Hex() + "@example.com"
+
+	assert.ErrorIs(t, services.RequestEmailChange(ctx, testStore, user.ID, "a-password", other.Email, time.Now()), services.ErrEmailTaken)
+	assert.ErrorIs(t, services.RequestEmailChange(ctx, testStore, user.ID, "wrong", newEmail, time.Now()), services.ErrWrongPassword)
+
+	w := postJSON(handlers.ChangeEmail(testStore), user.ID, `{"email": "`+newEmail+`", "password": "a-password"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := emailedToken(waitForEmail(t, newEmail, "Confirm your new email address"))
+
+	// Nothing changes until the new address is confirmed
+	assert.Equal(t, user.Email, getTestUser(user.ID).Email)
+
+	w = postJSON(handlers.ConfirmEmailChange(testStore), primitive.NilObjectID, `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	updated := getTestUser(user.ID)
+	assert.Equal(t, newEmail, updated.Email)
+	assert.True(t, updated.IsEmailVerified())
+	waitForEmail(t, user.Email, "Your email address was changed")
+
+	w = postJSON(handlers.ConfirmEmailChange(testStore), primitive.NilObjectID, `{"token": "`+token+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCloseAccount(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+	session, _ := services.StartSession(ctx, testStore, user.ID, "", "", time.Now())
+
+	// Refused while there is money saved
+	funded := setupUserForTransaction()
+	hash, _ := bcrypt.GenerateFromPassword([]byte("a-password"), bcrypt.MinCost)
+	assert.NoError(t, testStore.Users.SetPasswordHash(ctx, funded, string(hash)))
+	w := postJSON(handlers.CloseAccount(testStore), funded, `{"password": "a-password"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.False(t, getTestUser(funded).IsClosed())
+
+	w = postJSON(handlers.CloseAccount(testStore), user.ID, `{"password": "a-password", "reason": "Moving abroad"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	closed := getTestUser(user.ID)
+	assert.True(t, closed.IsClosed())
+	assert.Equal(t, "Moving abroad", closed.ClosureReason)
+
+	// Logged out, can't log back in, and can't receive transfers
+	_, err := services.CheckSession(ctx, testStore, user.ID.Hex(), sessionIDOf(t, session))
+	assert.ErrorIs(t, err, services.ErrSessionRevoked)
+	assert.Equal(t, http.StatusForbidden, sendLogin(user.Email, "a-password", "198.51.100.4").Code)
+	sender := getTestUser(funded)
+	_, err = services.Transfer(ctx, testStore, &sender, &closed, primitive.NilObjectID, models.NewMoney(100, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrAccountClosed)
+}
+
+func TestCloseAccountWithAccruedInterest(t *testing.T) {
+	ctx := context.Background()
+	user := createLoginUser(t)
+
+	// A whole kobo of interest is still to be paid
+	accrual := models.InterestAccrual{Accrued: models.InterestPrecision, AccruedThrough: time.Now()}
+	assert.NoError(t, testStore.Users.SetInterestAccrual(ctx, user.ID, accrual))
+	err := services.CloseAccount(ctx, testStore, user.ID, "a-password", "", time.Now())
+	assert.ErrorIs(t, err, services.ErrInterestPending)
+	assert.False(t, getTestUser(user.ID).IsClosed())
+
+	// Less than that can never be paid, so it is written off
+	accrual.Accrued = models.InterestPrecision - 1
+	assert.NoError(t, testStore.Users.SetInterestAccrual(ctx, user.ID, accrual))
+	assert.NoError(t, services.CloseAccount(ctx, testStore, user.ID, "a-password", "", time.Now()))
+	assert.Zero(t, getTestUser(user.ID).Interest.Accrued)
+}
+
+func TestTransferToAccountClosedAfterLookup(t *testing.T) {
+	ctx := context.Background()
+	recipient := createLoginUser(t)
+	sender := getTestUser(setupUserForTransaction())
+
+	// The recipient was looked up while still open
+	open := getTestUser(recipient.ID)
+	assert.NoError(t, services.CloseAccount(ctx, testStore, recipient.ID, "a-password", "", time.Now()))
+	_, err := services.Transfer(ctx, testStore, &sender, &open, primitive.NilObjectID, models.NewMoney(100, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrAccountClosed)
+	assert.True(t, getTestUser(recipient.ID).SavingsBalance.IsZero())
+}
+
+func TestGetUserShowsOwnDetailsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	user := createLoginUser(t)
+	phone := "+2348012345678"
+	_, err := services.UpdateProfile(context.Background(), testStore, user.ID, services.ProfileChanges{Phone: &phone}, time.Now())
+	assert.NoError(t, err)
+
+	get := func(callerID, userID primitive.ObjectID) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodGet, "/user?user_id="+userID.Hex(), nil)
+		c.Set("user_id", callerID.Hex()) // Simulate authentication
+		handlers.GetUserByID(testStore)(c)
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, get(setupUserForTransaction(), user.ID).Code)
+	w := get(user.ID, user.ID)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), phone)
+	assert.NotContains(t, w.Body.String(), "PasswordHash")
+
+	// Anywhere else a user is written out, personal details are left out
+	body, _ := json.Marshal(getTestUser(user.ID))
+	assert.NotContains(t, string(body), phone)
+	assert.NotContains(t, string(body), "PasswordHash")
+}
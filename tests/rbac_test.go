@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupAdminRouter serves a few admin routes the way main does
+func setupAdminRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	admin := router.Group("/admin")
+	admin.Use(middlewares.AuthMiddleware(testStore), middlewares.AdminAuthMiddleware(testStore), middlewares.RequireStepUp())
+	admin.GET("/dashboard", middlewares.RequirePermission(models.PermUsersRead), handlers.AdminDashboard(testStore))
+	admin.GET("/reconcile/:user_id", middlewares.RequirePermission(models.PermLedgerRead), handlers.AdminReconcileUser(testStore))
+	admin.PUT("/users/:user_id/roles", middlewares.RequirePermission(models.PermAdminsManage), handlers.AdminSetUserRoles(testStore))
+	return router
+}
+
+// signInStaff creates a user with the given roles and two-factor on, and
+// returns their ID and a stepped-up access token
+func signInStaff(t *testing.T, roles ...string) (primitive.ObjectID, string) {
+	user := models.User{
+		Email:          primitive.NewObjectID().Hex() + "@example.com",
+		SavingsBalance: models.NewMoney(0, "NGN"),
+		Roles:          roles,
+		MFA:            &models.MFASettings{Secret: testTOTPSecret, Enabled: true},
+	}
+	assert.NoError(t, testStore.Users.Create(context.Background(), &user))
+	return user.ID, signInSteppedUp(t, user.ID)
+}
+
+// signInSteppedUp starts a session that entered a two-factor code just now
+// and returns its access token
+func signInSteppedUp(t *testing.T, userID primitive.ObjectID) string {
+	tokens, err := services.StartSession(context.Background(), testStore, userID, "", "", time.Now())
+	assert.NoError(t, err)
+	sessionID, err := primitive.ObjectIDFromHex(sessionIDOf(t, tokens))
+	assert.NoError(t, err)
+	assert.NoError(t, testStore.Sessions.SetMFAVerified(context.Background(), sessionID, time.Now()))
+	return tokens.AccessToken
+}
+
+func sendAdminRequest(router *gin.Engine, method, path, accessToken, body string) int {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRolePermissions(t *testing.T) {
+	assert.True(t, models.HasPermission([]string{models.RoleSupport}, models.PermUsersRead))
+	assert.False(t, models.HasPermission([]string{models.RoleSupport}, models.PermBalancesAdjust))
+	assert.True(t, models.HasPermission([]string{models.RoleSupport, models.RoleFinance}, models.PermBalancesAdjust))
+	assert.False(t, models.HasPermission(nil, models.PermUsersRead))
+	assert.False(t, models.HasPermission([]string{models.RoleFinance}, models.PermAdminsManage))
+	assert.True(t, models.HasPermission([]string{models.RoleSuperAdmin}, models.PermAdminsManage))
+}
+
+func TestRequirePermission(t *testing.T) {
+	router := setupAdminRouter()
+	customerID := setupUserForTransaction()
+	_, supportToken := signInStaff(t, models.RoleSupport)
+	_, customerToken := signInStaff(t)
+
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", supportToken, ""))
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodGet, "/admin/reconcile/"+customerID.Hex(), supportToken, ""))
+	assert.NotEqual(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", customerToken, ""))
+}
+
+func TestAssignRoles(t *testing.T) {
+	router := setupAdminRouter()
+	customerID := setupUserForTransaction()
+	superID, superToken := signInStaff(t, models.RoleSuperAdmin)
+	staffID, staffToken := signInStaff(t, models.RoleSupport)
+	path := "/admin/users/" + staffID.Hex() + "/roles"
+
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodPut, path, staffToken, `{"roles": ["finance"]}`))
+	assert.Equal(t, http.StatusBadRequest, sendAdminRequest(router, http.MethodPut, path, superToken, `{"roles": ["janitor"]}`))
+	assert.Equal(t, http.StatusForbidden, sendAdminRequest(router, http.MethodPut, "/admin/users/"+superID.Hex()+"/roles", superToken, `{"roles": []}`))
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodPut, path, superToken, `{"roles": ["finance", "finance"]}`))
+
+	staff := getTestUser(staffID)
+	assert.Equal(t, []string{models.RoleFinance}, staff.Roles)
+
+	// The old token carried the old roles, so it stops working
+	assert.Equal(t, http.StatusUnauthorized, sendAdminRequest(router, http.MethodGet, "/admin/dashboard", staffToken, ""))
+	assert.Equal(t, http.StatusOK, sendAdminRequest(router, http.MethodGet, "/admin/reconcile/"+customerID.Hex(), signInSteppedUp(t, staffID), ""))
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/ledger"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// setupInvestedUser creates a user with 1000.00 in savings and 1000.00 invested
+func setupInvestedUser(tier string) primitive.ObjectID {
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		SavingsBalance:    models.NewMoney(100000, "NGN"),
+		InvestmentBalance: models.NewMoney(100000, "NGN"),
+		ProductTier:       tier,
+	}
+	_ = testStore.Users.Create(context.Background(), &user)
+	return user.ID
+}
+
+func performRedeem(userID primitive.ObjectID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/user/investments/redeem", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", userID.Hex()) // Simulate authentication
+
+	handlers.RedeemInvestment(testStore)(c)
+	return w
+}
+
+func TestRedeemWithoutNoticeSettlesImmediately(t *testing.T) {
+	userID := setupInvestedUser(models.DefaultProductTier)
+
+	w := performRedeem(userID, `{"amount": "400.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	user := getTestUser(userID)
+	assert.Equal(t, int64(60000), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(140000), user.SavingsBalance.Amount)
+
+	rows, _ := testStore.Transactions.List(context.Background(),
+		repository.TransactionFilter{UserID: userID, Type: models.Redemption})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, int64(40000), rows[0].Amount.Amount)
+		assert.Equal(t, int64(140000), rows[0].BalanceAfter.Amount)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, performRedeem(userID, `{"amount": "600.01"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, performRedeem(userID, `{"amount": "1.00", "full": true}`).Code)
+}
+
+func TestRedeemWithNoticeIsScheduled(t *testing.T) {
+	userID := setupInvestedUser("premium") // 30 days notice
+
+	w := performRedeem(userID, `{"full": true}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, http.StatusConflict, performRedeem(userID, `{"amount": "10.00"}`).Code)
+
+	// Nothing moves until the notice period has passed
+	_, _, _ = services.SettleDueRedemptions(context.Background(), testStore, time.Now().AddDate(0, 0, 29))
+	assert.Equal(t, int64(100000), getTestUser(userID).InvestmentBalance.Amount)
+
+	_, _, err := services.SettleDueRedemptions(context.Background(), testStore, time.Now().AddDate(0, 0, 31))
+	assert.NoError(t, err)
+	user := getTestUser(userID)
+	assert.Equal(t, int64(0), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(200000), user.SavingsBalance.Amount)
+
+	redemptions, _ := testStore.Redemptions.ListByUser(context.Background(), userID)
+	if assert.Len(t, redemptions, 1) {
+		assert.Equal(t, models.RedemptionSettled, redemptions[0].Status)
+		assert.Equal(t, int64(100000), redemptions[0].Amount.Amount)
+	}
+}
+
+func TestEarlyRedemptionPaysPenalty(t *testing.T) {
+	userID := setupInvestedUser("premium") // 2% early redemption penalty
+
+	w := performRedeem(userID, `{"amount": "500.00", "early": true}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	user := getTestUser(userID)
+	assert.Equal(t, int64(50000), user.InvestmentBalance.Amount)
+	assert.Equal(t, int64(149000), user.SavingsBalance.Amount)
+
+	// The penalty is booked as fee income
+	_, credits, err := testStore.Ledger.LineTotals(context.Background(), ledger.FeeIncomeAccount, "NGN")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), credits)
+}
+
+func TestSettleDueRedemptionsRetriesTransientFailures(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	now := time.Now()
+	due := func(userID primitive.ObjectID) *models.RedemptionRequest {
+		redemption := &models.RedemptionRequest{
+			UserID:      userID,
+			Full:        true,
+			Amount:      models.NewMoney(0, "NGN"),
+			Penalty:     models.NewMoney(0, "NGN"),
+			Status:      models.RedemptionPending,
+			RequestedAt: now,
+			SettleAt:    now,
+		}
+		assert.NoError(t, store.Redemptions.Create(ctx, redemption))
+		return redemption
+	}
+
+	// Nothing is left invested, which will never change
+	emptied := newScheduleUser(store)
+	due(emptied)
+	// The user can't be loaded, which may be a passing fault
+	due(primitive.NewObjectID())
+
+	settled, failed, err := services.SettleDueRedemptions(ctx, store, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, settled)
+	assert.Equal(t, 1, failed)
+
+	redemptions, _ := store.Redemptions.ListByUser(ctx, emptied)
+	if assert.Len(t, redemptions, 1) {
+		assert.Equal(t, models.RedemptionFailed, redemptions[0].Status)
+	}
+	pending, _ := store.Redemptions.ListDue(ctx, now.Add(time.Minute))
+	assert.Len(t, pending, 1)
+}
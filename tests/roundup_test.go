@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRoundUpRule(t *testing.T) {
+	rule := models.RoundUpRule{Nearest: 100, Multiplier: 1}
+	assert.NoError(t, rule.Validate())
+	assert.Equal(t, int64(6550), rule.RoundUpFor(models.NewMoney(123450, "NGN")).Amount)
+	assert.True(t, rule.RoundUpFor(models.NewMoney(120000, "NGN")).IsZero())
+
+	rule.Multiplier = 2
+	assert.Equal(t, int64(13100), rule.RoundUpFor(models.NewMoney(123450, "NGN")).Amount)
+
+	rule.Nearest, rule.Multiplier = 1000, 1
+	assert.Equal(t, int64(76550), rule.RoundUpFor(models.NewMoney(123450, "NGN")).Amount)
+
+	dailyCap := models.NewMoney(50000, "NGN")
+	rule.DailyCap = &dailyCap
+	assert.Equal(t, int64(50000), rule.Capped(models.NewMoney(76550, "NGN")).Amount)
+	assert.Equal(t, int64(100), rule.Capped(models.NewMoney(100, "NGN")).Amount)
+
+	assert.Error(t, models.RoundUpRule{Nearest: 50, Multiplier: 1}.Validate())
+	assert.Error(t, models.RoundUpRule{Nearest: 100, Multiplier: 11}.Validate())
+}
+
+func TestCardPurchaseWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("CARD_WEBHOOK_SECRET", "test-webhook-secret")
+	router := gin.New()
+	router.POST("/webhooks/card-purchases", middlewares.WebhookSignatureMiddleware("CARD_WEBHOOK_SECRET"), handlers.CardPurchaseWebhook(testStore))
+
+	send := func(body, signature string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/webhooks/card-purchases", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(middlewares.WebhookSignatureHeader, signature)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	sign := func(body string) string {
+		return hex.EncodeToString(middlewares.SignWebhook("test-webhook-secret", []byte(body)))
+	}
+
+	userID := setupUserForTransaction()
+	body := `{"event_id": "` + primitive.NewObjectID().Hex() + `", "user_id": "` + userID.Hex() + `", "amount": "1234.50", "merchant": "Shoprite"}`
+
+	// Round-ups are off until the user sets a rule
+	assert.Equal(t, http.StatusUnauthorized, send(body, sign(body+" ")).Code)
+	w := send(body, sign(body))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "ignored")
+
+	assert.NoError(t, services.SetRoundUpRule(context.Background(), testStore, userID, &models.RoundUpRule{Nearest: 100, Multiplier: 1}, time.Now()))
+	assert.Equal(t, http.StatusCreated, send(body, sign(body)).Code)
+	w = send(body, sign(body))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "duplicate")
+
+	pending, _ := testStore.RoundUps.ListByUser(context.Background(), userID, models.RoundUpPending)
+	if assert.Len(t, pending, 1) {
+		assert.Equal(t, int64(6550), pending[0].Amount.Amount)
+	}
+	// Nothing reaches savings until the daily batch
+	assert.Equal(t, int64(100000), getTestUser(userID).SavingsBalance.Amount)
+}
+
+func TestPostRoundUpsBatchesPerDayWithinCap(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+	dailyCap := models.NewMoney(10000, "NGN")
+	assert.NoError(t, services.SetRoundUpRule(ctx, store, userID, &models.RoundUpRule{Nearest: 100, Multiplier: 1, DailyCap: &dailyCap}, time.Now()))
+
+	day := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	record := func(amount int64, at time.Time) {
+		_, err := services.RecordPurchase(ctx, store, services.CardPurchase{
+			EventID:     primitive.NewObjectID().Hex(),
+			UserID:      userID,
+			Amount:      models.NewMoney(amount, "NGN"),
+			PurchasedAt: at,
+		}, at)
+		assert.NoError(t, err)
+	}
+	record(123450, day)                 // 65.50
+	record(97000, day.Add(time.Hour))   // 30.00
+	record(58000, day.Add(2*time.Hour)) // 20.00
+	record(9950, day.AddDate(0, 0, 1))  // 0.50, the next day
+
+	// Today's round-ups wait for tomorrow
+	posted, err := services.PostRoundUps(ctx, store, day.Add(12*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, posted)
+
+	posted, err = services.PostRoundUps(ctx, store, day.AddDate(0, 0, 1).Add(6*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, posted)
+
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(10000), user.SavingsBalance.Amount) // 115.50 capped at 100.00
+
+	rows, _ := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.RoundUp})
+	if assert.Len(t, rows, 1) {
+		assert.Equal(t, int64(10000), rows[0].Amount.Amount)
+	}
+
+	posted, _ = services.PostRoundUps(ctx, store, day.AddDate(0, 0, 1).Add(7*time.Hour))
+	assert.Equal(t, 0, posted)
+	posted, _ = services.PostRoundUps(ctx, store, day.AddDate(0, 0, 2))
+	assert.Equal(t, 1, posted)
+	user, _ = store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(10050), user.SavingsBalance.Amount)
+}
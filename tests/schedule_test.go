@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newScheduleUser(store *repository.Store) primitive.ObjectID {
+	user := models.User{
+		Email:             primitive.NewObjectID().Hex() + "@example.com",
+		SavingsBalance:    models.NewMoney(0, "NGN"),
+		InvestmentBalance: models.NewMoney(0, "NGN"),
+		GoalsBalance:      models.NewMoney(0, "NGN"),
+	}
+	_ = store.Users.Create(context.Background(), &user)
+	return user.ID
+}
+
+func TestScheduleOccurrences(t *testing.T) {
+	monthly := models.DepositSchedule{
+		Frequency: models.FrequencyMonthly,
+		StartAt:   time.Date(2026, 1, 31, 9, 0, 0, 0, time.UTC),
+	}
+	assert.Equal(t, time.Date(2026, 2, 28, 9, 0, 0, 0, time.UTC), monthly.OccurrenceAt(1))
+	assert.Equal(t, time.Date(2026, 3, 31, 9, 0, 0, 0, time.UTC), monthly.OccurrenceAt(2))
+	assert.Equal(t, time.Date(2027, 1, 31, 9, 0, 0, 0, time.UTC), monthly.OccurrenceAt(12))
+
+	weekly := models.DepositSchedule{Frequency: models.FrequencyWeekly, StartAt: monthly.StartAt}
+	assert.Equal(t, time.Date(2026, 2, 7, 9, 0, 0, 0, time.UTC), weekly.OccurrenceAt(1))
+	assert.Equal(t, 2, weekly.FirstOccurrenceFrom(time.Date(2026, 2, 7, 9, 0, 1, 0, time.UTC)))
+
+	end := time.Date(2026, 2, 14, 9, 0, 0, 0, time.UTC)
+	weekly.EndAt = &end
+	assert.False(t, weekly.HasEnded(2))
+	assert.True(t, weekly.HasEnded(3))
+}
+
+func TestScheduledDepositsRunOncePerOccurrence(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+	now := time.Now()
+
+	daily, amount := models.FrequencyDaily, models.NewMoney(50000, "NGN")
+	schedule, err := services.CreateSchedule(ctx, store, userID, services.ScheduleChanges{Frequency: &daily, Amount: &amount}, now)
+	assert.NoError(t, err)
+
+	succeeded, failed, err := services.RunDueSchedules(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, succeeded)
+	assert.Equal(t, 0, failed)
+
+	// Nothing more is due until tomorrow
+	succeeded, _, _ = services.RunDueSchedules(ctx, store, now.Add(time.Hour))
+	assert.Equal(t, 0, succeeded)
+	succeeded, _, _ = services.RunDueSchedules(ctx, store, now.AddDate(0, 0, 1))
+	assert.Equal(t, 1, succeeded)
+
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.Equal(t, int64(100000), user.SavingsBalance.Amount)
+
+	rows, _ := store.Transactions.List(ctx, repository.TransactionFilter{UserID: userID, Type: models.Deposit})
+	assert.Len(t, rows, 2)
+
+	runs, _ := store.Schedules.ListRuns(ctx, schedule.ID, 0)
+	if assert.Len(t, runs, 2) {
+		assert.Equal(t, models.RunSucceeded, runs[0].Status)
+		assert.Equal(t, 1, runs[0].Occurrence)
+		assert.False(t, runs[0].TransactionID.IsZero())
+	}
+}
+
+func TestScheduledDepositIntoGoal(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+	now := time.Now()
+
+	name, target := "School fees", models.NewMoney(1000000, "NGN")
+	goal, _ := services.CreateGoal(ctx, store, userID, services.GoalChanges{Name: &name, TargetAmount: &target}, now)
+
+	weekly, amount := models.FrequencyWeekly, models.NewMoney(25000, "NGN")
+	_, err := services.CreateSchedule(ctx, store, userID, services.ScheduleChanges{Frequency: &weekly, Amount: &amount, GoalID: &goal.ID}, now)
+	assert.NoError(t, err)
+
+	_, _, err = services.RunDueSchedules(ctx, store, now)
+	assert.NoError(t, err)
+
+	updated, _ := store.Goals.GetByID(ctx, userID, goal.ID)
+	assert.Equal(t, int64(25000), updated.SavedAmount.Amount)
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.True(t, user.SavingsBalance.IsZero())
+	assert.Equal(t, int64(25000), user.GoalsBalance.Amount)
+}
+
+func TestFailingScheduleRetriesThenPauses(t *testing.T) {
+	store := repository.NewMemoryStore()
+	ctx := context.Background()
+	userID := newScheduleUser(store)
+	now := time.Now()
+
+	charger := services.ScheduledDepositCharger
+	services.ScheduledDepositCharger = func(context.Context, models.DepositSchedule, string) error {
+		return errors.New("card declined")
+	}
+	defer func() { services.ScheduledDepositCharger = charger }()
+
+	monthly, amount := models.FrequencyMonthly, models.NewMoney(10000, "NGN")
+	schedule, _ := services.CreateSchedule(ctx, store, userID, services.ScheduleChanges{Frequency: &monthly, Amount: &amount}, now)
+
+	// Retries wait one hour, then two
+	for _, at := range []time.Time{now, now.Add(time.Hour), now.Add(3 * time.Hour)} {
+		_, failed, err := services.RunDueSchedules(ctx, store, at)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, failed)
+	}
+
+	paused, _ := store.Schedules.GetByID(ctx, userID, schedule.ID)
+	assert.Equal(t, models.SchedulePaused, paused.Status)
+	assert.Equal(t, models.MaxScheduleFailures, paused.ConsecutiveFailures)
+
+	runs, _ := store.Schedules.ListRuns(ctx, schedule.ID, 0)
+	if assert.Len(t, runs, 3) {
+		assert.Equal(t, 3, runs[0].Attempt)
+		assert.Equal(t, "card declined", runs[0].Error)
+	}
+	user, _ := store.Users.GetByID(ctx, userID)
+	assert.True(t, user.SavingsBalance.IsZero())
+
+	// Resuming starts again from the next run due
+	active := models.ScheduleActive
+	resumed, err := services.UpdateSchedule(ctx, store, userID, schedule.ID, services.ScheduleChanges{Status: &active}, now.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, resumed.ConsecutiveFailures)
+	assert.Equal(t, 1, resumed.Occurrence)
+}
+
+func TestCreateScheduleValidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := setupUserForTransaction()
+
+	create := func(body string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request, _ = http.NewRequest(http.MethodPost, "/user/schedules", bytes.NewBufferString(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+		handlers.CreateSchedule(testStore)(c)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, create(`{"frequency": "weekly", "amount": "100.00"}`))
+	assert.Equal(t, http.StatusBadRequest, create(`{"frequency": "hourly", "amount": "100.00"}`))
+	assert.Equal(t, http.StatusBadRequest, create(`{"frequency": "daily", "amount": "0"}`))
+	assert.Equal(t, http.StatusBadRequest, create(`{"frequency": "daily", "amount": "100.00", "end_at": "2020-01-01"}`))
+	assert.Equal(t, http.StatusNotFound, create(`{"frequency": "daily", "amount": "100.00", "goal_id": "`+primitive.NewObjectID().Hex()+`"}`))
+}
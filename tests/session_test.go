@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/middlewares"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupSessionRouter serves the session routes the way main does
+func setupSessionRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/user/refresh", handlers.RefreshToken(testStore))
+	protected := router.Group("/user")
+	protected.Use(middlewares.AuthMiddleware(testStore))
+	protected.POST("/logout", handlers.Logout(testStore))
+	protected.POST("/logout-all", handlers.LogoutAll(testStore))
+	protected.GET("/transactions", handlers.GetTransactions(testStore))
+	return router
+}
+
+func sendSessionRequest(router *gin.Engine, path, accessToken, body string) (*httptest.ResponseRecorder, services.TokenPair) {
+	method := http.MethodPost
+	if path == "/user/transactions" {
+		method = http.MethodGet
+	}
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	router.ServeHTTP(w, req)
+
+	var tokens services.TokenPair
+	_ = json.Unmarshal(w.Body.Bytes(), &tokens)
+	return w, tokens
+}
+
+func TestRefreshTokenRotationAndReuse(t *testing.T) {
+	router := setupSessionRouter()
+	userID := setupUserForTransaction()
+	login, err := services.StartSession(context.Background(), testStore, userID, "test", "127.0.0.1", time.Now())
+	assert.NoError(t, err)
+
+	w, _ := sendSessionRequest(router, "/user/transactions", login.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w, refreshed := sendSessionRequest(router, "/user/refresh", "", `{"refresh_token": "`+login.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEmpty(t, refreshed.AccessToken)
+	assert.NotEqual(t, login.RefreshToken, refreshed.RefreshToken)
+
+	// Presenting the old token again kills the whole family
+	w, _ = sendSessionRequest(router, "/user/refresh", "", `{"refresh_token": "`+login.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = sendSessionRequest(router, "/user/refresh", "", `{"refresh_token": "`+refreshed.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = sendSessionRequest(router, "/user/transactions", refreshed.AccessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w, _ = sendSessionRequest(router, "/user/refresh", "", `{"refresh_token": "not-a-token"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRefreshTokenExpires(t *testing.T) {
+	ctx := context.Background()
+	userID := setupUserForTransaction()
+	issued := time.Now()
+	login, _ := services.StartSession(ctx, testStore, userID, "", "", issued)
+
+	_, err := services.RefreshSession(ctx, testStore, login.RefreshToken, issued.Add(services.RefreshTokenTTL))
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+func TestLogoutEndsSessions(t *testing.T) {
+	router := setupSessionRouter()
+	ctx := context.Background()
+	userID := setupUserForTransaction()
+	phone, _ := services.StartSession(ctx, testStore, userID, "phone", "", time.Now())
+	laptop, _ := services.StartSession(ctx, testStore, userID, "laptop", "", time.Now())
+	tablet, _ := services.StartSession(ctx, testStore, userID, "tablet", "", time.Now())
+
+	w, _ := sendSessionRequest(router, "/user/logout", phone.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = sendSessionRequest(router, "/user/transactions", phone.AccessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w, _ = sendSessionRequest(router, "/user/refresh", "", `{"refresh_token": "`+phone.RefreshToken+`"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// The other devices are still signed in until they are all logged out
+	w, _ = sendSessionRequest(router, "/user/transactions", laptop.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w, _ = sendSessionRequest(router, "/user/logout-all", laptop.AccessToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"sessions_ended":2`)
+	w, _ = sendSessionRequest(router, "/user/transactions", tablet.AccessToken, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	sessions, _ := testStore.Sessions.RevokeAllForUser(ctx, userID, models.SessionLoggedOutAll, time.Now())
+	assert.Equal(t, int64(0), sessions)
+}
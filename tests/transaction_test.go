@@ -5,26 +5,39 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"micro-savings-app/handlers"
-	"micro-savings-app/database"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func setupUserForTransaction() primitive.ObjectID {
-	// Insert a test user with a balance
-	userCollection := database.GetTestCollection("users")
-	user := bson.M{
-		"email":           "test@example.com",
-		"savings_balance": 1000.0,
+	// Insert a verified test user with a balance
+	verifiedAt := time.Now()
+	user := models.User{
+		Email:           primitive.NewObjectID().Hex() + "@example.com",
+		EmailVerifiedAt: &verifiedAt,
+		SavingsBalance:  models.NewMoney(100000, "NGN"),
 	}
-	res, _ := userCollection.InsertOne(context.Background(), user)
-	return res.InsertedID.(primitive.ObjectID)
+	_ = testStore.Users.Create(context.Background(), &user)
+	return user.ID
+}
+
+// getTestUser reads a user back from the test store
+func getTestUser(userID primitive.ObjectID) models.User {
+	user, err := testStore.Users.GetByID(context.Background(), userID)
+	if err != nil {
+		return models.User{}
+	}
+	return *user
 }
 
 func TestDeposit(t *testing.T) {
@@ -34,19 +47,17 @@ func TestDeposit(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	requestBody := `{"amount": 500}`
+	requestBody := `{"amount": "500.00"}`
 	c.Request, _ = http.NewRequest(http.MethodPost, "/transactions/deposit", bytes.NewBuffer([]byte(requestBody)))
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("user_id", userID.Hex()) // Simulate authentication
 
-	handlers.Deposit(c)
+	handlers.Deposit(testStore)(c)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var user map[string]interface{}
-	userCollection := database.GetTestCollection("users")
-	_ = userCollection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
-	assert.Equal(t, 1500.0, user["savings_balance"])
+	user := getTestUser(userID)
+	assert.Equal(t, int64(150000), user.SavingsBalance.Amount)
 }
 
 func TestWithdraw(t *testing.T) {
@@ -56,17 +67,59 @@ func TestWithdraw(t *testing.T) {
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
-	requestBody := `{"amount": 500}`
+	requestBody := `{"amount": "500.00"}`
 	c.Request, _ = http.NewRequest("POST", "/transactions/withdraw", bytes.NewBuffer([]byte(requestBody)))
 	c.Request.Header.Set("Content-Type", "application/json")
 	c.Set("user_id", userID.Hex()) // Simulate authentication
 
-	handlers.Withdraw(c)
+	handlers.Withdraw(testStore)(c)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var user map[string]interface{}
-	userCollection := database.GetTestCollection("users")
-	_ = userCollection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
-	assert.Equal(t, 500.0, user["savings_balance"])
+	user := getTestUser(userID)
+	assert.Equal(t, int64(50000), user.SavingsBalance.Amount)
+}
+
+func TestConcurrentWithdrawalsNeverOverdraw(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	userID := setupUserForTransaction() // balance of 1000.00
+
+	// 300 withdrawals of 10.00 race for a balance that only covers 100 of them.
+	// The memory store runs them one at a time; TestMongoConcurrentWithdrawalsNeverOverdraw
+	// races them for real.
+	const attempts = 300
+	var wg sync.WaitGroup
+	var succeeded, rejected int64
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request, _ = http.NewRequest(http.MethodPost, "/user/withdraw", bytes.NewBufferString(`{"amount": "10.00"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Set("user_id", userID.Hex())
+
+			handlers.Withdraw(testStore)(c)
+
+			switch w.Code {
+			case http.StatusOK:
+				atomic.AddInt64(&succeeded, 1)
+			case http.StatusBadRequest:
+				atomic.AddInt64(&rejected, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), succeeded)
+	assert.Equal(t, int64(attempts-100), rejected)
+
+	user := getTestUser(userID)
+	assert.Equal(t, int64(0), user.SavingsBalance.Amount)
+
+	// Every successful withdrawal left exactly one ledger row
+	rows, _ := testStore.Transactions.List(context.Background(),
+		repository.TransactionFilter{UserID: userID, Type: models.Withdrawal})
+	assert.Len(t, rows, 100)
 }
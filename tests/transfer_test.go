@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func performTransfer(senderID primitive.ObjectID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/user/transfer", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set("user_id", senderID.Hex()) // Simulate authentication
+
+	handlers.Transfer(testStore)(c)
+	return w
+}
+
+func TestTransfer(t *testing.T) {
+	senderID := setupUserForTransaction() // balance of 1000.00
+	recipientID := setupUserForTransaction()
+
+	w := performTransfer(senderID, `{"recipient": "`+recipientID.Hex()+`", "amount": "250.00", "note": "lunch"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	sender, recipient := getTestUser(senderID), getTestUser(recipientID)
+	assert.Equal(t, int64(75000), sender.SavingsBalance.Amount)
+	assert.Equal(t, int64(125000), recipient.SavingsBalance.Amount)
+
+	// Both legs share one reference
+	var legs []models.Transaction
+	for _, userID := range []primitive.ObjectID{senderID, recipientID} {
+		rows, _ := testStore.Transactions.List(context.Background(),
+			repository.TransactionFilter{UserID: userID, Type: models.Transfer})
+		legs = append(legs, rows...)
+	}
+	assert.Len(t, legs, 2)
+	if len(legs) == 2 {
+		assert.Equal(t, legs[0].Reference, legs[1].Reference)
+		assert.NotEqual(t, legs[0].Direction, legs[1].Direction)
+	}
+}
+
+func TestTransferRejectsSelfAndOverdraft(t *testing.T) {
+	senderID := setupUserForTransaction()
+	recipientID := setupUserForTransaction()
+
+	w := performTransfer(senderID, `{"recipient": "`+senderID.Hex()+`", "amount": "10.00"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = performTransfer(senderID, `{"recipient": "`+recipientID.Hex()+`", "amount": "5000.00"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
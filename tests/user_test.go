@@ -1,16 +1,19 @@
 package tests
 
 import (
-	"testing"
 	"bytes"
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"encoding/json"
+	"testing"
 
 	"micro-savings-app/handlers"
+	"micro-savings-app/models"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestRegisterUser(t *testing.T) {
@@ -24,7 +27,7 @@ func TestRegisterUser(t *testing.T) {
 	c.Request.Header.Set("Content-Type", "application/json")
 
 	// Call the handler
-	handlers.RegisterUser(c)
+	handlers.RegisterUser(testStore)(c)
 
 	// Check the response
 	assert.Equal(t, http.StatusCreated, w.Code)
@@ -32,6 +35,9 @@ func TestRegisterUser(t *testing.T) {
 
 func TestLogin(t *testing.T) {
 	gin.SetMode(gin.TestMode)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	_ = testStore.Users.Create(context.Background(), &models.User{Email: "test@example.com", PasswordHash: string(hash)})
+
 	w := httptest.NewRecorder()
 	c, _ := gin.CreateTestContext(w)
 
@@ -40,7 +46,7 @@ func TestLogin(t *testing.T) {
 	c.Request, _ = http.NewRequest(http.MethodPost, "/user/login", bytes.NewBufferString(requestBody))
 	c.Request.Header.Set("Content-Type", "application/json")
 
-	handlers.Login(c)
+	handlers.Login(testStore)(c)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
@@ -49,4 +55,5 @@ func TestLogin(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, "success", response["status"])
 	assert.NotEmpty(t, response["token"])
-}
\ No newline at end of file
+	assert.NotEmpty(t, response["refresh_token"])
+}
@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/repository"
+	"micro-savings-app/services"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ngn returns a pointer to an NGN amount in minor units
+func ngn(minor int64) *models.Money {
+	amount := models.NewMoney(minor, "NGN")
+	return &amount
+}
+
+// recordPastTransaction inserts a transaction as if it happened ago
+func recordPastTransaction(t *testing.T, userID primitive.ObjectID, txType models.TransactionType, direction models.Direction, minor int64, ago time.Duration) time.Time {
+	at := time.Now().Add(-ago)
+	assert.NoError(t, testStore.Transactions.Insert(context.Background(), models.Transaction{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		Type:      string(txType),
+		Amount:    models.NewMoney(minor, "NGN"),
+		Direction: direction,
+		CreatedAt: at,
+		UpdatedAt: at,
+	}))
+	return at
+}
+
+func TestVelocityWithdrawalLimits(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTierMax, 1000000)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{
+		Withdrawal: models.VelocityLimit{Daily: ngn(100000), Monthly: ngn(300000)},
+	}))
+
+	// Transfers out count as withdrawals; transfers in and older rows don't
+	oldest := recordPastTransaction(t, user.ID, models.Withdrawal, "", 60000, 20*time.Hour)
+	recordPastTransaction(t, user.ID, models.Transfer, models.Debit, 30000, 2*time.Hour)
+	recordPastTransaction(t, user.ID, models.Transfer, models.Credit, 50000, time.Hour)
+	recordPastTransaction(t, user.ID, models.Withdrawal, "", 90000, 25*time.Hour)
+
+	_, err := services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(20000, "NGN"))
+	var exceeded *services.VelocityLimitError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+	assert.Equal(t, models.VelocityDaily, exceeded.Usage.Window)
+	assert.Equal(t, int64(90000), exceeded.Usage.Used.Amount)
+	assert.Equal(t, int64(10000), exceeded.Usage.Remaining.Amount)
+	assert.True(t, exceeded.Retryable())
+	assert.WithinDuration(t, oldest.Add(24*time.Hour), exceeded.RetryAt, time.Second)
+
+	_, err = services.Withdraw(ctx, testStore, user.ID, primitive.NilObjectID, models.NewMoney(10000, "NGN"))
+	assert.NoError(t, err)
+
+	// The monthly window also counts yesterday's withdrawal
+	usages, source, err := services.GetVelocityUsage(ctx, testStore, user.ID, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, services.PolicySourceUser, source)
+	assert.Len(t, usages, 2)
+	assert.Equal(t, int64(190000), usages[1].Used.Amount)
+	assert.Equal(t, int64(110000), usages[1].Remaining.Amount)
+
+	// Transfers are held to the same limits
+	sender, recipient := getTestUser(user.ID), getTestUser(createKYCUser(t, models.KYCTierMax, 0).ID)
+	_, err = services.Transfer(ctx, testStore, &sender, &recipient, primitive.NilObjectID, models.NewMoney(100, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+}
+
+func TestVelocityLimitResponses(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTierMax, 1000000)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{
+		Withdrawal: models.VelocityLimit{Daily: ngn(100000)},
+	}))
+	recordPastTransaction(t, user.ID, models.Withdrawal, "", 80000, time.Hour)
+
+	// Waiting helps: 429 with the allowance left and when to retry
+	w := postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "500.00"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"remaining":{"amount":"200.00"`)
+	assert.Contains(t, w.Body.String(), `"resets_at"`)
+	assert.Contains(t, w.Body.String(), `"retry_at"`)
+
+	// Over the limit on its own: waiting never helps
+	w = postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "1500.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"window":"daily"`)
+}
+
+func TestGlobalVelocityLimits(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() {
+		_, err := services.SetGlobalVelocityLimits(ctx, testStore, models.DefaultVelocityLimits())
+		assert.NoError(t, err)
+	})
+
+	for _, bad := range []models.VelocityLimits{
+		{Deposit: models.VelocityLimit{Daily: ngn(0)}},
+		{Deposit: models.VelocityLimit{Daily: ngn(200), Monthly: ngn(100)}},
+		{Withdrawal: models.VelocityLimit{Daily: ngn(100), Monthly: &models.Money{Amount: 500, Currency: "USD"}}},
+	} {
+		_, err := services.SetGlobalVelocityLimits(ctx, testStore, bad)
+		assert.ErrorIs(t, err, models.ErrInvalidVelocityLimits)
+	}
+
+	_, err := services.SetGlobalVelocityLimits(ctx, testStore, models.VelocityLimits{
+		Deposit: models.VelocityLimit{Monthly: ngn(100000)},
+	})
+	assert.NoError(t, err)
+
+	user := createKYCUser(t, models.KYCTierMax, 0)
+	recordPastTransaction(t, user.ID, models.Deposit, "", 70000, 10*24*time.Hour)
+	_, err = services.Deposit(ctx, testStore, user.ID, models.NewMoney(40000, "NGN"))
+	var exceeded *services.VelocityLimitError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, models.VelocityMonthly, exceeded.Usage.Window)
+
+	// A user's own limits replace the global ones, until they are reset
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{}))
+	_, err = services.Deposit(ctx, testStore, user.ID, models.NewMoney(40000, "NGN"))
+	assert.NoError(t, err)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, nil))
+	_, err = services.Deposit(ctx, testStore, user.ID, models.NewMoney(100, "NGN"))
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+
+	assert.ErrorIs(t, services.SetUserVelocityLimits(ctx, testStore, primitive.NewObjectID(), nil), services.ErrUserNotFound)
+}
+
+func TestVelocityLimitsOnGoalsAndSchedules(t *testing.T) {
+	ctx := context.Background()
+	user := createKYCUser(t, models.KYCTierMax, 0)
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, testStore, user.ID, &models.VelocityLimits{
+		Deposit:    models.VelocityLimit{Daily: ngn(100000)},
+		Withdrawal: models.VelocityLimit{Daily: ngn(30000)},
+	}))
+	name, target := "Holiday", models.NewMoney(500000, "NGN")
+	goal, err := services.CreateGoal(ctx, testStore, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	assert.NoError(t, err)
+
+	// Goal deposits and withdrawals count towards the same limits
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(80000, "NGN"))
+	assert.NoError(t, err)
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(30000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+	_, err = services.WithdrawFromGoal(ctx, testStore, user.ID, goal.ID, primitive.NilObjectID, models.NewMoney(20000, "NGN"))
+	assert.NoError(t, err)
+	_, err = services.WithdrawFromGoal(ctx, testStore, user.ID, goal.ID, primitive.NilObjectID, models.NewMoney(20000, "NGN"))
+	assert.ErrorIs(t, err, services.ErrVelocityLimitExceeded)
+
+	// So do scheduled deposits
+	store := repository.NewMemoryStore()
+	scheduled := models.User{Email: primitive.NewObjectID().Hex() + "@example.com", SavingsBalance: models.NewMoney(0, "NGN"), KYCTier: models.KYCTierMax}
+	assert.NoError(t, store.Users.Create(ctx, &scheduled))
+	assert.NoError(t, services.SetUserVelocityLimits(ctx, store, scheduled.ID, &models.VelocityLimits{
+		Deposit: models.VelocityLimit{Daily: ngn(100000)},
+	}))
+	now := time.Now()
+	daily, amount := models.FrequencyDaily, models.NewMoney(60000, "NGN")
+	_, err = services.CreateSchedule(ctx, store, scheduled.ID, services.ScheduleChanges{Frequency: &daily, Amount: &amount}, now)
+	assert.NoError(t, err)
+	_, err = services.Deposit(ctx, store, scheduled.ID, models.NewMoney(50000, "NGN"))
+	assert.NoError(t, err)
+	succeeded, failed, err := services.RunDueSchedules(ctx, store, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, succeeded)
+	assert.Equal(t, 1, failed)
+}
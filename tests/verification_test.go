@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"micro-savings-app/handlers"
+	"micro-savings-app/models"
+	"micro-savings-app/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func postJSON(handler gin.HandlerFunc, userID primitive.ObjectID, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	if !userID.IsZero() {
+		c.Set("user_id", userID.Hex()) // Simulate authentication
+	}
+	handler(c)
+	return w
+}
+
+func TestEmailVerificationUnblocksWithdrawals(t *testing.T) {
+	email := primitive.NewObjectID().Hex() + "@example.com"
+	w := postJSON(handlers.RegisterUser(testStore), primitive.NilObjectID,
+		`{"name": "Ada", "email": "`+email+`", "password": "password123"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	user, _ := testStore.Users.GetByEmail(context.Background(), email)
+	assert.False(t, user.IsEmailVerified())
+
+	w = postJSON(handlers.Deposit(testStore), user.ID, `{"amount": "100.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "10.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Nor can money leave by transfer or through a goal
+	ctx := context.Background()
+	recipient := createKYCUser(t, models.KYCTierMax, 0)
+	sender := getTestUser(user.ID)
+	_, err := services.Transfer(ctx, testStore, &sender, recipient, primitive.NilObjectID, models.NewMoney(1000, "NGN"), "")
+	assert.ErrorIs(t, err, services.ErrEmailNotVerified)
+	name, target := "Rent", models.NewMoney(100000, "NGN")
+	goal, err := services.CreateGoal(ctx, testStore, user.ID, services.GoalChanges{Name: &name, TargetAmount: &target}, time.Now())
+	assert.NoError(t, err)
+	_, err = services.DepositToGoal(ctx, testStore, user.ID, goal.ID, models.NewMoney(2000, "NGN"))
+	assert.NoError(t, err)
+	w = performGoalRequest(handlers.WithdrawFromGoal(testStore), user.ID, goal.ID.Hex(), `{"amount": "10.00"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Asking again makes the first link useless
+	first := emailedToken(waitForEmail(t, email, "Verify your email address"))
+	w = postJSON(handlers.ResendEmailVerification(testStore), user.ID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var second string
+	assert.Eventually(t, func() bool {
+		second = emailedToken(waitForEmail(t, email, "Verify your email address"))
+		return second != first
+	}, time.Second, 5*time.Millisecond)
+
+	w = postJSON(handlers.VerifyEmail(testStore), primitive.NilObjectID, `{"token": "`+first+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postJSON(handlers.VerifyEmail(testStore), primitive.NilObjectID, `{"token": "`+second+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(handlers.VerifyEmail(testStore), primitive.NilObjectID, `{"token": "`+second+`"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = postJSON(handlers.Withdraw(testStore), user.ID, `{"amount": "10.00"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(handlers.ResendEmailVerification(testStore), user.ID, "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestPasswordReset(t *testing.T) {
+	ctx := context.Background()
+	userID := setupUserForTransaction()
+	user := getTestUser(userID)
+	session, _ := services.StartSession(ctx, testStore, userID, "", "", time.Now())
+
+	// Unknown addresses get the same answer
+	w := postJSON(handlers.ForgotPassword(testStore), primitive.NilObjectID, `{"email": "nobody@example.com"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(handlers.ForgotPassword(testStore), primitive.NilObjectID, `{"email": "`+user.Email+`"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	token := emailedToken(waitForEmail(t, user.Email, "Reset your password"))
+
+	w = postJSON(handlers.ResetPassword(testStore), primitive.NilObjectID, `{"token": "`+token+`", "password": "short"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = postJSON(handlers.ResetPassword(testStore), primitive.NilObjectID, `{"token": "`+token+`", "password": "a-new-password"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = postJSON(handlers.ResetPassword(testStore), primitive.NilObjectID, `{"token": "`+token+`", "password": "another-password"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	user = getTestUser(userID)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("a-new-password")))
+	_, err := services.CheckSession(ctx, testStore, userID.Hex(), sessionIDOf(t, session))
+	assert.ErrorIs(t, err, services.ErrSessionRevoked)
+}
+
+func TestPasswordResetTokenExpires(t *testing.T) {
+	ctx := context.Background()
+	user := getTestUser(setupUserForTransaction())
+	requested := time.Now().Add(-models.PasswordResetTTL)
+	assert.NoError(t, services.RequestPasswordReset(ctx, testStore, user.Email, requested))
+	token := emailedToken(waitForEmail(t, user.Email, "Reset your password"))
+
+	err := services.ResetPassword(ctx, testStore, token, "a-new-password", time.Now())
+	assert.ErrorIs(t, err, services.ErrInvalidToken)
+}
+
+// sessionIDOf reads the session ID out of a token pair's access token
+func sessionIDOf(t *testing.T, tokens *services.TokenPair) string {
+	claims, err := services.ValidateJWT(tokens.AccessToken)
+	assert.NoError(t, err)
+	sessionID, _ := claims["sid"].(string)
+	return sessionID
+}